@@ -2,24 +2,37 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
 	"text/tabwriter"
 	"time"
 
+	"github.com/johncarpenter/trajectory-memory/internal/autoscore"
+	"github.com/johncarpenter/trajectory-memory/internal/bandit"
 	"github.com/johncarpenter/trajectory-memory/internal/config"
+	"github.com/johncarpenter/trajectory-memory/internal/gitscore"
 	"github.com/johncarpenter/trajectory-memory/internal/ingestion"
 	"github.com/johncarpenter/trajectory-memory/internal/installer"
 	"github.com/johncarpenter/trajectory-memory/internal/mcp"
 	"github.com/johncarpenter/trajectory-memory/internal/optimizer"
+	"github.com/johncarpenter/trajectory-memory/internal/preference"
+	"github.com/johncarpenter/trajectory-memory/internal/redact"
+	"github.com/johncarpenter/trajectory-memory/internal/rubric"
 	"github.com/johncarpenter/trajectory-memory/internal/store"
 	"github.com/johncarpenter/trajectory-memory/internal/summarize"
+	"github.com/johncarpenter/trajectory-memory/internal/transcript"
 	"github.com/johncarpenter/trajectory-memory/internal/types"
 	"github.com/johncarpenter/trajectory-memory/internal/updater"
 )
@@ -42,6 +55,8 @@ func main() {
 		cmdInstall(args)
 	case "uninstall":
 		cmdUninstall(args)
+	case "hook":
+		cmdHook(args)
 	case "list":
 		cmdList(args)
 	case "show":
@@ -50,20 +65,36 @@ func main() {
 		cmdScore(args)
 	case "search":
 		cmdSearch(args)
+	case "similar":
+		cmdSimilar(args)
 	case "export":
 		cmdExport(args)
 	case "import":
 		cmdImport(args)
+	case "import-transcripts":
+		cmdImportTranscripts(args)
+	case "autoscore":
+		cmdAutoscore(args)
+	case "score-from-git":
+		cmdScoreFromGit(args)
+	case "compare":
+		cmdCompare(args)
 	case "stats":
 		cmdStats(args)
 	case "prune":
 		cmdPrune(args)
+	case "redact":
+		cmdRedact(args)
+	case "db":
+		cmdDB(args)
 	case "optimize":
 		cmdOptimize(args)
 	case "curate":
 		cmdCurate(args)
 	case "trigger":
 		cmdTrigger(args)
+	case "strategies":
+		cmdStrategies(args)
 	case "update":
 		cmdUpdate(args)
 	case "version":
@@ -87,14 +118,22 @@ Commands:
   serve                   Run MCP server on stdio (how Claude Code launches it)
   install [--global]      Install hooks into Claude Code settings
   uninstall [--global]    Remove hooks from Claude Code settings
-  list [--limit N]        Show recent sessions with scores
+  hook                    Forward a hook payload from stdin (run by Claude Code)
+  list [--limit N] [--tag T] [--status S] [--min-score F] [--since DATE]  Show recent sessions with scores
   show <session-id>       Print full trajectory for a session
-  score <session-id> <score> [--notes "..."]  Score or re-score a session
+  score <session-id> [<score>] [--notes "..."] [--dim name=value ...]  Score a session, overall or per rubric dimension
+  autoscore [--rescore] [session-id]  Give completed sessions a provisional score from their trajectory
+  score-from-git [--days N] [--rescore] [--dry-run] [session-id]  Score sessions by whether their changes survived in git
+  compare <tag> [--rounds N] [--ratings] [--winner ID --loser ID [--tie]]  Judge sessions pairwise for preference scores
   search <query> [--limit N] [--min-score F]  Search past sessions
+  similar <prompt> [--limit N] [--tag T] [--min-score F]  Find sessions with similar tasks
   export [--output file.jsonl]  Export all sessions to JSONL
   import <file.jsonl>     Import sessions from JSONL
+  import-transcripts [--project DIR] [--since DATE]  Import past Claude Code transcripts
   stats                   Summary statistics
   prune [--before DATE] [--min-score F]  Delete old or low-scoring sessions
+  redact [--confirm]      Redact secrets and PII from stored sessions
+  db migrate [--dry-run]  Upgrade the database schema to this version
 
 Context Optimization:
   optimize propose <file> [--tag TAG]   Analyze trajectories and propose optimized content
@@ -103,19 +142,26 @@ Context Optimization:
   optimize rollback <record-id>         Revert an applied optimization
   optimize history [--file F] [--tag T] Show optimization history
   optimize diff <record-id>             Show diff for an optimization
-  curate <tag> [--max N] [--file F]     Curate best examples for a tag
+  optimize strategies <file> [--tag T]  Propose replacements for underperforming strategies
+  optimize strategies --record ID --save FILE  Add generated strategies to a proposal
+  curate <tag> [--max N] [--file F] [--dimension D] [--include-auto] [--use-preferences]  Curate best examples for a tag
   trigger status                        Show trigger configuration
   trigger configure [flags]             Update trigger settings
   trigger watch <file>                  Add file to watch list
+  strategies weights [--refit] <tag>    Show the contextual model's learned weights per strategy
+  strategies archive [tag]              List strategies retired by evolution
+  strategies analyze [--aggregate] <tag>  Show how each version of each strategy performed
 
   update [--check]        Update to latest version from GitHub
   version                 Print version information
   help                    Show this help message
 
 Environment Variables:
-  TM_DB_PATH       Database path (default: <project>/.trajectory-memory/tm.db)
-  TM_SOCKET_PATH   Unix socket path (default: /tmp/trajectory-memory-<hash>.sock)
-  TM_DATA_DIR      Data directory (default: <project>/.trajectory-memory)
+  TM_DB_PATH           Database path (default: <project>/.trajectory-memory/tm.db)
+  TM_SOCKET_PATH       Unix socket path (default: /tmp/trajectory-memory-<hash>.sock)
+  TM_DATA_DIR          Data directory (default: <project>/.trajectory-memory)
+  TM_REDACTION_CONFIG  Redaction rules (default: <data-dir>/redaction.json)
+  TM_ANALYZER_CONFIG   Per-tag analyzer settings (default: <data-dir>/analyzers.json)
 
 Note: <project> is detected by finding .git/, CLAUDE.md, or .claude/ markers.
       <hash> is an 8-character hash of the project path for isolation.
@@ -137,22 +183,15 @@ func cmdServe(args []string) {
 		os.Exit(1)
 	}
 
-	s, err := store.NewBoltStore(cfg.DBPath)
+	redactor, err := redact.Load(cfg.RedactionConfigPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error loading redaction rules: %v\n", err)
 		os.Exit(1)
 	}
-	defer s.Close()
 
-	// Start ingestion server
-	ingestionServer := ingestion.NewServer(s, cfg.SocketPath)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	if err := ingestionServer.Start(ctx); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to start ingestion server: %v\n", err)
-	}
-
 	// Handle shutdown
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -162,14 +201,96 @@ func cmdServe(args []string) {
 		cancel()
 	}()
 
+	// Claude Code restarts its MCP server as a child of the same process,
+	// so the parent PID finds a recording the previous server started
+	owner := strconv.Itoa(os.Getppid())
+
+	s, input, err := openForServe(ctx, cfg, owner)
+	if err != nil {
+		if err == context.Canceled {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	if s == nil {
+		// The client went away while its requests were being relayed
+		return
+	}
+	defer s.Close()
+
+	// Start ingestion server
+	ingestionServer := ingestion.NewServer(s, cfg.SocketPath)
+	ingestionServer.SetRedactor(redactor)
+	ingestionServer.SetSpoolDir(cfg.SpoolDir)
+
+	newMCPServer := func() *mcp.Server {
+		srv := mcp.NewServer(s, cfg.SocketPath, version)
+		srv.SetIngestionServer(ingestionServer)
+		srv.SetRubricSources(cfg.RubricConfigPath, cfg.RubricFiles()...)
+		srv.SetAnalyzerConfig(cfg.AnalyzerConfigPath)
+		return srv
+	}
+	// Other Claude Code windows can't open the database while this process
+	// holds it, so their serve processes relay MCP requests here
+	ingestionServer.Handle(mcp.RelayPath, mcp.NewRelay(newMCPServer))
+
+	if err := ingestionServer.Start(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to start ingestion server: %v\n", err)
+	}
+
 	// Run MCP server
-	mcpServer := mcp.NewServer(s, cfg.SocketPath, version)
+	mcpServer := newMCPServer()
+	mcpServer.SetOwner(owner)
+	mcpServer.SetIO(input, os.Stdout)
 	if err := mcpServer.Run(ctx); err != nil && err != context.Canceled {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+// maxRelayAttempts bounds how often serve retries a request when the
+// database stays locked but nothing answers on the ingestion socket.
+const maxRelayAttempts = 5
+
+// openForServe opens the database for serve. While another Claude Code
+// window's serve holds it, MCP requests from stdin are relayed to that
+// process instead; once it exits, this one takes the database over. It
+// returns the store and the requests left to handle, or a nil store if
+// stdin ended while relaying.
+func openForServe(ctx context.Context, cfg *config.Config, owner string) (*store.BoltStore, io.Reader, error) {
+	stdin := bufio.NewReader(os.Stdin)
+
+	var pending []byte
+	attempts := 0
+	for {
+		s, err := store.NewBoltStore(cfg.DBPath)
+		if err == nil {
+			if pending == nil {
+				return s, stdin, nil
+			}
+			return s, io.MultiReader(bytes.NewReader(pending), stdin), nil
+		}
+		if !errors.Is(err, store.ErrDatabaseLocked) {
+			return nil, nil, err
+		}
+
+		unsent, err := mcp.RunRelay(ctx, stdin, os.Stdout, cfg.SocketPath, owner, pending)
+		if err != mcp.ErrRelayUnavailable {
+			return nil, nil, err
+		}
+		if pending != nil && bytes.Equal(unsent, pending) {
+			attempts++
+		} else {
+			attempts = 0
+		}
+		if attempts >= maxRelayAttempts {
+			return nil, nil, fmt.Errorf("%w, and it isn't answering on %s", store.ErrDatabaseLocked, cfg.SocketPath)
+		}
+		pending = unsent
+	}
+}
+
 func cmdInstall(args []string) {
 	fs := flag.NewFlagSet("install", flag.ExitOnError)
 	global := fs.Bool("global", false, "Install to user-level settings instead of project-level")
@@ -192,7 +313,7 @@ func cmdInstall(args []string) {
 
 	fmt.Println("trajectory-memory installed successfully!")
 	fmt.Println()
-	fmt.Println("Hook script installed to:", inst.GetHookPath())
+	fmt.Println("Hook command registered:", inst.GetHookCommand())
 	fmt.Println()
 	fmt.Println("Add this to your Claude Code settings to enable the MCP server:")
 	fmt.Println(inst.GetMCPConfig())
@@ -221,11 +342,46 @@ func cmdUninstall(args []string) {
 	fmt.Printf("Note: Database not removed. To delete all data: rm -rf %s\n", cfg.DataDir)
 }
 
+func cmdHook(args []string) {
+	// Hooks must never block or fail Claude Code, so errors are reported
+	// on stderr and the exit status is always 0.
+	payload, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "trajectory-memory hook: %v\n", err)
+		return
+	}
+
+	cfg := config.Load()
+	if err := ingestion.Forward(cfg.SocketPath, cfg.SpoolDir, payload, ingestion.DefaultForwardTimeout); err != nil {
+		fmt.Fprintf(os.Stderr, "trajectory-memory hook: %v\n", err)
+	}
+}
+
 func cmdList(args []string) {
 	fs := flag.NewFlagSet("list", flag.ExitOnError)
 	limit := fs.Int("limit", 10, "Maximum number of sessions to show")
+	tag := fs.String("tag", "", "Only show sessions with this tag")
+	status := fs.String("status", "", "Only show sessions with this status")
+	minScore := fs.Float64("min-score", -1, "Only show sessions scoring at least this")
+	since := fs.String("since", "", "Only show sessions started on or after this date (YYYY-MM-DD)")
 	fs.Parse(args)
 
+	q := store.Query{Status: types.SessionStatus(*status), Limit: *limit}
+	if *tag != "" {
+		q.Tags = []string{*tag}
+	}
+	if *minScore >= 0 {
+		q.MinScore = minScore
+	}
+	if *since != "" {
+		var err error
+		q.Since, err = time.Parse("2006-01-02", *since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing date: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	s, err := openStore()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -233,7 +389,7 @@ func cmdList(args []string) {
 	}
 	defer s.Close()
 
-	sessions, err := s.ListSessions(*limit, 0)
+	sessions, err := s.QuerySessions(q)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -253,6 +409,9 @@ func cmdList(args []string) {
 		scoreStr := "-"
 		if sess.Score != nil {
 			scoreStr = fmt.Sprintf("%.2f", *sess.Score)
+		} else if sess.AutoScore != nil {
+			// Provisional score, marked so it isn't mistaken for a human one
+			scoreStr = fmt.Sprintf("~%.2f", *sess.AutoScore)
 		}
 		date := sess.StartedAt.Format("2006-01-02")
 		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n",
@@ -293,18 +452,31 @@ func cmdShow(args []string) {
 func cmdScore(args []string) {
 	fs := flag.NewFlagSet("score", flag.ExitOnError)
 	notes := fs.String("notes", "", "Notes about the scoring")
+	dims := dimensionFlag{}
+	fs.Var(dims, "dim", "Rubric dimension score as name=value (repeatable)")
 	fs.Parse(args)
 
 	remaining := fs.Args()
-	if len(remaining) < 2 {
-		fmt.Fprintln(os.Stderr, "Usage: trajectory-memory score <session-id> <score> [--notes \"...\"]")
+	if len(remaining) < 1 || (len(remaining) < 2 && len(dims) == 0) {
+		fmt.Fprintln(os.Stderr, "Usage: trajectory-memory score <session-id> [<score>] [--notes \"...\"] [--dim name=value ...]")
 		os.Exit(1)
 	}
 
 	sessionID := remaining[0]
-	scoreVal, err := strconv.ParseFloat(remaining[1], 64)
-	if err != nil || scoreVal < 0 || scoreVal > 1 {
-		fmt.Fprintln(os.Stderr, "Error: score must be a number between 0.0 and 1.0")
+	var score *float64
+	if len(remaining) >= 2 {
+		scoreVal, err := strconv.ParseFloat(remaining[1], 64)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: score must be a number between 0.0 and 1.0")
+			os.Exit(1)
+		}
+		score = &scoreVal
+	}
+
+	cfg := config.Load()
+	rubrics, err := rubric.Load(cfg.RubricConfigPath, cfg.RubricFiles()...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -321,10 +493,10 @@ func cmdScore(args []string) {
 		os.Exit(1)
 	}
 
-	outcome := types.Outcome{
-		Score:    scoreVal,
-		Notes:    *notes,
-		ScoredAt: time.Now(),
+	outcome, err := rubrics.NewOutcome(session.Tags, score, dims, *notes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
 	if err := s.SetOutcome(session.ID, outcome); err != nil {
@@ -332,7 +504,358 @@ func cmdScore(args []string) {
 		os.Exit(1)
 	}
 
-	fmt.Printf("Session %s scored %.2f\n", session.ID[:12], scoreVal)
+	fmt.Printf("Session %s scored %.2f\n", session.ID[:12], outcome.Score)
+	for _, name := range sortedKeys(outcome.Dimensions) {
+		fmt.Printf("  %s: %.2f\n", name, outcome.Dimensions[name])
+	}
+}
+
+// dimensionFlag collects repeated --dim name=value flags.
+type dimensionFlag map[string]float64
+
+func (d dimensionFlag) String() string {
+	var parts []string
+	for _, name := range sortedKeys(d) {
+		parts = append(parts, fmt.Sprintf("%s=%g", name, d[name]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (d dimensionFlag) Set(value string) error {
+	name, raw, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", value)
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fmt.Errorf("invalid score for %s: %q", name, raw)
+	}
+	d[name] = v
+	return nil
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func cmdAutoscore(args []string) {
+	fs := flag.NewFlagSet("autoscore", flag.ExitOnError)
+	rescore := fs.Bool("rescore", false, "Recompute auto scores that are already current")
+	fs.Parse(args)
+
+	s, err := openStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	var ids []string
+	if remaining := fs.Args(); len(remaining) > 0 {
+		session, err := findSession(s, remaining[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		ids = []string{session.ID}
+		*rescore = true
+	} else {
+		sessions, err := s.QuerySessions(store.Query{})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, meta := range sessions {
+			if meta.Status != string(types.StatusRecording) {
+				ids = append(ids, meta.ID)
+			}
+		}
+	}
+
+	scorer := autoscore.Default()
+	scored, skipped := 0, 0
+	for _, id := range ids {
+		session, err := s.GetSession(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", id, err)
+			continue
+		}
+		if !*rescore && session.AutoOutcome != nil && session.AutoOutcome.Scorer == scorer.Name() {
+			skipped++
+			continue
+		}
+
+		outcome := scorer.Score(session)
+		if outcome == nil {
+			skipped++
+			continue
+		}
+		if err := s.SetAutoOutcome(id, outcome); err != nil {
+			fmt.Fprintf(os.Stderr, "Error scoring %s: %v\n", id, err)
+			continue
+		}
+		scored++
+
+		if len(ids) == 1 {
+			fmt.Printf("Session %s auto-scored %.2f\n", id[:12], outcome.Score)
+			for _, signal := range outcome.Signals {
+				fmt.Printf("  %s: %.2f (%s)\n", signal.Rule, signal.Score, signal.Detail)
+			}
+		}
+	}
+
+	if len(ids) != 1 {
+		fmt.Printf("Auto-scored %d sessions (%d skipped)\n", scored, skipped)
+	}
+}
+
+func cmdScoreFromGit(args []string) {
+	fs := flag.NewFlagSet("score-from-git", flag.ExitOnError)
+	days := fs.Int("days", 7, "Days after the first commit to check for reverts and rewrites")
+	commitWindow := fs.Duration("commit-window", 24*time.Hour, "How long after a session a commit still counts as its work")
+	rescore := fs.Bool("rescore", false, "Recompute sessions that already have a git score")
+	dryRun := fs.Bool("dry-run", false, "Print scores without saving them")
+	fs.Parse(args)
+
+	s, err := openStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	var ids []string
+	if remaining := fs.Args(); len(remaining) > 0 {
+		session, err := findSession(s, remaining[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		ids = []string{session.ID}
+		*rescore = true
+	} else {
+		sessions, err := s.QuerySessions(store.Query{})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, meta := range sessions {
+			if meta.Status != string(types.StatusRecording) {
+				ids = append(ids, meta.ID)
+			}
+		}
+	}
+
+	opts := gitscore.DefaultOptions()
+	opts.CommitWindow = *commitWindow
+	opts.RevertWindow = time.Duration(*days) * 24 * time.Hour
+
+	scored, pending, skipped := 0, 0, 0
+	for _, id := range ids {
+		session, err := s.GetSession(id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", id, err)
+			continue
+		}
+		if !*rescore && session.GitOutcome != nil {
+			skipped++
+			continue
+		}
+
+		outcome, err := gitscore.Score(session, opts)
+		switch {
+		case errors.Is(err, gitscore.ErrTooRecent):
+			pending++
+			continue
+		case errors.Is(err, gitscore.ErrNotRepository):
+			skipped++
+			continue
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error scoring %s: %v\n", id[:12], err)
+			continue
+		case outcome == nil:
+			// Wrote no files in the repository
+			skipped++
+			continue
+		}
+
+		if !*dryRun {
+			if err := s.SetGitOutcome(id, outcome); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving %s: %v\n", id[:12], err)
+				continue
+			}
+		}
+		scored++
+
+		fmt.Printf("%s  %.2f  %s\n", id[:12], outcome.Score, truncate(session.TaskPrompt, 50))
+		for _, signal := range outcome.Signals {
+			fmt.Printf("  %s: %.2f (%s)\n", signal.Rule, signal.Score, signal.Detail)
+		}
+	}
+
+	fmt.Printf("Scored %d sessions from git (%d too recent, %d skipped)\n", scored, pending, skipped)
+}
+
+func cmdCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	rounds := fs.Int("rounds", 5, "Number of pairs to compare interactively")
+	winner := fs.String("winner", "", "Record that this session was better (with --loser)")
+	loser := fs.String("loser", "", "Record that this session was worse (with --winner)")
+	tie := fs.Bool("tie", false, "Record --winner and --loser as equally good")
+	notes := fs.String("notes", "", "Notes about the comparison")
+	ratings := fs.Bool("ratings", false, "Show preference scores instead of comparing")
+	fs.Parse(args)
+
+	remaining := fs.Args()
+	if len(remaining) < 1 || (*winner == "") != (*loser == "") {
+		fmt.Fprintln(os.Stderr, "Usage: trajectory-memory compare <tag> [--rounds N] [--ratings] [--winner ID --loser ID [--tie] [--notes \"...\"]]")
+		os.Exit(1)
+	}
+	tag := remaining[0]
+
+	s, err := openStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	comparisons, err := s.ListComparisons(tag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *ratings {
+		printRatings(s, preference.Fit(comparisons))
+		return
+	}
+
+	if *winner != "" {
+		a, errA := findSession(s, *winner)
+		b, errB := findSession(s, *loser)
+		if err := errors.Join(errA, errB); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		c, err := recordComparison(s, tag, a, b, *tie, *notes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if c.Tie {
+			fmt.Printf("Recorded tie between %s and %s\n", c.Winner[:12], c.Loser[:12])
+		} else {
+			fmt.Printf("Recorded %s over %s\n", c.Winner[:12], c.Loser[:12])
+		}
+		return
+	}
+
+	metas, err := s.QuerySessions(store.Query{Tags: []string{tag}})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var ids []string
+	for _, meta := range metas {
+		if meta.Status != string(types.StatusRecording) {
+			ids = append(ids, meta.ID)
+		}
+	}
+
+	input := bufio.NewScanner(os.Stdin)
+	recorded := 0
+rounds:
+	for recorded < *rounds {
+		aID, bID, ok := preference.NextPair(ids, comparisons)
+		if !ok {
+			fmt.Printf("No pairs left to compare for tag '%s'\n", tag)
+			break
+		}
+		a, errA := s.GetSession(aID)
+		b, errB := s.GetSession(bID)
+		if err := errors.Join(errA, errB); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(summarize.FormatComparison(a, b))
+		fmt.Print("Which session was better? [a/b/t(ie)/q(uit)]: ")
+		if !input.Scan() {
+			fmt.Println()
+			break
+		}
+
+		var c *types.Comparison
+		switch strings.ToLower(strings.TrimSpace(input.Text())) {
+		case "a":
+			c, err = recordComparison(s, tag, a, b, false, "")
+		case "b":
+			c, err = recordComparison(s, tag, b, a, false, "")
+		case "t", "tie":
+			c, err = recordComparison(s, tag, a, b, true, "")
+		case "q", "quit":
+			break rounds
+		default:
+			fmt.Println("Please answer a, b, t or q")
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		comparisons = append(comparisons, *c)
+		recorded++
+		fmt.Println()
+	}
+
+	fmt.Printf("Recorded %d comparisons for tag '%s'\n\n", recorded, tag)
+	if recorded > 0 {
+		printRatings(s, preference.Fit(comparisons))
+	}
+}
+
+// recordComparison stores that winner was better than loser (or as good,
+// for a tie). Both sessions must have the tag.
+func recordComparison(s *store.BoltStore, tag string, winner, loser *types.Session, tie bool, notes string) (*types.Comparison, error) {
+	for _, session := range []*types.Session{winner, loser} {
+		if !session.HasTag(tag) {
+			return nil, fmt.Errorf("session %s isn't tagged '%s'", session.ID[:12], tag)
+		}
+	}
+	c := &types.Comparison{Tag: tag, Winner: winner.ID, Loser: loser.ID, Tie: tie, Notes: notes}
+	if err := s.RecordComparison(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func printRatings(s *store.BoltStore, ratings preference.Ratings) {
+	if len(ratings) == 0 {
+		fmt.Println("No comparisons recorded")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTASK\tPREFERENCE\tW-L-T\tSCORE")
+	fmt.Fprintln(w, "--\t----\t----------\t-----\t-----")
+	for _, r := range ratings.Sorted() {
+		task, scoreStr := "-", "-"
+		if session, err := s.GetSessionHeader(r.SessionID); err == nil {
+			task = truncate(session.TaskPrompt, 40)
+			if session.Outcome != nil {
+				scoreStr = fmt.Sprintf("%.2f", session.Outcome.Score)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%d-%d-%d\t%s\n",
+			r.SessionID[:12], task, r.Score, r.Wins, r.Losses, r.Ties, scoreStr)
+	}
+	w.Flush()
 }
 
 func cmdSearch(args []string) {
@@ -356,30 +879,73 @@ func cmdSearch(args []string) {
 	}
 	defer s.Close()
 
-	results, err := s.SearchSessions(query, *limit*2) // Get more to allow filtering
+	if *minScore >= 0 {
+		query += fmt.Sprintf(" score>=%.4f", *minScore)
+	}
+
+	results, err := s.SearchSessions(query, *limit)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Filter by min score if specified
-	if *minScore >= 0 {
-		filtered := make([]types.SessionMetadata, 0)
-		for _, r := range results {
-			if r.Score != nil && *r.Score >= *minScore {
-				filtered = append(filtered, r)
-			}
+	if len(results) == 0 {
+		fmt.Println("No matching sessions found")
+		return
+	}
+
+	for _, r := range results {
+		scoreStr := "unscored"
+		if r.Score != nil {
+			scoreStr = fmt.Sprintf("%.2f", *r.Score)
+		}
+		fmt.Printf("\n[%s] %s (score: %s, relevance: %.2f)\n", r.ID[:12], r.StartedAt.Format("2006-01-02"), scoreStr, r.Relevance)
+		fmt.Printf("  Task: %s\n", truncate(r.TaskPrompt, 60))
+		if r.Summary != "" {
+			fmt.Printf("  Summary: %s\n", truncate(r.Summary, 80))
+		}
+		if len(r.Tags) > 0 {
+			fmt.Printf("  Tags: %v\n", r.Tags)
 		}
-		results = filtered
+	}
+}
+
+func cmdSimilar(args []string) {
+	fs := flag.NewFlagSet("similar", flag.ExitOnError)
+	limit := fs.Int("limit", 5, "Maximum number of results")
+	tag := fs.String("tag", "", "Only consider sessions with this tag")
+	minScore := fs.Float64("min-score", -1, "Minimum score filter")
+	fs.Parse(args)
+
+	remaining := fs.Args()
+	if len(remaining) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: trajectory-memory similar <prompt> [--limit N] [--tag T] [--min-score F]")
+		os.Exit(1)
+	}
+
+	s, err := openStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	q := store.Query{Limit: *limit}
+	if *tag != "" {
+		q.Tags = []string{*tag}
+	}
+	if *minScore >= 0 {
+		q.MinScore = minScore
 	}
 
-	// Limit results
-	if len(results) > *limit {
-		results = results[:*limit]
+	results, err := s.SimilarSessions(strings.Join(remaining, " "), q)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
 	if len(results) == 0 {
-		fmt.Println("No matching sessions found")
+		fmt.Println("No similar sessions found")
 		return
 	}
 
@@ -388,7 +954,7 @@ func cmdSearch(args []string) {
 		if r.Score != nil {
 			scoreStr = fmt.Sprintf("%.2f", *r.Score)
 		}
-		fmt.Printf("\n[%s] %s (score: %s)\n", r.ID[:12], r.StartedAt.Format("2006-01-02"), scoreStr)
+		fmt.Printf("\n[%s] %s (score: %s, similarity: %.2f)\n", r.ID[:12], r.StartedAt.Format("2006-01-02"), scoreStr, r.Similarity)
 		fmt.Printf("  Task: %s\n", truncate(r.TaskPrompt, 60))
 		if r.Summary != "" {
 			fmt.Printf("  Summary: %s\n", truncate(r.Summary, 80))
@@ -463,6 +1029,94 @@ func cmdImport(args []string) {
 	fmt.Printf("Imported sessions from %s\n", inputPath)
 }
 
+func cmdImportTranscripts(args []string) {
+	cfg := config.Load()
+
+	fs := flag.NewFlagSet("import-transcripts", flag.ExitOnError)
+	project := fs.String("project", cfg.ProjectRoot, "Project directory whose transcripts to import")
+	since := fs.String("since", "", "Only import sessions started on or after this date (YYYY-MM-DD)")
+	fs.Parse(args)
+
+	var sinceDate time.Time
+	if *since != "" {
+		var err error
+		sinceDate, err = time.Parse("2006-01-02", *since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing date: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	projectsDir, err := transcript.ProjectsDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	files, err := transcript.Files(projectsDir, *project)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		fmt.Printf("No transcripts found for %s\n", *project)
+		return
+	}
+
+	redactor, err := redact.Load(cfg.RedactionConfigPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	scorer := autoscore.Default()
+
+	s, err := openStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	sessions, err := s.QuerySessions(store.Query{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	// A live recording shares its Claude session ID with the transcript, so
+	// only earlier imports mean the transcript was already imported
+	existing := make(map[string]bool)
+	for _, meta := range sessions {
+		if meta.ClaudeSessionID != "" && transcript.IsImported(meta.Tags) {
+			existing[meta.ClaudeSessionID] = true
+		}
+	}
+
+	imported, skipped := 0, 0
+	for _, path := range files {
+		session, err := transcript.ParseFile(path, redactor)
+		if err != nil {
+			if err != transcript.ErrNoTask {
+				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", filepath.Base(path), err)
+			}
+			skipped++
+			continue
+		}
+		if existing[session.ClaudeSessionID] || session.StartedAt.Before(sinceDate) {
+			skipped++
+			continue
+		}
+
+		session.AutoOutcome = scorer.Score(session)
+		if err := s.CreateSession(session); err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing %s: %v\n", filepath.Base(path), err)
+			continue
+		}
+		existing[session.ClaudeSessionID] = true
+		imported++
+	}
+
+	fmt.Printf("Imported %d sessions (%d skipped) from %s\n", imported, skipped, transcript.ProjectDir(projectsDir, *project))
+}
+
 func cmdStats(args []string) {
 	s, err := openStore()
 	if err != nil {
@@ -471,7 +1125,7 @@ func cmdStats(args []string) {
 	}
 	defer s.Close()
 
-	sessions, err := s.ListSessions(1000, 0) // Get all sessions
+	sessions, err := s.QuerySessions(store.Query{})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -538,6 +1192,20 @@ func cmdStats(args []string) {
 			shown++
 		}
 	}
+
+	// Scores of these sessions never reach their strategy's stats
+	unrecorded, err := s.SessionsWithoutStrategyUsage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(unrecorded) > 0 {
+		fmt.Println()
+		fmt.Printf("Warning: %d sessions name a strategy but have no usage record:\n", len(unrecorded))
+		for _, sess := range unrecorded {
+			fmt.Printf("  %s  %s  %s\n", sess.ID[:12], sess.Strategy, truncate(sess.TaskPrompt, 40))
+		}
+	}
 }
 
 func cmdPrune(args []string) {
@@ -570,56 +1238,194 @@ func cmdPrune(args []string) {
 	}
 	defer s.Close()
 
-	sessions, err := s.ListSessions(10000, 0)
+	// Sessions matching either criterion are deleted
+	var queries []store.Query
+	if *before != "" {
+		queries = append(queries, store.Query{Until: beforeDate})
+	}
+	if *minScore >= 0 {
+		queries = append(queries, store.Query{MaxScore: minScore})
+	}
+
+	var toDelete []string
+	seen := make(map[string]bool)
+	for _, q := range queries {
+		sessions, err := s.QuerySessions(q)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, sess := range sessions {
+			// MaxScore is inclusive, but prune deletes strictly below --min-score
+			if q.MaxScore != nil && *sess.Score >= *minScore {
+				continue
+			}
+			if !seen[sess.ID] {
+				seen[sess.ID] = true
+				toDelete = append(toDelete, sess.ID)
+			}
+		}
+	}
+
+	if len(toDelete) == 0 {
+		fmt.Println("No sessions match the criteria")
+		return
+	}
+
+	if !*confirm {
+		fmt.Printf("Would delete %d sessions (use --confirm to actually delete):\n", len(toDelete))
+		for i, id := range toDelete {
+			if i >= 10 {
+				fmt.Printf("  ... and %d more\n", len(toDelete)-10)
+				break
+			}
+			fmt.Printf("  %s\n", id[:12])
+		}
+		return
+	}
+
+	deleted := 0
+	for _, id := range toDelete {
+		if err := s.DeleteSession(id); err != nil {
+			fmt.Fprintf(os.Stderr, "Error deleting %s: %v\n", id[:12], err)
+		} else {
+			deleted++
+		}
+	}
+
+	fmt.Printf("Deleted %d sessions\n", deleted)
+}
+
+func cmdRedact(args []string) {
+	fs := flag.NewFlagSet("redact", flag.ExitOnError)
+	confirm := fs.Bool("confirm", false, "Actually rewrite sessions (dry run without this)")
+	fs.Parse(args)
+
+	cfg := config.Load()
+	redactor, err := redact.Load(cfg.RedactionConfigPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	s, err := openStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	sessions, err := s.QuerySessions(store.Query{})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	var toDelete []string
-	for _, sess := range sessions {
-		shouldDelete := false
+	fmt.Printf("Redaction rules: %s\n", strings.Join(redactor.Rules(), ", "))
 
-		if *before != "" && sess.StartedAt.Before(beforeDate) {
-			shouldDelete = true
+	affected := 0
+	total := 0
+	for _, meta := range sessions {
+		session, err := s.GetSession(meta.ID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", meta.ID[:12], err)
+			continue
 		}
 
-		if *minScore >= 0 && sess.Score != nil && *sess.Score < *minScore {
-			shouldDelete = true
+		n := redactor.RedactSession(session)
+		if n == 0 {
+			continue
 		}
+		affected++
+		total += n
 
-		if shouldDelete {
-			toDelete = append(toDelete, sess.ID)
+		if !*confirm {
+			fmt.Printf("  %s: %d redactions\n", session.ID[:12], n)
+			continue
+		}
+		if err := s.UpdateSession(session); err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating %s: %v\n", session.ID[:12], err)
 		}
 	}
 
-	if len(toDelete) == 0 {
-		fmt.Println("No sessions match the criteria")
+	if affected == 0 {
+		fmt.Println("No secrets found in stored sessions")
 		return
 	}
 
 	if !*confirm {
-		fmt.Printf("Would delete %d sessions (use --confirm to actually delete):\n", len(toDelete))
-		for i, id := range toDelete {
-			if i >= 10 {
-				fmt.Printf("  ... and %d more\n", len(toDelete)-10)
-				break
-			}
-			fmt.Printf("  %s\n", id[:12])
-		}
+		fmt.Printf("Would redact %d matches in %d sessions (use --confirm to rewrite)\n", total, affected)
 		return
 	}
 
-	deleted := 0
-	for _, id := range toDelete {
-		if err := s.DeleteSession(id); err != nil {
-			fmt.Fprintf(os.Stderr, "Error deleting %s: %v\n", id[:12], err)
-		} else {
-			deleted++
-		}
+	fmt.Printf("Redacted %d matches in %d sessions\n", total, affected)
+}
+
+func cmdDB(args []string) {
+	if len(args) < 1 {
+		printDBUsage()
+		os.Exit(1)
 	}
 
-	fmt.Printf("Deleted %d sessions\n", deleted)
+	subCmd := args[0]
+	subArgs := args[1:]
+
+	switch subCmd {
+	case "migrate":
+		cmdDBMigrate(subArgs)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown db subcommand: %s\n", subCmd)
+		printDBUsage()
+		os.Exit(1)
+	}
+}
+
+func printDBUsage() {
+	fmt.Print(`Usage: trajectory-memory db <subcommand>
+
+Subcommands:
+  migrate [--dry-run]   Upgrade the database schema to this version
+`)
+}
+
+func cmdDBMigrate(args []string) {
+	fs := flag.NewFlagSet("db migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Show pending migrations without applying them")
+	fs.Parse(args)
+
+	cfg := config.Load()
+	version, pending, err := store.PendingMigrations(cfg.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Database: %s\n", cfg.DBPath)
+	fmt.Printf("Schema version: %d (latest %d)\n", version, store.SchemaVersion())
+
+	if len(pending) == 0 {
+		fmt.Println("Database is up to date")
+		return
+	}
+
+	fmt.Println("Pending migrations:")
+	for _, m := range pending {
+		fmt.Printf("  %d. %s\n", m.Version, m.Description)
+	}
+
+	if *dryRun {
+		return
+	}
+
+	// Opening the store applies pending migrations
+	s, err := openStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	s.Close()
+
+	fmt.Printf("Migrated to schema version %d\n", store.SchemaVersion())
 }
 
 // findSession finds a session by full or partial ID.
@@ -687,6 +1493,8 @@ func cmdOptimize(args []string) {
 		cmdOptimizeHistory(subArgs)
 	case "diff":
 		cmdOptimizeDiff(subArgs)
+	case "strategies":
+		cmdOptimizeStrategies(subArgs)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown optimize subcommand: %s\n", subCmd)
 		printOptimizeUsage()
@@ -698,23 +1506,28 @@ func printOptimizeUsage() {
 	fmt.Print(`Usage: trajectory-memory optimize <subcommand>
 
 Subcommands:
-  propose <file> [--tag TAG]   Analyze trajectories and propose optimized content
+  propose <file> [--tag TAG] [--include-auto] [--use-preferences]  Analyze trajectories and propose optimized content
   apply <record-id>            Apply a proposed optimization
   reject <record-id>           Reject a proposed optimization
   rollback <record-id>         Revert an applied optimization
   history [--file F] [--tag T] Show optimization history
   diff <record-id>             Show diff for an optimization
+  strategies [flags] <file>    Propose replacements for underperforming strategies
+    --tag=T                    Strategies tag to evolve (required with --save)
+    --save=FILE                Save generated strategies from FILE (- for stdin) as a proposal
 `)
 }
 
 func cmdOptimizePropose(args []string) {
 	fs := flag.NewFlagSet("optimize propose", flag.ExitOnError)
 	tag := fs.String("tag", "", "Specific tag to optimize")
+	includeAuto := fs.Bool("include-auto", false, "Count unscored sessions by their auto score")
+	usePreferences := fs.Bool("use-preferences", false, "Score compared sessions by their preference rating")
 	fs.Parse(args)
 
 	remaining := fs.Args()
 	if len(remaining) < 1 {
-		fmt.Fprintln(os.Stderr, "Usage: trajectory-memory optimize propose <file> [--tag TAG]")
+		fmt.Fprintln(os.Stderr, "Usage: trajectory-memory optimize propose <file> [--tag TAG] [--include-auto] [--use-preferences]")
 		os.Exit(1)
 	}
 
@@ -727,7 +1540,14 @@ func cmdOptimizePropose(args []string) {
 	}
 	defer s.Close()
 
+	settings, err := optimizer.LoadSettings(config.Load().AnalyzerConfigPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	opt := optimizer.NewOptimizer(s)
+	opt.SetSettings(settings)
 	parser := optimizer.NewParser()
 
 	// Find targets
@@ -759,6 +1579,12 @@ func cmdOptimizePropose(args []string) {
 
 	// Analyze each target
 	for _, target := range targets {
+		if *includeAuto {
+			target.IncludeAutoScored = true
+		}
+		if *usePreferences {
+			target.UsePreferences = true
+		}
 		result, err := opt.Propose(target)
 		if err != nil {
 			fmt.Printf("\n## Target: %s (SKIPPED)\n%v\n", target.Tag, err)
@@ -769,6 +1595,88 @@ func cmdOptimizePropose(args []string) {
 	}
 }
 
+func cmdOptimizeStrategies(args []string) {
+	fs := flag.NewFlagSet("optimize strategies", flag.ExitOnError)
+	tag := fs.String("tag", "", "Strategies tag to evolve")
+	save := fs.String("save", "", "Add generated strategies from a file (- for stdin) to a proposal")
+	recordID := fs.String("record", "", "Proposal the generated strategies belong to (with --save)")
+	fs.Parse(args)
+
+	if (*save == "" && fs.NArg() < 1) || (*save != "" && *recordID == "") {
+		fmt.Fprintln(os.Stderr, "Usage: trajectory-memory optimize strategies [--tag T] <file>")
+		fmt.Fprintln(os.Stderr, "       trajectory-memory optimize strategies --record ID --save FILE|-")
+		os.Exit(1)
+	}
+
+	s, err := openStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	opt := optimizer.NewOptimizer(s)
+
+	if *save != "" {
+		var content []byte
+		if *save == "-" {
+			content, err = io.ReadAll(os.Stdin)
+		} else {
+			content, err = os.ReadFile(*save)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading strategies: %v\n", err)
+			os.Exit(1)
+		}
+
+		record, err := opt.SaveStrategiesProposal(*recordID, strings.TrimSpace(string(content)))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Proposal %s saved\n", record.ID)
+		if len(record.Retired) > 0 {
+			fmt.Printf("Retires: %s\n", strings.Join(record.Retired, ", "))
+		}
+		fmt.Printf("\n%s\n", record.Diff)
+		fmt.Printf("Apply with: trajectory-memory optimize apply %s\n", record.ID)
+		return
+	}
+
+	filePath := fs.Arg(0)
+	targets, err := optimizer.NewParser().FindStrategiesTargets(filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing file: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Filter by tag if specified
+	if *tag != "" {
+		var filtered []types.StrategiesTarget
+		for _, t := range targets {
+			if t.Tag == *tag {
+				filtered = append(filtered, t)
+			}
+		}
+		targets = filtered
+	}
+	if len(targets) == 0 {
+		fmt.Fprintln(os.Stderr, "No strategies found in file")
+		os.Exit(1)
+	}
+
+	for _, target := range targets {
+		result, err := opt.ProposeStrategies(target)
+		if err != nil {
+			fmt.Printf("\n## Strategies: %s (SKIPPED)\n%v\n", target.Tag, err)
+			continue
+		}
+
+		fmt.Println(optimizer.FormatEvolveForCLI(filePath, result))
+	}
+}
+
 func cmdOptimizeApply(args []string) {
 	if len(args) < 1 {
 		fmt.Fprintln(os.Stderr, "Usage: trajectory-memory optimize apply <record-id>")
@@ -906,11 +1814,14 @@ func cmdCurate(args []string) {
 	maxExamples := fs.Int("max", 3, "Maximum positive examples")
 	filePath := fs.String("file", "", "File to apply curated examples to")
 	includeNegative := fs.Bool("include-negative", true, "Include negative example")
+	dimension := fs.String("dimension", "", "Rank examples by a single rubric dimension")
+	includeAuto := fs.Bool("include-auto", false, "Count unscored sessions by their auto score")
+	usePreferences := fs.Bool("use-preferences", false, "Score compared sessions by their preference rating")
 	fs.Parse(args)
 
 	remaining := fs.Args()
 	if len(remaining) < 1 {
-		fmt.Fprintln(os.Stderr, "Usage: trajectory-memory curate <tag> [--max N] [--file F]")
+		fmt.Fprintln(os.Stderr, "Usage: trajectory-memory curate <tag> [--max N] [--file F] [--dimension D] [--include-auto] [--use-preferences]")
 		os.Exit(1)
 	}
 
@@ -923,8 +1834,17 @@ func cmdCurate(args []string) {
 	}
 	defer s.Close()
 
+	settings, err := optimizer.LoadSettings(config.Load().AnalyzerConfigPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	analyzer := optimizer.NewAnalyzer(s)
-	analysis, err := analyzer.Analyze(tag, 3) // Low minimum for curation
+	analyzer.IncludeAutoScored = *includeAuto
+	analyzer.UsePreferences = *usePreferences
+	analyzer.Configure(settings.For(tag))
+	analysis, err := analyzer.AnalyzeDimension(tag, *dimension, 3) // Low minimum for curation
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -977,7 +1897,7 @@ func formatCuratedExamplesForCLI(examples []types.CuratedExample, includeNegativ
 	buf.WriteString("### What Works Well (from past sessions)\n\n")
 
 	for _, ex := range examples {
-		if ex.Score >= 0.75 {
+		if !ex.Negative {
 			buf.WriteString(fmt.Sprintf("**Example: %s** (scored %.0f%%)\n",
 				truncate(ex.TaskPrompt, 50), ex.Score*100))
 			if ex.Summary != "" {
@@ -990,7 +1910,7 @@ func formatCuratedExamplesForCLI(examples []types.CuratedExample, includeNegativ
 
 	if includeNegative {
 		for _, ex := range examples {
-			if ex.Score < 0.5 {
+			if ex.Negative {
 				buf.WriteString("### What to Avoid\n\n")
 				buf.WriteString(fmt.Sprintf("**Anti-example: %s** (scored %.0f%%)\n",
 					truncate(ex.TaskPrompt, 50), ex.Score*100))
@@ -1006,6 +1926,121 @@ func formatCuratedExamplesForCLI(examples []types.CuratedExample, includeNegativ
 	return buf.String()
 }
 
+func cmdStrategies(args []string) {
+	if len(args) < 1 {
+		printStrategiesUsage()
+		os.Exit(1)
+	}
+
+	subCmd := args[0]
+	subArgs := args[1:]
+
+	switch subCmd {
+	case "weights":
+		cmdStrategiesWeights(subArgs)
+	case "archive":
+		cmdStrategiesArchive(subArgs)
+	case "analyze":
+		cmdStrategiesAnalyze(subArgs)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown strategies subcommand: %s\n", subCmd)
+		printStrategiesUsage()
+		os.Exit(1)
+	}
+}
+
+func printStrategiesUsage() {
+	fmt.Print(`Usage: trajectory-memory strategies <subcommand>
+
+Subcommands:
+  weights [flags] <tag>         Show the contextual model's learned weights per strategy
+    --refit                     Relearn the model from the latest scores first
+    --alpha=F                   Confidence bound width when refitting (default 1.0)
+  archive [tag]                 List strategies retired by evolution, with their stats
+  analyze [flags] <tag>         Show how each version of each strategy performed
+    --file=F                    File the current versions are read from (default CLAUDE.md)
+    --aggregate                 Combine every version of a strategy
+`)
+}
+
+func cmdStrategiesWeights(args []string) {
+	fs := flag.NewFlagSet("strategies weights", flag.ExitOnError)
+	refit := fs.Bool("refit", false, "Relearn the model from the latest scores first")
+	alpha := fs.Float64("alpha", bandit.DefaultAlpha, "Confidence bound width when refitting")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: trajectory-memory strategies weights [--refit] [--alpha F] <tag>")
+		os.Exit(1)
+	}
+	tag := fs.Arg(0)
+
+	s, err := openStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	model, err := s.GetStrategyModel(tag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Without a saved model there's nothing to inspect, so learn one
+	if model == nil || *refit {
+		usages, err := s.GetStrategyUsage(tag, 0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		var names []string
+		if model != nil {
+			for _, arm := range model.Arms {
+				names = append(names, arm.Name)
+			}
+		}
+		model = bandit.Fit(tag, names, bandit.Samples(usages, s.GetSessionHeader), *alpha)
+		if len(model.Arms) == 0 {
+			fmt.Printf("No strategy usage recorded for tag: %s\n", tag)
+			return
+		}
+		if err := s.SaveStrategyModel(model); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("Contextual model for %s\n", tag)
+	fmt.Printf("Learned from %d scored sessions (alpha %.2f, updated %s)\n\n",
+		model.Samples, model.Alpha, model.UpdatedAt.Local().Format("2006-01-02 15:04"))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header, rule := "FEATURE", "-------"
+	for _, arm := range model.Arms {
+		header += "\t" + arm.Name
+		rule += "\t" + strings.Repeat("-", len(arm.Name))
+	}
+	fmt.Fprintln(w, header)
+	fmt.Fprintln(w, rule)
+	for i, name := range model.Features {
+		row := name
+		for _, arm := range model.Arms {
+			row += fmt.Sprintf("\t%+.3f", arm.Weights[i])
+		}
+		fmt.Fprintln(w, row)
+	}
+	row := "(sessions)"
+	for _, arm := range model.Arms {
+		row += fmt.Sprintf("\t%d", arm.Pulls)
+	}
+	fmt.Fprintln(w, row)
+	w.Flush()
+
+	fmt.Println("\nA strategy's predicted score for a task is the sum of its weights times the task's features.")
+}
+
 func cmdTrigger(args []string) {
 	if len(args) < 1 {
 		printTriggerUsage()
@@ -1183,3 +2218,110 @@ func cmdUpdate(args []string) {
 
 	fmt.Printf("Successfully updated to %s!\n", release.TagName)
 }
+
+func cmdStrategiesArchive(args []string) {
+	var tag string
+	if len(args) > 0 {
+		tag = args[0]
+	}
+
+	s, err := openStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	archived, err := s.ListArchivedStrategies(tag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(archived) == 0 {
+		fmt.Println("No archived strategies")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TAG\tSTRATEGY\tSCORED\tAVG\tRECORD\tARCHIVED")
+	fmt.Fprintln(w, "---\t--------\t------\t---\t------\t--------")
+	for _, a := range archived {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", a.Tag, a.Strategy.Name, a.Strategy.ScoredCount, optimizer.FormatStrategyScore(a.Strategy),
+			a.RecordID, a.ArchivedAt.Local().Format("2006-01-02"))
+	}
+	w.Flush()
+}
+
+func cmdStrategiesAnalyze(args []string) {
+	fs := flag.NewFlagSet("strategies analyze", flag.ExitOnError)
+	filePath := fs.String("file", "CLAUDE.md", "File the current versions are read from")
+	aggregate := fs.Bool("aggregate", false, "Combine every version of a strategy")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: trajectory-memory strategies analyze [--file F] [--aggregate] <tag>")
+		os.Exit(1)
+	}
+	tag := fs.Arg(0)
+
+	s, err := openStore()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	var stats []types.Strategy
+	if *aggregate {
+		byName, err := s.GetStrategyStats(tag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, strat := range byName {
+			stats = append(stats, *strat)
+		}
+		sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	} else {
+		stats, err = s.GetStrategyVersionStats(tag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if len(stats) == 0 {
+		fmt.Printf("No strategy usage recorded for tag: %s\n", tag)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if *aggregate {
+		fmt.Fprintln(w, "STRATEGY\tSESSIONS\tSCORED\tAVG")
+		fmt.Fprintln(w, "--------\t--------\t------\t---")
+		for _, strat := range stats {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", strat.Name, strat.SessionCount, strat.ScoredCount, optimizer.FormatStrategyScore(strat))
+		}
+		w.Flush()
+		return
+	}
+
+	// Without the strategies file, versions just aren't marked current
+	current, _ := optimizer.NewParser().StrategyVersions(*filePath, tag)
+	fmt.Fprintln(w, "STRATEGY\tVERSION\tSESSIONS\tSCORED\tAVG")
+	fmt.Fprintln(w, "--------\t-------\t--------\t------\t---")
+	for _, strat := range optimizer.WithCurrentVersions(stats, current) {
+		version := strat.Version
+		if version == "" {
+			version = "unversioned"
+		} else if current[strat.Name] == strat.Version {
+			version += " *"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", strat.Name, version, strat.SessionCount, strat.ScoredCount, optimizer.FormatStrategyScore(strat))
+	}
+	w.Flush()
+	if len(current) > 0 {
+		fmt.Printf("\n* current version in %s\n", *filePath)
+	}
+}
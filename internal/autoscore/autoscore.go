@@ -0,0 +1,110 @@
+// Package autoscore derives a provisional score for a session from
+// observable signals in its trajectory, so sessions nobody scored can
+// still inform analysis.
+package autoscore
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+// ScorerName identifies auto outcomes produced by the default rules. Bump
+// the version when the rules change so stale scores can be recomputed.
+const ScorerName = "heuristic/v1"
+
+// Rule scores one aspect of a session's trajectory.
+type Rule interface {
+	// Name identifies the rule in an outcome's signals.
+	Name() string
+	// Evaluate scores the session from 0.0 to 1.0 and explains why.
+	// ok is false when the rule has nothing to go on.
+	Evaluate(s *types.Session) (score float64, detail string, ok bool)
+}
+
+// WeightedRule pairs a rule with its share of the overall score.
+type WeightedRule struct {
+	Rule   Rule
+	Weight float64
+}
+
+// Scorer combines rules into a provisional score.
+type Scorer struct {
+	name  string
+	rules []WeightedRule
+}
+
+// New creates a scorer from weighted rules.
+func New(name string, rules ...WeightedRule) *Scorer {
+	return &Scorer{name: name, rules: rules}
+}
+
+// Default returns the scorer with the built-in rules.
+func Default() *Scorer {
+	return New(ScorerName,
+		WeightedRule{Rule: TestsPassed{Pattern: DefaultTestPattern}, Weight: 0.4},
+		WeightedRule{Rule: NoTrailingErrors{Window: 3}, Weight: 0.25},
+		WeightedRule{Rule: WritesKept{Window: 5}, Weight: 0.2},
+		WeightedRule{Rule: Interrupts{Max: 3}, Weight: 0.15},
+	)
+}
+
+// Name returns the scorer's name, recorded as the outcome's provenance.
+func (sc *Scorer) Name() string {
+	return sc.name
+}
+
+// Score evaluates every rule and combines those that apply. It returns nil
+// when no rule applies.
+func (sc *Scorer) Score(s *types.Session) *types.AutoOutcome {
+	var signals []types.AutoSignal
+	for _, wr := range sc.rules {
+		score, detail, ok := wr.Rule.Evaluate(s)
+		if !ok {
+			continue
+		}
+		signals = append(signals, types.AutoSignal{
+			Rule:   wr.Rule.Name(),
+			Score:  score,
+			Weight: wr.Weight,
+			Detail: detail,
+		})
+	}
+	return Combine(sc.name, signals)
+}
+
+// Combine builds an outcome from signals, scoring it with their weighted
+// average. Scores are clamped to 0-1. It returns nil when the signals carry
+// no weight.
+func Combine(scorer string, signals []types.AutoSignal) *types.AutoOutcome {
+	var sum, total float64
+	for i := range signals {
+		signals[i].Score = clamp(signals[i].Score)
+		sum += signals[i].Weight * signals[i].Score
+		total += signals[i].Weight
+	}
+	if total == 0 {
+		return nil
+	}
+
+	return &types.AutoOutcome{
+		Score:    sum / total,
+		Scorer:   scorer,
+		Signals:  signals,
+		ScoredAt: time.Now(),
+	}
+}
+
+// DefaultTestPattern matches common test runner commands.
+var DefaultTestPattern = regexp.MustCompile(`(^|[\s;&|(])(go test|cargo test|pytest|python -m pytest|npm (run )?test|yarn test|pnpm (run )?test|jest|vitest|mvn test|gradle test|\./gradlew test|make test|rspec|mix test|dotnet test|phpunit|ctest)\b`)
+
+func clamp(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
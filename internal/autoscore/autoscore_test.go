@@ -0,0 +1,149 @@
+package autoscore
+
+import (
+	"math"
+	"testing"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+func step(tool, input string, status types.StepStatus) types.TrajectoryStep {
+	return types.TrajectoryStep{ToolName: tool, InputSummary: input, Status: status}
+}
+
+const (
+	ok     = types.StepStatusCompleted
+	failed = types.StepStatusFailed
+	denied = types.StepStatusIncomplete
+)
+
+func TestTestsPassed(t *testing.T) {
+	rule := TestsPassed{Pattern: DefaultTestPattern}
+
+	tests := []struct {
+		name    string
+		steps   []types.TrajectoryStep
+		want    float64
+		applies bool
+	}{
+		{"last run passed", []types.TrajectoryStep{
+			step("Bash", "go test ./...", failed),
+			step("Edit", "store.go", ok),
+			step("Bash", "go test ./...", ok),
+		}, 1, true},
+		{"last run failed", []types.TrajectoryStep{
+			step("Bash", "cd web && npm test", ok),
+			step("Bash", "npm run test -- --watch=false", failed),
+		}, 0, true},
+		{"interrupted", []types.TrajectoryStep{step("Bash", "pytest -x", denied)}, 0, true},
+		{"no tests", []types.TrajectoryStep{step("Bash", "go build ./...", ok), step("Bash", "echo latest", ok)}, 0, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, _, applies := rule.Evaluate(&types.Session{Steps: tc.steps})
+			if applies != tc.applies || got != tc.want {
+				t.Errorf("expected (%v, %v), got (%v, %v)", tc.want, tc.applies, got, applies)
+			}
+		})
+	}
+}
+
+func TestNoTrailingErrors(t *testing.T) {
+	rule := NoTrailingErrors{Window: 3}
+	s := &types.Session{Steps: []types.TrajectoryStep{
+		step("Bash", "make", failed),
+		step("Bash", "make", failed),
+		step("Edit", "Makefile", ok),
+		step("Bash", "make", failed),
+		step("Read", "Makefile", ok),
+	}}
+
+	got, detail, applies := rule.Evaluate(s)
+	if !applies || math.Abs(got-2.0/3) > 1e-9 {
+		t.Errorf("expected 2/3, got %v (%s)", got, detail)
+	}
+	if _, _, applies := rule.Evaluate(&types.Session{}); applies {
+		t.Error("expected rule not to apply without steps")
+	}
+}
+
+func TestWritesKept(t *testing.T) {
+	rule := WritesKept{Window: 2}
+	s := &types.Session{Steps: []types.TrajectoryStep{
+		step("Write", "/repo/internal/cache.go", ok),
+		step("Bash", "git checkout -- internal/cache.go", ok),
+		step("Edit", "/repo/README.md", ok),
+		step("Read", "/repo/go.mod", ok),
+		step("Read", "/repo/go.sum", ok),
+		// Outside the window
+		step("Bash", "git restore README.md", ok),
+		step("Edit", "/repo/main.go", failed),
+	}}
+
+	got, detail, applies := rule.Evaluate(s)
+	if !applies || got != 0.5 {
+		t.Errorf("expected half the writes kept, got %v (%s)", got, detail)
+	}
+
+	s = &types.Session{Steps: []types.TrajectoryStep{
+		step("Edit", "/repo/a.go", ok),
+		step("Bash", "git stash", ok),
+	}}
+	if got, _, _ := rule.Evaluate(s); got != 0 {
+		t.Errorf("expected git stash to revert every write, got %v", got)
+	}
+	s = &types.Session{Steps: []types.TrajectoryStep{
+		step("Edit", "/repo/a.go", ok),
+		step("Bash", "git stash pop", ok),
+	}}
+	if got, _, _ := rule.Evaluate(s); got != 1 {
+		t.Errorf("expected git stash pop not to count as a revert, got %v", got)
+	}
+
+	if _, _, applies := rule.Evaluate(&types.Session{Steps: []types.TrajectoryStep{step("Read", "a.go", ok)}}); applies {
+		t.Error("expected rule not to apply without writes")
+	}
+}
+
+func TestInterrupts(t *testing.T) {
+	rule := Interrupts{Max: 2}
+	s := &types.Session{Steps: []types.TrajectoryStep{
+		step("Bash", "rm -rf build", denied),
+		step("Read", "a.go", ok),
+	}}
+	if got, _, _ := rule.Evaluate(s); got != 0.5 {
+		t.Errorf("expected 0.5 for one interrupt, got %v", got)
+	}
+}
+
+func TestScorer(t *testing.T) {
+	s := &types.Session{Steps: []types.TrajectoryStep{
+		step("Edit", "/repo/a.go", ok),
+		step("Bash", "go test ./...", failed),
+	}}
+
+	outcome := Default().Score(s)
+	if outcome == nil {
+		t.Fatal("expected an outcome")
+	}
+	if outcome.Scorer != ScorerName {
+		t.Errorf("expected scorer %s, got %s", ScorerName, outcome.Scorer)
+	}
+	if len(outcome.Signals) != 4 {
+		t.Fatalf("expected 4 signals, got %+v", outcome.Signals)
+	}
+	// tests 0.4*0 + trailing 0.25*0.5 + writes 0.2*1 + interrupts 0.15*1
+	if math.Abs(outcome.Score-0.475) > 1e-9 {
+		t.Errorf("expected 0.475, got %v", outcome.Score)
+	}
+
+	// Weights are renormalized over the rules that apply
+	s = &types.Session{Steps: []types.TrajectoryStep{step("Read", "a.go", ok)}}
+	if outcome := Default().Score(s); outcome == nil || outcome.Score != 1 || len(outcome.Signals) != 2 {
+		t.Errorf("expected a clean read-only session to score 1 on two signals, got %+v", outcome)
+	}
+
+	if outcome := Default().Score(&types.Session{}); outcome != nil {
+		t.Errorf("expected no outcome for an empty session, got %+v", outcome)
+	}
+}
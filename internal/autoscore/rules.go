@@ -0,0 +1,180 @@
+package autoscore
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+// TestsPassed scores 1 if the last test command in the session passed and
+// 0 if it failed. It doesn't apply to sessions that ran no tests.
+type TestsPassed struct {
+	// Pattern matches Bash commands that run tests.
+	Pattern *regexp.Regexp
+}
+
+// Name implements Rule.
+func (TestsPassed) Name() string { return "tests_passed" }
+
+// Evaluate implements Rule.
+func (r TestsPassed) Evaluate(s *types.Session) (float64, string, bool) {
+	for i := len(s.Steps) - 1; i >= 0; i-- {
+		step := s.Steps[i]
+		if !isBash(step) || !r.Pattern.MatchString(step.InputSummary) {
+			continue
+		}
+		command := types.TruncateString(step.InputSummary, 80)
+		switch {
+		case step.IsFailed():
+			return 0, fmt.Sprintf("last test command failed: %s", command), true
+		case step.IsIncomplete():
+			return 0, fmt.Sprintf("last test command didn't finish: %s", command), true
+		default:
+			return 1, fmt.Sprintf("last test command passed: %s", command), true
+		}
+	}
+	return 0, "", false
+}
+
+// NoTrailingErrors scores the share of the session's final steps that
+// succeeded, so a session that ends while still hitting errors scores low.
+type NoTrailingErrors struct {
+	// Window is the number of final steps to check.
+	Window int
+}
+
+// Name implements Rule.
+func (NoTrailingErrors) Name() string { return "no_trailing_errors" }
+
+// Evaluate implements Rule.
+func (r NoTrailingErrors) Evaluate(s *types.Session) (float64, string, bool) {
+	if len(s.Steps) == 0 || r.Window <= 0 {
+		return 0, "", false
+	}
+
+	start := len(s.Steps) - r.Window
+	if start < 0 {
+		start = 0
+	}
+	tail := s.Steps[start:]
+
+	failed := 0
+	for _, step := range tail {
+		if step.IsFailed() {
+			failed++
+		}
+	}
+	return 1 - float64(failed)/float64(len(tail)),
+		fmt.Sprintf("%d of the last %d steps failed", failed, len(tail)), true
+}
+
+// WritesKept scores the share of written files that weren't reverted
+// within a few steps, e.g. with git checkout, git restore or rm.
+type WritesKept struct {
+	// Window is the number of steps after a write in which a revert counts.
+	Window int
+}
+
+// Name implements Rule.
+func (WritesKept) Name() string { return "writes_kept" }
+
+var (
+	// Commands that discard changes to the files they name
+	fileRevertPattern = regexp.MustCompile(`\bgit\s+(checkout|restore)\b|(^|[\s;&|])rm\s`)
+	// Commands that discard every uncommitted change
+	allRevertPattern = regexp.MustCompile(`\bgit\s+(reset\s+--hard|stash(\s+(push|save)\b|\s*$|\s*[;&|])|(checkout|restore)\s+(--\s+)?\.(\s|$))`)
+)
+
+// Evaluate implements Rule.
+func (r WritesKept) Evaluate(s *types.Session) (float64, string, bool) {
+	written, reverted := 0, 0
+	for i, step := range s.Steps {
+		if !isWrite(step) || step.IsFailed() || step.IsIncomplete() || step.InputSummary == "" {
+			continue
+		}
+		written++
+
+		end := i + 1 + r.Window
+		if end > len(s.Steps) {
+			end = len(s.Steps)
+		}
+		for _, later := range s.Steps[i+1 : end] {
+			if isBash(later) && !later.IsFailed() && reverts(later.InputSummary, step.InputSummary) {
+				reverted++
+				break
+			}
+		}
+	}
+	if written == 0 {
+		return 0, "", false
+	}
+	return 1 - float64(reverted)/float64(written),
+		fmt.Sprintf("%d of %d writes reverted", reverted, written), true
+}
+
+// reverts reports whether a shell command discards changes to a file.
+func reverts(command, path string) bool {
+	if allRevertPattern.MatchString(command) {
+		return true
+	}
+	if !fileRevertPattern.MatchString(command) {
+		return false
+	}
+	return namesPath(command, path)
+}
+
+// Interrupts scores sessions lower the more tool calls were interrupted or
+// denied by the user, reaching 0 at Max.
+type Interrupts struct {
+	Max int
+}
+
+// Name implements Rule.
+func (Interrupts) Name() string { return "interrupts" }
+
+// Evaluate implements Rule.
+func (r Interrupts) Evaluate(s *types.Session) (float64, string, bool) {
+	if len(s.Steps) == 0 || r.Max <= 0 {
+		return 0, "", false
+	}
+
+	count := 0
+	for _, step := range s.Steps {
+		if step.IsIncomplete() {
+			count++
+		}
+	}
+	return 1 - float64(count)/float64(r.Max),
+		fmt.Sprintf("%d tool calls interrupted or denied", count), true
+}
+
+func isBash(step types.TrajectoryStep) bool {
+	return step.ToolName == "Bash"
+}
+
+func isWrite(step types.TrajectoryStep) bool {
+	switch step.ToolName {
+	case "Write", "Edit", "MultiEdit", "NotebookEdit":
+		return true
+	}
+	return false
+}
+
+// namesPath reports whether a command has an argument naming path, either
+// exactly or relative to a parent directory.
+func namesPath(command, path string) bool {
+	path = filepath.ToSlash(path)
+	for _, field := range strings.Fields(command) {
+		arg := strings.TrimPrefix(filepath.ToSlash(strings.Trim(field, `"'`)), "./")
+		if arg == "" || strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if arg == path || strings.HasSuffix(path, "/"+arg) {
+			return true
+		}
+	}
+	return false
+}
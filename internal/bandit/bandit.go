@@ -0,0 +1,160 @@
+// Package bandit chooses between strategies by treating each as an arm of a
+// multi-armed bandit, so selection keeps trying strategies it is unsure of
+// while favoring those that have scored well.
+package bandit
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// DefaultEpsilon is how often EpsilonGreedy explores when no rate is given.
+const DefaultEpsilon = 0.1
+
+// Arm is one strategy and the scores its sessions have earned.
+type Arm struct {
+	Name  string
+	Pulls int     // scored sessions
+	Mean  float64 // average score, 0.0 to 1.0
+}
+
+// Choice is the arm a policy picked, the value each arm was ranked by and
+// why the winner won.
+type Choice struct {
+	Index  int
+	Values []float64
+	Reason string
+}
+
+// Thompson draws a plausible mean for every arm from its Beta posterior and
+// picks the highest draw. Scores count as fractional successes on a uniform
+// prior, so an arm with mean m over n sessions has posterior
+// Beta(1+m·n, 1+(1-m)·n).
+func Thompson(arms []Arm, rng *rand.Rand) Choice {
+	choice := Choice{Index: -1, Values: make([]float64, len(arms))}
+	for i, arm := range arms {
+		a, b := posterior(arm)
+		choice.Values[i] = betaSample(rng, a, b)
+		if choice.Index < 0 || choice.Values[i] > choice.Values[choice.Index] {
+			choice.Index = i
+		}
+	}
+	if choice.Index < 0 {
+		return choice
+	}
+
+	arm := arms[choice.Index]
+	a, b := posterior(arm)
+	choice.Reason = fmt.Sprintf("Thompson sampling drew %.2f from Beta(%.1f, %.1f) (posterior mean %.2f over %d scored sessions)",
+		choice.Values[choice.Index], a, b, a/(a+b), arm.Pulls)
+	return choice
+}
+
+// posterior returns the Beta parameters for an arm.
+func posterior(arm Arm) (a, b float64) {
+	n := float64(arm.Pulls)
+	return 1 + arm.Mean*n, 1 + (1-arm.Mean)*n
+}
+
+// UCB1 picks the arm with the highest upper confidence bound,
+// mean + sqrt(2 ln N / n), after trying every arm once. Ties go to the
+// earlier arm.
+func UCB1(arms []Arm) Choice {
+	choice := Choice{Index: -1, Values: make([]float64, len(arms))}
+
+	total := 0
+	for _, arm := range arms {
+		total += arm.Pulls
+	}
+
+	for i, arm := range arms {
+		if arm.Pulls == 0 {
+			choice.Values[i] = math.Inf(1)
+		} else {
+			choice.Values[i] = arm.Mean + math.Sqrt(2*math.Log(float64(total))/float64(arm.Pulls))
+		}
+		if choice.Index < 0 || choice.Values[i] > choice.Values[choice.Index] {
+			choice.Index = i
+		}
+	}
+	if choice.Index < 0 {
+		return choice
+	}
+
+	arm := arms[choice.Index]
+	if arm.Pulls == 0 {
+		choice.Reason = "UCB1 tries every strategy once first (no scored sessions yet)"
+		return choice
+	}
+	bound := choice.Values[choice.Index]
+	choice.Reason = fmt.Sprintf("UCB1 bound %.2f = %.2f avg + %.2f exploration bonus (%d of %d scored sessions)",
+		bound, arm.Mean, bound-arm.Mean, arm.Pulls, total)
+	return choice
+}
+
+// EpsilonGreedy picks a random arm with probability epsilon and otherwise
+// the arm with the best mean. Arms without scores are only picked at
+// random, unless no arm has scores yet.
+func EpsilonGreedy(arms []Arm, epsilon float64, rng *rand.Rand) Choice {
+	choice := Choice{Index: -1, Values: make([]float64, len(arms))}
+	if len(arms) == 0 {
+		return choice
+	}
+	if epsilon <= 0 {
+		epsilon = DefaultEpsilon
+	}
+
+	best := -1
+	for i, arm := range arms {
+		choice.Values[i] = arm.Mean
+		if arm.Pulls > 0 && (best < 0 || arm.Mean > arms[best].Mean) {
+			best = i
+		}
+	}
+
+	if roll := rng.Float64(); roll < epsilon || best < 0 {
+		choice.Index = rng.Intn(len(arms))
+		if best < 0 {
+			choice.Reason = "Epsilon-greedy picked at random (no scored sessions yet)"
+		} else {
+			choice.Reason = fmt.Sprintf("Epsilon-greedy explored at random (ε = %.2f)", epsilon)
+		}
+		return choice
+	}
+
+	choice.Index = best
+	choice.Reason = fmt.Sprintf("Epsilon-greedy exploited the best average %.2f over %d scored sessions (ε = %.2f)",
+		arms[best].Mean, arms[best].Pulls, epsilon)
+	return choice
+}
+
+// betaSample draws from Beta(a, b) as the ratio of two gamma draws.
+func betaSample(rng *rand.Rand, a, b float64) float64 {
+	x := gammaSample(rng, a)
+	y := gammaSample(rng, b)
+	return x / (x + y)
+}
+
+// gammaSample draws from Gamma(shape, 1) with the Marsaglia-Tsang method.
+func gammaSample(rng *rand.Rand, shape float64) float64 {
+	if shape < 1 {
+		// Boost the shape above 1 and scale the draw back down
+		return gammaSample(rng, shape+1) * math.Pow(rng.Float64(), 1/shape)
+	}
+
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
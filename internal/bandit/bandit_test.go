@@ -0,0 +1,114 @@
+package bandit
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestBetaSample(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, tc := range []struct{ a, b float64 }{{1, 1}, {4, 2}, {0.5, 0.5}, {30, 10}} {
+		sum := 0.0
+		const n = 20000
+		for i := 0; i < n; i++ {
+			v := betaSample(rng, tc.a, tc.b)
+			if v < 0 || v > 1 {
+				t.Fatalf("Beta(%g, %g) drew %f", tc.a, tc.b, v)
+			}
+			sum += v
+		}
+		if mean, want := sum/n, tc.a/(tc.a+tc.b); math.Abs(mean-want) > 0.01 {
+			t.Errorf("Beta(%g, %g): expected mean %.3f, got %.3f", tc.a, tc.b, want, mean)
+		}
+	}
+}
+
+func TestThompson(t *testing.T) {
+	arms := []Arm{
+		{Name: "weak", Pulls: 20, Mean: 0.3},
+		{Name: "strong", Pulls: 20, Mean: 0.8},
+		{Name: "new"},
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	picks := make([]int, len(arms))
+	for i := 0; i < 1000; i++ {
+		choice := Thompson(arms, rng)
+		if len(choice.Values) != len(arms) {
+			t.Fatalf("expected a value per arm, got %v", choice.Values)
+		}
+		picks[choice.Index]++
+	}
+	// The untried arm is uniform, so it still wins sometimes
+	if picks[1] < 700 || picks[2] == 0 || picks[0] > picks[2] {
+		t.Errorf("expected strong to dominate while new is still explored, got %v", picks)
+	}
+
+	// The same seed gives the same choice
+	a := Thompson(arms, rand.New(rand.NewSource(7)))
+	b := Thompson(arms, rand.New(rand.NewSource(7)))
+	if a.Index != b.Index || a.Reason != b.Reason {
+		t.Errorf("expected seeded draws to repeat, got %+v and %+v", a, b)
+	}
+	if !strings.Contains(a.Reason, "Beta(") {
+		t.Errorf("expected the reason to show the posterior, got %q", a.Reason)
+	}
+
+	if choice := Thompson(nil, rng); choice.Index != -1 {
+		t.Errorf("expected no choice without arms, got %+v", choice)
+	}
+}
+
+func TestUCB1(t *testing.T) {
+	arms := []Arm{
+		{Name: "a", Pulls: 8, Mean: 0.7},
+		{Name: "b", Pulls: 2, Mean: 0.6},
+	}
+	choice := UCB1(arms)
+
+	// ln 10 = 2.303; a: 0.7 + sqrt(4.605/8) = 1.459, b: 0.6 + sqrt(4.605/2) = 2.117
+	if choice.Index != 1 {
+		t.Errorf("expected the less-tried arm to win on its bonus, got %+v", choice)
+	}
+	if math.Abs(choice.Values[0]-(0.7+math.Sqrt(2*math.Log(10)/8))) > 1e-12 {
+		t.Errorf("unexpected bound %f", choice.Values[0])
+	}
+	if want := "UCB1 bound 2.12 = 0.60 avg + 1.52 exploration bonus (2 of 10 scored sessions)"; choice.Reason != want {
+		t.Errorf("expected reason %q, got %q", want, choice.Reason)
+	}
+
+	arms = append(arms, Arm{Name: "c"})
+	if choice := UCB1(arms); choice.Index != 2 || !strings.Contains(choice.Reason, "once first") {
+		t.Errorf("expected the untried arm first, got %+v", choice)
+	}
+}
+
+func TestEpsilonGreedy(t *testing.T) {
+	arms := []Arm{
+		{Name: "a", Pulls: 3, Mean: 0.5},
+		{Name: "b", Pulls: 3, Mean: 0.9},
+		{Name: "c"},
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	picks := make([]int, len(arms))
+	for i := 0; i < 1000; i++ {
+		picks[EpsilonGreedy(arms, 0.3, rng).Index]++
+	}
+	// Exploitation plus a third of the 30% exploration
+	if picks[1] < 750 || picks[1] > 850 || picks[2] == 0 {
+		t.Errorf("expected b about 80%% of the time with some exploration, got %v", picks)
+	}
+
+	choice := EpsilonGreedy(arms, 1e-9, rng)
+	if choice.Index != 1 || !strings.Contains(choice.Reason, "best average 0.90") {
+		t.Errorf("expected to exploit b, got %+v", choice)
+	}
+
+	choice = EpsilonGreedy([]Arm{{Name: "x"}, {Name: "y"}}, 0, rng)
+	if choice.Index < 0 || !strings.Contains(choice.Reason, "no scored sessions") {
+		t.Errorf("expected a random pick without data, got %+v", choice)
+	}
+}
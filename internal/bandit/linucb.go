@@ -0,0 +1,291 @@
+package bandit
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+// DefaultAlpha is the width of LinUCB's confidence bound when none is given.
+const DefaultAlpha = 1.0
+
+// Task features every model has. Tag features are added as "tag:<name>" for
+// each tag seen in training.
+const (
+	FeatureBias          = "bias"
+	FeaturePromptLength  = "prompt_length"
+	FeatureMentionsFiles = "mentions_files"
+	FeatureMentionsTests = "mentions_tests"
+	FeatureMentionsFix   = "mentions_fix"
+	FeatureHourSin       = "hour_sin"
+	FeatureHourCos       = "hour_cos"
+)
+
+var baseFeatures = []string{
+	FeatureBias, FeaturePromptLength, FeatureMentionsFiles, FeatureMentionsTests,
+	FeatureMentionsFix, FeatureHourSin, FeatureHourCos,
+}
+
+// longPromptWords is the prompt length that counts as fully long.
+const longPromptWords = 200
+
+var (
+	filePattern = regexp.MustCompile(`[\w.-]*/[\w./-]+|\b[\w-]+\.(go|py|js|jsx|ts|tsx|rs|java|rb|c|h|cpp|cs|swift|kt|md|json|ya?ml|toml|sql|sh|css|html)\b`)
+	testPattern = regexp.MustCompile(`(?i)\b(tests?|testing|specs?)\b`)
+	fixPattern  = regexp.MustCompile(`(?i)\b(fix\w*|bugs?|errors?|fail\w*|broken|crash\w*)\b`)
+)
+
+// TaskFeatures describes a task for contextual selection: how long its
+// prompt is, whether the prompt names files, tests or a fix, the hour it
+// started on a 24-hour circle, and its tags.
+func TaskFeatures(prompt string, tags []string, at time.Time) map[string]float64 {
+	words := len(strings.Fields(prompt))
+	x := map[string]float64{
+		FeatureBias:         1,
+		FeaturePromptLength: math.Min(math.Log1p(float64(words))/math.Log1p(longPromptWords), 1),
+	}
+	if filePattern.MatchString(prompt) {
+		x[FeatureMentionsFiles] = 1
+	}
+	if testPattern.MatchString(prompt) {
+		x[FeatureMentionsTests] = 1
+	}
+	if fixPattern.MatchString(prompt) {
+		x[FeatureMentionsFix] = 1
+	}
+	if !at.IsZero() {
+		local := at.Local()
+		angle := 2 * math.Pi * (float64(local.Hour()) + float64(local.Minute())/60) / 24
+		x[FeatureHourSin] = math.Sin(angle)
+		x[FeatureHourCos] = math.Cos(angle)
+	}
+	for _, tag := range tags {
+		x["tag:"+tag] = 1
+	}
+	return x
+}
+
+// Sample is the score a strategy earned on one task.
+type Sample struct {
+	Arm      string
+	Features map[string]float64
+	Reward   float64
+}
+
+// Samples joins usage records with their sessions. Each is scored by its
+// session's outcome, 0 included, or else the score recorded with the usage;
+// sessions with neither are skipped. The usage's own tag is left out of the features
+// since every sample shares it.
+func Samples(usages []types.StrategyUsage, session func(id string) (*types.Session, error)) []Sample {
+	var samples []Sample
+	for _, usage := range usages {
+		s, err := session(usage.SessionID)
+		if err != nil {
+			continue
+		}
+
+		var reward float64
+		switch {
+		case s.Outcome != nil:
+			reward = s.Outcome.Score
+		case usage.IsScored():
+			reward = usage.Score
+		default:
+			continue
+		}
+
+		var tags []string
+		for _, tag := range s.Tags {
+			if tag != usage.Tag {
+				tags = append(tags, tag)
+			}
+		}
+		samples = append(samples, Sample{
+			Arm:      usage.StrategyName,
+			Features: TaskFeatures(s.TaskPrompt, tags, s.StartedAt),
+			Reward:   reward,
+		})
+	}
+	return samples
+}
+
+// Fit learns a ridge regression from task features to score for every arm,
+// as LinUCB does. Arms without samples keep zero weights and the widest
+// confidence bound.
+func Fit(tag string, arms []string, samples []Sample, alpha float64) *types.StrategyModel {
+	if alpha <= 0 {
+		alpha = DefaultAlpha
+	}
+
+	model := &types.StrategyModel{
+		Tag:       tag,
+		Features:  featureNames(samples),
+		Alpha:     alpha,
+		Samples:   len(samples),
+		UpdatedAt: time.Now(),
+	}
+	d := len(model.Features)
+
+	index := make(map[string]int)
+	arm := func(name string) *types.StrategyArm {
+		if i, ok := index[name]; ok {
+			return &model.Arms[i]
+		}
+		a := make([][]float64, d)
+		for i := range a {
+			a[i] = make([]float64, d)
+			a[i][i] = 1
+		}
+		index[name] = len(model.Arms)
+		model.Arms = append(model.Arms, types.StrategyArm{Name: name, A: a, B: make([]float64, d)})
+		return &model.Arms[len(model.Arms)-1]
+	}
+	for _, name := range arms {
+		arm(name)
+	}
+
+	for _, s := range samples {
+		a := arm(s.Arm)
+		x := vector(model.Features, s.Features)
+		for i := range x {
+			for j := range x {
+				a.A[i][j] += x[i] * x[j]
+			}
+			a.B[i] += s.Reward * x[i]
+		}
+		a.Pulls++
+	}
+
+	for i := range model.Arms {
+		model.Arms[i].Weights = solve(model.Arms[i].A, model.Arms[i].B)
+	}
+	return model
+}
+
+// featureNames returns the base features followed by the tag features seen
+// in samples, sorted.
+func featureNames(samples []Sample) []string {
+	known := make(map[string]bool)
+	for _, name := range baseFeatures {
+		known[name] = true
+	}
+	var extra []string
+	for _, s := range samples {
+		for name := range s.Features {
+			if !known[name] {
+				known[name] = true
+				extra = append(extra, name)
+			}
+		}
+	}
+	sort.Strings(extra)
+	return append(append([]string{}, baseFeatures...), extra...)
+}
+
+// vector lays features out in the model's order, dropping any it doesn't
+// know.
+func vector(names []string, features map[string]float64) []float64 {
+	x := make([]float64, len(names))
+	for i, name := range names {
+		x[i] = features[name]
+	}
+	return x
+}
+
+// Predict returns an arm's predicted score for a task and the exploration
+// bonus on top of it. Arms the model hasn't seen predict zero.
+func Predict(model *types.StrategyModel, name string, features map[string]float64) (score, bonus float64) {
+	x := vector(model.Features, features)
+	for _, arm := range model.Arms {
+		if arm.Name != name {
+			continue
+		}
+		for i := range x {
+			score += arm.Weights[i] * x[i]
+		}
+		return score, model.Alpha * math.Sqrt(dot(x, solve(arm.A, x)))
+	}
+	return 0, model.Alpha * math.Sqrt(dot(x, x))
+}
+
+// LinUCB picks the arm with the highest upper confidence bound on its
+// predicted score for a task, θ·x + α·sqrt(xᵀA⁻¹x). Ties go to the earlier
+// arm.
+func LinUCB(model *types.StrategyModel, arms []string, features map[string]float64) Choice {
+	choice := Choice{Index: -1, Values: make([]float64, len(arms))}
+	for i, name := range arms {
+		score, bonus := Predict(model, name, features)
+		choice.Values[i] = score + bonus
+		if choice.Index < 0 || choice.Values[i] > choice.Values[choice.Index] {
+			choice.Index = i
+		}
+	}
+	if choice.Index < 0 {
+		return choice
+	}
+
+	name := arms[choice.Index]
+	score, bonus := Predict(model, name, features)
+	pulls := 0
+	for _, arm := range model.Arms {
+		if arm.Name == name {
+			pulls = arm.Pulls
+		}
+	}
+	choice.Reason = fmt.Sprintf("LinUCB bound %.2f = %.2f predicted for this task + %.2f exploration bonus (%d scored sessions with this strategy)",
+		choice.Values[choice.Index], score, bonus, pulls)
+	return choice
+}
+
+// solve returns x with Ax = b for a symmetric positive definite A, by
+// Cholesky decomposition.
+func solve(a [][]float64, b []float64) []float64 {
+	n := len(b)
+	l := make([][]float64, n)
+	for i := range l {
+		l[i] = make([]float64, n)
+		for j := 0; j <= i; j++ {
+			sum := a[i][j]
+			for k := 0; k < j; k++ {
+				sum -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				l[i][i] = math.Sqrt(sum)
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+
+	// Forward substitution for Ly = b, then back substitution for Lᵀx = y
+	y := make([]float64, n)
+	for i := 0; i < n; i++ {
+		sum := b[i]
+		for k := 0; k < i; k++ {
+			sum -= l[i][k] * y[k]
+		}
+		y[i] = sum / l[i][i]
+	}
+	x := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		sum := y[i]
+		for k := i + 1; k < n; k++ {
+			sum -= l[k][i] * x[k]
+		}
+		x[i] = sum / l[i][i]
+	}
+	return x
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
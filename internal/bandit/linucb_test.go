@@ -0,0 +1,120 @@
+package bandit
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+func TestTaskFeatures(t *testing.T) {
+	at := time.Date(2026, 3, 2, 18, 0, 0, 0, time.Local)
+	x := TaskFeatures("Fix the failing test in internal/store/store.go", []string{"backend"}, at)
+
+	for _, name := range []string{FeatureBias, FeatureMentionsFiles, FeatureMentionsTests, FeatureMentionsFix, "tag:backend"} {
+		if x[name] != 1 {
+			t.Errorf("expected %s to be set, got %v", name, x)
+		}
+	}
+	if x[FeaturePromptLength] <= 0 || x[FeaturePromptLength] >= 1 {
+		t.Errorf("expected a partial prompt length, got %f", x[FeaturePromptLength])
+	}
+	// 18:00 is three quarters of the way round the clock
+	if math.Abs(x[FeatureHourSin]+1) > 1e-9 || math.Abs(x[FeatureHourCos]) > 1e-9 {
+		t.Errorf("unexpected hour features %f, %f", x[FeatureHourSin], x[FeatureHourCos])
+	}
+
+	x = TaskFeatures("Summarize the news", nil, time.Time{})
+	if x[FeatureMentionsFiles] != 0 || x[FeatureMentionsTests] != 0 || x[FeatureMentionsFix] != 0 {
+		t.Errorf("expected a plain prompt, got %v", x)
+	}
+	if _, ok := x[FeatureHourSin]; ok {
+		t.Errorf("expected no hour without a start time, got %v", x)
+	}
+}
+
+func TestSolve(t *testing.T) {
+	a := [][]float64{{4, 2}, {2, 3}}
+	x := solve(a, []float64{2, 1})
+	if math.Abs(x[0]-0.5) > 1e-12 || math.Abs(x[1]) > 1e-12 {
+		t.Errorf("expected [0.5 0], got %v", x)
+	}
+}
+
+func TestSamples(t *testing.T) {
+	sessions := map[string]*types.Session{
+		"a": {ID: "a", TaskPrompt: "Fix bug", Tags: []string{"backend", "api"}, Outcome: &types.Outcome{Score: 0.9}},
+		"b": {ID: "b", TaskPrompt: "Write docs", Tags: []string{"backend"}},
+		"c": {ID: "c", TaskPrompt: "Refactor", Tags: []string{"backend"}},
+		"d": {ID: "d", TaskPrompt: "Migrate", Tags: []string{"backend"}, Outcome: &types.Outcome{Score: 0}},
+	}
+	usages := []types.StrategyUsage{
+		{Tag: "backend", StrategyName: "careful", SessionID: "a", Score: 0.5},
+		{Tag: "backend", StrategyName: "fast", SessionID: "b", Score: 0.4},
+		{Tag: "backend", StrategyName: "fast", SessionID: "c"},
+		{Tag: "backend", StrategyName: "fast", SessionID: "missing", Score: 0.7},
+		{Tag: "backend", StrategyName: "fast", SessionID: "d"},
+	}
+	samples := Samples(usages, func(id string) (*types.Session, error) {
+		if s, ok := sessions[id]; ok {
+			return s, nil
+		}
+		return nil, errors.New("not found")
+	})
+
+	if len(samples) != 3 {
+		t.Fatalf("expected the three scored sessions, got %+v", samples)
+	}
+	if samples[2].Arm != "fast" || samples[2].Reward != 0 {
+		t.Errorf("expected a session scored 0 to count as a failure, got %+v", samples[2])
+	}
+	if samples[0].Reward != 0.9 || samples[1].Reward != 0.4 {
+		t.Errorf("expected outcomes to win over usage scores, got %+v", samples)
+	}
+	if samples[0].Features["tag:api"] != 1 || samples[0].Features["tag:backend"] != 0 {
+		t.Errorf("expected only the other tags as features, got %v", samples[0].Features)
+	}
+}
+
+func TestLinUCB(t *testing.T) {
+	bug := TaskFeatures("Fix the crash in parser.go", nil, time.Time{})
+	docs := TaskFeatures("Write a guide to the config options", nil, time.Time{})
+
+	// careful does well on fixes and badly on docs; quick the reverse
+	var samples []Sample
+	for i := 0; i < 10; i++ {
+		samples = append(samples,
+			Sample{Arm: "careful", Features: bug, Reward: 0.9},
+			Sample{Arm: "careful", Features: docs, Reward: 0.2},
+			Sample{Arm: "quick", Features: bug, Reward: 0.3},
+			Sample{Arm: "quick", Features: docs, Reward: 0.8},
+		)
+	}
+	arms := []string{"careful", "quick"}
+	model := Fit("backend", arms, samples, 0.1)
+
+	if model.Samples != 40 || len(model.Arms) != 2 || model.Arms[0].Pulls != 20 {
+		t.Fatalf("unexpected model %+v", model)
+	}
+	if score, _ := Predict(model, "careful", bug); math.Abs(score-0.9) > 0.05 {
+		t.Errorf("expected careful to predict about 0.9 on a fix, got %f", score)
+	}
+
+	if choice := LinUCB(model, arms, bug); choice.Index != 0 {
+		t.Errorf("expected careful for a fix, got %+v", choice)
+	}
+	choice := LinUCB(model, arms, docs)
+	if choice.Index != 1 || !strings.Contains(choice.Reason, "(20 scored sessions with this strategy)") {
+		t.Errorf("expected quick for docs, got %+v", choice)
+	}
+
+	// With a wide enough bound, a new strategy gets tried
+	model.Alpha = DefaultAlpha
+	arms = append(arms, "fresh")
+	if choice := LinUCB(model, arms, bug); choice.Index != 2 {
+		t.Errorf("expected the untried strategy to win on its bonus, got %+v", choice)
+	}
+}
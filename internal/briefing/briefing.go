@@ -0,0 +1,142 @@
+// Package briefing assembles a summary of relevant past sessions for an
+// agent starting a new task.
+package briefing
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/johncarpenter/trajectory-memory/internal/store"
+)
+
+// Options controls which sessions a briefing includes and how large it gets.
+type Options struct {
+	// MaxTokens caps the estimated size of the briefing (0 = no limit).
+	MaxTokens int
+	// MaxSessions limits the number of similar sessions to learn from.
+	MaxSessions int
+	// MinScore is the lowest score a session can have to be recommended.
+	MinScore float64
+	// AvoidBelow is the score under which a session is shown as one to avoid.
+	AvoidBelow float64
+	// MinSimilarity drops sessions that are only loosely related.
+	MinSimilarity float64
+}
+
+// DefaultOptions returns the default briefing options.
+func DefaultOptions() Options {
+	return Options{
+		MaxTokens:     1000,
+		MaxSessions:   3,
+		MinScore:      0.7,
+		AvoidBelow:    0.4,
+		MinSimilarity: 0.2,
+	}
+}
+
+// Entry is a past session included in a briefing.
+type Entry struct {
+	SessionID  string   `json:"session_id"`
+	TaskPrompt string   `json:"task_prompt"`
+	Summary    string   `json:"summary,omitempty"`
+	Notes      string   `json:"notes,omitempty"`
+	Score      *float64 `json:"score,omitempty"`
+	Similarity float64  `json:"similarity"`
+}
+
+// Briefing lists similar past sessions to follow and one to avoid.
+type Briefing struct {
+	Similar []Entry `json:"similar,omitempty"`
+	Avoid   *Entry  `json:"avoid,omitempty"`
+	// Tokens is the estimated size of the entries.
+	Tokens int `json:"tokens"`
+}
+
+// IsEmpty reports whether the briefing has no sessions.
+func (b *Briefing) IsEmpty() bool {
+	return len(b.Similar) == 0 && b.Avoid == nil
+}
+
+// Build assembles a briefing for a task. Similar high-scoring sessions are
+// recommended and the most similar low-scoring one is flagged to avoid.
+// When the token budget is tight, the best match is kept first, then the
+// session to avoid, then the remaining matches; entries that don't fit are
+// dropped.
+func Build(st store.Store, taskPrompt string, tags []string, opts Options) (*Briefing, error) {
+	text := strings.Join(append([]string{taskPrompt}, tags...), "\n")
+
+	good, err := st.SimilarSessions(text, store.Query{MinScore: &opts.MinScore, Limit: opts.MaxSessions})
+	if err != nil {
+		return nil, err
+	}
+	bad, err := st.SimilarSessions(text, store.Query{MaxScore: &opts.AvoidBelow, Limit: 5})
+	if err != nil {
+		return nil, err
+	}
+
+	var similar []Entry
+	for _, r := range good {
+		if r.Similarity < opts.MinSimilarity {
+			continue
+		}
+		similar = append(similar, newEntry(st, r))
+	}
+	var avoid *Entry
+	for _, r := range bad {
+		// MaxScore is inclusive; AvoidBelow isn't
+		if r.Similarity >= opts.MinSimilarity && *r.Score < opts.AvoidBelow {
+			e := newEntry(st, r)
+			avoid = &e
+			break
+		}
+	}
+
+	// Order by priority for the token budget
+	var ordered []*Entry
+	for i := range similar {
+		if i == 1 && avoid != nil {
+			ordered = append(ordered, avoid)
+		}
+		ordered = append(ordered, &similar[i])
+	}
+	if avoid != nil && len(similar) <= 1 {
+		ordered = append(ordered, avoid)
+	}
+
+	b := &Briefing{}
+	for _, e := range ordered {
+		tokens := EstimateTokens(e)
+		if opts.MaxTokens > 0 && b.Tokens+tokens > opts.MaxTokens {
+			continue
+		}
+		b.Tokens += tokens
+		if e == avoid {
+			b.Avoid = avoid
+		} else {
+			b.Similar = append(b.Similar, *e)
+		}
+	}
+	return b, nil
+}
+
+// newEntry converts a search result, adding the user's outcome notes.
+func newEntry(st store.Store, r store.SimilarResult) Entry {
+	e := Entry{
+		SessionID:  r.ID,
+		TaskPrompt: r.TaskPrompt,
+		Summary:    r.Summary,
+		Score:      r.Score,
+		Similarity: r.Similarity,
+	}
+	if session, err := st.GetSessionHeader(r.ID); err == nil && session.Outcome != nil {
+		e.Notes = session.Outcome.Notes
+	}
+	return e
+}
+
+// EstimateTokens approximates the tokens an entry adds to the tool output,
+// at roughly four bytes of JSON per token.
+func EstimateTokens(e *Entry) int {
+	data, _ := json.Marshal(e)
+	return (len(data) + 3) / 4
+}
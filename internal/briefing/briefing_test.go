@@ -0,0 +1,102 @@
+package briefing
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/store"
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+func setupStore(t *testing.T, sessions ...*types.Session) *store.BoltStore {
+	t.Helper()
+	st, err := store.NewBoltStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	for _, s := range sessions {
+		if err := st.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+	}
+	return st
+}
+
+func scored(prompt string, score float64) *types.Session {
+	return &types.Session{
+		ID:         store.NewULID(),
+		TaskPrompt: prompt,
+		Status:     types.StatusScored,
+		StartedAt:  time.Now(),
+		Outcome:    &types.Outcome{Score: score},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	good1 := scored("Migrate the config loader to YAML", 0.9)
+	good2 := scored("Migrate config loading to YAML files", 0.8)
+	bad := scored("Migrate the config loader to YAML format", 0.1)
+	middling := scored("Migrate the config loader to TOML", 0.5)
+	unrelated := scored("Draw a chart of weekly signups", 0.95)
+	st := setupStore(t, good1, good2, bad, middling, unrelated)
+
+	b, err := Build(st, "Migrate the config loader to YAML", []string{"config"}, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	if len(b.Similar) != 2 || b.Similar[0].SessionID != good1.ID {
+		t.Errorf("expected the two high-scoring matches, best first, got %+v", b.Similar)
+	}
+	for _, e := range b.Similar {
+		if e.SessionID == unrelated.ID || e.SessionID == middling.ID {
+			t.Errorf("unexpected session in briefing: %s", e.TaskPrompt)
+		}
+	}
+	if b.Avoid == nil || b.Avoid.SessionID != bad.ID {
+		t.Errorf("expected the low-scoring match to be flagged, got %+v", b.Avoid)
+	}
+}
+
+func TestBuild_TokenBudget(t *testing.T) {
+	good1 := scored("Speed up the image resize worker", 0.9)
+	good2 := scored("Speed up the image resize worker pool", 0.8)
+	bad := scored("Speed up image resize worker", 0.1)
+	st := setupStore(t, good1, good2, bad)
+
+	full, err := Build(st, "Speed up the image resize worker", nil, Options{MaxSessions: 3, MinScore: 0.7, AvoidBelow: 0.4})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if len(full.Similar) != 2 || full.Avoid == nil {
+		t.Fatalf("expected all three sessions without a budget, got %+v", full)
+	}
+
+	// Room for two entries: the best match, then the one to avoid
+	budget := EstimateTokens(&full.Similar[0]) + EstimateTokens(full.Avoid)
+	b, err := Build(st, "Speed up the image resize worker", nil, Options{MaxTokens: budget, MaxSessions: 3, MinScore: 0.7, AvoidBelow: 0.4})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if len(b.Similar) != 1 || b.Similar[0].SessionID != good1.ID || b.Avoid == nil {
+		t.Errorf("expected best match and session to avoid, got %+v", b)
+	}
+	if b.Tokens > budget {
+		t.Errorf("briefing uses %d tokens, over budget of %d", b.Tokens, budget)
+	}
+}
+
+func TestBuild_Empty(t *testing.T) {
+	st := setupStore(t, scored("Draw a chart of weekly signups", 0.95))
+
+	b, err := Build(st, "Rotate the TLS certificates", nil, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if !b.IsEmpty() {
+		t.Errorf("expected empty briefing for unrelated history, got %+v", b)
+	}
+}
@@ -3,16 +3,21 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 
 	"github.com/johncarpenter/trajectory-memory/internal/project"
 )
 
 // Config holds the application configuration.
 type Config struct {
-	ProjectRoot string
-	DBPath      string
-	SocketPath  string
-	DataDir     string
+	ProjectRoot         string
+	DBPath              string
+	SocketPath          string
+	DataDir             string
+	RedactionConfigPath string
+	RubricConfigPath    string
+	AnalyzerConfigPath  string
+	SpoolDir            string
 }
 
 // Load creates a Config from environment variables with defaults.
@@ -41,9 +46,31 @@ func Load() *Config {
 		cfg.DataDir = path
 	}
 
+	cfg.RedactionConfigPath = filepath.Join(cfg.DataDir, "redaction.json")
+	if path := os.Getenv("TM_REDACTION_CONFIG"); path != "" {
+		cfg.RedactionConfigPath = path
+	}
+
+	cfg.RubricConfigPath = filepath.Join(cfg.DataDir, "rubrics.json")
+	if path := os.Getenv("TM_RUBRIC_CONFIG"); path != "" {
+		cfg.RubricConfigPath = path
+	}
+
+	cfg.AnalyzerConfigPath = filepath.Join(cfg.DataDir, "analyzers.json")
+	if path := os.Getenv("TM_ANALYZER_CONFIG"); path != "" {
+		cfg.AnalyzerConfigPath = path
+	}
+
+	cfg.SpoolDir = filepath.Join(cfg.DataDir, "spool")
+
 	return cfg
 }
 
+// RubricFiles returns the markdown files searched for trajectory-rubric blocks.
+func (c *Config) RubricFiles() []string {
+	return []string{filepath.Join(c.ProjectRoot, "CLAUDE.md")}
+}
+
 // EnsureDataDir creates the data directory if it doesn't exist.
 func (c *Config) EnsureDataDir() error {
 	return os.MkdirAll(c.DataDir, 0755)
@@ -0,0 +1,153 @@
+// Package embed turns text into vectors for similarity search.
+package embed
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Embedder maps text to a fixed-length vector. Texts with similar meaning
+// should map to vectors with high cosine similarity.
+type Embedder interface {
+	// Name identifies the model and its settings. Vectors from embedders
+	// with different names are not comparable.
+	Name() string
+	// Embed returns the vector for a text.
+	Embed(text string) ([]float32, error)
+}
+
+// DefaultDimensions is the vector length used by Default.
+const DefaultDimensions = 512
+
+// Default returns the built-in offline embedder.
+func Default() Embedder {
+	return NewHashing(DefaultDimensions)
+}
+
+// Feature weights for the hashing embedder. Whole words carry most of the
+// meaning; character trigrams let "pooling" and "pool" partially match, and
+// word pairs reward shared phrasing.
+const (
+	weightWord    = 1.0
+	weightBigram  = 0.5
+	weightTrigram = 0.25
+)
+
+// Hashing embeds text offline by hashing words, word pairs and character
+// trigrams into a fixed number of dimensions (the "hashing trick"). Counts
+// are dampened logarithmically and the vector is L2-normalized, so cosine
+// similarity reduces to a dot product.
+type Hashing struct {
+	dims int
+}
+
+// NewHashing creates a hashing embedder producing vectors of length dims.
+func NewHashing(dims int) *Hashing {
+	if dims <= 0 {
+		dims = DefaultDimensions
+	}
+	return &Hashing{dims: dims}
+}
+
+// Name implements Embedder.
+func (h *Hashing) Name() string {
+	return fmt.Sprintf("hashing-v1-%d", h.dims)
+}
+
+// Embed implements Embedder.
+func (h *Hashing) Embed(text string) ([]float32, error) {
+	features := make(map[string]float64)
+	words := Tokenize(text)
+	for i, w := range words {
+		features["w:"+w] += weightWord
+		if i > 0 {
+			features["b:"+words[i-1]+" "+w] += weightBigram
+		}
+		padded := "^" + w + "$"
+		for j := 0; j+3 <= len(padded); j++ {
+			features["c:"+padded[j:j+3]] += weightTrigram
+		}
+	}
+
+	vec := make([]float32, h.dims)
+	for feature, weight := range features {
+		hash := fnv.New64a()
+		hash.Write([]byte(feature))
+		sum := hash.Sum64()
+
+		// The top bit picks a sign so collisions tend to cancel out
+		value := 1 + math.Log(weight)
+		if weight < 1 {
+			value = weight
+		}
+		if sum>>63 == 1 {
+			value = -value
+		}
+		vec[sum%uint64(h.dims)] += float32(value)
+	}
+
+	normalize(vec)
+	return vec, nil
+}
+
+// stopWords carry little meaning and are left out of embeddings and the
+// search index.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "with": true,
+}
+
+// Tokenize lowercases text and splits it into words, dropping stop words
+// and single characters. It is the one tokenizer shared by embeddings, the
+// search index and the analyzer.
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	filtered := words[:0]
+	for _, w := range words {
+		if len(w) < 2 || stopWords[w] {
+			continue
+		}
+		filtered = append(filtered, w)
+	}
+	return filtered
+}
+
+// Cosine returns the cosine similarity of two vectors, or 0 if their
+// lengths differ or either is all zeros.
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func normalize(vec []float32) {
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return
+	}
+	norm = math.Sqrt(norm)
+	for i := range vec {
+		vec[i] = float32(float64(vec[i]) / norm)
+	}
+}
@@ -0,0 +1,74 @@
+package embed
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHashing_SimilarTextsScoreHigher(t *testing.T) {
+	e := Default()
+
+	embed := func(text string) []float32 {
+		t.Helper()
+		vec, err := e.Embed(text)
+		if err != nil {
+			t.Fatalf("Embed failed: %v", err)
+		}
+		return vec
+	}
+
+	query := embed("Add connection pooling to the database client")
+	related := embed("Pool database connections in the client")
+	unrelated := embed("Update README badges and fix typos")
+
+	if Cosine(query, related) <= Cosine(query, unrelated) {
+		t.Errorf("expected related text to be more similar: related=%f unrelated=%f",
+			Cosine(query, related), Cosine(query, unrelated))
+	}
+	if sim := Cosine(query, query); math.Abs(sim-1) > 1e-6 {
+		t.Errorf("expected self-similarity of 1, got %f", sim)
+	}
+}
+
+func TestHashing_Deterministic(t *testing.T) {
+	a, _ := NewHashing(64).Embed("refactor the parser")
+	b, _ := NewHashing(64).Embed("refactor the parser")
+	if len(a) != 64 {
+		t.Fatalf("expected 64 dimensions, got %d", len(a))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatal("expected identical vectors for identical text")
+		}
+	}
+}
+
+func TestHashing_Name(t *testing.T) {
+	if NewHashing(64).Name() == NewHashing(128).Name() {
+		t.Error("embedders with different dimensions should have different names")
+	}
+}
+
+func TestCosine_Degenerate(t *testing.T) {
+	empty, _ := Default().Embed("")
+	other, _ := Default().Embed("something")
+	if Cosine(empty, other) != 0 {
+		t.Error("expected 0 similarity for an empty vector")
+	}
+	if Cosine([]float32{1}, []float32{1, 0}) != 0 {
+		t.Error("expected 0 similarity for mismatched lengths")
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Fix the DB-connection bug in a_b")
+	want := []string{"fix", "db", "connection", "bug"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package gitscore
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotRepository is returned when a directory isn't inside a git work tree.
+var ErrNotRepository = errors.New("not a git repository")
+
+// commit is a commit that touched files a session wrote.
+type commit struct {
+	Hash  string
+	Time  time.Time
+	Files []string
+}
+
+// repo runs the git CLI against a work tree.
+type repo struct {
+	root string
+}
+
+// openRepo finds the work tree containing dir.
+func openRepo(dir string) (*repo, error) {
+	if dir == "" {
+		return nil, ErrNotRepository
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotRepository, dir)
+	}
+	return &repo{root: strings.TrimSpace(string(out))}, nil
+}
+
+func (r *repo) git(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", r.root}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// gitDate formats a time the way git's --since and --before parse reliably.
+func gitDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05 -0700")
+}
+
+// commits lists commits on HEAD between since and until that touched any
+// of paths, oldest first.
+func (r *repo) commits(since, until time.Time, paths []string) ([]commit, error) {
+	args := []string{"log", "--reverse", "--no-renames", "--name-only",
+		"--format=%x1e%H %ct", "--since=" + gitDate(since), "--until=" + gitDate(until), "--"}
+	out, err := r.git(append(args, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []commit
+	for _, record := range strings.Split(out, "\x1e") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		fields := strings.Fields(lines[0])
+		if len(fields) != 2 {
+			continue
+		}
+		ts, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		c := commit{Hash: fields[0], Time: time.Unix(ts, 0)}
+		for _, line := range lines[1:] {
+			if line = strings.TrimSpace(line); line != "" {
+				c.Files = append(c.Files, line)
+			}
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// reverts returns the hashes among commits that a commit on HEAD between
+// since and until reverted, going by the "This reverts commit" line git
+// revert writes.
+func (r *repo) reverts(since, until time.Time, commits []commit) (map[string]bool, error) {
+	out, err := r.git("log", "--format=%B", "--grep=This reverts commit",
+		"--since="+gitDate(since), "--until="+gitDate(until))
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := make(map[string]bool)
+	for _, c := range commits {
+		if strings.Contains(out, "This reverts commit "+c.Hash) {
+			reverted[c.Hash] = true
+		}
+	}
+	return reverted, nil
+}
+
+// revisionAt returns the last commit on HEAD made at or before t.
+func (r *repo) revisionAt(t time.Time) (string, error) {
+	out, err := r.git("rev-list", "-1", "--before="+gitDate(t), "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// linesAdded counts the lines a commit added to paths.
+func (r *repo) linesAdded(hash string, paths []string) (int, error) {
+	out, err := r.git(append([]string{"show", "--numstat", "--format=", hash, "--"}, paths...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		// Binary files report "-"
+		if n, err := strconv.Atoi(fields[0]); err == nil {
+			added += n
+		}
+	}
+	return added, nil
+}
+
+// linesFrom counts the lines of path at rev that git blame attributes to
+// any of hashes. A path missing at rev has none.
+func (r *repo) linesFrom(rev, path string, hashes map[string]bool) int {
+	out, err := r.git("blame", "--porcelain", rev, "--", path)
+	if err != nil {
+		return 0
+	}
+	return countBlamed(out, hashes)
+}
+
+// countBlamed counts the lines in git blame --porcelain output attributed to
+// any of hashes.
+func countBlamed(porcelain string, hashes map[string]bool) int {
+	count := 0
+	scanner := bufio.NewScanner(strings.NewReader(porcelain))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// Header lines start with the commit hash followed by line numbers
+		hash, _, ok := strings.Cut(scanner.Text(), " ")
+		if ok && isObjectHash(hash) && hashes[hash] {
+			count++
+		}
+	}
+	return count
+}
+
+// isObjectHash reports whether s is a full SHA-1 or SHA-256 object name.
+func isObjectHash(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,214 @@
+// Package gitscore grades sessions by what happened to their changes in
+// git afterwards: whether the files they wrote were committed, whether the
+// commits were reverted, and how much of the added code survived.
+package gitscore
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/autoscore"
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+// ScorerName identifies outcomes produced by this package.
+const ScorerName = "git/v1"
+
+// ErrTooRecent is returned for sessions whose revert window hasn't closed.
+var ErrTooRecent = errors.New("session is too recent to judge from git history")
+
+// Options controls how history is matched to a session and judged.
+type Options struct {
+	// CommitWindow is how long after a session ends a commit touching its
+	// files still counts as committing its work.
+	CommitWindow time.Duration
+	// RevertWindow is how long after the session's first commit history is
+	// checked for reverts and rewrites.
+	RevertWindow time.Duration
+	// Now is the current time; zero means time.Now.
+	Now time.Time
+}
+
+// DefaultOptions returns the default options: commits within a day of the
+// session count, and history is judged a week after.
+func DefaultOptions() Options {
+	return Options{
+		CommitWindow: 24 * time.Hour,
+		RevertWindow: 7 * 24 * time.Hour,
+	}
+}
+
+// Signal weights. Survival carries the most weight, so committed code that
+// was reverted or rewritten soon after scores little above work that was
+// never committed.
+const (
+	weightCommitted   = 0.2
+	weightNotReverted = 0.3
+	weightSurvived    = 0.5
+)
+
+// Score grades a completed session from the history of the repository
+// containing its working directory. It returns nil without an error when the
+// session wrote no files in the repository.
+func Score(s *types.Session, opts Options) (*types.AutoOutcome, error) {
+	if s.CompletedAt == nil {
+		return nil, fmt.Errorf("session %s hasn't completed", s.ID)
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	r, err := openRepo(s.WorkingDir)
+	if err != nil {
+		return nil, err
+	}
+	paths := writtenPaths(s, r.root)
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	commitDeadline := s.CompletedAt.Add(opts.CommitWindow)
+	if now.Before(commitDeadline) {
+		return nil, ErrTooRecent
+	}
+	commits, err := r.commits(s.StartedAt, commitDeadline, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	committedFiles := make(map[string]bool)
+	hashes := make(map[string]bool)
+	for _, c := range commits {
+		hashes[c.Hash] = true
+		for _, f := range c.Files {
+			committedFiles[f] = true
+		}
+	}
+	committed := 0
+	for _, p := range paths {
+		if committedFiles[p] {
+			committed++
+		}
+	}
+
+	signals := []types.AutoSignal{{
+		Rule:   "committed",
+		Score:  float64(committed) / float64(len(paths)),
+		Weight: weightCommitted,
+		Detail: fmt.Sprintf("%d of %d written files committed in %s", committed, len(paths), shortHashes(commits)),
+	}}
+	if len(commits) == 0 {
+		signals[0].Detail = fmt.Sprintf("none of %d written files committed", len(paths))
+		return autoscore.Combine(ScorerName, signals), nil
+	}
+
+	// Judge the commits once the revert window has closed
+	windowEnd := commits[0].Time.Add(opts.RevertWindow)
+	if now.Before(windowEnd) {
+		return nil, ErrTooRecent
+	}
+
+	reverted, err := r.reverts(commits[0].Time, windowEnd, commits)
+	if err != nil {
+		return nil, err
+	}
+	revertSignal := types.AutoSignal{Rule: "not_reverted", Score: 1, Weight: weightNotReverted,
+		Detail: fmt.Sprintf("no commits reverted within %s", formatDays(opts.RevertWindow))}
+	if len(reverted) > 0 {
+		revertSignal.Score = 0
+		revertSignal.Detail = fmt.Sprintf("%d of %d commits reverted within %s", len(reverted), len(commits), formatDays(opts.RevertWindow))
+	}
+	signals = append(signals, revertSignal)
+
+	added := 0
+	for _, c := range commits {
+		n, err := r.linesAdded(c.Hash, paths)
+		if err != nil {
+			return nil, err
+		}
+		added += n
+	}
+	if added > 0 {
+		rev, err := r.revisionAt(windowEnd)
+		if err != nil {
+			return nil, err
+		}
+		surviving := 0
+		for f := range committedFiles {
+			surviving += r.linesFrom(rev, f, hashes)
+		}
+		survival := float64(surviving) / float64(added)
+		signals = append(signals, types.AutoSignal{
+			Rule:   "survived",
+			Score:  survival,
+			Weight: weightSurvived,
+			Detail: fmt.Sprintf("%d of %d added lines unchanged after %s", surviving, added, formatDays(opts.RevertWindow)),
+		})
+	}
+
+	return autoscore.Combine(ScorerName, signals), nil
+}
+
+// writtenPaths returns the repository-relative paths of files the session
+// wrote successfully, skipping files outside the repository.
+func writtenPaths(s *types.Session, root string) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, step := range s.Steps {
+		switch step.ToolName {
+		case "Write", "Edit", "MultiEdit", "NotebookEdit":
+		default:
+			continue
+		}
+		if step.IsFailed() || step.IsIncomplete() || step.InputSummary == "" {
+			continue
+		}
+
+		path := step.InputSummary
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(s.WorkingDir, path)
+		}
+		rel, err := filepath.Rel(root, resolve(path))
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if !seen[rel] {
+			seen[rel] = true
+			paths = append(paths, rel)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// resolve follows symlinks in the directory part of a path so it can be
+// compared with the repository root git reports. The file itself may no
+// longer exist.
+func resolve(path string) string {
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		return filepath.Join(dir, filepath.Base(path))
+	}
+	return path
+}
+
+func shortHashes(commits []commit) string {
+	var hashes []string
+	for _, c := range commits {
+		hashes = append(hashes, c.Hash[:7])
+	}
+	return strings.Join(hashes, ", ")
+}
+
+func formatDays(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
@@ -0,0 +1,204 @@
+package gitscore
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+// testRepo is a scratch repository with commits at chosen times.
+type testRepo struct {
+	t   *testing.T
+	dir string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &testRepo{t: t, dir: dir}
+	r.git(time.Now(), "init", "-q")
+	r.git(time.Now(), "config", "user.email", "dev@example.com")
+	r.git(time.Now(), "config", "user.name", "Dev")
+	return r
+}
+
+func (r *testRepo) git(at time.Time, args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", r.dir}, args...)...)
+	date := at.Format(time.RFC3339)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (r *testRepo) write(name string, lines ...string) string {
+	r.t.Helper()
+	path := filepath.Join(r.dir, name)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+	return path
+}
+
+func (r *testRepo) commit(at time.Time, message string) string {
+	r.t.Helper()
+	r.git(at, "add", "-A")
+	r.git(at, "commit", "-q", "-m", message)
+	return r.git(at, "rev-parse", "HEAD")
+}
+
+func numbered(prefix string, n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("%s %d", prefix, i)
+	}
+	return lines
+}
+
+func TestScore(t *testing.T) {
+	start := time.Now().Add(-60 * 24 * time.Hour).Truncate(time.Second)
+	end := start.Add(time.Hour)
+	opts := DefaultOptions()
+	opts.Now = start.Add(30 * 24 * time.Hour)
+
+	tests := []struct {
+		name  string
+		after func(r *testRepo, hash string)
+		want  float64
+	}{
+		{"kept", func(r *testRepo, hash string) {}, 1},
+		{"reverted", func(r *testRepo, hash string) {
+			r.git(start.Add(48*time.Hour), "revert", "--no-edit", hash)
+		}, 0.2},
+		{"rewritten", func(r *testRepo, hash string) {
+			lines := numbered("rewritten", 10)
+			copy(lines[8:], numbered("line", 10)[8:])
+			r.write("cache.go", lines...)
+			r.commit(start.Add(72*time.Hour), "Rewrite cache")
+		}, 0.2 + 0.3 + 0.5*0.2},
+		{"rewritten after window", func(r *testRepo, hash string) {
+			r.write("cache.go", numbered("rewritten", 10)...)
+			r.commit(start.Add(20*24*time.Hour), "Rewrite cache")
+		}, 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			r.write("README.md", "# Project")
+			r.commit(start.Add(-24*time.Hour), "Initial commit")
+
+			cache := r.write("cache.go", numbered("line", 10)...)
+			hash := r.commit(start.Add(30*time.Minute), "Add cache")
+			tc.after(r, hash)
+
+			session := &types.Session{
+				ID:          "s1",
+				WorkingDir:  r.dir,
+				StartedAt:   start,
+				CompletedAt: &end,
+				Steps: []types.TrajectoryStep{
+					{ToolName: "Read", InputSummary: filepath.Join(r.dir, "README.md"), Status: types.StepStatusCompleted},
+					{ToolName: "Write", InputSummary: cache, Status: types.StepStatusCompleted},
+					{ToolName: "Edit", InputSummary: "/elsewhere/notes.md", Status: types.StepStatusCompleted},
+				},
+			}
+
+			outcome, err := Score(session, opts)
+			if err != nil {
+				t.Fatalf("Score failed: %v", err)
+			}
+			if outcome == nil || outcome.Scorer != ScorerName {
+				t.Fatalf("expected a %s outcome, got %+v", ScorerName, outcome)
+			}
+			if math.Abs(outcome.Score-tc.want) > 1e-9 {
+				t.Errorf("expected %.2f, got %.2f: %+v", tc.want, outcome.Score, outcome.Signals)
+			}
+		})
+	}
+}
+
+func TestScore_NotCommitted(t *testing.T) {
+	r := newTestRepo(t)
+	r.write("README.md", "# Project")
+	start := time.Now().Add(-10 * 24 * time.Hour)
+	r.commit(start.Add(-time.Hour), "Initial commit")
+
+	end := start.Add(time.Hour)
+	session := &types.Session{
+		ID:          "s1",
+		WorkingDir:  r.dir,
+		StartedAt:   start,
+		CompletedAt: &end,
+		Steps: []types.TrajectoryStep{
+			{ToolName: "Write", InputSummary: r.write("scratch.go", "package main"), Status: types.StepStatusCompleted},
+		},
+	}
+
+	outcome, err := Score(session, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Score failed: %v", err)
+	}
+	if outcome == nil || outcome.Score != 0 || len(outcome.Signals) != 1 {
+		t.Errorf("expected uncommitted work to score 0, got %+v", outcome)
+	}
+
+	// Sessions still inside the commit window are left for later
+	opts := DefaultOptions()
+	opts.Now = end.Add(time.Hour)
+	if _, err := Score(session, opts); !errors.Is(err, ErrTooRecent) {
+		t.Errorf("expected ErrTooRecent, got %v", err)
+	}
+
+	// Sessions that wrote nothing have no git outcome
+	session.Steps = nil
+	if outcome, err := Score(session, DefaultOptions()); outcome != nil || err != nil {
+		t.Errorf("expected no outcome, got %+v, %v", outcome, err)
+	}
+
+	session.WorkingDir = t.TempDir()
+	if _, err := Score(session, DefaultOptions()); !errors.Is(err, ErrNotRepository) {
+		t.Errorf("expected ErrNotRepository, got %v", err)
+	}
+}
+
+func TestCountBlamed(t *testing.T) {
+	sha1 := strings.Repeat("a1", 20)
+	sha256 := strings.Repeat("b2", 32)
+	other := strings.Repeat("c3", 32)
+	porcelain := func(hashes ...string) string {
+		var buf strings.Builder
+		for i, hash := range hashes {
+			fmt.Fprintf(&buf, "%s %d %d 1\nauthor Dev\nfilename cache.go\n\tline %d\n", hash, i+1, i+1, i)
+		}
+		return buf.String()
+	}
+
+	if got := countBlamed(porcelain(sha1, sha1, strings.Repeat("d4", 20)), map[string]bool{sha1: true}); got != 2 {
+		t.Errorf("expected 2 lines from the SHA-1 commit, got %d", got)
+	}
+	// Repositories using --object-format=sha256 have 64-character hashes
+	if got := countBlamed(porcelain(sha256, other, sha256, sha256), map[string]bool{sha256: true}); got != 3 {
+		t.Errorf("expected 3 lines from the SHA-256 commit, got %d", got)
+	}
+	// Content lines that happen to start with a hash aren't headers
+	if got := countBlamed("\t"+sha256+" 1 1 1\n", map[string]bool{sha256: true}); got != 0 {
+		t.Errorf("expected no header lines, got %d", got)
+	}
+}
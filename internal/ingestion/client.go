@@ -0,0 +1,61 @@
+package ingestion
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultForwardTimeout bounds how long a hook waits on the ingestion socket,
+// so a stalled server never blocks Claude Code.
+const DefaultForwardTimeout = time.Second
+
+// Forward delivers a hook payload to the ingestion socket. If the server
+// can't be reached within timeout, the payload is appended to the spool in
+// spoolDir for replay once the server is back.
+func Forward(socketPath, spoolDir string, payload []byte, timeout time.Duration) error {
+	if len(bytes.TrimSpace(payload)) == 0 {
+		return nil
+	}
+
+	if err := post(socketPath, payload, timeout); err == nil {
+		return nil
+	}
+
+	if spoolDir == "" {
+		return fmt.Errorf("ingestion server unavailable and no spool directory configured")
+	}
+	return AppendSpool(spoolDir, payload, time.Now())
+}
+
+// post sends a payload to the /step endpoint. A response other than 2xx is
+// an error, so the payload is spooled rather than lost, except for 404: the
+// server is up but nothing is recording, and replay would drop it anyway.
+func post(socketPath string, payload []byte, timeout time.Duration) error {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+		Timeout: timeout,
+	}
+
+	resp, err := client.Post("http://localhost/step", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("ingestion server returned %s", resp.Status)
+	}
+	return nil
+}
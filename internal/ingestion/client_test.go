@@ -0,0 +1,114 @@
+package ingestion
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/store"
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+func TestForwardDelivers(t *testing.T) {
+	server, s, socketPath, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	session := &types.Session{
+		ID:         store.NewULID(),
+		TaskPrompt: "Test task",
+		Status:     types.StatusRecording,
+		StartedAt:  time.Now(),
+	}
+	s.CreateSession(session)
+	s.SetActiveSession(session.ID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+
+	spoolDir := filepath.Join(t.TempDir(), "spool")
+	payload := []byte(`{"tool_name": "Read", "tool_input": {"file_path": "main.go"}}`)
+	if err := Forward(socketPath, spoolDir, payload, DefaultForwardTimeout); err != nil {
+		t.Fatalf("Forward failed: %v", err)
+	}
+
+	updated, _ := s.GetSession(session.ID)
+	if len(updated.Steps) != 1 {
+		t.Errorf("expected 1 step, got %d", len(updated.Steps))
+	}
+	if _, err := os.Stat(spoolDir); !os.IsNotExist(err) {
+		t.Error("delivered payload should not be spooled")
+	}
+}
+
+func TestForwardSpoolsWhenUnavailable(t *testing.T) {
+	tmpDir := t.TempDir()
+	socketPath := filepath.Join(tmpDir, "missing.sock")
+	spoolDir := filepath.Join(tmpDir, "spool")
+
+	payload := []byte("{\n  \"tool_name\": \"Bash\",\n  \"tool_use_id\": \"toolu_1\"\n}")
+	if err := Forward(socketPath, spoolDir, payload, 100*time.Millisecond); err != nil {
+		t.Fatalf("Forward failed: %v", err)
+	}
+	if err := Forward(socketPath, spoolDir, []byte(`{"tool_name": "Read"}`), 100*time.Millisecond); err != nil {
+		t.Fatalf("Forward failed: %v", err)
+	}
+
+	entries, err := readSpoolFile(filepath.Join(spoolDir, SpoolFileName))
+	if err != nil {
+		t.Fatalf("failed to read spool: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 spooled entries, got %d", len(entries))
+	}
+	if string(entries[0].Payload) != `{"tool_name":"Bash","tool_use_id":"toolu_1"}` {
+		t.Errorf("expected compacted payload, got %s", entries[0].Payload)
+	}
+
+	if err := Forward(socketPath, spoolDir, []byte("not json"), 100*time.Millisecond); err == nil {
+		t.Error("expected error spooling invalid JSON")
+	}
+	if err := Forward(socketPath, spoolDir, []byte("  "), 100*time.Millisecond); err != nil {
+		t.Errorf("empty payload should be ignored, got %v", err)
+	}
+}
+
+func TestForwardSpoolsOnServerError(t *testing.T) {
+	tmpDir := t.TempDir()
+	socketPath := filepath.Join(tmpDir, "failing.sock")
+	spoolDir := filepath.Join(tmpDir, "spool")
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	status := http.StatusInternalServerError
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "failed to record step", status)
+	})}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	if err := Forward(socketPath, spoolDir, []byte(`{"tool_name": "Read"}`), DefaultForwardTimeout); err != nil {
+		t.Fatalf("Forward failed: %v", err)
+	}
+	entries, err := readSpoolFile(filepath.Join(spoolDir, SpoolFileName))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected the rejected payload to be spooled, got %d entries: %v", len(entries), err)
+	}
+
+	// Nothing recording is a final answer, not a failure
+	status = http.StatusNotFound
+	if err := Forward(socketPath, spoolDir, []byte(`{"tool_name": "Read"}`), DefaultForwardTimeout); err != nil {
+		t.Fatalf("Forward failed: %v", err)
+	}
+	if entries, _ := readSpoolFile(filepath.Join(spoolDir, SpoolFileName)); len(entries) != 1 {
+		t.Errorf("expected no spooling without an active session, got %d entries", len(entries))
+	}
+}
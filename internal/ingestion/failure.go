@@ -0,0 +1,95 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// exitCodePattern matches a line reporting a command's exit status.
+var exitCodePattern = regexp.MustCompile(`(?i)^(?:error: *)?exit (?:code|status):? *(-?\d+)\b`)
+
+// detectFailure reports whether a PostToolUse payload describes a failed tool call
+// and, when known, the command's exit code.
+func detectFailure(payload HookPayload) (bool, int) {
+	return DetectFailure(payload.ToolName, payload.IsError, payload.toolOutput())
+}
+
+// DetectFailure reports whether a tool call failed and, when known, the
+// command's exit code. It checks the isError flag, error fields and exit
+// codes in the tool's output, and the exit status a Bash call reports on the
+// last line of its stderr or output. Stdout is never searched, since
+// commands print "exit code" text of their own.
+func DetectFailure(toolName string, isError bool, output json.RawMessage) (bool, int) {
+	if isError {
+		return true, 0
+	}
+
+	if len(output) == 0 {
+		return false, 0
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(output, &obj); err == nil {
+		if isErr, ok := obj["is_error"].(bool); ok && isErr {
+			return true, exitCodeField(obj)
+		}
+		if msg, ok := obj["error"].(string); ok && msg != "" {
+			if code := exitCodeField(obj); code != 0 {
+				return true, code
+			}
+			return true, exitCodeFromText(msg)
+		}
+		if code := exitCodeField(obj); code != 0 {
+			return true, code
+		}
+		if toolName != "Bash" {
+			return false, 0
+		}
+		// Bash responses carry output in stdout/stderr
+		if text, ok := obj["stderr"].(string); ok {
+			if code := exitCodeFromText(text); code != 0 {
+				return true, code
+			}
+		}
+		return false, 0
+	}
+
+	if toolName != "Bash" {
+		return false, 0
+	}
+	var text string
+	if err := json.Unmarshal(output, &text); err != nil {
+		text = string(output)
+	}
+	if code := exitCodeFromText(text); code != 0 {
+		return true, code
+	}
+	return false, 0
+}
+
+// exitCodeField extracts a numeric exit code from a tool response object.
+func exitCodeField(obj map[string]interface{}) int {
+	for _, key := range []string{"exit_code", "exitCode", "returncode"} {
+		if code, ok := obj[key].(float64); ok {
+			return int(code)
+		}
+	}
+	return 0
+}
+
+// exitCodeFromText reads the exit status reported on the last non-empty line
+// of text, where the shell and Claude Code report it.
+func exitCodeFromText(text string) int {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	match := exitCodePattern.FindStringSubmatch(strings.TrimSpace(lines[len(lines)-1]))
+	if match == nil {
+		return 0
+	}
+	code, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0
+	}
+	return code
+}
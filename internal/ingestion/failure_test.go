@@ -0,0 +1,102 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDetectFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  HookPayload
+		failed   bool
+		exitCode int
+	}{
+		{
+			name:    "is_error flag",
+			payload: HookPayload{ToolName: "Edit", IsError: true},
+			failed:  true,
+		},
+		{
+			name:    "is_error in response",
+			payload: HookPayload{ToolName: "Read", ToolResponse: json.RawMessage(`{"is_error": true, "content": "File does not exist"}`)},
+			failed:  true,
+		},
+		{
+			name:    "error field",
+			payload: HookPayload{ToolName: "WebFetch", ToolOutput: json.RawMessage(`{"error": "404 Not Found"}`)},
+			failed:  true,
+		},
+		{
+			name:     "exit code field",
+			payload:  HookPayload{ToolName: "Bash", ToolResponse: json.RawMessage(`{"stdout": "", "exit_code": 2}`)},
+			failed:   true,
+			exitCode: 2,
+		},
+		{
+			name:     "exit code in bash stderr",
+			payload:  HookPayload{ToolName: "Bash", ToolResponse: json.RawMessage(`{"stdout": "", "stderr": "FAIL\nExit code 1"}`)},
+			failed:   true,
+			exitCode: 1,
+		},
+		{
+			name:     "exit status in bash string output",
+			payload:  HookPayload{ToolName: "Bash", ToolOutput: json.RawMessage(`"make: *** [test] Error 2\nexit status 2"`)},
+			failed:   true,
+			exitCode: 2,
+		},
+		{
+			name:     "exit code in error field",
+			payload:  HookPayload{ToolName: "Bash", ToolResponse: json.RawMessage(`{"error": "Command failed\nExit code 127"}`)},
+			failed:   true,
+			exitCode: 127,
+		},
+		{
+			name:    "exit text in bash stdout",
+			payload: HookPayload{ToolName: "Bash", ToolResponse: json.RawMessage(`{"stdout": "grep: exit code 1 means no match", "stderr": ""}`)},
+			failed:  false,
+		},
+		{
+			name:    "exit text before the last stderr line",
+			payload: HookPayload{ToolName: "Bash", ToolResponse: json.RawMessage(`{"stdout": "", "stderr": "retrying after exit status 3\nwarning: slow network"}`)},
+			failed:  false,
+		},
+		{
+			name:    "exit text mid-line in bash string output",
+			payload: HookPayload{ToolName: "Bash", ToolOutput: json.RawMessage(`"the tests print exit code 1 on failure"`)},
+			failed:  false,
+		},
+		{
+			name:    "zero exit code",
+			payload: HookPayload{ToolName: "Bash", ToolOutput: json.RawMessage(`"ok\nexit status 0"`)},
+			failed:  false,
+		},
+		{
+			name:    "exit text in non-bash output",
+			payload: HookPayload{ToolName: "Read", ToolOutput: json.RawMessage(`"docs mention exit code 1"`)},
+			failed:  false,
+		},
+		{
+			name:    "successful bash",
+			payload: HookPayload{ToolName: "Bash", ToolResponse: json.RawMessage(`{"stdout": "PASS", "stderr": ""}`)},
+			failed:  false,
+		},
+		{
+			name:    "no output",
+			payload: HookPayload{ToolName: "Bash"},
+			failed:  false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			failed, exitCode := detectFailure(tc.payload)
+			if failed != tc.failed {
+				t.Errorf("expected failed=%v, got %v", tc.failed, failed)
+			}
+			if exitCode != tc.exitCode {
+				t.Errorf("expected exit code %d, got %d", tc.exitCode, exitCode)
+			}
+		})
+	}
+}
@@ -14,37 +14,135 @@ import (
 	"sync"
 	"time"
 
+	"github.com/johncarpenter/trajectory-memory/internal/redact"
 	"github.com/johncarpenter/trajectory-memory/internal/store"
 	"github.com/johncarpenter/trajectory-memory/internal/types"
 )
 
-// HookPayload represents the Claude Code PostToolUse hook payload.
+// HookPayload represents a Claude Code PreToolUse or PostToolUse hook payload.
 type HookPayload struct {
-	SessionID  string          `json:"session_id"`
-	ToolName   string          `json:"tool_name"`
-	ToolInput  json.RawMessage `json:"tool_input"`
-	ToolOutput json.RawMessage `json:"tool_output"`
-	DurationMs int64           `json:"duration_ms"`
+	SessionID     string          `json:"session_id"`
+	HookEventName string          `json:"hook_event_name"`
+	ToolName      string          `json:"tool_name"`
+	ToolUseID     string          `json:"tool_use_id"`
+	ToolInput     json.RawMessage `json:"tool_input"`
+	ToolOutput    json.RawMessage `json:"tool_output"`
+	ToolResponse  json.RawMessage `json:"tool_response"`
+	IsError       bool            `json:"is_error"`
+	DurationMs    int64           `json:"duration_ms"`
 }
 
+// toolOutput returns the tool's output, preferring tool_output and falling
+// back to the tool_response field that Claude Code sends.
+func (p HookPayload) toolOutput() json.RawMessage {
+	if len(p.ToolOutput) > 0 {
+		return p.ToolOutput
+	}
+	return p.ToolResponse
+}
+
+// mcpServerName is the name this binary's MCP server is registered under.
+const mcpServerName = "trajectory-memory"
+
+// IsOwnTool reports whether a tool is one of trajectory-memory's MCP tools, named
+// mcp__<server>__<tool>. Plugins prefix the server name, so it is matched
+// within the server segment.
+func IsOwnTool(toolName string) bool {
+	parts := strings.Split(toolName, "__")
+	if len(parts) < 3 || parts[0] != "mcp" {
+		return false
+	}
+	return strings.Contains(parts[len(parts)-2], mcpServerName)
+}
+
+// contentBlock is a content block of an MCP tool result.
+type contentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// startedSessionID returns the recording ID from trajectory_start's result,
+// or "" if the call failed. Claude Code reports an MCP tool's result as its
+// content blocks, either bare or inside the result object; the first text
+// block holds the tool's JSON output.
+func startedSessionID(output json.RawMessage) string {
+	var blocks []contentBlock
+	if err := json.Unmarshal(output, &blocks); err != nil {
+		var result struct {
+			Content []contentBlock `json:"content"`
+			IsError bool           `json:"isError"`
+		}
+		if err := json.Unmarshal(output, &result); err != nil || result.IsError {
+			return ""
+		}
+		blocks = result.Content
+	}
+
+	for _, block := range blocks {
+		if block.Type != "text" {
+			continue
+		}
+		var started struct {
+			SessionID string `json:"session_id"`
+		}
+		if err := json.Unmarshal([]byte(block.Text), &started); err != nil {
+			return ""
+		}
+		return started.SessionID
+	}
+	return ""
+}
+
+// Hook event names sent by Claude Code.
+const (
+	EventPreToolUse  = "PreToolUse"
+	EventPostToolUse = "PostToolUse"
+)
+
 // Server handles incoming step events from hook scripts.
 type Server struct {
 	store      store.Store
 	socketPath string
+	redactor   *redact.Redactor
+	spoolDir   string
 	listener   net.Listener
 	server     *http.Server
+	handlers   map[string]http.Handler
 	mu         sync.RWMutex
+	replayMu   sync.Mutex
 	running    bool
 }
 
 // NewServer creates a new ingestion server.
+// Captured tool input and output are redacted with the built-in detectors
+// unless a different redactor is configured with SetRedactor.
 func NewServer(s store.Store, socketPath string) *Server {
 	return &Server{
 		store:      s,
 		socketPath: socketPath,
+		redactor:   redact.NewDefault(),
 	}
 }
 
+// SetRedactor replaces the redaction pipeline applied before steps are stored.
+// Passing nil disables redaction.
+func (s *Server) SetRedactor(r *redact.Redactor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.redactor = r
+}
+
+// Handle registers an additional handler on the socket, such as the MCP
+// relay. Handlers must be registered before Start.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handlers == nil {
+		s.handlers = make(map[string]http.Handler)
+	}
+	s.handlers[pattern] = handler
+}
+
 // Start begins listening on the Unix socket.
 func (s *Server) Start(ctx context.Context) error {
 	s.mu.Lock()
@@ -82,6 +180,9 @@ func (s *Server) Start(ctx context.Context) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/step", s.handleStep)
 	mux.HandleFunc("/health", s.handleHealth)
+	for pattern, handler := range s.handlers {
+		mux.Handle(pattern, handler)
+	}
 
 	s.listener = listener
 	s.server = &http.Server{Handler: mux}
@@ -101,6 +202,13 @@ func (s *Server) Start(ctx context.Context) error {
 		s.Stop()
 	}()
 
+	// Recover steps spooled by hooks while the server was down
+	if n, err := s.ReplaySpool(); err != nil {
+		log.Printf("failed to replay spool: %v", err)
+	} else if n > 0 {
+		log.Printf("replayed %d spooled steps", n)
+	}
+
 	return nil
 }
 
@@ -162,32 +270,51 @@ func (s *Server) handleStep(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get active session
-	session, err := s.store.GetActiveSession()
-	if err != nil {
+	if err := s.recordStep(payload, time.Now()); err != nil {
 		if err == store.ErrNoActiveSession {
 			http.Error(w, "no active session", http.StatusNotFound)
 			return
 		}
-		log.Printf("failed to get active session: %v", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		log.Printf("failed to record step: %v", err)
+		http.Error(w, "failed to record step", http.StatusInternalServerError)
 		return
 	}
 
-	// Create trajectory step
-	step := types.TrajectoryStep{
-		Timestamp:     time.Now(),
-		ToolName:      payload.ToolName,
-		InputSummary:  summarizeJSON(payload.ToolInput),
-		OutputSummary: summarizeJSON(payload.ToolOutput),
-		DurationMs:    payload.DurationMs,
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// recordStep routes a hook payload received at the given time to the
+// recording bound to its Claude session. Events already recorded for the
+// same tool use are ignored, so payloads can safely be delivered twice, as
+// are calls to this server's own MCP tools.
+func (s *Server) recordStep(payload HookPayload, at time.Time) error {
+	// Calls to trajectory-memory itself aren't part of the task. Recording
+	// them would leave trajectory_stop's own step unfinished when it stops
+	// the session.
+	if IsOwnTool(payload.ToolName) {
+		if payload.HookEventName == EventPostToolUse && strings.HasSuffix(payload.ToolName, "__trajectory_start") {
+			return s.bindRecording(payload)
+		}
+		return nil
 	}
 
-	// Append step to session
-	if err := s.store.AppendStep(session.ID, step); err != nil {
-		log.Printf("failed to append step: %v", err)
-		http.Error(w, "failed to append step", http.StatusInternalServerError)
-		return
+	session, err := s.resolveSession(payload.SessionID)
+	if err != nil {
+		return err
+	}
+
+	// Redact secrets before anything is truncated or stored
+	s.mu.RLock()
+	redactor := s.redactor
+	s.mu.RUnlock()
+
+	if payload.HookEventName == EventPreToolUse {
+		return s.startStep(session, redactor, payload, at)
+	}
+
+	if err := s.finishStep(session, redactor, payload, at); err != nil {
+		return err
 	}
 
 	// If Read tool targets .md file, update LoadedContext
@@ -201,13 +328,126 @@ func (s *Server) handleStep(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("ok"))
+	return nil
+}
+
+// startStep records a PreToolUse event as a started step.
+// Events without a tool use ID can't be paired, so they are left for PostToolUse to record.
+func (s *Server) startStep(session *types.Session, redactor *redact.Redactor, payload HookPayload, at time.Time) error {
+	if payload.ToolUseID == "" {
+		return nil
+	}
+	if _, err := s.store.FindStep(session.ID, payload.ToolUseID); err != store.ErrStepNotFound {
+		// Already recorded, or the lookup failed
+		return err
+	}
+
+	input, redactions := redactor.Redact(extractSummary(payload.ToolInput))
+	step := types.TrajectoryStep{
+		Timestamp:      at,
+		ToolName:       payload.ToolName,
+		ToolUseID:      payload.ToolUseID,
+		InputSummary:   types.TruncateString(input, types.MaxInputSummaryLen),
+		Status:         types.StepStatusStarted,
+		RedactionCount: redactions,
+	}
+	return s.store.AppendStep(session.ID, step)
+}
+
+// finishStep records a PostToolUse event. If a matching started step exists,
+// it is completed in place and its duration measured from the PreToolUse event;
+// otherwise a new step is appended. Steps whose tool reported an error are
+// recorded as failed.
+func (s *Server) finishStep(session *types.Session, redactor *redact.Redactor, payload HookPayload, at time.Time) error {
+	input, inputRedactions := redactor.Redact(extractSummary(payload.ToolInput))
+	output, outputRedactions := redactor.Redact(extractSummary(payload.toolOutput()))
+
+	status := types.StepStatusCompleted
+	failed, exitCode := detectFailure(payload)
+	if failed {
+		status = types.StepStatusFailed
+	}
+
+	existing, err := s.store.FindStep(session.ID, payload.ToolUseID)
+	if err != nil && err != store.ErrStepNotFound {
+		return err
+	}
+	if existing != nil && existing.Status != types.StepStatusStarted {
+		// Already finished; this is a duplicate delivery
+		return nil
+	}
+
+	if existing != nil {
+		step := *existing
+		step.InputSummary = types.TruncateString(input, types.MaxInputSummaryLen)
+		step.OutputSummary = types.TruncateString(output, types.MaxOutputSummaryLen)
+		step.DurationMs = payload.DurationMs
+		if step.DurationMs == 0 {
+			step.DurationMs = at.Sub(step.Timestamp).Milliseconds()
+		}
+		step.Status = status
+		step.ExitCode = exitCode
+		step.RedactionCount = inputRedactions + outputRedactions
+
+		err := s.store.UpdateStep(session.ID, step)
+		if err != store.ErrStepNotFound {
+			return err
+		}
+		// The step vanished between lookup and update; record it as a new step
+	}
+
+	step := types.TrajectoryStep{
+		Timestamp:      at,
+		ToolName:       payload.ToolName,
+		ToolUseID:      payload.ToolUseID,
+		InputSummary:   types.TruncateString(input, types.MaxInputSummaryLen),
+		OutputSummary:  types.TruncateString(output, types.MaxOutputSummaryLen),
+		DurationMs:     payload.DurationMs,
+		Status:         status,
+		ExitCode:       exitCode,
+		RedactionCount: inputRedactions + outputRedactions,
+	}
+	return s.store.AppendStep(session.ID, step)
+}
+
+// bindRecording binds the recording trajectory_start just created to the
+// Claude session whose hook reported the call. Until then the recording is
+// pending and receives no steps.
+func (s *Server) bindRecording(payload HookPayload) error {
+	sessionID := startedSessionID(payload.toolOutput())
+	if sessionID == "" {
+		// trajectory_start failed, so there is nothing to bind
+		return nil
+	}
+	return s.store.BindPendingSession(payload.SessionID, sessionID)
+}
+
+// resolveSession finds the recording that a hook payload belongs to: the one
+// bound to the payload's Claude session, or the default recording for
+// payloads without one. Events from other Claude sessions aren't recorded.
+// Steps are not loaded, so routing a hook event stays cheap in long sessions.
+func (s *Server) resolveSession(claudeSessionID string) (*types.Session, error) {
+	bindings, err := s.store.ListActiveSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, ok := bindings[claudeSessionID]
+	if !ok {
+		return nil, store.ErrNoActiveSession
+	}
+
+	session, err := s.store.GetSessionHeader(sessionID)
+	if err == store.ErrSessionNotFound {
+		return nil, store.ErrNoActiveSession
+	}
+	return session, err
 }
 
 // appendLoadedContext adds a file path to the session's LoadedContext.
 func (s *Server) appendLoadedContext(sessionID string, filePath string) error {
-	session, err := s.store.GetSession(sessionID)
+	// Steps aren't needed, and leaving them unloaded keeps them untouched on update
+	session, err := s.store.GetSessionHeader(sessionID)
 	if err != nil {
 		return err
 	}
@@ -225,6 +465,13 @@ func (s *Server) appendLoadedContext(sessionID string, filePath string) error {
 
 // summarizeJSON converts JSON to a string summary, truncated to max length.
 func summarizeJSON(data json.RawMessage) string {
+	return types.TruncateString(extractSummary(data), types.MaxInputSummaryLen)
+}
+
+// extractSummary converts JSON to an untruncated string summary.
+// Redaction runs on this form so secrets straddling the truncation
+// boundary are still detected.
+func extractSummary(data json.RawMessage) string {
 	if len(data) == 0 {
 		return ""
 	}
@@ -234,21 +481,21 @@ func summarizeJSON(data json.RawMessage) string {
 	if err := json.Unmarshal(data, &obj); err == nil {
 		// For Read/Write tools, extract file_path
 		if filePath, ok := obj["file_path"].(string); ok {
-			return types.TruncateString(filePath, types.MaxInputSummaryLen)
+			return filePath
 		}
 		// For Bash, extract command
 		if cmd, ok := obj["command"].(string); ok {
-			return types.TruncateString(cmd, types.MaxInputSummaryLen)
+			return cmd
 		}
 	}
 
-	// Fall back to raw string, truncated
+	// Fall back to raw string
 	s := string(data)
 	// Remove surrounding quotes if present
 	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
 		s = s[1 : len(s)-1]
 	}
-	return types.TruncateString(s, types.MaxInputSummaryLen)
+	return s
 }
 
 // extractFilePath extracts the file_path from a tool input JSON.
@@ -0,0 +1,225 @@
+package ingestion
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/store"
+)
+
+// SpoolFileName is the file hooks append to when the socket is unavailable.
+const SpoolFileName = "steps.jsonl"
+
+// replayingSuffix marks spool files claimed by a replay in progress.
+const replayingSuffix = ".replaying"
+
+// SpoolEntry is a hook payload that could not be delivered over the socket.
+type SpoolEntry struct {
+	SpooledAt time.Time       `json:"spooled_at"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// AppendSpool appends a hook payload to the spool file in dir.
+func AppendSpool(dir string, payload []byte, at time.Time) error {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, payload); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	line, err := json.Marshal(SpoolEntry{SpooledAt: at, Payload: compact.Bytes()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal spool entry: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, SpoolFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open spool file: %w", err)
+	}
+	defer f.Close()
+
+	// A single write keeps concurrent hooks from interleaving lines
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	return nil
+}
+
+// SetSpoolDir configures the directory that hooks spool payloads into
+// while the socket is down. An empty dir disables replay.
+func (s *Server) SetSpoolDir(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spoolDir = dir
+}
+
+// ReplaySpool records spooled payloads into their sessions and removes the
+// spool files. Payloads are replayed in the order they were spooled; ones
+// already recorded, or with no recording to route to, are dropped.
+// Returns the number of entries replayed.
+func (s *Server) ReplaySpool() (int, error) {
+	s.mu.RLock()
+	dir := s.spoolDir
+	s.mu.RUnlock()
+
+	if dir == "" {
+		return 0, nil
+	}
+
+	// Serialize replays so startup and trajectory_start don't race
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	files, err := claimSpoolFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, nil
+	}
+
+	var entries []SpoolEntry
+	for _, path := range files {
+		fileEntries, err := readSpoolFile(path)
+		if err != nil {
+			return 0, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].SpooledAt.Before(entries[j].SpooledAt)
+	})
+
+	replayed := 0
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		// Identical lines can appear when a hook retried after a partial failure
+		key := entry.SpooledAt.String() + string(entry.Payload)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if s.replayEntry(entry) {
+			replayed++
+		}
+	}
+
+	for _, path := range files {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return replayed, fmt.Errorf("failed to remove spool file: %w", err)
+		}
+	}
+
+	return replayed, nil
+}
+
+// replayEntry records a single spooled payload, reporting whether it was routed to a session.
+func (s *Server) replayEntry(entry SpoolEntry) bool {
+	var payload HookPayload
+	if err := json.Unmarshal(entry.Payload, &payload); err != nil || payload.ToolName == "" {
+		log.Printf("skipping malformed spool entry")
+		return false
+	}
+
+	// Binding a recording doesn't route to one
+	if IsOwnTool(payload.ToolName) {
+		if err := s.recordStep(payload, entry.SpooledAt); err != nil && err != store.ErrNoActiveSession {
+			log.Printf("failed to replay spooled step: %v", err)
+		}
+		return false
+	}
+
+	// Steps spooled before the recording started belong to no recording
+	session, err := s.resolveSession(payload.SessionID)
+	if err != nil {
+		if err != store.ErrNoActiveSession {
+			log.Printf("failed to replay spooled step: %v", err)
+		}
+		return false
+	}
+	if entry.SpooledAt.Before(session.StartedAt.Truncate(time.Second)) {
+		return false
+	}
+
+	if err := s.recordStep(payload, entry.SpooledAt); err != nil {
+		log.Printf("failed to replay spooled step: %v", err)
+		return false
+	}
+	return true
+}
+
+// claimSpoolFiles renames pending spool files so hooks start a fresh file,
+// and returns every claimed file, including ones left by an interrupted replay.
+func claimSpoolFiles(dir string) ([]string, error) {
+	names, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	var claimed []string
+	for _, entry := range names {
+		name := entry.Name()
+		path := filepath.Join(dir, name)
+		switch {
+		case strings.HasSuffix(name, replayingSuffix):
+			claimed = append(claimed, path)
+		case strings.HasSuffix(name, ".jsonl"):
+			// Unique name so a file left by an interrupted replay isn't overwritten
+			target := fmt.Sprintf("%s.%d%s", path, time.Now().UnixNano(), replayingSuffix)
+			if err := os.Rename(path, target); err != nil {
+				return nil, fmt.Errorf("failed to claim spool file: %w", err)
+			}
+			claimed = append(claimed, target)
+		}
+	}
+
+	sort.Strings(claimed)
+	return claimed, nil
+}
+
+// readSpoolFile parses a spool file, skipping lines that can't be decoded
+// (such as one truncated by a crash mid-write).
+func readSpoolFile(path string) ([]SpoolEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool file: %w", err)
+	}
+	defer f.Close()
+
+	var entries []SpoolEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry SpoolEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || len(entry.Payload) == 0 {
+			log.Printf("skipping malformed spool line in %s", filepath.Base(path))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read spool file: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,176 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/store"
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+func writeSpool(t *testing.T, dir string, entries ...SpoolEntry) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create spool dir: %v", err)
+	}
+	var lines []string
+	for _, e := range entries {
+		data, _ := json.Marshal(e)
+		lines = append(lines, string(data))
+	}
+	path := filepath.Join(dir, SpoolFileName)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write spool: %v", err)
+	}
+}
+
+func spoolPayload(p HookPayload) json.RawMessage {
+	data, _ := json.Marshal(p)
+	return data
+}
+
+func TestReplaySpool(t *testing.T) {
+	server, s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	start := time.Now().Add(-time.Minute)
+	session := &types.Session{
+		ID:         store.NewULID(),
+		TaskPrompt: "Test task",
+		Status:     types.StatusRecording,
+		StartedAt:  start,
+		Steps: []types.TrajectoryStep{
+			{Timestamp: start, ToolName: "Read", ToolUseID: "toolu_live", Status: types.StepStatusCompleted},
+		},
+	}
+	if err := s.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if err := s.SetActiveSessionFor("claude-a", session.ID); err != nil {
+		t.Fatalf("failed to set active session: %v", err)
+	}
+
+	spoolDir := filepath.Join(t.TempDir(), "spool")
+	server.SetSpoolDir(spoolDir)
+
+	pre := SpoolEntry{
+		SpooledAt: start.Add(10 * time.Second),
+		Payload: spoolPayload(HookPayload{
+			SessionID: "claude-a", HookEventName: EventPreToolUse, ToolName: "Bash", ToolUseID: "toolu_1",
+			ToolInput: json.RawMessage(`{"command": "go test ./..."}`),
+		}),
+	}
+	post := SpoolEntry{
+		SpooledAt: start.Add(13 * time.Second),
+		Payload: spoolPayload(HookPayload{
+			SessionID: "claude-a", HookEventName: EventPostToolUse, ToolName: "Bash", ToolUseID: "toolu_1",
+			ToolInput: json.RawMessage(`{"command": "go test ./..."}`), ToolOutput: json.RawMessage(`"PASS"`),
+		}),
+	}
+	duplicateLive := SpoolEntry{
+		SpooledAt: start.Add(5 * time.Second),
+		Payload: spoolPayload(HookPayload{
+			SessionID: "claude-a", HookEventName: EventPostToolUse, ToolName: "Read", ToolUseID: "toolu_live",
+		}),
+	}
+	beforeStart := SpoolEntry{
+		SpooledAt: start.Add(-time.Hour),
+		Payload:   spoolPayload(HookPayload{SessionID: "claude-a", ToolName: "Grep"}),
+	}
+	unrouted := SpoolEntry{
+		SpooledAt: start.Add(20 * time.Second),
+		Payload:   spoolPayload(HookPayload{SessionID: "claude-b", ToolName: "Write", ToolUseID: "toolu_b"}),
+	}
+
+	// Out of order, with the Post delivered twice
+	writeSpool(t, spoolDir, post, pre, post, duplicateLive, beforeStart, unrouted)
+
+	if _, err := server.ReplaySpool(); err != nil {
+		t.Fatalf("ReplaySpool failed: %v", err)
+	}
+
+	updated, err := s.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if len(updated.Steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d: %+v", len(updated.Steps), updated.Steps)
+	}
+
+	step := updated.Steps[1]
+	if step.ToolUseID != "toolu_1" || step.Status != types.StepStatusCompleted {
+		t.Errorf("expected completed toolu_1 step, got %+v", step)
+	}
+	if step.DurationMs != 3000 {
+		t.Errorf("expected duration from spool timestamps (3000ms), got %d", step.DurationMs)
+	}
+	if !step.Timestamp.Equal(pre.SpooledAt) {
+		t.Errorf("expected step timestamp %v, got %v", pre.SpooledAt, step.Timestamp)
+	}
+
+	files, _ := os.ReadDir(spoolDir)
+	if len(files) != 0 {
+		t.Errorf("expected spool files to be removed, found %d", len(files))
+	}
+}
+
+func TestReplaySpoolOnStart(t *testing.T) {
+	server, s, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	session := &types.Session{
+		ID:         store.NewULID(),
+		TaskPrompt: "Test task",
+		Status:     types.StatusRecording,
+		StartedAt:  time.Now().Add(-time.Minute),
+	}
+	if err := s.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if err := s.SetActiveSession(session.ID); err != nil {
+		t.Fatalf("failed to set active session: %v", err)
+	}
+
+	spoolDir := filepath.Join(t.TempDir(), "spool")
+	server.SetSpoolDir(spoolDir)
+
+	// A replay interrupted mid-way leaves a claimed file behind
+	writeSpool(t, spoolDir, SpoolEntry{
+		SpooledAt: time.Now(),
+		Payload:   spoolPayload(HookPayload{ToolName: "Read", ToolInput: json.RawMessage(`{"file_path": "a.go"}`)}),
+	})
+	claimed := filepath.Join(spoolDir, SpoolFileName+replayingSuffix)
+	if err := os.Rename(filepath.Join(spoolDir, SpoolFileName), claimed); err != nil {
+		t.Fatalf("failed to rename spool: %v", err)
+	}
+	os.WriteFile(filepath.Join(spoolDir, SpoolFileName), []byte("not json\n"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := server.Start(ctx); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+
+	updated, err := s.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if len(updated.Steps) != 1 || updated.Steps[0].InputSummary != "a.go" {
+		t.Errorf("expected spooled Read step, got %+v", updated.Steps)
+	}
+}
+
+func TestReplaySpoolDisabled(t *testing.T) {
+	server, _, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	n, err := server.ReplaySpool()
+	if err != nil || n != 0 {
+		t.Errorf("expected no-op without spool dir, got %d, %v", n, err)
+	}
+}
@@ -6,56 +6,19 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
-// HookScriptContent is the embedded hook script.
-// It detects project root and computes socket path to match the MCP server.
-const HookScriptContent = `#!/bin/bash
-# trajectory-memory hook script
-# Reads Claude Code hook payload from stdin and forwards to trajectory-memory ingestion socket
-# This script is fire-and-forget - errors are silently ignored to not block CC
-
-# Find project root by looking for markers (same logic as Go code)
-find_project_root() {
-    local dir="$PWD"
-    while [[ "$dir" != "/" ]]; do
-        if [[ -d "$dir/.git" || -f "$dir/CLAUDE.md" || -d "$dir/.claude" ]]; then
-            echo "$dir"
-            return
-        fi
-        dir="$(dirname "$dir")"
-    done
-    # No marker found, use current directory
-    echo "$PWD"
-}
+// binaryName is the name trajectory-memory is installed under.
+const binaryName = "trajectory-memory"
 
-# Compute socket path from project root
-get_socket_path() {
-    local project_root="$1"
-    # SHA256 hash, first 8 chars (matches Go implementation)
-    local hash=$(echo -n "$project_root" | shasum -a 256 | cut -c1-8)
-    echo "/tmp/trajectory-memory-${hash}.sock"
-}
+// hookArg is the subcommand the hooks run. It forwards the hook payload to
+// the ingestion socket, spooling it if the server is unavailable.
+const hookArg = "hook"
 
-# Allow override via environment variable
-if [[ -n "$TM_SOCKET_PATH" ]]; then
-    SOCKET_PATH="$TM_SOCKET_PATH"
-else
-    PROJECT_ROOT=$(find_project_root)
-    SOCKET_PATH=$(get_socket_path "$PROJECT_ROOT")
-fi
-
-PAYLOAD=$(cat)
-
-# Only proceed if socket exists
-if [ -S "$SOCKET_PATH" ]; then
-    curl -s -X POST --unix-socket "$SOCKET_PATH" \
-        -H "Content-Type: application/json" \
-        -d "$PAYLOAD" \
-        --max-time 1 \
-        http://localhost/step > /dev/null 2>&1 || true
-fi
-`
+// legacyHookScript is the script that older versions wrote to <data-dir>/hooks
+// and registered in settings.json. Install and Uninstall clean it up.
+const legacyHookScript = "trajectory-hook.sh"
 
 // ClaudeSettings represents the Claude Code settings.json structure.
 type ClaudeSettings struct {
@@ -66,6 +29,7 @@ type ClaudeSettings struct {
 
 // HooksConfig contains hook configurations.
 type HooksConfig struct {
+	PreToolUse  []HookEntry `json:"PreToolUse,omitempty"`
 	PostToolUse []HookEntry `json:"PostToolUse,omitempty"`
 }
 
@@ -91,28 +55,45 @@ type InstallOptions struct {
 
 // Installer manages hook installation.
 type Installer struct {
-	dataDir string
+	dataDir     string
+	hookCommand string
 }
 
-// NewInstaller creates a new installer.
+// NewInstaller creates a new installer that registers the running
+// executable as the hook command.
 func NewInstaller(dataDir string) *Installer {
-	return &Installer{dataDir: dataDir}
+	return &Installer{dataDir: dataDir, hookCommand: HookCommand()}
 }
 
-// Install installs the trajectory-memory hooks.
-func (i *Installer) Install(opts InstallOptions) error {
-	// Create hooks directory
-	hooksDir := filepath.Join(i.dataDir, "hooks")
-	if err := os.MkdirAll(hooksDir, 0755); err != nil {
-		return fmt.Errorf("failed to create hooks directory: %w", err)
+// HookCommand returns the command registered for PreToolUse and PostToolUse
+// hooks. It names the running executable by absolute path, so the hooks work
+// even when trajectory-memory isn't on the PATH Claude Code runs them with.
+func HookCommand() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return binaryName + " " + hookArg
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
 	}
+	return shellQuote(exe) + " " + hookArg
+}
 
-	// Write hook script
-	hookPath := filepath.Join(hooksDir, "trajectory-hook.sh")
-	if err := os.WriteFile(hookPath, []byte(HookScriptContent), 0755); err != nil {
-		return fmt.Errorf("failed to write hook script: %w", err)
+// shellQuote single-quotes a path for the shell if it has characters the
+// shell would interpret.
+func shellQuote(path string) string {
+	safe := func(r rune) bool {
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("/._-+", r)
+	}
+	if strings.IndexFunc(path, func(r rune) bool { return !safe(r) }) < 0 {
+		return path
 	}
+	return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+}
 
+// Install installs the trajectory-memory hooks.
+// Hooks registered by older versions, which ran a bash script, are replaced.
+func (i *Installer) Install(opts InstallOptions) error {
 	// Find and update settings
 	settingsPath, err := i.findSettingsPath(opts.Global)
 	if err != nil {
@@ -129,36 +110,39 @@ func (i *Installer) Install(opts InstallOptions) error {
 	}
 
 	// Check if already installed
-	if i.isInstalled(settings, hookPath) {
+	if i.isInstalled(settings) {
 		return fmt.Errorf("trajectory-memory is already installed")
 	}
 
-	// Add hook entry
+	// Add hook entries; PreToolUse and PostToolUse are paired to measure step durations
 	if settings.Hooks == nil {
 		settings.Hooks = &HooksConfig{}
 	}
-	settings.Hooks.PostToolUse = append(settings.Hooks.PostToolUse, HookEntry{
+	i.removeHooks(settings.Hooks)
+	entry := HookEntry{
 		Matcher: "*", // Match all tools
 		Hooks: []Hook{
 			{
 				Type:    "command",
-				Command: hookPath,
+				Command: i.hookCommand,
 			},
 		},
-	})
+	}
+	settings.Hooks.PreToolUse = append(settings.Hooks.PreToolUse, entry)
+	settings.Hooks.PostToolUse = append(settings.Hooks.PostToolUse, entry)
 
 	// Write updated settings
 	if err := i.writeSettings(settingsPath, settings); err != nil {
 		return fmt.Errorf("failed to write settings: %w", err)
 	}
 
+	i.removeLegacyHookScript()
+
 	return nil
 }
 
 // Uninstall removes the trajectory-memory hooks.
 func (i *Installer) Uninstall(opts InstallOptions) error {
-	hookPath := filepath.Join(i.dataDir, "hooks", "trajectory-hook.sh")
-
 	// Find settings
 	settingsPath, err := i.findSettingsPath(opts.Global)
 	if err != nil {
@@ -169,22 +153,16 @@ func (i *Installer) Uninstall(opts InstallOptions) error {
 	settings, err := i.readSettings(settingsPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			// No settings file - just remove hook script
-			os.Remove(hookPath)
+			// No settings file - just remove any legacy hook script
+			i.removeLegacyHookScript()
 			return nil
 		}
 		return fmt.Errorf("failed to read settings: %w", err)
 	}
 
-	// Remove hook entry
+	// Remove hook entries
 	if settings.Hooks != nil {
-		var newHooks []HookEntry
-		for _, h := range settings.Hooks.PostToolUse {
-			if !i.hookEntryContains(h, hookPath) {
-				newHooks = append(newHooks, h)
-			}
-		}
-		settings.Hooks.PostToolUse = newHooks
+		i.removeHooks(settings.Hooks)
 	}
 
 	// Write updated settings
@@ -192,16 +170,13 @@ func (i *Installer) Uninstall(opts InstallOptions) error {
 		return fmt.Errorf("failed to write settings: %w", err)
 	}
 
-	// Remove hook script
-	os.Remove(hookPath)
+	i.removeLegacyHookScript()
 
 	return nil
 }
 
 // IsInstalled checks if trajectory-memory is already installed.
 func (i *Installer) IsInstalled(opts InstallOptions) bool {
-	hookPath := filepath.Join(i.dataDir, "hooks", "trajectory-hook.sh")
-
 	settingsPath, err := i.findSettingsPath(opts.Global)
 	if err != nil {
 		return false
@@ -212,12 +187,12 @@ func (i *Installer) IsInstalled(opts InstallOptions) bool {
 		return false
 	}
 
-	return i.isInstalled(settings, hookPath)
+	return i.isInstalled(settings)
 }
 
-// GetHookPath returns the path where the hook script will be installed.
-func (i *Installer) GetHookPath() string {
-	return filepath.Join(i.dataDir, "hooks", "trajectory-hook.sh")
+// GetHookCommand returns the command registered in Claude Code settings.
+func (i *Installer) GetHookCommand() string {
+	return i.hookCommand
 }
 
 // GetMCPConfig returns the MCP server configuration snippet.
@@ -278,27 +253,75 @@ func (i *Installer) findSettingsPath(global bool) (string, error) {
 	return filepath.Join(settingsDir, "settings.json"), nil
 }
 
-func (i *Installer) isInstalled(settings *ClaudeSettings, hookPath string) bool {
+// isInstalled reports whether both the PreToolUse and PostToolUse hooks run
+// this executable; a half-registered install is repaired by Install.
+func (i *Installer) isInstalled(settings *ClaudeSettings) bool {
 	if settings.Hooks == nil {
 		return false
 	}
-	for _, h := range settings.Hooks.PostToolUse {
-		if i.hookEntryContains(h, hookPath) {
+	return i.hasHook(settings.Hooks.PreToolUse) && i.hasHook(settings.Hooks.PostToolUse)
+}
+
+func (i *Installer) hasHook(entries []HookEntry) bool {
+	for _, h := range entries {
+		if hookEntryContains(h, i.hookCommand) {
 			return true
 		}
 	}
 	return false
 }
 
-func (i *Installer) hookEntryContains(entry HookEntry, hookPath string) bool {
+// removeHooks drops trajectory-memory entries, current and legacy, from both hook lists.
+func (i *Installer) removeHooks(hooks *HooksConfig) {
+	hooks.PreToolUse = i.removeHookEntries(hooks.PreToolUse)
+	hooks.PostToolUse = i.removeHookEntries(hooks.PostToolUse)
+}
+
+func (i *Installer) removeHookEntries(entries []HookEntry) []HookEntry {
+	legacyPath := i.legacyHookPath()
+	var kept []HookEntry
+	for _, h := range entries {
+		if !hookEntryContains(h, i.hookCommand) && !hookEntryMatches(h, isHookCommand) && !hookEntryContains(h, legacyPath) {
+			kept = append(kept, h)
+		}
+	}
+	return kept
+}
+
+// isHookCommand reports whether a command runs a trajectory-memory hook,
+// wherever the binary was when it was registered.
+func isHookCommand(command string) bool {
+	program, ok := strings.CutSuffix(command, " "+hookArg)
+	if !ok {
+		return false
+	}
+	return filepath.Base(strings.Trim(program, "'")) == binaryName
+}
+
+func hookEntryContains(entry HookEntry, command string) bool {
+	return hookEntryMatches(entry, func(c string) bool { return c == command })
+}
+
+func hookEntryMatches(entry HookEntry, match func(string) bool) bool {
 	for _, h := range entry.Hooks {
-		if h.Command == hookPath {
+		if match(h.Command) {
 			return true
 		}
 	}
 	return false
 }
 
+func (i *Installer) legacyHookPath() string {
+	return filepath.Join(i.dataDir, "hooks", legacyHookScript)
+}
+
+// removeLegacyHookScript deletes the bash hook written by older versions.
+func (i *Installer) removeLegacyHookScript() {
+	os.Remove(i.legacyHookPath())
+	// Only succeeds if the directory is now empty
+	os.Remove(filepath.Dir(i.legacyHookPath()))
+}
+
 func (i *Installer) readSettings(path string) (*ClaudeSettings, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
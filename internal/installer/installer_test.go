@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -41,38 +42,42 @@ func setupTestInstaller(t *testing.T) (*Installer, string, func()) {
 	return NewInstaller(dataDir), projectDir, cleanup
 }
 
-func TestInstallCreatesHookScript(t *testing.T) {
-	installer, _, cleanup := setupTestInstaller(t)
+func TestInstallMigratesLegacyHookScript(t *testing.T) {
+	installer, projectDir, cleanup := setupTestInstaller(t)
 	defer cleanup()
 
-	err := installer.Install(InstallOptions{})
-	if err != nil {
-		t.Fatalf("Install failed: %v", err)
-	}
+	// Simulate an install by an older version that registered a bash script
+	legacyPath := installer.legacyHookPath()
+	os.MkdirAll(filepath.Dir(legacyPath), 0755)
+	os.WriteFile(legacyPath, []byte("#!/bin/bash\n"), 0755)
 
-	// Verify hook script exists
-	hookPath := installer.GetHookPath()
-	info, err := os.Stat(hookPath)
-	if err != nil {
-		t.Fatalf("hook script not found: %v", err)
-	}
+	settingsPath := filepath.Join(projectDir, ".claude", "settings.json")
+	legacySettings := `{
+  "hooks": {
+    "PostToolUse": [
+      {"matcher": "*", "hooks": [{"type": "command", "command": "` + legacyPath + `"}]}
+    ]
+  }
+}`
+	os.WriteFile(settingsPath, []byte(legacySettings), 0644)
 
-	// Check it's executable
-	if info.Mode()&0111 == 0 {
-		t.Error("hook script should be executable")
+	if installer.IsInstalled(InstallOptions{}) {
+		t.Fatal("legacy install should not count as installed")
 	}
 
-	// Check content
-	content, err := os.ReadFile(hookPath)
-	if err != nil {
-		t.Fatalf("failed to read hook script: %v", err)
+	if err := installer.Install(InstallOptions{}); err != nil {
+		t.Fatalf("Install failed: %v", err)
 	}
 
-	if len(content) == 0 {
-		t.Error("hook script should not be empty")
+	data, _ := os.ReadFile(settingsPath)
+	var settings ClaudeSettings
+	json.Unmarshal(data, &settings)
+
+	if len(settings.Hooks.PostToolUse) != 1 || settings.Hooks.PostToolUse[0].Hooks[0].Command != installer.GetHookCommand() {
+		t.Errorf("expected legacy hook to be replaced, got %+v", settings.Hooks.PostToolUse)
 	}
-	if string(content[:2]) != "#!" {
-		t.Error("hook script should start with shebang")
+	if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+		t.Error("legacy hook script should be removed")
 	}
 }
 
@@ -103,13 +108,18 @@ func TestInstallUpdatesSettings(t *testing.T) {
 	if len(settings.Hooks.PostToolUse) != 1 {
 		t.Errorf("expected 1 PostToolUse hook, got %d", len(settings.Hooks.PostToolUse))
 	}
+	if len(settings.Hooks.PreToolUse) != 1 {
+		t.Errorf("expected 1 PreToolUse hook, got %d", len(settings.Hooks.PreToolUse))
+	} else if settings.Hooks.PreToolUse[0].Hooks[0].Command != installer.GetHookCommand() {
+		t.Errorf("PreToolUse hook path mismatch: got %s", settings.Hooks.PreToolUse[0].Hooks[0].Command)
+	}
 
 	hook := settings.Hooks.PostToolUse[0]
 	if len(hook.Hooks) != 1 {
 		t.Errorf("expected 1 hook command, got %d", len(hook.Hooks))
 	}
-	if hook.Hooks[0].Command != installer.GetHookPath() {
-		t.Errorf("hook path mismatch: got %s, want %s", hook.Hooks[0].Command, installer.GetHookPath())
+	if hook.Hooks[0].Command != installer.GetHookCommand() {
+		t.Errorf("hook path mismatch: got %s, want %s", hook.Hooks[0].Command, installer.GetHookCommand())
 	}
 	if hook.Hooks[0].Type != "command" {
 		t.Errorf("hook type should be 'command', got %s", hook.Hooks[0].Type)
@@ -233,19 +243,13 @@ func TestUninstall(t *testing.T) {
 		t.Fatalf("Uninstall failed: %v", err)
 	}
 
-	// Verify hook script removed
-	hookPath := installer.GetHookPath()
-	if _, err := os.Stat(hookPath); !os.IsNotExist(err) {
-		t.Error("hook script should be removed")
-	}
-
 	// Verify settings updated
 	settingsPath := filepath.Join(projectDir, ".claude", "settings.json")
 	data, _ := os.ReadFile(settingsPath)
 	var settings ClaudeSettings
 	json.Unmarshal(data, &settings)
 
-	if settings.Hooks != nil && len(settings.Hooks.PostToolUse) > 0 {
+	if settings.Hooks != nil && (len(settings.Hooks.PostToolUse) > 0 || len(settings.Hooks.PreToolUse) > 0) {
 		t.Error("hooks should be empty after uninstall")
 	}
 
@@ -330,6 +334,44 @@ func TestIsInstalled(t *testing.T) {
 	}
 }
 
+func TestIsInstalledNeedsBothHooks(t *testing.T) {
+	installer, projectDir, cleanup := setupTestInstaller(t)
+	defer cleanup()
+
+	if !filepath.IsAbs(strings.Fields(installer.GetHookCommand())[0]) {
+		t.Errorf("expected the hook command to name an absolute path, got %q", installer.GetHookCommand())
+	}
+
+	// Only PostToolUse, registered by an older version that relied on PATH
+	settingsPath := filepath.Join(projectDir, ".claude", "settings.json")
+	partial := `{
+  "hooks": {
+    "PostToolUse": [
+      {"matcher": "*", "hooks": [{"type": "command", "command": "trajectory-memory hook"}]},
+      {"matcher": "*", "hooks": [{"type": "command", "command": "` + installer.GetHookCommand() + `"}]}
+    ]
+  }
+}`
+	os.WriteFile(settingsPath, []byte(partial), 0644)
+
+	if installer.IsInstalled(InstallOptions{}) {
+		t.Fatal("a missing PreToolUse hook should not count as installed")
+	}
+	if err := installer.Install(InstallOptions{}); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(settingsPath)
+	var settings ClaudeSettings
+	json.Unmarshal(data, &settings)
+	if len(settings.Hooks.PreToolUse) != 1 || len(settings.Hooks.PostToolUse) != 1 {
+		t.Errorf("expected one hook per event, got %+v", settings.Hooks)
+	}
+	if !installer.IsInstalled(InstallOptions{}) {
+		t.Error("should be installed after Install()")
+	}
+}
+
 func TestGetMCPConfig(t *testing.T) {
 	installer, _, cleanup := setupTestInstaller(t)
 	defer cleanup()
@@ -88,13 +88,14 @@ type InputSchema struct {
 
 // Property describes a single property in the schema.
 type Property struct {
-	Type        string   `json:"type"`
-	Description string   `json:"description,omitempty"`
-	Default     interface{} `json:"default,omitempty"`
-	Enum        []string `json:"enum,omitempty"`
-	Items       *Property `json:"items,omitempty"`
-	Minimum     *float64 `json:"minimum,omitempty"`
-	Maximum     *float64 `json:"maximum,omitempty"`
+	Type                 string      `json:"type"`
+	Description          string      `json:"description,omitempty"`
+	Default              interface{} `json:"default,omitempty"`
+	Enum                 []string    `json:"enum,omitempty"`
+	Items                *Property   `json:"items,omitempty"`
+	AdditionalProperties *Property   `json:"additionalProperties,omitempty"`
+	Minimum              *float64    `json:"minimum,omitempty"`
+	Maximum              *float64    `json:"maximum,omitempty"`
 }
 
 // ToolsListResult is the result of tools/list.
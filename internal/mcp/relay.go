@@ -0,0 +1,144 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// RelayPath is where the serve process holding the database accepts MCP
+// requests relayed from other Claude Code windows, on its ingestion socket.
+const RelayPath = "/mcp"
+
+// ownerHeader carries the relaying client's owner, so each window keeps its
+// own recording.
+const ownerHeader = "X-Trajectory-Owner"
+
+// ErrRelayUnavailable is returned by RunRelay when no serve process answers
+// on the ingestion socket.
+var ErrRelayUnavailable = errors.New("no trajectory-memory serve process is answering on the ingestion socket")
+
+// Relay serves MCP requests relayed from serve processes that couldn't open
+// the database because this one holds it. Each owner gets its own Server,
+// which handles one request at a time.
+type Relay struct {
+	newServer func() *Server
+
+	mu      sync.Mutex
+	servers map[string]*Server
+}
+
+// NewRelay creates a relay handler. newServer configures a Server for each
+// new owner.
+func NewRelay(newServer func() *Server) *Relay {
+	return &Relay{
+		newServer: newServer,
+		servers:   make(map[string]*Server),
+	}
+}
+
+// ServeHTTP handles one JSON-RPC request line and writes back the response,
+// or nothing for a notification.
+func (r *Relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	line, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	owner := req.Header.Get(ownerHeader)
+	server, ok := r.servers[owner]
+	if !ok {
+		server = r.newServer()
+		server.SetOwner(owner)
+		r.servers[owner] = server
+	}
+
+	var out bytes.Buffer
+	server.writer = &out
+	server.handleLine(line)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out.Bytes())
+}
+
+// RunRelay relays MCP requests read from r to the serve process listening
+// on socketPath, and writes its responses to w. pending, if not nil, is a
+// request read earlier that is relayed first.
+//
+// It returns nil when r is exhausted. If the serve process goes away, it
+// returns the request it couldn't relay with ErrRelayUnavailable, so the
+// caller can take over the database and handle it.
+func RunRelay(ctx context.Context, r *bufio.Reader, w io.Writer, socketPath, owner string, pending []byte) ([]byte, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+
+	for {
+		line := pending
+		pending = nil
+		if line == nil {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			default:
+			}
+
+			var err error
+			line, err = r.ReadBytes('\n')
+			if err != nil {
+				if err == io.EOF {
+					return nil, nil
+				}
+				return nil, fmt.Errorf("read error: %w", err)
+			}
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://localhost"+RelayPath, bytes.NewReader(line))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(ownerHeader, owner)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return line, ErrRelayUnavailable
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			// An older serve without the relay, or one that went away mid-request
+			return line, ErrRelayUnavailable
+		}
+
+		if _, err := w.Write(body); err != nil {
+			return nil, fmt.Errorf("write error: %w", err)
+		}
+	}
+}
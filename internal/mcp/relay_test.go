@@ -0,0 +1,99 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/johncarpenter/trajectory-memory/internal/ingestion"
+)
+
+func TestRelay(t *testing.T) {
+	primary, s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ingestionServer := ingestion.NewServer(s, primary.socketPath)
+	ingestionServer.Handle(RelayPath, NewRelay(func() *Server {
+		srv := NewServer(s, primary.socketPath, "test")
+		srv.SetIngestionServer(ingestionServer)
+		return srv
+	}))
+	if err := ingestionServer.Start(ctx); err != nil {
+		t.Fatalf("failed to start ingestion server: %v", err)
+	}
+
+	relay := func(owner string, requests ...string) []Response {
+		t.Helper()
+		input := bufio.NewReader(strings.NewReader(strings.Join(requests, "\n") + "\n"))
+		var output bytes.Buffer
+		if _, err := RunRelay(ctx, input, &output, primary.socketPath, owner, nil); err != nil {
+			t.Fatalf("RunRelay failed: %v", err)
+		}
+		var responses []Response
+		decoder := json.NewDecoder(&output)
+		for decoder.More() {
+			var resp Response
+			if err := decoder.Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			responses = append(responses, resp)
+		}
+		return responses
+	}
+	start := func(owner string) string {
+		t.Helper()
+		responses := relay(owner,
+			`{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {}}`,
+			`{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "trajectory_start", "arguments": {"task_prompt": "Task for `+owner+`", "briefing": false}}}`,
+		)
+		if len(responses) != 2 {
+			t.Fatalf("expected two responses for %s, got %d", owner, len(responses))
+		}
+		var result ToolCallResult
+		resultJSON, _ := json.Marshal(responses[1].Result)
+		json.Unmarshal(resultJSON, &result)
+		if result.IsError {
+			t.Fatalf("unexpected error starting for %s: %v", owner, result.Content)
+		}
+		var output TrajectoryStartOutput
+		json.Unmarshal([]byte(result.Content[0].Text), &output)
+		return output.SessionID
+	}
+
+	// Each window relaying through the primary records separately
+	first := start("window-2")
+	second := start("window-3")
+	if first == "" || first == second {
+		t.Fatalf("expected a recording per window, got %q and %q", first, second)
+	}
+	for owner, want := range map[string]string{"window-2": first, "window-3": second} {
+		if recording, err := s.GetRecordingFor(owner); err != nil || recording.ID != want {
+			t.Errorf("expected %s to own %s, got %+v (%v)", owner, want, recording, err)
+		}
+	}
+
+	responses := relay("window-2", `{"jsonrpc": "2.0", "id": 3, "method": "tools/call", "params": {"name": "trajectory_status"}}`)
+	var result ToolCallResult
+	resultJSON, _ := json.Marshal(responses[0].Result)
+	json.Unmarshal(resultJSON, &result)
+	var status TrajectoryStatusOutput
+	json.Unmarshal([]byte(result.Content[0].Text), &status)
+	if !status.Active || status.SessionID != first {
+		t.Errorf("expected window-2's own recording, got %+v", status)
+	}
+
+	// Once the primary is gone, the request comes back for the caller to handle
+	ingestionServer.Stop()
+	request := []byte(`{"jsonrpc": "2.0", "id": 4, "method": "ping"}` + "\n")
+	var output bytes.Buffer
+	unsent, err := RunRelay(ctx, bufio.NewReader(bytes.NewReader(request)), &output, primary.socketPath, "window-2", nil)
+	if err != ErrRelayUnavailable || !bytes.Equal(unsent, request) {
+		t.Errorf("expected the unsent request with ErrRelayUnavailable, got %q (%v)", unsent, err)
+	}
+}
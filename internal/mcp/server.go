@@ -9,13 +9,21 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/johncarpenter/trajectory-memory/internal/autoscore"
+	"github.com/johncarpenter/trajectory-memory/internal/bandit"
+	"github.com/johncarpenter/trajectory-memory/internal/briefing"
 	"github.com/johncarpenter/trajectory-memory/internal/ingestion"
 	"github.com/johncarpenter/trajectory-memory/internal/optimizer"
+	"github.com/johncarpenter/trajectory-memory/internal/preference"
+	"github.com/johncarpenter/trajectory-memory/internal/rubric"
 	"github.com/johncarpenter/trajectory-memory/internal/store"
 	"github.com/johncarpenter/trajectory-memory/internal/summarize"
 	"github.com/johncarpenter/trajectory-memory/internal/types"
@@ -36,6 +44,27 @@ type Server struct {
 	reader          *bufio.Reader
 	writer          io.Writer
 	socketPath      string
+
+	// Rubric sources for per-dimension scoring
+	rubricConfigPath string
+	rubricFiles      []string
+
+	// analyzerConfigPath holds per-tag analyzer settings
+	analyzerConfigPath string
+
+	// autoScorer gives stopped sessions a provisional score
+	autoScorer *autoscore.Scorer
+
+	// rng drives randomized strategy selection; tests seed it
+	rng *rand.Rand
+
+	// recordingID is the session trajectory_start created. Claude Code runs
+	// an MCP server per session, so it is the caller's own recording.
+	recordingID string
+
+	// owner identifies the MCP client across server restarts, so a
+	// restarted server finds the recording its client started
+	owner string
 }
 
 // NewServer creates a new MCP server.
@@ -46,6 +75,8 @@ func NewServer(s store.Store, socketPath, version string) *Server {
 		socketPath: socketPath,
 		reader:     bufio.NewReader(os.Stdin),
 		writer:     os.Stdout,
+		autoScorer: autoscore.Default(),
+		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 
 	// If the store is a BoltStore, set up optimization features
@@ -57,6 +88,31 @@ func NewServer(s store.Store, socketPath, version string) *Server {
 	return srv
 }
 
+// SetRubricSources sets where scoring rubrics are read from: a JSON config
+// file and markdown files containing trajectory-rubric blocks.
+func (s *Server) SetRubricSources(configPath string, markdownPaths ...string) {
+	s.rubricConfigPath = configPath
+	s.rubricFiles = markdownPaths
+}
+
+// SetAnalyzerConfig sets the file per-tag analyzer settings are read from.
+func (s *Server) SetAnalyzerConfig(path string) {
+	s.analyzerConfigPath = path
+}
+
+// SetIngestionServer shares an already-configured ingestion server with the
+// MCP server so trajectory_start doesn't create a second listener.
+func (s *Server) SetIngestionServer(srv *ingestion.Server) {
+	s.ingestionServer = srv
+}
+
+// SetOwner identifies the MCP client this server serves. Recordings it
+// starts are stored under the owner, so a server restarted for the same
+// client picks them up again.
+func (s *Server) SetOwner(owner string) {
+	s.owner = owner
+}
+
 // SetIO allows setting custom IO for testing.
 func (s *Server) SetIO(r io.Reader, w io.Writer) {
 	s.reader = bufio.NewReader(r)
@@ -84,19 +140,24 @@ func (s *Server) Run(ctx context.Context) error {
 			continue
 		}
 
-		var req Request
-		if err := json.Unmarshal(line, &req); err != nil {
-			s.sendError(nil, ParseError, "Parse error", nil)
-			continue
-		}
+		s.handleLine(line)
+	}
+}
 
-		if req.JSONRPC != "2.0" {
-			s.sendError(req.ID, InvalidRequest, "Invalid JSON-RPC version", nil)
-			continue
-		}
+// handleLine handles one JSON-RPC request read from the client.
+func (s *Server) handleLine(line []byte) {
+	var req Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		s.sendError(nil, ParseError, "Parse error", nil)
+		return
+	}
 
-		s.handleRequest(&req)
+	if req.JSONRPC != "2.0" {
+		s.sendError(req.ID, InvalidRequest, "Invalid JSON-RPC version", nil)
+		return
 	}
+
+	s.handleRequest(&req)
 }
 
 func (s *Server) handleRequest(req *Request) {
@@ -156,10 +217,14 @@ func (s *Server) handleToolsCall(req *Request) {
 		result, err = s.handleTrajectoryStatus()
 	case "trajectory_search":
 		result, err = s.handleTrajectorySearch(params.Arguments)
+	case "trajectory_similar":
+		result, err = s.handleTrajectorySimilar(params.Arguments)
 	case "trajectory_list":
 		result, err = s.handleTrajectoryList(params.Arguments)
 	case "trajectory_score":
 		result, err = s.handleTrajectoryScore(params.Arguments)
+	case "trajectory_compare":
+		result, err = s.handleTrajectoryCompare(params.Arguments)
 	case "trajectory_summarize":
 		result, err = s.handleTrajectorySummarize(params.Arguments)
 	case "trajectory_optimize_propose":
@@ -188,6 +253,8 @@ func (s *Server) handleToolsCall(req *Request) {
 		result, err = s.handleStrategiesRecord(params.Arguments)
 	case "trajectory_strategies_analyze":
 		result, err = s.handleStrategiesAnalyze(params.Arguments)
+	case "trajectory_strategies_evolve":
+		result, err = s.handleStrategiesEvolve(params.Arguments)
 	default:
 		s.sendError(req.ID, InvalidParams, fmt.Sprintf("Unknown tool: %s", params.Name), nil)
 		return
@@ -213,11 +280,26 @@ func (s *Server) handleTrajectoryStart(args json.RawMessage) (ToolCallResult, er
 		return ToolCallResult{}, fmt.Errorf("task_prompt is required")
 	}
 
-	// Check if already recording
-	if _, err := s.store.GetActiveSession(); err == nil {
+	// Check if this Claude session is already recording
+	if _, err := s.activeRecording(); err == nil {
 		return ToolCallResult{}, fmt.Errorf("a session is already recording - stop it first")
 	}
 
+	// Look up relevant past sessions before this one exists
+	var brief *briefing.Briefing
+	if input.Briefing == nil || *input.Briefing {
+		opts := briefing.DefaultOptions()
+		if input.BriefingTokens > 0 {
+			opts.MaxTokens = input.BriefingTokens
+		}
+		b, err := briefing.Build(s.store, input.TaskPrompt, input.Tags, opts)
+		if err != nil {
+			log.Printf("Warning: failed to build briefing: %v", err)
+		} else if !b.IsEmpty() {
+			brief = b
+		}
+	}
+
 	// Get working directory
 	wd, err := os.Getwd()
 	if err != nil {
@@ -249,9 +331,12 @@ func (s *Server) handleTrajectoryStart(args json.RawMessage) (ToolCallResult, er
 		return ToolCallResult{}, fmt.Errorf("failed to create session: %w", err)
 	}
 
-	if err := s.store.SetActiveSession(session.ID); err != nil {
+	// The Claude session ID is only known to hooks, so the ingestion server
+	// binds the recording when trajectory_start's PostToolUse event arrives
+	if err := s.store.SetPendingSession(s.owner, session.ID); err != nil {
 		return ToolCallResult{}, fmt.Errorf("failed to set active session: %w", err)
 	}
+	s.recordingID = session.ID
 
 	// Start ingestion server if not already running
 	if s.ingestionServer == nil {
@@ -261,11 +346,15 @@ func (s *Server) handleTrajectoryStart(args json.RawMessage) (ToolCallResult, er
 		if err := s.ingestionServer.Start(context.Background()); err != nil {
 			log.Printf("Warning: failed to start ingestion server: %v", err)
 		}
+	} else if _, err := s.ingestionServer.ReplaySpool(); err != nil {
+		// Start replays the spool itself; a running server may have missed steps during a restart
+		log.Printf("Warning: failed to replay spooled steps: %v", err)
 	}
 
 	output := TrajectoryStartOutput{
 		SessionID: session.ID,
 		Message:   "Recording started",
+		Briefing:  brief,
 	}
 
 	jsonOutput, _ := json.Marshal(output)
@@ -274,6 +363,48 @@ func (s *Server) handleTrajectoryStart(args json.RawMessage) (ToolCallResult, er
 	}, nil
 }
 
+// activeRecording returns the caller's recording without its steps: the one
+// this server or an earlier server for the same owner started, or else the
+// default recording that isn't bound to a Claude session.
+func (s *Server) activeRecording() (*types.Session, error) {
+	if s.recordingID != "" {
+		session, err := s.store.GetSessionHeader(s.recordingID)
+		if err == nil && session.Status == types.StatusRecording {
+			return session, nil
+		}
+		if err != nil && err != store.ErrSessionNotFound {
+			return nil, err
+		}
+		// Stopped or deleted from elsewhere
+		s.recordingID = ""
+	}
+
+	if s.owner != "" {
+		session, err := s.store.GetRecordingFor(s.owner)
+		if err == nil {
+			s.recordingID = session.ID
+			return session, nil
+		}
+		if err != store.ErrNoActiveSession {
+			return nil, err
+		}
+	}
+
+	bindings, err := s.store.ListActiveSessions()
+	if err != nil {
+		return nil, err
+	}
+	sessionID, ok := bindings[""]
+	if !ok {
+		return nil, store.ErrNoActiveSession
+	}
+	session, err := s.store.GetSessionHeader(sessionID)
+	if err == store.ErrSessionNotFound {
+		return nil, store.ErrNoActiveSession
+	}
+	return session, err
+}
+
 func (s *Server) handleTrajectoryStop(args json.RawMessage) (ToolCallResult, error) {
 	var input TrajectoryStopInput
 	if len(args) > 0 {
@@ -283,15 +414,21 @@ func (s *Server) handleTrajectoryStop(args json.RawMessage) (ToolCallResult, err
 	}
 
 	// Get active session
-	session, err := s.store.GetActiveSession()
+	recording, err := s.activeRecording()
 	if err != nil {
 		return ToolCallResult{}, fmt.Errorf("no active session to stop")
 	}
+	session, err := s.store.GetSession(recording.ID)
+	if err != nil {
+		return ToolCallResult{}, fmt.Errorf("failed to load session: %w", err)
+	}
 
-	// Update session status
+	// Update session status; tools still awaiting PostToolUse were denied or interrupted
+	session.MarkIncompleteSteps()
 	session.Status = types.StatusCompleted
 	now := time.Now()
 	session.CompletedAt = &now
+	session.AutoOutcome = s.autoScorer.Score(session)
 
 	// Set score if provided
 	if input.Score != nil {
@@ -308,9 +445,10 @@ func (s *Server) handleTrajectoryStop(args json.RawMessage) (ToolCallResult, err
 	}
 
 	// Clear active session
-	if err := s.store.ClearActiveSession(); err != nil {
+	if err := s.store.ClearRecording(session.ID); err != nil {
 		return ToolCallResult{}, fmt.Errorf("failed to clear active session: %w", err)
 	}
+	s.recordingID = ""
 
 	// Format trajectory for summarization
 	autoSummarize := true
@@ -330,15 +468,22 @@ func (s *Server) handleTrajectoryStop(args json.RawMessage) (ToolCallResult, err
 }
 
 func (s *Server) handleTrajectoryStatus() (ToolCallResult, error) {
-	session, err := s.store.GetActiveSession()
+	session, err := s.activeRecording()
 
 	output := TrajectoryStatusOutput{
 		Active: err == nil,
 	}
 
 	if session != nil {
+		steps := 0
+		if err := s.store.ForEachStep(session.ID, func(types.TrajectoryStep) error {
+			steps++
+			return nil
+		}); err != nil {
+			return ToolCallResult{}, fmt.Errorf("failed to count steps: %w", err)
+		}
 		output.SessionID = session.ID
-		output.StepCount = len(session.Steps)
+		output.StepCount = steps
 		output.DurationSeconds = int(time.Since(session.StartedAt).Seconds())
 	}
 
@@ -363,20 +508,14 @@ func (s *Server) handleTrajectorySearch(args json.RawMessage) (ToolCallResult, e
 		limit = input.Limit
 	}
 
-	results, err := s.store.SearchSessions(input.Query, limit)
-	if err != nil {
-		return ToolCallResult{}, fmt.Errorf("search failed: %w", err)
+	query := input.Query
+	if input.MinScore != nil {
+		query += fmt.Sprintf(" score>=%.4f", *input.MinScore)
 	}
 
-	// Filter by min_score if specified
-	if input.MinScore != nil {
-		filtered := make([]types.SessionMetadata, 0)
-		for _, r := range results {
-			if r.Score != nil && *r.Score >= *input.MinScore {
-				filtered = append(filtered, r)
-			}
-		}
-		results = filtered
+	results, err := s.store.SearchSessions(query, limit)
+	if err != nil {
+		return ToolCallResult{}, fmt.Errorf("search failed: %w", err)
 	}
 
 	// Convert to output format
@@ -390,6 +529,7 @@ func (s *Server) handleTrajectorySearch(args json.RawMessage) (ToolCallResult, e
 			StepCount:  r.StepCount,
 			Tags:       r.Tags,
 			StartedAt:  r.StartedAt.Format(time.RFC3339),
+			Relevance:  r.Relevance,
 		})
 	}
 
@@ -399,6 +539,50 @@ func (s *Server) handleTrajectorySearch(args json.RawMessage) (ToolCallResult, e
 	}, nil
 }
 
+func (s *Server) handleTrajectorySimilar(args json.RawMessage) (ToolCallResult, error) {
+	var input TrajectorySimilarInput
+	if err := json.Unmarshal(args, &input); err != nil {
+		return ToolCallResult{}, fmt.Errorf("invalid input: %w", err)
+	}
+
+	if input.Prompt == "" {
+		return ToolCallResult{}, fmt.Errorf("prompt is required")
+	}
+
+	q := store.Query{Limit: 5, MinScore: input.MinScore}
+	if input.Limit > 0 {
+		q.Limit = input.Limit
+	}
+	if input.Tag != "" {
+		q.Tags = []string{input.Tag}
+	}
+
+	results, err := s.store.SimilarSessions(input.Prompt, q)
+	if err != nil {
+		return ToolCallResult{}, fmt.Errorf("similarity search failed: %w", err)
+	}
+
+	// Convert to output format
+	var similar []TrajectorySimilarResult
+	for _, r := range results {
+		similar = append(similar, TrajectorySimilarResult{
+			SessionID:  r.ID,
+			TaskPrompt: r.TaskPrompt,
+			Summary:    r.Summary,
+			Score:      r.Score,
+			StepCount:  r.StepCount,
+			Tags:       r.Tags,
+			StartedAt:  r.StartedAt.Format(time.RFC3339),
+			Similarity: r.Similarity,
+		})
+	}
+
+	jsonOutput, _ := json.Marshal(similar)
+	return ToolCallResult{
+		Content: []ContentBlock{{Type: "text", Text: string(jsonOutput)}},
+	}, nil
+}
+
 func (s *Server) handleTrajectoryList(args json.RawMessage) (ToolCallResult, error) {
 	var input TrajectoryListInput
 	if len(args) > 0 {
@@ -431,14 +615,18 @@ func (s *Server) handleTrajectoryScore(args json.RawMessage) (ToolCallResult, er
 		return ToolCallResult{}, fmt.Errorf("session_id is required")
 	}
 
-	if input.Score < 0 || input.Score > 1 {
-		return ToolCallResult{}, fmt.Errorf("score must be between 0.0 and 1.0")
+	session, err := s.store.GetSessionHeader(input.SessionID)
+	if err != nil {
+		return ToolCallResult{}, fmt.Errorf("failed to set outcome: %w", err)
 	}
 
-	outcome := types.Outcome{
-		Score:    input.Score,
-		Notes:    input.Notes,
-		ScoredAt: time.Now(),
+	rubrics, err := rubric.Load(s.rubricConfigPath, s.rubricFiles...)
+	if err != nil {
+		return ToolCallResult{}, err
+	}
+	outcome, err := rubrics.NewOutcome(session.Tags, input.Score, input.Dimensions, input.Notes)
+	if err != nil {
+		return ToolCallResult{}, err
 	}
 
 	if err := s.store.SetOutcome(input.SessionID, outcome); err != nil {
@@ -446,7 +634,105 @@ func (s *Server) handleTrajectoryScore(args json.RawMessage) (ToolCallResult, er
 	}
 
 	return ToolCallResult{
-		Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Session %s scored %.2f", input.SessionID, input.Score)}},
+		Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("Session %s scored %.2f", input.SessionID, outcome.Score)}},
+	}, nil
+}
+
+func (s *Server) handleTrajectoryCompare(args json.RawMessage) (ToolCallResult, error) {
+	var input TrajectoryCompareInput
+	if err := json.Unmarshal(args, &input); err != nil {
+		return ToolCallResult{}, fmt.Errorf("invalid input: %w", err)
+	}
+
+	if input.Tag == "" {
+		return ToolCallResult{}, fmt.Errorf("tag is required")
+	}
+
+	comparisons, err := s.store.ListComparisons(input.Tag)
+	if err != nil {
+		return ToolCallResult{}, fmt.Errorf("failed to list comparisons: %w", err)
+	}
+
+	// Pick the next pair when none was given
+	if input.SessionA == "" && input.SessionB == "" && input.Winner == "" {
+		metas, err := s.store.QuerySessions(store.Query{Tags: []string{input.Tag}})
+		if err != nil {
+			return ToolCallResult{}, fmt.Errorf("failed to query sessions: %w", err)
+		}
+		var ids []string
+		for _, meta := range metas {
+			if meta.Status != string(types.StatusRecording) {
+				ids = append(ids, meta.ID)
+			}
+		}
+		a, b, ok := preference.NextPair(ids, comparisons)
+		if !ok {
+			return ToolCallResult{
+				Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("No pairs left to compare for tag '%s' (%d sessions)", input.Tag, len(ids))}},
+			}, nil
+		}
+		input.SessionA, input.SessionB = a, b
+	}
+
+	if input.SessionA == "" || input.SessionB == "" {
+		return ToolCallResult{}, fmt.Errorf("session_a and session_b are required")
+	}
+	if input.SessionA == input.SessionB {
+		return ToolCallResult{}, fmt.Errorf("session_a and session_b must be different sessions")
+	}
+	a, err := s.store.GetSession(input.SessionA)
+	if err != nil {
+		return ToolCallResult{}, fmt.Errorf("session not found: %w", err)
+	}
+	b, err := s.store.GetSession(input.SessionB)
+	if err != nil {
+		return ToolCallResult{}, fmt.Errorf("session not found: %w", err)
+	}
+	for _, session := range []*types.Session{a, b} {
+		if !session.HasTag(input.Tag) {
+			return ToolCallResult{}, fmt.Errorf("session %s isn't tagged '%s'", session.ID, input.Tag)
+		}
+	}
+
+	if input.Winner == "" {
+		var output strings.Builder
+		output.WriteString(summarize.FormatComparison(a, b))
+		output.WriteString("\n---\n\n")
+		output.WriteString("Judge which session handled its task better, then call `trajectory_compare` with:\n")
+		output.WriteString(fmt.Sprintf("- tag: \"%s\"\n", input.Tag))
+		output.WriteString(fmt.Sprintf("- session_a: \"%s\"\n", a.ID))
+		output.WriteString(fmt.Sprintf("- session_b: \"%s\"\n", b.ID))
+		output.WriteString("- winner: \"a\", \"b\" or \"tie\"\n")
+		return ToolCallResult{
+			Content: []ContentBlock{{Type: "text", Text: output.String()}},
+		}, nil
+	}
+
+	comparison := &types.Comparison{Tag: input.Tag, Notes: input.Notes}
+	switch input.Winner {
+	case "a":
+		comparison.Winner, comparison.Loser = a.ID, b.ID
+	case "b":
+		comparison.Winner, comparison.Loser = b.ID, a.ID
+	case "tie":
+		comparison.Winner, comparison.Loser, comparison.Tie = a.ID, b.ID, true
+	default:
+		return ToolCallResult{}, fmt.Errorf("winner must be 'a', 'b' or 'tie'")
+	}
+	if err := s.store.RecordComparison(comparison); err != nil {
+		return ToolCallResult{}, fmt.Errorf("failed to record comparison: %w", err)
+	}
+
+	ratings := preference.Fit(append(comparisons, *comparison))
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Comparison recorded for tag '%s'. Preference scores:\n", input.Tag))
+	for _, id := range []string{a.ID, b.ID} {
+		r := ratings[id]
+		output.WriteString(fmt.Sprintf("- %s: %.2f (%d-%d-%d)\n", id, r.Score, r.Wins, r.Losses, r.Ties))
+	}
+
+	return ToolCallResult{
+		Content: []ContentBlock{{Type: "text", Text: output.String()}},
 	}, nil
 }
 
@@ -518,9 +804,21 @@ func (s *Server) handleOptimizePropose(args json.RawMessage) (ToolCallResult, er
 		}
 	}
 
+	settings, err := optimizer.LoadSettings(s.analyzerConfigPath)
+	if err != nil {
+		return ToolCallResult{}, err
+	}
+	s.optimizer.SetSettings(settings)
+
 	// Generate proposals for each target
 	var output strings.Builder
 	for _, target := range targets {
+		if input.IncludeAutoScored {
+			target.IncludeAutoScored = true
+		}
+		if input.UsePreferences {
+			target.UsePreferences = true
+		}
 		result, err := s.optimizer.Propose(target)
 		if err != nil {
 			output.WriteString(fmt.Sprintf("## Target: %s (SKIPPED)\n%v\n\n", target.Tag, err))
@@ -678,9 +976,17 @@ func (s *Server) handleCurateExamples(args json.RawMessage) (ToolCallResult, err
 		maxExamples = input.MaxExamples
 	}
 
+	settings, err := optimizer.LoadSettings(s.analyzerConfigPath)
+	if err != nil {
+		return ToolCallResult{}, err
+	}
+
 	// Use analyzer to get curated examples
 	analyzer := optimizer.NewAnalyzer(s.boltStore)
-	analysis, err := analyzer.Analyze(input.Tag, 3) // Low minimum for curation
+	analyzer.IncludeAutoScored = input.IncludeAutoScored
+	analyzer.UsePreferences = input.UsePreferences
+	analyzer.Configure(settings.For(input.Tag))
+	analysis, err := analyzer.AnalyzeDimension(input.Tag, input.Dimension, 3) // Low minimum for curation
 	if err != nil {
 		return ToolCallResult{}, fmt.Errorf("analysis failed: %w", err)
 	}
@@ -823,7 +1129,7 @@ func formatCuratedExamples(examples []types.CuratedExample, includeNegative bool
 	buf.WriteString("### What Works Well (from past sessions)\n\n")
 
 	for _, ex := range examples {
-		if ex.Score >= 0.75 {
+		if !ex.Negative {
 			buf.WriteString(fmt.Sprintf("**Example: %s** (scored %.0f%%)\n",
 				truncateString(ex.TaskPrompt, 50), ex.Score*100))
 			if ex.Summary != "" {
@@ -836,7 +1142,7 @@ func formatCuratedExamples(examples []types.CuratedExample, includeNegative bool
 
 	if includeNegative {
 		for _, ex := range examples {
-			if ex.Score < 0.5 {
+			if ex.Negative {
 				buf.WriteString("### What to Avoid\n\n")
 				buf.WriteString(fmt.Sprintf("**Anti-example: %s** (scored %.0f%%)\n",
 					truncateString(ex.TaskPrompt, 50), ex.Score*100))
@@ -935,7 +1241,7 @@ func (s *Server) handleStrategiesList(args json.RawMessage) (ToolCallResult, err
 
 	// Get usage stats if available
 	if s.boltStore != nil {
-		stats, _ := s.boltStore.GetStrategyStats(input.Tag)
+		stats := s.strategyStats(input.Tag, strategies, input.Aggregate)
 		for i, strat := range strategies {
 			if stat, ok := stats[strat.Name]; ok {
 				strategies[i].AvgScore = stat.AvgScore
@@ -965,6 +1271,48 @@ func (s *Server) handleStrategiesList(args json.RawMessage) (ToolCallResult, err
 	}, nil
 }
 
+// strategyStats returns the stats of each strategy's current version, keyed
+// by name, so editing an approach prompt starts its record over. With
+// aggregate, every version of a strategy counts together. It returns nil
+// without a store.
+func (s *Server) strategyStats(tag string, strategies []types.Strategy, aggregate bool) map[string]*types.Strategy {
+	if s.boltStore == nil {
+		return nil
+	}
+	if aggregate {
+		stats, _ := s.boltStore.GetStrategyStats(tag)
+		return stats
+	}
+
+	versions, _ := s.boltStore.GetStrategyVersionStats(tag)
+	current := make(map[string]string)
+	for _, strat := range strategies {
+		current[strat.Name] = strat.Version
+	}
+	stats := make(map[string]*types.Strategy)
+	for i, v := range versions {
+		if v.Version == current[v.Name] {
+			stats[v.Name] = &versions[i]
+		}
+	}
+	return stats
+}
+
+// currentVersionUsage keeps the usage of each strategy's current version.
+func currentVersionUsage(usages []types.StrategyUsage, strategies []types.Strategy) []types.StrategyUsage {
+	current := make(map[string]string)
+	for _, strat := range strategies {
+		current[strat.Name] = strat.Version
+	}
+	var kept []types.StrategyUsage
+	for _, usage := range usages {
+		if version, ok := current[usage.StrategyName]; ok && usage.Version == version {
+			kept = append(kept, usage)
+		}
+	}
+	return kept
+}
+
 func (s *Server) handleStrategiesSelect(args json.RawMessage) (ToolCallResult, error) {
 	var input TrajectoryStrategiesSelectInput
 	if err := json.Unmarshal(args, &input); err != nil {
@@ -974,9 +1322,6 @@ func (s *Server) handleStrategiesSelect(args json.RawMessage) (ToolCallResult, e
 	if input.Tag == "" {
 		return ToolCallResult{}, fmt.Errorf("tag is required")
 	}
-	if input.Mode == "" {
-		return ToolCallResult{}, fmt.Errorf("mode is required")
-	}
 
 	// Default to CLAUDE.md
 	filePath := input.FilePath
@@ -1013,11 +1358,21 @@ func (s *Server) handleStrategiesSelect(args json.RawMessage) (ToolCallResult, e
 		return ToolCallResult{}, fmt.Errorf("no strategies defined for tag: %s", input.Tag)
 	}
 
+	// Fall back to the mode set on the strategies marker
+	mode := types.StrategySelectionMode(input.Mode)
+	if mode == "" {
+		mode = matchingTarget.DefaultMode
+	}
+	if mode == "" {
+		return ToolCallResult{}, fmt.Errorf("mode is required (or set a default with mode=... on the strategies marker)")
+	}
+
 	var selectedStrategy *types.Strategy
 	var reason string
+	var candidates string
 
-	switch input.Mode {
-	case "explicit":
+	switch mode {
+	case types.StrategyModeExplicit:
 		if input.StrategyName == "" {
 			return ToolCallResult{}, fmt.Errorf("strategy_name is required for explicit mode")
 		}
@@ -1032,12 +1387,9 @@ func (s *Server) handleStrategiesSelect(args json.RawMessage) (ToolCallResult, e
 			return ToolCallResult{}, fmt.Errorf("strategy '%s' not found", input.StrategyName)
 		}
 
-	case "recommend":
+	case types.StrategyModeRecommend:
 		// Get stats and recommend best performer
-		var stats map[string]*types.Strategy
-		if s.boltStore != nil {
-			stats, _ = s.boltStore.GetStrategyStats(input.Tag)
-		}
+		stats := s.strategyStats(input.Tag, strategies, input.Aggregate)
 
 		var bestScore float64 = -1
 		for _, strat := range strategies {
@@ -1059,12 +1411,9 @@ func (s *Server) handleStrategiesSelect(args json.RawMessage) (ToolCallResult, e
 			reason = "Default (no performance data yet)"
 		}
 
-	case "rotate":
+	case types.StrategyModeRotate:
 		// Find least-used strategy for exploration
-		var stats map[string]*types.Strategy
-		if s.boltStore != nil {
-			stats, _ = s.boltStore.GetStrategyStats(input.Tag)
-		}
+		stats := s.strategyStats(input.Tag, strategies, input.Aggregate)
 
 		var minCount int = 999999
 		for _, strat := range strategies {
@@ -1083,26 +1432,180 @@ func (s *Server) handleStrategiesSelect(args json.RawMessage) (ToolCallResult, e
 			}
 		}
 
+	case types.StrategyModeThompson, types.StrategyModeUCB1, types.StrategyModeEpsilonGreedy:
+		// Treat strategies as bandit arms scored by their past sessions
+		stats := s.strategyStats(input.Tag, strategies, input.Aggregate)
+
+		arms := make([]bandit.Arm, len(strategies))
+		for i, strat := range strategies {
+			arms[i] = bandit.Arm{Name: strat.Name}
+			if stat, ok := stats[strat.Name]; ok {
+				arms[i].Pulls, arms[i].Mean = stat.ScoredCount, stat.AvgScore
+				strategies[i].AvgScore = stat.AvgScore
+				strategies[i].SessionCount = stat.SessionCount
+			}
+		}
+
+		var choice bandit.Choice
+		valueLabel := "Posterior draw"
+		switch mode {
+		case types.StrategyModeThompson:
+			choice = bandit.Thompson(arms, s.rng)
+		case types.StrategyModeUCB1:
+			choice = bandit.UCB1(arms)
+			valueLabel = "Upper bound"
+		default:
+			choice = bandit.EpsilonGreedy(arms, input.Epsilon, s.rng)
+			valueLabel = "Average"
+		}
+		selectedStrategy = &strategies[choice.Index]
+		reason = choice.Reason
+		candidates = formatBanditCandidates(arms, choice, valueLabel)
+
+	case types.StrategyModeContextual:
+		// Relearn from every scored use of the tag's strategies, then score this
+		// task. The saved model is a snapshot for `strategies weights`; selecting
+		// never reads it back, so rescored sessions count immediately.
+		if s.boltStore == nil {
+			return ToolCallResult{}, fmt.Errorf("contextual mode is not available")
+		}
+		usages, err := s.boltStore.GetStrategyUsage(input.Tag, 0)
+		if err != nil {
+			return ToolCallResult{}, fmt.Errorf("failed to get strategy usage: %w", err)
+		}
+		if !input.Aggregate {
+			usages = currentVersionUsage(usages, strategies)
+		}
+
+		names := make([]string, len(strategies))
+		for i, strat := range strategies {
+			names[i] = strat.Name
+		}
+		model := bandit.Fit(input.Tag, names, bandit.Samples(usages, s.boltStore.GetSessionHeader), 0)
+		if err := s.boltStore.SaveStrategyModel(model); err != nil {
+			return ToolCallResult{}, fmt.Errorf("failed to save strategy model: %w", err)
+		}
+
+		features := s.selectionFeatures(input)
+		choice := bandit.LinUCB(model, names, features)
+		selectedStrategy = &strategies[choice.Index]
+		reason = choice.Reason
+		candidates = formatContextualCandidates(model, names, features, choice)
+
 	default:
-		return ToolCallResult{}, fmt.Errorf("invalid mode: %s (use explicit, recommend, or rotate)", input.Mode)
+		return ToolCallResult{}, fmt.Errorf("invalid mode: %s (use explicit, recommend, rotate, thompson, ucb1, epsilon_greedy, or contextual)", mode)
 	}
 
 	var output strings.Builder
 	output.WriteString(fmt.Sprintf("## Selected Strategy: %s\n\n", selectedStrategy.Name))
 	output.WriteString(fmt.Sprintf("**Selection reason:** %s\n\n", reason))
+	output.WriteString(candidates)
 	if selectedStrategy.Description != "" {
 		output.WriteString(fmt.Sprintf("**Description:** %s\n\n", selectedStrategy.Description))
 	}
+	output.WriteString(fmt.Sprintf("**Version:** %s\n\n", selectedStrategy.Version))
 	output.WriteString("**Approach to use:**\n```\n")
 	output.WriteString(selectedStrategy.ApproachPrompt)
 	output.WriteString("\n```\n\n")
-	output.WriteString(fmt.Sprintf("After completing the task, record this strategy with `trajectory_strategies_record` using strategy_name=\"%s\"", selectedStrategy.Name))
+
+	// Bind the strategy to the active session so stopping it records usage
+	session, err := s.activeRecording()
+	if err == nil && s.boltStore != nil {
+		if err := s.boltStore.SetSessionStrategy(session.ID, input.Tag, selectedStrategy.Name, selectedStrategy.Version); err != nil {
+			return ToolCallResult{}, fmt.Errorf("failed to bind strategy to session: %w", err)
+		}
+		output.WriteString(fmt.Sprintf("Bound to active session %s. Its score will be credited to this strategy when it is stopped or scored.", session.ID))
+	} else {
+		output.WriteString(fmt.Sprintf("No active session to bind to. Start recording with `trajectory_start` and select again, or record this strategy with `trajectory_strategies_record` using strategy_name=\"%s\"", selectedStrategy.Name))
+	}
 
 	return ToolCallResult{
 		Content: []ContentBlock{{Type: "text", Text: output.String()}},
 	}, nil
 }
 
+// formatBanditCandidates lists every strategy with the value a bandit mode
+// ranked it by.
+func formatBanditCandidates(arms []bandit.Arm, choice bandit.Choice, valueLabel string) string {
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("| Strategy | Scored Sessions | Avg Score | %s |\n", valueLabel))
+	buf.WriteString("|----------|-----------------|-----------|------|\n")
+	for i, arm := range arms {
+		avg, value := "N/A", "untried"
+		if arm.Pulls > 0 {
+			avg = fmt.Sprintf("%.2f", arm.Mean)
+		}
+		if !math.IsInf(choice.Values[i], 1) {
+			value = fmt.Sprintf("%.2f", choice.Values[i])
+		}
+		name := arm.Name
+		if i == choice.Index {
+			name = "**" + name + "**"
+		}
+		buf.WriteString(fmt.Sprintf("| %s | %d | %s | %s |\n", name, arm.Pulls, avg, value))
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+// selectionFeatures describes the task a strategy is being selected for,
+// from the prompt and tags given or else the caller's active session.
+func (s *Server) selectionFeatures(input TrajectoryStrategiesSelectInput) map[string]float64 {
+	prompt, tags, at := input.TaskPrompt, input.Tags, time.Now()
+	if session, err := s.activeRecording(); err == nil {
+		if prompt == "" {
+			prompt = session.TaskPrompt
+		}
+		if tags == nil {
+			tags = session.Tags
+		}
+		at = session.StartedAt
+	}
+
+	// Every sample shares the strategies tag, so it carries no signal
+	var others []string
+	for _, tag := range tags {
+		if tag != input.Tag {
+			others = append(others, tag)
+		}
+	}
+	return bandit.TaskFeatures(prompt, others, at)
+}
+
+// formatContextualCandidates lists every strategy with the score the
+// contextual model predicts for this task and its confidence bound.
+func formatContextualCandidates(model *types.StrategyModel, names []string, features map[string]float64, choice bandit.Choice) string {
+	var buf strings.Builder
+
+	var active []string
+	for _, name := range model.Features {
+		if v := features[name]; v != 0 && name != bandit.FeatureBias {
+			active = append(active, fmt.Sprintf("%s=%.2f", name, v))
+		}
+	}
+	if len(active) > 0 {
+		buf.WriteString(fmt.Sprintf("**Task features:** %s\n\n", strings.Join(active, ", ")))
+	}
+
+	buf.WriteString("| Strategy | Scored Sessions | Predicted | Bonus | Bound |\n")
+	buf.WriteString("|----------|-----------------|-----------|-------|-------|\n")
+	for i, name := range names {
+		score, bonus := bandit.Predict(model, name, features)
+		pulls := 0
+		for _, arm := range model.Arms {
+			if arm.Name == name {
+				pulls = arm.Pulls
+			}
+		}
+		if i == choice.Index {
+			name = "**" + name + "**"
+		}
+		buf.WriteString(fmt.Sprintf("| %s | %d | %.2f | %.2f | %.2f |\n", name, pulls, score, bonus, choice.Values[i]))
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
 func (s *Server) handleStrategiesRecord(args json.RawMessage) (ToolCallResult, error) {
 	if s.boltStore == nil {
 		return ToolCallResult{}, fmt.Errorf("strategy recording not available")
@@ -1123,10 +1626,26 @@ func (s *Server) handleStrategiesRecord(args json.RawMessage) (ToolCallResult, e
 		return ToolCallResult{}, fmt.Errorf("strategy_name is required")
 	}
 
+	// Credit the version bound when the strategy was selected, or else the
+	// version currently in the strategies file, if it's there
+	var version string
+	if session, err := s.boltStore.GetSessionHeader(input.SessionID); err == nil &&
+		session.StrategyTag == input.Tag && session.Strategy == input.StrategyName {
+		version = session.StrategyVersion
+	}
+	if version == "" {
+		filePath := input.FilePath
+		if filePath == "" {
+			filePath = "CLAUDE.md"
+		}
+		versions, _ := optimizer.NewParser().StrategyVersions(filePath, input.Tag)
+		version = versions[input.StrategyName]
+	}
 	usage := types.StrategyUsage{
 		Tag:          input.Tag,
 		StrategyName: input.StrategyName,
 		SessionID:    input.SessionID,
+		Version:      version,
 		UsedAt:       time.Now(),
 	}
 
@@ -1134,11 +1653,9 @@ func (s *Server) handleStrategiesRecord(args json.RawMessage) (ToolCallResult, e
 		return ToolCallResult{}, fmt.Errorf("failed to record strategy usage: %w", err)
 	}
 
-	// Also update the session's Strategy field
-	session, err := s.store.GetSession(input.SessionID)
-	if err == nil {
-		session.Strategy = input.StrategyName
-		s.store.UpdateSession(session)
+	// Also bind the session, which carries over a score it already has
+	if err := s.boltStore.SetSessionStrategy(input.SessionID, input.Tag, input.StrategyName, usage.Version); err != nil && err != store.ErrSessionNotFound {
+		return ToolCallResult{}, fmt.Errorf("failed to bind strategy to session: %w", err)
 	}
 
 	return ToolCallResult{
@@ -1146,6 +1663,46 @@ func (s *Server) handleStrategiesRecord(args json.RawMessage) (ToolCallResult, e
 	}, nil
 }
 
+// unrecordedStrategySessions warns about sessions under a tag that name a
+// strategy but have no usage record, so their scores are missing from the
+// stats. It returns an empty string when there are none.
+func (s *Server) unrecordedStrategySessions(tag string) (string, error) {
+	sessions, err := s.boltStore.SessionsWithoutStrategyUsage()
+	if err != nil {
+		return "", fmt.Errorf("failed to check strategy usage: %w", err)
+	}
+
+	var buf strings.Builder
+	for _, session := range sessions {
+		if session.StrategyTag != tag && (session.StrategyTag != "" || !session.HasTag(tag)) {
+			continue
+		}
+		if buf.Len() == 0 {
+			buf.WriteString("\n**Warning:** these sessions name a strategy but have no usage record, so their scores are not counted. Record them with `trajectory_strategies_record`:\n")
+		}
+		buf.WriteString(fmt.Sprintf("- %s: %s (%s)\n", session.ID, session.Strategy, truncateString(session.TaskPrompt, 60)))
+	}
+	return buf.String(), nil
+}
+
+// formatStrategyVersions tables how each version of each strategy performed,
+// marking the versions currently defined.
+func formatStrategyVersions(versions []types.Strategy, current map[string]string) string {
+	var buf strings.Builder
+	buf.WriteString("| Strategy | Version | Sessions | Avg Score |\n")
+	buf.WriteString("|----------|---------|----------|-----------|\n")
+	for _, v := range versions {
+		label := v.Version
+		if label == "" {
+			label = "unversioned"
+		} else if current[v.Name] == v.Version {
+			label += " (current)"
+		}
+		buf.WriteString(fmt.Sprintf("| %s | %s | %d | %s |\n", v.Name, label, v.SessionCount, optimizer.FormatStrategyScore(v)))
+	}
+	return buf.String()
+}
+
 func (s *Server) handleStrategiesAnalyze(args json.RawMessage) (ToolCallResult, error) {
 	if s.boltStore == nil {
 		return ToolCallResult{}, fmt.Errorf("strategy analysis not available")
@@ -1165,17 +1722,45 @@ func (s *Server) handleStrategiesAnalyze(args json.RawMessage) (ToolCallResult,
 		return ToolCallResult{}, fmt.Errorf("failed to get strategy stats: %w", err)
 	}
 
+	unrecorded, err := s.unrecordedStrategySessions(input.Tag)
+	if err != nil {
+		return ToolCallResult{}, err
+	}
+
 	if len(stats) == 0 {
 		return ToolCallResult{
-			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("No strategy usage data for tag '%s' yet. Use `trajectory_strategies_record` to associate strategies with sessions.", input.Tag)}},
+			Content: []ContentBlock{{Type: "text", Text: fmt.Sprintf("No strategy usage data for tag '%s' yet. Select a strategy with `trajectory_strategies_select` during a recording to associate it with the session.", input.Tag) + unrecorded}},
 		}, nil
 	}
 
 	// Build analysis
 	var output strings.Builder
 	output.WriteString(fmt.Sprintf("## Strategy Analysis for '%s'\n\n", input.Tag))
-	output.WriteString("| Strategy | Sessions | Avg Score |\n")
-	output.WriteString("|----------|----------|----------|\n")
+	if input.Aggregate {
+		names := make([]string, 0, len(stats))
+		for name := range stats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		output.WriteString("| Strategy | Sessions | Avg Score |\n")
+		output.WriteString("|----------|----------|----------|\n")
+		for _, name := range names {
+			output.WriteString(fmt.Sprintf("| %s | %d | %s |\n", name, stats[name].SessionCount, optimizer.FormatStrategyScore(*stats[name])))
+		}
+	} else {
+		filePath := input.FilePath
+		if filePath == "" {
+			filePath = "CLAUDE.md"
+		}
+		versions, err := s.boltStore.GetStrategyVersionStats(input.Tag)
+		if err != nil {
+			return ToolCallResult{}, fmt.Errorf("failed to get strategy version stats: %w", err)
+		}
+		// The file only marks which versions are current, so analysis works without it
+		current, _ := optimizer.NewParser().StrategyVersions(filePath, input.Tag)
+		output.WriteString(formatStrategyVersions(optimizer.WithCurrentVersions(versions, current), current))
+	}
 
 	var bestStrategy string
 	var bestScore float64 = -1
@@ -1184,12 +1769,6 @@ func (s *Server) handleStrategiesAnalyze(args json.RawMessage) (ToolCallResult,
 	var leastUsedCount int = 999999
 
 	for name, strat := range stats {
-		scoreStr := "N/A"
-		if strat.SessionCount > 0 && strat.AvgScore > 0 {
-			scoreStr = fmt.Sprintf("%.2f", strat.AvgScore)
-		}
-		output.WriteString(fmt.Sprintf("| %s | %d | %s |\n", name, strat.SessionCount, scoreStr))
-
 		totalSessions += strat.SessionCount
 
 		if strat.AvgScore > bestScore && strat.SessionCount >= 2 {
@@ -1227,9 +1806,95 @@ func (s *Server) handleStrategiesAnalyze(args json.RawMessage) (ToolCallResult,
 	}
 
 	output.WriteString(fmt.Sprintf("\n**Total sessions analyzed:** %d\n", totalSessions))
+	output.WriteString(unrecorded)
 
 	return ToolCallResult{
 		Content: []ContentBlock{{Type: "text", Text: output.String()}},
 	}, nil
 }
 
+func (s *Server) handleStrategiesEvolve(args json.RawMessage) (ToolCallResult, error) {
+	if s.optimizer == nil {
+		return ToolCallResult{}, fmt.Errorf("optimization features not available")
+	}
+
+	var input TrajectoryStrategiesEvolveInput
+	if err := json.Unmarshal(args, &input); err != nil {
+		return ToolCallResult{}, fmt.Errorf("invalid input: %w", err)
+	}
+
+	if input.Tag == "" {
+		return ToolCallResult{}, fmt.Errorf("tag is required")
+	}
+
+	var output strings.Builder
+
+	// Add the generated strategies to the proposal
+	if input.Content != "" {
+		if input.RecordID == "" {
+			return ToolCallResult{}, fmt.Errorf("record_id is required with content")
+		}
+		record, err := s.optimizer.SaveStrategiesProposal(input.RecordID, input.Content)
+		if err != nil {
+			return ToolCallResult{}, err
+		}
+
+		output.WriteString("## Strategies Proposal Saved\n\n")
+		output.WriteString(fmt.Sprintf("**Record ID:** %s\n", record.ID))
+		output.WriteString(fmt.Sprintf("**Status:** %s\n", record.Status))
+		if len(record.Retired) > 0 {
+			output.WriteString(fmt.Sprintf("**Retires:** %s\n", strings.Join(record.Retired, ", ")))
+		}
+		output.WriteString("\n### Diff\n```diff\n")
+		output.WriteString(record.Diff)
+		output.WriteString("```\n\n")
+		output.WriteString("To apply these strategies, call `trajectory_optimize_apply` with:\n")
+		output.WriteString(fmt.Sprintf("- record_id: \"%s\"\n\n", record.ID))
+		output.WriteString("Retired strategies are archived with their stats when applied. To undo, call `trajectory_optimize_rollback` with the same record_id.\n")
+
+		return ToolCallResult{
+			Content: []ContentBlock{{Type: "text", Text: output.String()}},
+		}, nil
+	}
+
+	// Default to CLAUDE.md in current directory
+	filePath := input.FilePath
+	if filePath == "" {
+		filePath = "CLAUDE.md"
+	}
+
+	targets, err := optimizer.NewParser().FindStrategiesTargets(filePath)
+	if err != nil {
+		return ToolCallResult{}, fmt.Errorf("failed to parse file: %w", err)
+	}
+
+	var matchingTarget *types.StrategiesTarget
+	for _, t := range targets {
+		if t.Tag == input.Tag {
+			matchingTarget = &t
+			break
+		}
+	}
+
+	if matchingTarget == nil {
+		return ToolCallResult{}, fmt.Errorf("no strategies found for tag: %s", input.Tag)
+	}
+
+	result, err := s.optimizer.ProposeStrategies(*matchingTarget)
+	if err != nil {
+		return ToolCallResult{}, err
+	}
+
+	output.WriteString(result.Prompt)
+	output.WriteString(fmt.Sprintf("\n---\n**Record ID:** %s\n", result.Record.ID))
+	output.WriteString(fmt.Sprintf("**File:** %s\n", filePath))
+	output.WriteString(fmt.Sprintf("**Tag:** %s\n\n", input.Tag))
+	output.WriteString("After generating the new strategies, call `trajectory_strategies_evolve` again with:\n")
+	output.WriteString(fmt.Sprintf("- record_id: \"%s\"\n", result.Record.ID))
+	output.WriteString(fmt.Sprintf("- tag: \"%s\"\n", input.Tag))
+	output.WriteString("- content: (your generated strategies YAML)\n")
+
+	return ToolCallResult{
+		Content: []ContentBlock{{Type: "text", Text: output.String()}},
+	}, nil
+}
@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/johncarpenter/trajectory-memory/internal/ingestion"
+	"github.com/johncarpenter/trajectory-memory/internal/optimizer"
 	"github.com/johncarpenter/trajectory-memory/internal/store"
 	"github.com/johncarpenter/trajectory-memory/internal/types"
 )
@@ -120,8 +124,10 @@ func TestToolsList(t *testing.T) {
 		"trajectory_stop",
 		"trajectory_status",
 		"trajectory_search",
+		"trajectory_similar",
 		"trajectory_list",
 		"trajectory_score",
+		"trajectory_compare",
 		"trajectory_summarize",
 		"trajectory_optimize_propose",
 		"trajectory_optimize_save",
@@ -136,6 +142,7 @@ func TestToolsList(t *testing.T) {
 		"trajectory_strategies_select",
 		"trajectory_strategies_record",
 		"trajectory_strategies_analyze",
+		"trajectory_strategies_evolve",
 	}
 
 	if len(result.Tools) != len(expectedTools) {
@@ -177,11 +184,14 @@ func TestTrajectoryStart(t *testing.T) {
 		t.Errorf("unexpected tool error: %v", result.Content)
 	}
 
-	// Verify session was created
-	session, err := s.GetActiveSession()
+	// Verify session was created, pending until a hook reports whose it is
+	session, err := s.GetSession(server.recordingID)
 	if err != nil {
 		t.Fatalf("no active session: %v", err)
 	}
+	if bindings, _ := s.ListActiveSessions(); len(bindings) != 0 {
+		t.Errorf("expected the recording to wait for its Claude session, got %v", bindings)
+	}
 
 	if session.TaskPrompt != "Test task" {
 		t.Errorf("expected task prompt 'Test task', got %s", session.TaskPrompt)
@@ -191,6 +201,74 @@ func TestTrajectoryStart(t *testing.T) {
 	}
 }
 
+func TestTrajectoryStartBriefing(t *testing.T) {
+	server, s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	past := []*types.Session{
+		{
+			ID:         store.NewULID(),
+			TaskPrompt: "Add retry logic to the HTTP client",
+			Summary:    "Wrapped requests with exponential backoff",
+			Status:     types.StatusScored,
+			StartedAt:  time.Now().Add(-time.Hour),
+			Outcome:    &types.Outcome{Score: 0.9, Notes: "Backoff with jitter worked well"},
+		},
+		{
+			ID:         store.NewULID(),
+			TaskPrompt: "Add retry logic to the HTTP client calls",
+			Summary:    "Retried in a tight loop",
+			Status:     types.StatusScored,
+			StartedAt:  time.Now().Add(-time.Hour),
+			Outcome:    &types.Outcome{Score: 0.2, Notes: "Hammered the server"},
+		},
+	}
+	for _, sess := range past {
+		s.CreateSession(sess)
+	}
+
+	start := func(args string) TrajectoryStartOutput {
+		t.Helper()
+		resp := sendRequest(server, "tools/call", ToolCallParams{Name: "trajectory_start", Arguments: json.RawMessage(args)})
+		var result ToolCallResult
+		resultJSON, _ := json.Marshal(resp.Result)
+		json.Unmarshal(resultJSON, &result)
+		if result.IsError {
+			t.Fatalf("unexpected tool error: %v", result.Content)
+		}
+		var output TrajectoryStartOutput
+		json.Unmarshal([]byte(result.Content[0].Text), &output)
+		server.recordingID = ""
+		return output
+	}
+
+	output := start(`{"task_prompt": "Add retry logic to the HTTP client"}`)
+	if output.Briefing == nil {
+		t.Fatal("expected a briefing")
+	}
+	if len(output.Briefing.Similar) != 1 || output.Briefing.Similar[0].SessionID != past[0].ID {
+		t.Errorf("expected the high-scoring session to be recommended, got %+v", output.Briefing.Similar)
+	}
+	if output.Briefing.Similar[0].Notes != "Backoff with jitter worked well" {
+		t.Errorf("expected outcome notes in briefing, got %q", output.Briefing.Similar[0].Notes)
+	}
+	if output.Briefing.Avoid == nil || output.Briefing.Avoid.SessionID != past[1].ID {
+		t.Errorf("expected the low-scoring session to be flagged, got %+v", output.Briefing.Avoid)
+	}
+
+	// A tiny budget keeps only what fits
+	output = start(`{"task_prompt": "Add retry logic to the HTTP client", "briefing_tokens": 60}`)
+	if output.Briefing == nil || output.Briefing.Tokens > 60 || output.Briefing.Avoid != nil {
+		t.Errorf("expected briefing within budget, got %+v", output.Briefing)
+	}
+
+	// The briefing can be turned off
+	output = start(`{"task_prompt": "Add retry logic to the HTTP client", "briefing": false}`)
+	if output.Briefing != nil {
+		t.Errorf("expected no briefing when disabled, got %+v", output.Briefing)
+	}
+}
+
 func TestTrajectoryStartDuplicate(t *testing.T) {
 	server, s, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -289,6 +367,108 @@ func TestTrajectoryStop(t *testing.T) {
 	}
 }
 
+func TestTrajectoryStopMarksIncompleteSteps(t *testing.T) {
+	server, s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	session := &types.Session{
+		ID:         store.NewULID(),
+		TaskPrompt: "Test task",
+		Status:     types.StatusRecording,
+		StartedAt:  time.Now(),
+		Steps: []types.TrajectoryStep{
+			{ToolName: "Read", ToolUseID: "toolu_1", Status: types.StepStatusCompleted},
+			{ToolName: "Bash", ToolUseID: "toolu_2", Status: types.StepStatusStarted},
+		},
+	}
+	s.CreateSession(session)
+	s.SetActiveSession(session.ID)
+
+	sendRequest(server, "tools/call", ToolCallParams{
+		Name:      "trajectory_stop",
+		Arguments: json.RawMessage(`{}`),
+	})
+
+	updated, err := s.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if updated.Steps[0].Status != types.StepStatusCompleted {
+		t.Errorf("completed step should be unchanged, got %s", updated.Steps[0].Status)
+	}
+	if updated.Steps[1].Status != types.StepStatusIncomplete {
+		t.Errorf("expected unfinished step to be incomplete, got %s", updated.Steps[1].Status)
+	}
+
+	// The interrupted step counts against the provisional score
+	if updated.AutoOutcome == nil || updated.AutoOutcome.Score >= 1 {
+		t.Errorf("expected a reduced auto score, got %+v", updated.AutoOutcome)
+	}
+	if updated.Outcome != nil || updated.Status != types.StatusCompleted {
+		t.Errorf("auto score should not count as a human score, got %+v with status %s", updated.Outcome, updated.Status)
+	}
+}
+
+func TestTrajectoryStopThroughHooks(t *testing.T) {
+	server, s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	resp := sendRequest(server, "tools/call", ToolCallParams{
+		Name:      "trajectory_start",
+		Arguments: json.RawMessage(`{"task_prompt": "Test task", "briefing": false}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	defer server.ingestionServer.Stop()
+
+	var result ToolCallResult
+	resultJSON, _ := json.Marshal(resp.Result)
+	json.Unmarshal(resultJSON, &result)
+	response, _ := json.Marshal(result.Content)
+
+	// The hooks also see the model's call to trajectory_stop itself, which
+	// never gets its PostToolUse before the session ends
+	for _, payload := range []string{
+		`{"hook_event_name": "PostToolUse", "tool_name": "mcp__trajectory-memory__trajectory_start", "tool_use_id": "toolu_0", "tool_response": ` + string(response) + `}`,
+		`{"hook_event_name": "PreToolUse", "tool_name": "Read", "tool_use_id": "toolu_1", "tool_input": {"file_path": "main.go"}}`,
+		`{"hook_event_name": "PostToolUse", "tool_name": "Read", "tool_use_id": "toolu_1", "tool_response": "package main"}`,
+		`{"hook_event_name": "PreToolUse", "tool_name": "mcp__trajectory-memory__trajectory_stop", "tool_use_id": "toolu_2", "tool_input": {}}`,
+	} {
+		if err := ingestion.Forward(server.socketPath, "", []byte(payload), time.Second); err != nil {
+			t.Fatalf("failed to forward hook payload: %v", err)
+		}
+	}
+
+	resp = sendRequest(server, "tools/call", ToolCallParams{
+		Name:      "trajectory_stop",
+		Arguments: json.RawMessage(`{"auto_summarize": false}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	sessions, err := s.ListSessions(10, 0)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("expected one session, got %d: %v", len(sessions), err)
+	}
+	session, err := s.GetSession(sessions[0].ID)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if len(session.Steps) != 1 || session.Steps[0].ToolName != "Read" || session.Steps[0].IsIncomplete() {
+		t.Fatalf("expected only the completed Read step, got %+v", session.Steps)
+	}
+	if session.AutoOutcome == nil {
+		t.Fatal("expected an auto score")
+	}
+	for _, signal := range session.AutoOutcome.Signals {
+		if signal.Rule == "interrupts" && signal.Score != 1 {
+			t.Errorf("stopping through MCP should not count as an interrupt, got %+v", signal)
+		}
+	}
+}
+
 func TestTrajectoryStatus(t *testing.T) {
 	server, s, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -400,6 +580,87 @@ func TestTrajectorySearch(t *testing.T) {
 	}
 }
 
+func TestTrajectorySimilar(t *testing.T) {
+	server, s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	sessions := []*types.Session{
+		{
+			ID:         store.NewULID(),
+			TaskPrompt: "Add connection pooling to the postgres client",
+			Tags:       []string{"database"},
+			Status:     types.StatusScored,
+			StartedAt:  time.Now(),
+			Outcome:    &types.Outcome{Score: 0.9},
+		},
+		{
+			ID:         store.NewULID(),
+			TaskPrompt: "Pool postgres connections in the worker",
+			Tags:       []string{"database"},
+			Status:     types.StatusScored,
+			StartedAt:  time.Now(),
+			Outcome:    &types.Outcome{Score: 0.3},
+		},
+		{
+			ID:         store.NewULID(),
+			TaskPrompt: "Update the README badges",
+			Tags:       []string{"docs"},
+			Status:     types.StatusScored,
+			StartedAt:  time.Now(),
+			Outcome:    &types.Outcome{Score: 0.9},
+		},
+	}
+	for _, sess := range sessions {
+		s.CreateSession(sess)
+	}
+
+	params := ToolCallParams{
+		Name:      "trajectory_similar",
+		Arguments: json.RawMessage(`{"prompt": "postgres connection pool", "limit": 2}`),
+	}
+	resp := sendRequest(server, "tools/call", params)
+
+	var result ToolCallResult
+	resultJSON, _ := json.Marshal(resp.Result)
+	json.Unmarshal(resultJSON, &result)
+
+	var similar []TrajectorySimilarResult
+	json.Unmarshal([]byte(result.Content[0].Text), &similar)
+
+	if len(similar) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(similar))
+	}
+	for _, r := range similar {
+		if r.SessionID == sessions[2].ID {
+			t.Error("unrelated session should not rank in the top 2")
+		}
+		if r.Similarity <= 0 {
+			t.Errorf("expected positive similarity, got %f", r.Similarity)
+		}
+	}
+
+	// Filter by tag and minimum score
+	params.Arguments = json.RawMessage(`{"prompt": "postgres connection pool", "tag": "database", "min_score": 0.5}`)
+	resp = sendRequest(server, "tools/call", params)
+	resultJSON, _ = json.Marshal(resp.Result)
+	json.Unmarshal(resultJSON, &result)
+	similar = nil
+	json.Unmarshal([]byte(result.Content[0].Text), &similar)
+
+	if len(similar) != 1 || similar[0].SessionID != sessions[0].ID {
+		t.Errorf("expected only the high-scoring database session, got %+v", similar)
+	}
+
+	// Prompt is required
+	params.Arguments = json.RawMessage(`{}`)
+	resp = sendRequest(server, "tools/call", params)
+	resultJSON, _ = json.Marshal(resp.Result)
+	json.Unmarshal(resultJSON, &result)
+	if !result.IsError {
+		t.Error("expected error without prompt")
+	}
+}
+
 func TestTrajectoryScore(t *testing.T) {
 	server, s, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -470,6 +731,127 @@ func TestTrajectoryScoreInvalidRange(t *testing.T) {
 	}
 }
 
+func TestTrajectoryScoreDimensions(t *testing.T) {
+	server, s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	rubricPath := filepath.Join(t.TempDir(), "rubrics.json")
+	os.WriteFile(rubricPath, []byte(`{"rubrics": [{"tag": "backend", "dimensions": [
+		{"name": "correctness", "weight": 0.8},
+		{"name": "efficiency", "weight": 0.2}
+	]}]}`), 0644)
+	server.SetRubricSources(rubricPath)
+
+	session := &types.Session{
+		ID:         store.NewULID(),
+		TaskPrompt: "Test task",
+		Tags:       []string{"backend"},
+		Status:     types.StatusCompleted,
+		StartedAt:  time.Now(),
+	}
+	s.CreateSession(session)
+
+	params := ToolCallParams{
+		Name:      "trajectory_score",
+		Arguments: json.RawMessage(`{"session_id": "` + session.ID + `", "dimensions": {"correctness": 1, "efficiency": 0.5}}`),
+	}
+
+	resp := sendRequest(server, "tools/call", params)
+
+	var result ToolCallResult
+	resultJSON, _ := json.Marshal(resp.Result)
+	json.Unmarshal(resultJSON, &result)
+
+	if result.IsError {
+		t.Fatalf("unexpected error: %v", result.Content)
+	}
+
+	updated, _ := s.GetSession(session.ID)
+	if updated.Outcome == nil {
+		t.Fatal("outcome should be set")
+	}
+	if updated.Outcome.Score < 0.899 || updated.Outcome.Score > 0.901 {
+		t.Errorf("expected composite score 0.9, got %f", updated.Outcome.Score)
+	}
+	if updated.Outcome.Dimensions["efficiency"] != 0.5 {
+		t.Errorf("expected efficiency 0.5, got %v", updated.Outcome.Dimensions)
+	}
+
+	// Dimensions outside the rubric are rejected
+	params.Arguments = json.RawMessage(`{"session_id": "` + session.ID + `", "dimensions": {"style": 1}}`)
+	resp = sendRequest(server, "tools/call", params)
+	resultJSON, _ = json.Marshal(resp.Result)
+	result = ToolCallResult{}
+	json.Unmarshal(resultJSON, &result)
+	if !result.IsError {
+		t.Error("expected error for dimension not in rubric")
+	}
+}
+
+func TestTrajectoryCompare(t *testing.T) {
+	server, s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	var ids []string
+	for _, tags := range [][]string{{"backend"}, {"backend"}, {"frontend"}} {
+		session := &types.Session{
+			ID:         store.NewULID(),
+			TaskPrompt: "Test task",
+			Tags:       tags,
+			Status:     types.StatusCompleted,
+			StartedAt:  time.Now(),
+		}
+		s.CreateSession(session)
+		ids = append(ids, session.ID)
+	}
+
+	call := func(args string) ToolCallResult {
+		resp := sendRequest(server, "tools/call", ToolCallParams{
+			Name:      "trajectory_compare",
+			Arguments: json.RawMessage(args),
+		})
+		var result ToolCallResult
+		resultJSON, _ := json.Marshal(resp.Result)
+		json.Unmarshal(resultJSON, &result)
+		return result
+	}
+
+	// Without a pair, the tool picks one from the tag
+	result := call(`{"tag": "backend"}`)
+	if result.IsError {
+		t.Fatalf("unexpected error: %v", result.Content)
+	}
+	text := result.Content[0].Text
+	if !strings.Contains(text, "# Session A") || !strings.Contains(text, ids[0]) || !strings.Contains(text, ids[1]) {
+		t.Errorf("expected both backend sessions side by side, got: %s", text)
+	}
+
+	result = call(`{"tag": "backend", "session_a": "` + ids[0] + `", "session_b": "` + ids[1] + `", "winner": "b", "notes": "Ran the tests"}`)
+	if result.IsError {
+		t.Fatalf("unexpected error: %v", result.Content)
+	}
+	comparisons, _ := s.ListComparisons("backend")
+	if len(comparisons) != 1 || comparisons[0].Winner != ids[1] || comparisons[0].Loser != ids[0] {
+		t.Errorf("expected b to win, got %+v", comparisons)
+	}
+
+	// Every pair has been compared
+	result = call(`{"tag": "backend"}`)
+	if result.IsError || !strings.Contains(result.Content[0].Text, "No pairs left") {
+		t.Errorf("expected no pairs left, got %v", result.Content)
+	}
+
+	// Sessions must share the tag
+	result = call(`{"tag": "backend", "session_a": "` + ids[0] + `", "session_b": "` + ids[2] + `", "winner": "a"}`)
+	if !result.IsError {
+		t.Error("expected error for session without the tag")
+	}
+	result = call(`{"tag": "backend", "session_a": "` + ids[0] + `", "session_b": "` + ids[1] + `", "winner": "c"}`)
+	if !result.IsError {
+		t.Error("expected error for invalid winner")
+	}
+}
+
 func TestTrajectorySummarize(t *testing.T) {
 	server, s, cleanup := setupTestServer(t)
 	defer cleanup()
@@ -519,3 +901,564 @@ func TestMethodNotFound(t *testing.T) {
 }
 
 // FormatTrajectory tests are in the summarize package
+
+func TestTrajectoryStartConcurrentClaudeSessions(t *testing.T) {
+	serverA, s, cleanup := setupTestServer(t)
+	defer cleanup()
+	// Each Claude Code session runs its own MCP server against the shared store
+	serverB := NewServer(s, serverA.socketPath, "test")
+
+	callTool := func(server *Server, name, args string) ToolCallResult {
+		params := ToolCallParams{
+			Name:      name,
+			Arguments: json.RawMessage(args),
+		}
+		resp := sendRequest(server, "tools/call", params)
+		var result ToolCallResult
+		resultJSON, _ := json.Marshal(resp.Result)
+		json.Unmarshal(resultJSON, &result)
+		return result
+	}
+	forward := func(payload string) {
+		t.Helper()
+		if err := ingestion.Forward(serverA.socketPath, "", []byte(payload), time.Second); err != nil {
+			t.Fatalf("failed to forward hook payload: %v", err)
+		}
+	}
+	// The hooks report trajectory_start's response along with the Claude session ID
+	start := func(server *Server, claudeID, task string) string {
+		t.Helper()
+		result := callTool(server, "trajectory_start", fmt.Sprintf(`{"task_prompt": %q, "briefing": false}`, task))
+		if result.IsError {
+			t.Fatalf("unexpected error starting %s: %v", task, result.Content)
+		}
+		var output TrajectoryStartOutput
+		json.Unmarshal([]byte(result.Content[0].Text), &output)
+		response, _ := json.Marshal(result.Content)
+		forward(fmt.Sprintf(`{"session_id": %q, "hook_event_name": "PostToolUse", "tool_name": "mcp__trajectory-memory__trajectory_start", "tool_response": %s}`, claudeID, response))
+		return output.SessionID
+	}
+
+	sessionA := start(serverA, "claude-a", "Task A")
+	defer serverA.ingestionServer.Stop()
+	serverB.SetIngestionServer(serverA.ingestionServer)
+	sessionB := start(serverB, "claude-b", "Task B")
+
+	// Same Claude session can't start twice
+	if result := callTool(serverA, "trajectory_start", `{"task_prompt": "Task A2", "briefing": false}`); !result.IsError {
+		t.Error("expected error when Claude session is already recording")
+	}
+
+	forward(`{"session_id": "claude-a", "hook_event_name": "PostToolUse", "tool_name": "Read", "tool_use_id": "toolu_a"}`)
+	forward(`{"session_id": "claude-b", "hook_event_name": "PostToolUse", "tool_name": "Bash", "tool_use_id": "toolu_b"}`)
+
+	bindings, err := s.ListActiveSessions()
+	if err != nil || len(bindings) != 2 || bindings["claude-a"] != sessionA || bindings["claude-b"] != sessionB {
+		t.Fatalf("expected each recording bound to its Claude session, got %v (%v)", bindings, err)
+	}
+
+	// Stopping B leaves A recording
+	result := callTool(serverB, "trajectory_stop", `{"auto_summarize": false}`)
+	if result.IsError {
+		t.Fatalf("unexpected error stopping B: %v", result.Content)
+	}
+	if _, err := s.GetActiveSessionFor("claude-b"); err != store.ErrNoActiveSession {
+		t.Errorf("expected claude-b to be stopped, got %v", err)
+	}
+	stopped, _ := s.GetSession(sessionB)
+	if len(stopped.Steps) != 1 || stopped.Steps[0].ToolName != "Bash" || stopped.ClaudeSessionID != "claude-b" {
+		t.Errorf("expected B to hold only its own step, got %+v", stopped)
+	}
+
+	result = callTool(serverA, "trajectory_status", `{}`)
+	var status TrajectoryStatusOutput
+	json.Unmarshal([]byte(result.Content[0].Text), &status)
+	if !status.Active || status.SessionID != sessionA || status.StepCount != 1 {
+		t.Errorf("expected claude-a still recording %s, got %+v", sessionA, status)
+	}
+}
+
+func TestTrajectoryRecordingSurvivesRestart(t *testing.T) {
+	server, s, cleanup := setupTestServer(t)
+	defer cleanup()
+	server.SetOwner("window-1")
+
+	callTool := func(server *Server, name, args string) ToolCallResult {
+		resp := sendRequest(server, "tools/call", ToolCallParams{Name: name, Arguments: json.RawMessage(args)})
+		var result ToolCallResult
+		resultJSON, _ := json.Marshal(resp.Result)
+		json.Unmarshal(resultJSON, &result)
+		return result
+	}
+	forward := func(payload string) {
+		t.Helper()
+		if err := ingestion.Forward(server.socketPath, "", []byte(payload), time.Second); err != nil {
+			t.Fatalf("failed to forward hook payload: %v", err)
+		}
+	}
+	start := func(server *Server, claudeID string) string {
+		t.Helper()
+		result := callTool(server, "trajectory_start", `{"task_prompt": "Test task", "briefing": false}`)
+		if result.IsError {
+			t.Fatalf("unexpected error starting: %v", result.Content)
+		}
+		var output TrajectoryStartOutput
+		json.Unmarshal([]byte(result.Content[0].Text), &output)
+		response, _ := json.Marshal(result.Content)
+		forward(fmt.Sprintf(`{"session_id": %q, "hook_event_name": "PostToolUse", "tool_name": "mcp__trajectory-memory__trajectory_start", "tool_response": %s}`, claudeID, response))
+		return output.SessionID
+	}
+	status := func(server *Server) TrajectoryStatusOutput {
+		var output TrajectoryStatusOutput
+		json.Unmarshal([]byte(callTool(server, "trajectory_status", `{}`).Content[0].Text), &output)
+		return output
+	}
+
+	first := start(server, "claude-a")
+	defer server.ingestionServer.Stop()
+	forward(`{"session_id": "claude-a", "hook_event_name": "PostToolUse", "tool_name": "Read", "tool_use_id": "toolu_1"}`)
+
+	// Claude Code restarts the MCP server for the same window
+	restarted := NewServer(s, server.socketPath, "test")
+	restarted.SetOwner("window-1")
+	restarted.SetIngestionServer(server.ingestionServer)
+	if got := status(restarted); !got.Active || got.SessionID != first || got.StepCount != 1 {
+		t.Fatalf("expected the restarted server to find %s, got %+v", first, got)
+	}
+	if result := callTool(restarted, "trajectory_stop", `{"auto_summarize": false}`); result.IsError {
+		t.Fatalf("unexpected error stopping after restart: %v", result.Content)
+	}
+	if got := status(restarted); got.Active {
+		t.Errorf("expected no recording after stop, got %+v", got)
+	}
+
+	// A server that lost track of its recording can still start a new one;
+	// it replaces the stale binding for its Claude session
+	orphaned := start(restarted, "claude-a")
+	unowned := NewServer(s, server.socketPath, "test")
+	unowned.SetIngestionServer(server.ingestionServer)
+	second := start(unowned, "claude-a")
+	if bindings, _ := s.ListActiveSessions(); bindings["claude-a"] != second {
+		t.Fatalf("expected the new recording bound, got %v", bindings)
+	}
+	if old, _ := s.GetSessionHeader(orphaned); old.Status != types.StatusCompleted {
+		t.Errorf("expected the stale recording to be completed, got %s", old.Status)
+	}
+	if got := status(unowned); !got.Active || got.SessionID != second {
+		t.Errorf("expected the new recording active, got %+v", got)
+	}
+}
+
+func TestStrategiesSelectBandit(t *testing.T) {
+	server, s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	content := `<!-- trajectory-strategies:backend mode=ucb1 -->
+strategies:
+  - name: careful
+    approach_prompt: Read first
+  - name: fast
+    approach_prompt: Edit first
+  - name: fresh
+    approach_prompt: Plan first
+<!-- /trajectory-strategies:backend -->
+`
+	filePath := filepath.Join(t.TempDir(), "CLAUDE.md")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	versions, _ := optimizer.NewParser().StrategyVersions(filePath, "backend")
+	for i, score := range []float64{0.9, 0.8, 0.3} {
+		s.RecordStrategyUsage(types.StrategyUsage{Tag: "backend", StrategyName: "careful", SessionID: "c" + string(rune('0'+i)), Score: score, Version: versions["careful"]})
+	}
+	s.RecordStrategyUsage(types.StrategyUsage{Tag: "backend", StrategyName: "fast", SessionID: "f0", Score: 0.4, Version: versions["fast"]})
+	// Scores of an earlier version of careful don't count toward the current one
+	s.RecordStrategyUsage(types.StrategyUsage{Tag: "backend", StrategyName: "careful", SessionID: "old0", Score: 0.1, Version: "00000000"})
+
+	call := func(args string) ToolCallResult {
+		resp := sendRequest(server, "tools/call", ToolCallParams{
+			Name:      "trajectory_strategies_select",
+			Arguments: json.RawMessage(args),
+		})
+		var result ToolCallResult
+		resultJSON, _ := json.Marshal(resp.Result)
+		json.Unmarshal(resultJSON, &result)
+		return result
+	}
+
+	// The marker's default mode tries the unscored strategy first
+	result := call(`{"tag": "backend", "file_path": "` + filePath + `"}`)
+	if result.IsError {
+		t.Fatalf("unexpected error: %v", result.Content)
+	}
+	text := result.Content[0].Text
+	if !strings.Contains(text, "Selected Strategy: fresh") || !strings.Contains(text, "UCB1 tries every strategy once first") {
+		t.Errorf("expected UCB1 to pick the untried strategy, got: %s", text)
+	}
+	if !strings.Contains(text, "| careful | 3 | 0.67 |") || !strings.Contains(text, "| **fresh** | 0 | N/A | untried |") {
+		t.Errorf("expected a row per strategy, got: %s", text)
+	}
+
+	// Seeded draws repeat
+	var texts []string
+	for i := 0; i < 2; i++ {
+		server.rng = rand.New(rand.NewSource(3))
+		result = call(`{"tag": "backend", "mode": "thompson", "file_path": "` + filePath + `"}`)
+		if result.IsError {
+			t.Fatalf("unexpected error: %v", result.Content)
+		}
+		texts = append(texts, result.Content[0].Text)
+	}
+	if texts[0] != texts[1] || !strings.Contains(texts[0], "Thompson sampling drew") {
+		t.Errorf("expected the same Thompson draw from the same seed, got:\n%s\n%s", texts[0], texts[1])
+	}
+
+	result = call(`{"tag": "backend", "mode": "epsilon_greedy", "epsilon": 0.000001, "file_path": "` + filePath + `"}`)
+	if result.IsError || !strings.Contains(result.Content[0].Text, "Selected Strategy: careful") {
+		t.Errorf("expected epsilon-greedy to exploit careful, got %v", result.Content)
+	}
+
+	result = call(`{"tag": "backend", "mode": "ucb1", "aggregate": true, "file_path": "` + filePath + `"}`)
+	if result.IsError || !strings.Contains(result.Content[0].Text, "| careful | 4 |") {
+		t.Errorf("expected aggregate stats to count every version, got %v", result.Content)
+	}
+
+	result = call(`{"tag": "backend", "mode": "softmax", "file_path": "` + filePath + `"}`)
+	if !result.IsError {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestStrategiesSelectBandit_ZeroScores(t *testing.T) {
+	server, s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	content := `<!-- trajectory-strategies:backend mode=ucb1 -->
+strategies:
+  - name: broken
+    approach_prompt: Guess
+  - name: steady
+    approach_prompt: Read first
+<!-- /trajectory-strategies:backend -->
+`
+	filePath := filepath.Join(t.TempDir(), "CLAUDE.md")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	// A strategy that always scores 0 has been tried, not left untried
+	versions, _ := optimizer.NewParser().StrategyVersions(filePath, "backend")
+	scores := map[string][]float64{"broken": make([]float64, 10), "steady": {0.6, 0.5, 0.7}}
+	for name, list := range scores {
+		for _, score := range list {
+			session := &types.Session{ID: store.NewULID(), TaskPrompt: "Task", Tags: []string{"backend"}, StartedAt: time.Now()}
+			s.CreateSession(session)
+			s.SetSessionStrategy(session.ID, "backend", name, versions[name])
+			s.SetOutcome(session.ID, types.Outcome{Score: score})
+		}
+	}
+
+	resp := sendRequest(server, "tools/call", ToolCallParams{
+		Name:      "trajectory_strategies_select",
+		Arguments: json.RawMessage(`{"tag": "backend", "file_path": "` + filePath + `"}`),
+	})
+	var result ToolCallResult
+	resultJSON, _ := json.Marshal(resp.Result)
+	json.Unmarshal(resultJSON, &result)
+	if result.IsError {
+		t.Fatalf("unexpected error: %v", result.Content)
+	}
+	text := result.Content[0].Text
+	if !strings.Contains(text, "Selected Strategy: steady") || !strings.Contains(text, "| broken | 10 | 0.00 |") {
+		t.Errorf("expected UCB1 to count the zero scores and pick steady, got: %s", text)
+	}
+}
+
+func TestStrategiesSelectContextual(t *testing.T) {
+	server, s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	content := `<!-- trajectory-strategies:backend -->
+strategies:
+  - name: careful
+    approach_prompt: Reproduce the bug first
+  - name: quick
+    approach_prompt: Draft and iterate
+<!-- /trajectory-strategies:backend -->
+`
+	filePath := filepath.Join(t.TempDir(), "CLAUDE.md")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	// careful scores well on fixes, quick on docs
+	versions, _ := optimizer.NewParser().StrategyVersions(filePath, "backend")
+	record := func(prompt, strategy string, score float64) {
+		session := &types.Session{
+			ID:         store.NewULID(),
+			TaskPrompt: prompt,
+			Tags:       []string{"backend"},
+			Status:     types.StatusScored,
+			StartedAt:  time.Now(),
+			Outcome:    &types.Outcome{Score: score},
+		}
+		s.CreateSession(session)
+		s.RecordStrategyUsage(types.StrategyUsage{Tag: "backend", StrategyName: strategy, SessionID: session.ID, Version: versions[strategy]})
+	}
+	for i := 0; i < 8; i++ {
+		record("Fix the crash in parser.go", "careful", 0.9)
+		record("Fix the crash in parser.go", "quick", 0.3)
+		record("Write a guide to the config options", "careful", 0.2)
+		record("Write a guide to the config options", "quick", 0.8)
+	}
+
+	call := func(args string) ToolCallResult {
+		resp := sendRequest(server, "tools/call", ToolCallParams{
+			Name:      "trajectory_strategies_select",
+			Arguments: json.RawMessage(args),
+		})
+		var result ToolCallResult
+		resultJSON, _ := json.Marshal(resp.Result)
+		json.Unmarshal(resultJSON, &result)
+		return result
+	}
+
+	result := call(`{"tag": "backend", "mode": "contextual", "task_prompt": "Fix the failing login in auth.go", "file_path": "` + filePath + `"}`)
+	if result.IsError {
+		t.Fatalf("unexpected error: %v", result.Content)
+	}
+	text := result.Content[0].Text
+	if !strings.Contains(text, "Selected Strategy: careful") || !strings.Contains(text, "LinUCB bound") {
+		t.Errorf("expected careful for a fix, got: %s", text)
+	}
+	if !strings.Contains(text, "mentions_fix=1.00") || !strings.Contains(text, "| **careful** | 16 |") {
+		t.Errorf("expected the task features and a row per strategy, got: %s", text)
+	}
+
+	result = call(`{"tag": "backend", "mode": "contextual", "task_prompt": "Write a guide to deployment", "file_path": "` + filePath + `"}`)
+	if result.IsError || !strings.Contains(result.Content[0].Text, "Selected Strategy: quick") {
+		t.Errorf("expected quick for docs, got %v", result.Content)
+	}
+
+	model, err := s.GetStrategyModel("backend")
+	if err != nil || model == nil || model.Samples != 32 || len(model.Arms) != 2 {
+		t.Errorf("expected the model to be saved, got %+v (%v)", model, err)
+	}
+}
+
+func TestStrategiesSelectBindsActiveSession(t *testing.T) {
+	server, s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	content := `<!-- trajectory-strategies:backend -->
+strategies:
+  - name: careful
+    approach_prompt: Read first
+<!-- /trajectory-strategies:backend -->
+`
+	filePath := filepath.Join(t.TempDir(), "CLAUDE.md")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	call := func(name, args string) ToolCallResult {
+		resp := sendRequest(server, "tools/call", ToolCallParams{
+			Name:      name,
+			Arguments: json.RawMessage(args),
+		})
+		var result ToolCallResult
+		resultJSON, _ := json.Marshal(resp.Result)
+		json.Unmarshal(resultJSON, &result)
+		return result
+	}
+
+	call("trajectory_start", `{"task_prompt": "Add an endpoint", "tags": ["backend"]}`)
+	result := call("trajectory_strategies_select", `{"tag": "backend", "mode": "explicit", "strategy_name": "careful", "file_path": "`+filePath+`"}`)
+	if result.IsError || !strings.Contains(result.Content[0].Text, "Bound to active session") {
+		t.Fatalf("expected the strategy to be bound, got %v", result.Content)
+	}
+
+	// Stopping with a score credits the strategy without a separate record call
+	if result := call("trajectory_stop", `{"score": 0.9}`); result.IsError {
+		t.Fatalf("unexpected error: %v", result.Content)
+	}
+	stats, _ := s.GetStrategyStats("backend")
+	if stats["careful"] == nil || stats["careful"].ScoredCount != 1 || stats["careful"].AvgScore != 0.9 {
+		t.Errorf("expected the stop score in the strategy stats, got %+v", stats["careful"])
+	}
+
+	// Sessions that name a strategy without a usage record are flagged
+	legacy := &types.Session{
+		ID:         store.NewULID(),
+		TaskPrompt: "Fix the login",
+		Tags:       []string{"backend"},
+		Strategy:   "quick",
+		Status:     types.StatusCompleted,
+		StartedAt:  time.Now(),
+	}
+	s.CreateSession(legacy)
+	result = call("trajectory_strategies_analyze", `{"tag": "backend"}`)
+	text := result.Content[0].Text
+	if !strings.Contains(text, "**Warning:**") || !strings.Contains(text, legacy.ID+": quick") {
+		t.Errorf("expected a warning about the legacy session, got: %s", text)
+	}
+
+	// Selecting without a recording says how to bind it
+	result = call("trajectory_strategies_select", `{"tag": "backend", "mode": "explicit", "strategy_name": "careful", "file_path": "`+filePath+`"}`)
+	if !strings.Contains(result.Content[0].Text, "No active session to bind to") {
+		t.Errorf("expected a note that nothing was bound, got %v", result.Content)
+	}
+}
+
+func TestStrategiesEvolve(t *testing.T) {
+	server, s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	content := `<!-- trajectory-strategies:backend -->
+strategies:
+  - name: careful
+    approach_prompt: |
+      Read every related file first
+  - name: fast
+    approach_prompt: |
+      Edit straight away
+<!-- /trajectory-strategies:backend -->
+`
+	filePath := filepath.Join(t.TempDir(), "CLAUDE.md")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	call := func(args string) ToolCallResult {
+		resp := sendRequest(server, "tools/call", ToolCallParams{
+			Name:      "trajectory_strategies_evolve",
+			Arguments: json.RawMessage(args),
+		})
+		var result ToolCallResult
+		resultJSON, _ := json.Marshal(resp.Result)
+		json.Unmarshal(resultJSON, &result)
+		return result
+	}
+
+	// Too little data to judge
+	result := call(`{"tag": "backend", "file_path": "` + filePath + `"}`)
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "have 0") {
+		t.Errorf("expected insufficient data error, got %v", result.Content)
+	}
+
+	for i, score := range []float64{0.9, 0.8, 0.85} {
+		s.RecordStrategyUsage(types.StrategyUsage{Tag: "backend", StrategyName: "careful", SessionID: "c" + string(rune('0'+i)), Score: score, Version: optimizer.StrategyVersion("Read every related file first")})
+	}
+	for i, score := range []float64{0.4, 0.3, 0.5} {
+		s.RecordStrategyUsage(types.StrategyUsage{Tag: "backend", StrategyName: "fast", SessionID: "f" + string(rune('0'+i)), Score: score, Version: optimizer.StrategyVersion("Edit straight away")})
+	}
+
+	result = call(`{"tag": "backend", "file_path": "` + filePath + `"}`)
+	if result.IsError {
+		t.Fatalf("unexpected error: %v", result.Content)
+	}
+	text := result.Content[0].Text
+	if !strings.Contains(text, "| careful | 3 | 3 | 0.85 | parent |") || !strings.Contains(text, "| fast | 3 | 3 | 0.40 | retire |") {
+		t.Errorf("expected careful as parent and fast retired, got: %s", text)
+	}
+	start := strings.Index(text, `record_id: "`) + len(`record_id: "`)
+	recordID := text[start : start+strings.Index(text[start:], `"`)]
+
+	result = call(`{"tag": "backend", "file_path": "` + filePath + `", "record_id": "` + recordID + `", "content": "strategies:\n  - name: careful\n"}`)
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "no approach_prompt") {
+		t.Errorf("expected incomplete strategies to be rejected, got %v", result.Content)
+	}
+
+	evolved := `strategies:\n  - name: careful\n    approach_prompt: |\n      Read every related file first\n  - name: scoped\n    description: Mutation of careful\n    approach_prompt: |\n      Read only the files you will edit\n`
+	result = call(`{"tag": "backend", "file_path": "` + filePath + `", "record_id": "` + recordID + `", "content": "` + evolved + `"}`)
+	if result.IsError {
+		t.Fatalf("unexpected error: %v", result.Content)
+	}
+	if text := result.Content[0].Text; !strings.Contains(text, "**Retires:** fast") || !strings.Contains(text, "trajectory_optimize_apply") {
+		t.Errorf("expected the proposal to retire fast, got: %s", text)
+	}
+
+	record, err := s.GetOptimization(recordID)
+	if err != nil {
+		t.Fatalf("failed to get record: %v", err)
+	}
+	if record.Kind != types.OptKindStrategies || record.Status != types.OptStatusProposed {
+		t.Errorf("expected a proposed strategies record, got %+v", record)
+	}
+}
+
+func TestStrategiesAnalyzeVersions(t *testing.T) {
+	server, s, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	filePath := filepath.Join(t.TempDir(), "CLAUDE.md")
+	writeStrategies := func(prompt string) {
+		content := "<!-- trajectory-strategies:backend -->\nstrategies:\n  - name: careful\n    approach_prompt: |\n      " + prompt + "\n<!-- /trajectory-strategies:backend -->\n"
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	call := func(name, args string) ToolCallResult {
+		resp := sendRequest(server, "tools/call", ToolCallParams{
+			Name:      name,
+			Arguments: json.RawMessage(args),
+		})
+		var result ToolCallResult
+		resultJSON, _ := json.Marshal(resp.Result)
+		json.Unmarshal(resultJSON, &result)
+		return result
+	}
+
+	// Run one session on each revision of the approach prompt
+	for _, run := range []struct {
+		prompt string
+		score  string
+	}{{"Read first", "0.4"}, {"Read first, then write a test", "0.9"}} {
+		writeStrategies(run.prompt)
+		call("trajectory_start", `{"task_prompt": "Add an endpoint", "tags": ["backend"]}`)
+		result := call("trajectory_strategies_select", `{"tag": "backend", "mode": "explicit", "strategy_name": "careful", "file_path": "`+filePath+`"}`)
+		if want := "**Version:** " + optimizer.StrategyVersion(run.prompt); !strings.Contains(result.Content[0].Text, want) {
+			t.Errorf("expected %q in the selection, got %v", want, result.Content)
+		}
+		call("trajectory_stop", `{"score": `+run.score+`}`)
+	}
+
+	oldVersion := optimizer.StrategyVersion("Read first")
+	newVersion := optimizer.StrategyVersion("Read first, then write a test")
+	result := call("trajectory_strategies_analyze", `{"tag": "backend", "file_path": "`+filePath+`"}`)
+	if result.IsError {
+		t.Fatalf("unexpected error: %v", result.Content)
+	}
+	text := result.Content[0].Text
+	if !strings.Contains(text, "| careful | "+oldVersion+" | 1 | 0.40 |") || !strings.Contains(text, "| careful | "+newVersion+" (current) | 1 | 0.90 |") {
+		t.Errorf("expected a row per version, got: %s", text)
+	}
+
+	result = call("trajectory_strategies_analyze", `{"tag": "backend", "aggregate": true, "file_path": "`+filePath+`"}`)
+	if text := result.Content[0].Text; !strings.Contains(text, "| careful | 2 | 0.65 |") || strings.Contains(text, newVersion) {
+		t.Errorf("expected the versions combined, got: %s", text)
+	}
+
+	// Editing the prompt again starts a version with no sessions
+	writeStrategies("Plan first")
+	result = call("trajectory_strategies_analyze", `{"tag": "backend", "file_path": "`+filePath+`"}`)
+	if text := result.Content[0].Text; !strings.Contains(text, "| careful | "+optimizer.StrategyVersion("Plan first")+" (current) | 0 | N/A |") || strings.Contains(text, "(current) | 1") {
+		t.Errorf("expected only the new version marked current, got: %s", text)
+	}
+
+	// Recording credits the version that was selected, even after an edit
+	result = call("trajectory_start", `{"task_prompt": "Add a handler", "tags": ["backend"]}`)
+	var started TrajectoryStartOutput
+	json.Unmarshal([]byte(result.Content[0].Text), &started)
+	call("trajectory_strategies_select", `{"tag": "backend", "mode": "explicit", "strategy_name": "careful", "file_path": "`+filePath+`"}`)
+	writeStrategies("Plan carefully")
+	call("trajectory_strategies_record", `{"session_id": "`+started.SessionID+`", "tag": "backend", "strategy_name": "careful", "file_path": "`+filePath+`"}`)
+	usages, _ := s.GetStrategyUsage("backend", 0)
+	for _, usage := range usages {
+		if usage.SessionID == started.SessionID && usage.Version != optimizer.StrategyVersion("Plan first") {
+			t.Errorf("expected the selected version to be recorded, got %s", usage.Version)
+		}
+	}
+}
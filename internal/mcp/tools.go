@@ -1,17 +1,22 @@
 package mcp
 
+import "github.com/johncarpenter/trajectory-memory/internal/briefing"
+
 // Tool input/output structures for trajectory memory
 
 // TrajectoryStartInput is the input for trajectory_start.
 type TrajectoryStartInput struct {
-	TaskPrompt string   `json:"task_prompt"`
-	Tags       []string `json:"tags,omitempty"`
+	TaskPrompt     string   `json:"task_prompt"`
+	Tags           []string `json:"tags,omitempty"`
+	Briefing       *bool    `json:"briefing,omitempty"`
+	BriefingTokens int      `json:"briefing_tokens,omitempty"`
 }
 
 // TrajectoryStartOutput is the output for trajectory_start.
 type TrajectoryStartOutput struct {
-	SessionID string `json:"session_id"`
-	Message   string `json:"message"`
+	SessionID string             `json:"session_id"`
+	Message   string             `json:"message"`
+	Briefing  *briefing.Briefing `json:"briefing,omitempty"`
 }
 
 // TrajectoryStopInput is the input for trajectory_stop.
@@ -45,6 +50,27 @@ type TrajectorySearchResult struct {
 	StepCount  int      `json:"step_count"`
 	Tags       []string `json:"tags"`
 	StartedAt  string   `json:"started_at"`
+	Relevance  float64  `json:"relevance"`
+}
+
+// TrajectorySimilarInput is the input for trajectory_similar.
+type TrajectorySimilarInput struct {
+	Prompt   string   `json:"prompt"`
+	Limit    int      `json:"limit,omitempty"`
+	Tag      string   `json:"tag,omitempty"`
+	MinScore *float64 `json:"min_score,omitempty"`
+}
+
+// TrajectorySimilarResult is a single similar session.
+type TrajectorySimilarResult struct {
+	SessionID  string   `json:"session_id"`
+	TaskPrompt string   `json:"task_prompt"`
+	Summary    string   `json:"summary"`
+	Score      *float64 `json:"score,omitempty"`
+	StepCount  int      `json:"step_count"`
+	Tags       []string `json:"tags"`
+	StartedAt  string   `json:"started_at"`
+	Similarity float64  `json:"similarity"`
 }
 
 // TrajectoryListInput is the input for trajectory_list.
@@ -54,9 +80,19 @@ type TrajectoryListInput struct {
 
 // TrajectoryScoreInput is the input for trajectory_score.
 type TrajectoryScoreInput struct {
-	SessionID string  `json:"session_id"`
-	Score     float64 `json:"score"`
-	Notes     string  `json:"notes,omitempty"`
+	SessionID  string             `json:"session_id"`
+	Score      *float64           `json:"score,omitempty"`
+	Dimensions map[string]float64 `json:"dimensions,omitempty"`
+	Notes      string             `json:"notes,omitempty"`
+}
+
+// TrajectoryCompareInput is the input for trajectory_compare.
+type TrajectoryCompareInput struct {
+	Tag      string `json:"tag"`
+	SessionA string `json:"session_a,omitempty"`
+	SessionB string `json:"session_b,omitempty"`
+	Winner   string `json:"winner,omitempty"` // "a", "b" or "tie"
+	Notes    string `json:"notes,omitempty"`
 }
 
 // TrajectorySummarizeInput is the input for trajectory_summarize.
@@ -67,8 +103,10 @@ type TrajectorySummarizeInput struct {
 
 // TrajectoryOptimizeProposeInput is the input for trajectory_optimize_propose.
 type TrajectoryOptimizeProposeInput struct {
-	FilePath string `json:"file_path"`
-	Tag      string `json:"tag,omitempty"`
+	FilePath          string `json:"file_path"`
+	Tag               string `json:"tag,omitempty"`
+	IncludeAutoScored bool   `json:"include_auto_scored,omitempty"`
+	UsePreferences    bool   `json:"use_preferences,omitempty"`
 }
 
 // TrajectoryOptimizeSaveInput is the input for trajectory_optimize_save.
@@ -99,9 +137,12 @@ type TrajectoryOptimizeHistoryInput struct {
 
 // TrajectoryCurateExamplesInput is the input for trajectory_curate_examples.
 type TrajectoryCurateExamplesInput struct {
-	Tag             string `json:"tag"`
-	MaxExamples     int    `json:"max_examples,omitempty"`
-	IncludeNegative bool   `json:"include_negative,omitempty"`
+	Tag               string `json:"tag"`
+	MaxExamples       int    `json:"max_examples,omitempty"`
+	IncludeNegative   bool   `json:"include_negative,omitempty"`
+	Dimension         string `json:"dimension,omitempty"`
+	IncludeAutoScored bool   `json:"include_auto_scored,omitempty"`
+	UsePreferences    bool   `json:"use_preferences,omitempty"`
 }
 
 // TrajectoryCurateApplyInput is the input for trajectory_curate_apply.
@@ -145,16 +186,21 @@ type TrajectoryTriggerConfigureInput struct {
 
 // TrajectoryStrategiesListInput is the input for trajectory_strategies_list.
 type TrajectoryStrategiesListInput struct {
-	FilePath string `json:"file_path,omitempty"`
-	Tag      string `json:"tag"`
+	FilePath  string `json:"file_path,omitempty"`
+	Tag       string `json:"tag"`
+	Aggregate bool   `json:"aggregate,omitempty"` // stats over every version rather than the current one
 }
 
 // TrajectoryStrategiesSelectInput is the input for trajectory_strategies_select.
 type TrajectoryStrategiesSelectInput struct {
-	FilePath     string `json:"file_path,omitempty"`
-	Tag          string `json:"tag"`
-	Mode         string `json:"mode"` // explicit, recommend, rotate
-	StrategyName string `json:"strategy_name,omitempty"`
+	FilePath     string   `json:"file_path,omitempty"`
+	Tag          string   `json:"tag"`
+	Mode         string   `json:"mode,omitempty"` // explicit, recommend, rotate, thompson, ucb1, epsilon_greedy, contextual
+	StrategyName string   `json:"strategy_name,omitempty"`
+	Epsilon      float64  `json:"epsilon,omitempty"`     // exploration rate for epsilon_greedy
+	TaskPrompt   string   `json:"task_prompt,omitempty"` // task to select for in contextual mode
+	Tags         []string `json:"tags,omitempty"`        // task tags for contextual mode
+	Aggregate    bool     `json:"aggregate,omitempty"`   // learn from every version rather than the current one
 }
 
 // TrajectoryStrategiesRecordInput is the input for trajectory_strategies_record.
@@ -162,11 +208,22 @@ type TrajectoryStrategiesRecordInput struct {
 	SessionID    string `json:"session_id"`
 	Tag          string `json:"tag"`
 	StrategyName string `json:"strategy_name"`
+	FilePath     string `json:"file_path,omitempty"` // where to read the strategy's current version
 }
 
 // TrajectoryStrategiesAnalyzeInput is the input for trajectory_strategies_analyze.
 type TrajectoryStrategiesAnalyzeInput struct {
-	Tag string `json:"tag"`
+	Tag       string `json:"tag"`
+	FilePath  string `json:"file_path,omitempty"` // where to read the current versions
+	Aggregate bool   `json:"aggregate,omitempty"` // combine every version of a strategy
+}
+
+// TrajectoryStrategiesEvolveInput is the input for trajectory_strategies_evolve.
+type TrajectoryStrategiesEvolveInput struct {
+	FilePath string `json:"file_path,omitempty"`
+	Tag      string `json:"tag"`
+	RecordID string `json:"record_id,omitempty"`
+	Content  string `json:"content,omitempty"` // generated strategies to save as a proposal
 }
 
 // GetToolDefinitions returns all trajectory memory tool definitions.
@@ -177,7 +234,7 @@ func GetToolDefinitions() []Tool {
 	return []Tool{
 		{
 			Name:        "trajectory_start",
-			Description: "Start recording a new trajectory session. Captures tool invocations for later analysis and learning.",
+			Description: "Start recording a new trajectory session. Captures tool invocations for later analysis and learning. Returns a briefing of relevant past sessions: follow what worked in the similar ones and avoid what went wrong in the low-scoring one.",
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
@@ -190,6 +247,16 @@ func GetToolDefinitions() []Tool {
 						Description: "Optional tags for categorizing the session",
 						Items:       &Property{Type: "string"},
 					},
+					"briefing": {
+						Type:        "boolean",
+						Description: "Include a briefing of similar high-scoring past sessions and one low-scoring session to avoid (default: true)",
+						Default:     true,
+					},
+					"briefing_tokens": {
+						Type:        "number",
+						Description: "Approximate token budget for the briefing (default: 1000)",
+						Default:     float64(1000),
+					},
 				},
 				Required: []string{"task_prompt"},
 			},
@@ -228,13 +295,13 @@ func GetToolDefinitions() []Tool {
 		},
 		{
 			Name:        "trajectory_search",
-			Description: "Search past trajectory sessions by keyword. Returns matching sessions with summaries and scores.",
+			Description: "Search past trajectory sessions, ranked by relevance. Matches task prompts, summaries, outcome notes, tags and step inputs/outputs. Returns matching sessions with summaries and scores.",
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
 					"query": {
 						Type:        "string",
-						Description: "Search query to match against task prompts, summaries, and tags",
+						Description: "Search query. Words must all match; supports OR, -exclude, prefix*, \"exact phrases\", and filters tag:X, tool:X, file:X, status:X, score>0.7",
 					},
 					"limit": {
 						Type:        "number",
@@ -251,6 +318,35 @@ func GetToolDefinitions() []Tool {
 				Required: []string{"query"},
 			},
 		},
+		{
+			Name:        "trajectory_similar",
+			Description: "Find past sessions whose task is most similar to a prompt, using local embeddings. Use it before starting a task to learn from similar high-scoring trajectories.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"prompt": {
+						Type:        "string",
+						Description: "Task description to compare against past sessions",
+					},
+					"limit": {
+						Type:        "number",
+						Description: "Maximum number of results (default: 5)",
+						Default:     float64(5),
+					},
+					"tag": {
+						Type:        "string",
+						Description: "Only consider sessions with this tag",
+					},
+					"min_score": {
+						Type:        "number",
+						Description: "Minimum score filter (0.0 to 1.0)",
+						Minimum:     &minScore,
+						Maximum:     &maxScore,
+					},
+				},
+				Required: []string{"prompt"},
+			},
+		},
 		{
 			Name:        "trajectory_list",
 			Description: "List recent trajectory sessions.",
@@ -267,7 +363,7 @@ func GetToolDefinitions() []Tool {
 		},
 		{
 			Name:        "trajectory_score",
-			Description: "Score or re-score a past trajectory session.",
+			Description: "Score or re-score a past trajectory session, overall or per rubric dimension (e.g. correctness, completeness, style, efficiency).",
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
@@ -277,16 +373,25 @@ func GetToolDefinitions() []Tool {
 					},
 					"score": {
 						Type:        "number",
-						Description: "Score value (0.0 to 1.0)",
+						Description: "Score value (0.0 to 1.0). Optional when dimensions are given; defaults to their weighted composite",
 						Minimum:     &minScore,
 						Maximum:     &maxScore,
 					},
+					"dimensions": {
+						Type:        "object",
+						Description: "Per-dimension scores (0.0 to 1.0) keyed by the rubric dimension names for the session's tag, e.g. {\"correctness\": 0.9, \"style\": 0.6}",
+						AdditionalProperties: &Property{
+							Type:    "number",
+							Minimum: &minScore,
+							Maximum: &maxScore,
+						},
+					},
 					"notes": {
 						Type:        "string",
 						Description: "Optional notes about the scoring",
 					},
 				},
-				Required: []string{"session_id", "score"},
+				Required: []string{"session_id"},
 			},
 		},
 		{
@@ -307,6 +412,37 @@ func GetToolDefinitions() []Tool {
 				Required: []string{"session_id", "summary"},
 			},
 		},
+		{
+			Name:        "trajectory_compare",
+			Description: "Compare two sessions with the same tag. Without a winner, returns the next pair worth comparing (or the given sessions) side by side. With a winner, records which session was better; preferences are fit into calibrated scores the optimizer can use instead of absolute scores.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"tag": {
+						Type:        "string",
+						Description: "Tag both sessions share",
+					},
+					"session_a": {
+						Type:        "string",
+						Description: "First session ID (required with winner)",
+					},
+					"session_b": {
+						Type:        "string",
+						Description: "Second session ID (required with winner)",
+					},
+					"winner": {
+						Type:        "string",
+						Description: "Which session was better",
+						Enum:        []string{"a", "b", "tie"},
+					},
+					"notes": {
+						Type:        "string",
+						Description: "Why one session was better",
+					},
+				},
+				Required: []string{"tag"},
+			},
+		},
 		// Optimization tools
 		{
 			Name:        "trajectory_optimize_propose",
@@ -322,6 +458,14 @@ func GetToolDefinitions() []Tool {
 						Type:        "string",
 						Description: "Specific tag to optimize. If omitted, analyzes all targets in the file",
 					},
+					"include_auto_scored": {
+						Type:        "boolean",
+						Description: "Count sessions nobody scored by their provisional auto score (default: the marker's auto_scored attribute)",
+					},
+					"use_preferences": {
+						Type:        "boolean",
+						Description: "Score sessions that have been compared with trajectory_compare by their preference rating (default: false)",
+					},
 				},
 				Required: []string{"file_path"},
 			},
@@ -426,6 +570,18 @@ func GetToolDefinitions() []Tool {
 						Description: "Include a negative example (default: true)",
 						Default:     true,
 					},
+					"dimension": {
+						Type:        "string",
+						Description: "Rank examples by a single rubric dimension instead of the composite score",
+					},
+					"include_auto_scored": {
+						Type:        "boolean",
+						Description: "Count sessions nobody scored by their provisional auto score (default: false)",
+					},
+					"use_preferences": {
+						Type:        "boolean",
+						Description: "Score sessions that have been compared with trajectory_compare by their preference rating (default: false)",
+					},
 				},
 				Required: []string{"tag"},
 			},
@@ -501,13 +657,17 @@ func GetToolDefinitions() []Tool {
 						Type:        "string",
 						Description: "Strategy tag to look up (e.g., 'daily-briefing')",
 					},
+					"aggregate": {
+						Type:        "boolean",
+						Description: "Show stats over every version of a strategy instead of its current version",
+					},
 				},
 				Required: []string{"tag"},
 			},
 		},
 		{
 			Name:        "trajectory_strategies_select",
-			Description: "Select which strategy to use for the current session. Supports explicit selection, AI recommendation based on past scores, or rotation for exploration.",
+			Description: "Select which strategy to use for the current session. Supports explicit selection, AI recommendation based on past scores, rotation for exploration, bandit modes (Thompson sampling, UCB1, epsilon-greedy) that balance exploring and exploiting, or a contextual mode that learns which strategy suits which kind of task.",
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
@@ -521,15 +681,32 @@ func GetToolDefinitions() []Tool {
 					},
 					"mode": {
 						Type:        "string",
-						Description: "Selection mode: 'explicit' (specify strategy_name), 'recommend' (best performer), or 'rotate' (explore underused)",
-						Enum:        []string{"explicit", "recommend", "rotate"},
+						Description: "Selection mode: 'explicit' (specify strategy_name), 'recommend' (best performer), 'rotate' (explore underused), 'thompson' (sample score posteriors), 'ucb1' (upper confidence bound), 'epsilon_greedy' (best performer, exploring at random), or 'contextual' (learned model of which strategy suits this task). Defaults to the mode= attribute on the strategies marker",
+						Enum:        []string{"explicit", "recommend", "rotate", "thompson", "ucb1", "epsilon_greedy", "contextual"},
 					},
 					"strategy_name": {
 						Type:        "string",
 						Description: "Strategy name (required for explicit mode)",
 					},
+					"epsilon": {
+						Type:        "number",
+						Description: "Exploration rate for epsilon_greedy mode (default 0.1)",
+					},
+					"task_prompt": {
+						Type:        "string",
+						Description: "Task to select for in contextual mode. Defaults to the active session's task, if any",
+					},
+					"tags": {
+						Type:        "array",
+						Description: "Task tags for contextual mode. Defaults to the active session's tags",
+						Items:       &Property{Type: "string"},
+					},
+					"aggregate": {
+						Type:        "boolean",
+						Description: "Select from the scores of every version of a strategy instead of its current version",
+					},
 				},
-				Required: []string{"tag", "mode"},
+				Required: []string{"tag"},
 			},
 		},
 		{
@@ -550,13 +727,17 @@ func GetToolDefinitions() []Tool {
 						Type:        "string",
 						Description: "Name of the strategy that was used",
 					},
+					"file_path": {
+						Type:        "string",
+						Description: "File the strategy's current version is read from. Defaults to ./CLAUDE.md",
+					},
 				},
 				Required: []string{"session_id", "tag", "strategy_name"},
 			},
 		},
 		{
 			Name:        "trajectory_strategies_analyze",
-			Description: "Analyze strategy performance based on trajectory scores. Shows which strategies have the best average scores and recommends the next strategy to try.",
+			Description: "Analyze strategy performance based on trajectory scores. Shows how each version of each strategy performed, which strategies have the best average scores, and recommends the next strategy to try. A strategy's version changes when its approach_prompt is edited.",
 			InputSchema: InputSchema{
 				Type: "object",
 				Properties: map[string]Property{
@@ -564,6 +745,40 @@ func GetToolDefinitions() []Tool {
 						Type:        "string",
 						Description: "Strategy tag to analyze",
 					},
+					"file_path": {
+						Type:        "string",
+						Description: "File the current versions are read from. Defaults to ./CLAUDE.md",
+					},
+					"aggregate": {
+						Type:        "boolean",
+						Description: "Combine every version of a strategy into one row",
+					},
+				},
+				Required: []string{"tag"},
+			},
+		},
+		{
+			Name:        "trajectory_strategies_evolve",
+			Description: "Evolve a tag's strategies from their scores. Without content, finds the underperforming strategies and returns a meta-prompt to replace them with mutations or crossbreeds of the top performers. With content, adds the generated strategies to that proposal, to apply with trajectory_optimize_apply; they must replace exactly the underperformers, which are archived.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"file_path": {
+						Type:        "string",
+						Description: "Path to CLAUDE.md or file containing strategy markers. Defaults to ./CLAUDE.md. Not needed with content",
+					},
+					"tag": {
+						Type:        "string",
+						Description: "Strategy tag to evolve",
+					},
+					"record_id": {
+						Type:        "string",
+						Description: "Record ID returned with the meta-prompt (required with content)",
+					},
+					"content": {
+						Type:        "string",
+						Description: "Generated strategies YAML, starting with 'strategies:', to save as a proposal",
+					},
 				},
 				Required: []string{"tag"},
 			},
@@ -3,9 +3,13 @@ package optimizer
 import (
 	"errors"
 	"fmt"
+	"math"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/johncarpenter/trajectory-memory/internal/embed"
+	"github.com/johncarpenter/trajectory-memory/internal/preference"
 	"github.com/johncarpenter/trajectory-memory/internal/store"
 	"github.com/johncarpenter/trajectory-memory/internal/types"
 )
@@ -21,9 +25,47 @@ const (
 	LowScoreThreshold  = 0.5
 )
 
+// PreferenceCohortQuantile is the share of compared sessions at each end of
+// the preference ranking that form the high and low cohorts, when
+// CohortQuantile isn't set.
+const PreferenceCohortQuantile = 0.25
+
 // Analyzer provides trajectory analysis functionality.
 type Analyzer struct {
 	store store.Store
+
+	// IncludeAutoScored counts sessions without a human score using their
+	// provisional auto score. Human scores always take precedence.
+	IncludeAutoScored bool
+
+	// UsePreferences scores sessions that have been compared pairwise by
+	// their fitted preference score instead of their outcome. Preference
+	// scores are relative, so compared sessions are split into cohorts by
+	// rank among themselves rather than by the score thresholds.
+	UsePreferences bool
+
+	// Significance is the p-value a feature's correlation with score must
+	// fall below for its patterns to be reported. Zero means
+	// DefaultSignificance.
+	Significance float64
+
+	// HighThreshold and LowThreshold split sessions into cohorts by score.
+	// Zero means HighScoreThreshold and LowScoreThreshold.
+	HighThreshold float64
+	LowThreshold  float64
+
+	// CohortQuantile, when set, splits cohorts by rank instead: the top and
+	// bottom share of sessions form the high and low cohorts, whatever
+	// their scores.
+	CohortQuantile float64
+
+	// RecencyHalfLife weights sessions by age in cohort averages and
+	// pattern rates, halving a session's weight every half-life. Zero
+	// weighs every session equally.
+	RecencyHalfLife time.Duration
+
+	// Detectors lists the pattern detectors to run. Nil runs all of them.
+	Detectors map[string]bool
 }
 
 // NewAnalyzer creates a new Analyzer instance.
@@ -31,74 +73,146 @@ func NewAnalyzer(s store.Store) *Analyzer {
 	return &Analyzer{store: s}
 }
 
-// Analyze performs analysis on all scored trajectories for a given tag.
+// Analyze performs analysis on all scored trajectories for a given tag,
+// splitting cohorts on the composite score.
 func (a *Analyzer) Analyze(tag string, minSessions int) (*types.TrajectoryAnalysis, error) {
+	return a.AnalyzeDimension(tag, "", minSessions)
+}
+
+// AnalyzeDimension performs analysis like Analyze, but splits cohorts on a
+// single rubric dimension. Sessions not scored on that dimension are left
+// out. An empty dimension means the composite score.
+func (a *Analyzer) AnalyzeDimension(tag string, dimension string, minSessions int) (*types.TrajectoryAnalysis, error) {
 	// Get all sessions with this tag
 	sessions, err := a.getSessionsByTag(tag)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sessions: %w", err)
 	}
 
-	// Filter to scored sessions only
-	var scored []*types.Session
+	var ratings preference.Ratings
+	if a.UsePreferences {
+		comparisons, err := a.store.ListComparisons(tag)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get comparisons: %w", err)
+		}
+		ratings = preference.Fit(comparisons)
+	}
+
+	// Filter to sessions scored on the dimension
+	var scored, compared []*types.Session
+	autoScored, preferenceScored := 0, 0
 	for _, s := range sessions {
-		if s.Outcome != nil {
+		if rating, ok := ratings[s.ID]; ok && dimension == "" {
+			// Comparisons judge whole sessions, so they only replace the composite
+			withScore := *s
+			withScore.Outcome = &types.Outcome{Score: rating.Score}
+			if s.Outcome != nil {
+				withScore.Outcome.Notes = s.Outcome.Notes
+				withScore.Outcome.ScoredAt = s.Outcome.ScoredAt
+			}
+			scored = append(scored, &withScore)
+			compared = append(compared, &withScore)
+			preferenceScored++
+			continue
+		}
+		if auto := s.ProvisionalOutcome(); s.Outcome == nil && a.IncludeAutoScored && auto != nil {
+			// Auto scores have no dimensions, so they only count toward the composite
+			withScore := *s
+			withScore.Outcome = &types.Outcome{Score: auto.Score, ScoredAt: auto.ScoredAt}
+			if _, ok := withScore.Outcome.DimensionScore(dimension); ok {
+				scored = append(scored, &withScore)
+				autoScored++
+			}
+			continue
+		}
+		if s.Outcome == nil {
+			continue
+		}
+		if _, ok := s.Outcome.DimensionScore(dimension); ok {
 			scored = append(scored, s)
 		}
 	}
 
 	if len(scored) < minSessions {
-		return nil, fmt.Errorf("%w: have %d scored sessions for tag '%s', need at least %d",
-			ErrInsufficientData, len(scored), tag, minSessions)
+		what := "scored sessions"
+		if dimension != "" {
+			what = fmt.Sprintf("sessions scored on '%s'", dimension)
+		}
+		return nil, fmt.Errorf("%w: have %d %s for tag '%s', need at least %d",
+			ErrInsufficientData, len(scored), what, tag, minSessions)
 	}
 
-	// Split into cohorts
-	var high, medium, low []*types.Session
-	for _, s := range scored {
-		switch {
-		case s.Outcome.Score >= HighScoreThreshold:
-			high = append(high, s)
-		case s.Outcome.Score >= LowScoreThreshold:
-			medium = append(medium, s)
-		default:
-			low = append(low, s)
-		}
+	// Split into cohorts. A preference score is a chance of beating a
+	// typical compared session, not an absolute score, so compared sessions
+	// are only ranked against each other
+	absolute := without(scored, compared)
+	high, low := a.splitCohorts(absolute, dimension)
+	quantile := a.CohortQuantile
+	if quantile <= 0 {
+		quantile = PreferenceCohortQuantile
 	}
+	comparedHigh, comparedLow := splitByRank(compared, dimension, quantile)
+	high = append(high, comparedHigh...)
+	low = append(low, comparedLow...)
 
 	// Calculate averages
-	avgHigh := calculateAvgScore(high)
-	avgLow := calculateAvgScore(low)
+	avgHigh := a.calculateAvgScore(high, dimension)
+	avgLow := a.calculateAvgScore(low, dimension)
+
+	// Measure how each feature tracks score, so patterns that could be
+	// chance are left out. Pooling preference scores with absolute ones
+	// would correlate features with the scale a session was scored on, so
+	// evidence comes from whichever kind scored more sessions
+	evidenceSessions := absolute
+	if len(compared) > len(absolute) {
+		evidenceSessions = compared
+	}
+	evidence := a.measureEvidence(evidenceSessions, dimension)
 
 	// Extract patterns from high-scoring sessions
-	highPatterns := a.extractPatterns(high, true)
+	highPatterns := a.extractPatterns(high, evidence)
 
 	// Extract anti-patterns from low-scoring sessions
-	lowAntiPatterns := a.extractAntiPatterns(low, high)
+	lowAntiPatterns := a.extractAntiPatterns(low, high, evidence)
+
+	// Mine the order tools were used in, which the counts above miss
+	var sequences *types.SequenceAnalysis
+	if a.detects(DetectorSequences) {
+		sequences = a.mineSequences(scored, high, low)
+		seqPatterns, seqAntiPatterns := sequencePatterns(sequences)
+		highPatterns = append(highPatterns, seqPatterns...)
+		lowAntiPatterns = append(lowAntiPatterns, seqAntiPatterns...)
+	}
 
 	// Generate recommended practices by combining insights
 	recommendations := a.generateRecommendations(highPatterns, lowAntiPatterns)
 
 	// Select curated examples
-	curatedExamples := a.curateExamples(high, low)
+	curatedExamples := a.curateExamples(high, low, dimension)
 
 	return &types.TrajectoryAnalysis{
 		Tag:                  tag,
+		Dimension:            dimension,
 		TotalSessions:        len(scored),
+		AutoScoredSessions:   autoScored,
+		PreferenceSessions:   preferenceScored,
 		HighScoreSessions:    len(high),
 		LowScoreSessions:     len(low),
 		AvgScoreHigh:         avgHigh,
 		AvgScoreLow:          avgLow,
 		HighScorePatterns:    highPatterns,
 		LowScoreAntiPatterns: lowAntiPatterns,
+		Evidence:             evidence,
+		Sequences:            sequences,
 		RecommendedPractices: recommendations,
 		CuratedExamples:      curatedExamples,
+		Settings:             a.settings(tag),
 	}, nil
 }
 
 // getSessionsByTag retrieves all sessions with a specific tag.
 func (a *Analyzer) getSessionsByTag(tag string) ([]*types.Session, error) {
-	// Search for sessions with the tag
-	metas, err := a.store.SearchSessions(tag, 1000)
+	metas, err := a.store.QuerySessions(store.Query{Tags: []string{tag}})
 	if err != nil {
 		return nil, err
 	}
@@ -109,34 +223,120 @@ func (a *Analyzer) getSessionsByTag(tag string) ([]*types.Session, error) {
 		if err != nil {
 			continue
 		}
-		// Verify tag match (search might be fuzzy)
-		for _, t := range session.Tags {
-			if t == tag {
-				sessions = append(sessions, session)
-				break
-			}
-		}
+		sessions = append(sessions, session)
 	}
 
 	return sessions, nil
 }
 
+// sessionScore returns a session's score on a rubric dimension, or its
+// composite score for an empty dimension. Unscored sessions score 0.
+func sessionScore(s *types.Session, dimension string) float64 {
+	if s.Outcome == nil {
+		return 0
+	}
+	score, _ := s.Outcome.DimensionScore(dimension)
+	return score
+}
+
 // calculateAvgScore computes the average score for a list of sessions.
-func calculateAvgScore(sessions []*types.Session) float64 {
+func (a *Analyzer) calculateAvgScore(sessions []*types.Session, dimension string) float64 {
 	if len(sessions) == 0 {
 		return 0
 	}
-	var sum float64
+	var sum, total float64
 	for _, s := range sessions {
-		if s.Outcome != nil {
-			sum += s.Outcome.Score
+		w := a.weight(s)
+		sum += w * sessionScore(s, dimension)
+		total += w
+	}
+	return sum / total
+}
+
+// splitCohorts divides sessions into high and low cohorts, by score
+// threshold or, with CohortQuantile set, by rank. Sessions in neither are
+// the medium cohort.
+func (a *Analyzer) splitCohorts(sessions []*types.Session, dimension string) (high, low []*types.Session) {
+	if a.CohortQuantile > 0 {
+		return splitByRank(sessions, dimension, a.CohortQuantile)
+	}
+
+	highThreshold, lowThreshold := HighScoreThreshold, LowScoreThreshold
+	if a.HighThreshold > 0 {
+		highThreshold = a.HighThreshold
+	}
+	if a.LowThreshold > 0 {
+		lowThreshold = a.LowThreshold
+	}
+	for _, s := range sessions {
+		score := sessionScore(s, dimension)
+		switch {
+		case score >= highThreshold:
+			high = append(high, s)
+		case score < lowThreshold:
+			low = append(low, s)
+		}
+	}
+	return high, low
+}
+
+// splitByRank puts the top and bottom quantile of sessions by score in the
+// high and low cohorts, with at least one session in each when there are two
+// or more.
+func splitByRank(sessions []*types.Session, dimension string, quantile float64) (high, low []*types.Session) {
+	ranked := make([]*types.Session, len(sessions))
+	copy(ranked, sessions)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return sessionScore(ranked[i], dimension) > sessionScore(ranked[j], dimension)
+	})
+	n := int(math.Round(quantile * float64(len(ranked))))
+	if n < 1 {
+		n = 1
+	}
+	if n > len(ranked)/2 {
+		n = len(ranked) / 2
+	}
+	high = append(high, ranked[:n]...)
+	low = append(low, ranked[len(ranked)-n:]...)
+	return high, low
+}
+
+// without returns the sessions not in exclude.
+func without(sessions, exclude []*types.Session) []*types.Session {
+	excluded := make(map[*types.Session]bool, len(exclude))
+	for _, s := range exclude {
+		excluded[s] = true
+	}
+	var kept []*types.Session
+	for _, s := range sessions {
+		if !excluded[s] {
+			kept = append(kept, s)
 		}
 	}
-	return sum / float64(len(sessions))
+	return kept
+}
+
+// weight is how much a session counts toward cohort averages and pattern
+// rates. Sessions halve in weight every RecencyHalfLife.
+func (a *Analyzer) weight(s *types.Session) float64 {
+	if a.RecencyHalfLife <= 0 {
+		return 1
+	}
+	age := time.Since(s.StartedAt)
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, float64(age)/float64(a.RecencyHalfLife))
+}
+
+// detects reports whether a pattern detector is enabled.
+func (a *Analyzer) detects(name string) bool {
+	return a.Detectors == nil || a.Detectors[name]
 }
 
-// extractPatterns identifies common patterns in high-scoring sessions.
-func (a *Analyzer) extractPatterns(high []*types.Session, isHigh bool) []string {
+// extractPatterns identifies common patterns in high-scoring sessions whose
+// link to score is significant.
+func (a *Analyzer) extractPatterns(high []*types.Session, evidence []types.PatternEvidence) []string {
 	if len(high) == 0 {
 		return nil
 	}
@@ -145,7 +345,7 @@ func (a *Analyzer) extractPatterns(high []*types.Session, isHigh bool) []string
 
 	// 1. Read-before-write ratio
 	readBeforeWrite := a.analyzeReadBeforeWrite(high)
-	if readBeforeWrite.ratio > 0.6 && readBeforeWrite.avgReads >= 2 {
+	if readBeforeWrite.ratio > 0.6 && readBeforeWrite.avgReads >= 2 && significant(evidence, featureReadsBeforeWrite) {
 		patterns = append(patterns,
 			fmt.Sprintf("Read source material before writing (%.0f%% of sessions read %.1f+ files first)",
 				readBeforeWrite.ratio*100, readBeforeWrite.avgReads))
@@ -153,7 +353,7 @@ func (a *Analyzer) extractPatterns(high []*types.Session, isHigh bool) []string
 
 	// 2. Revision detection
 	revisionStats := a.analyzeRevisions(high)
-	if revisionStats.ratio > 0.5 {
+	if revisionStats.ratio > 0.5 && significant(evidence, featureRevisions) {
 		patterns = append(patterns,
 			fmt.Sprintf("Revise and iterate on output (%.0f%% of sessions made revisions)",
 				revisionStats.ratio*100))
@@ -161,7 +361,7 @@ func (a *Analyzer) extractPatterns(high []*types.Session, isHigh bool) []string
 
 	// 3. Tool diversity
 	diversityStats := a.analyzeToolDiversity(high)
-	if diversityStats.avgDistinct >= 3 {
+	if diversityStats.avgDistinct >= 3 && significant(evidence, featureToolDiversity) {
 		patterns = append(patterns,
 			fmt.Sprintf("Use diverse tool set (average %.1f distinct tools used)",
 				diversityStats.avgDistinct))
@@ -169,7 +369,7 @@ func (a *Analyzer) extractPatterns(high []*types.Session, isHigh bool) []string
 
 	// 4. Step count
 	stepStats := a.analyzeStepCount(high)
-	if stepStats.avg >= 5 {
+	if stepStats.avg >= 5 && significant(evidence, featureStepCount) {
 		patterns = append(patterns,
 			fmt.Sprintf("Invest thoroughness with multiple steps (average %.0f steps)",
 				stepStats.avg))
@@ -177,7 +377,7 @@ func (a *Analyzer) extractPatterns(high []*types.Session, isHigh bool) []string
 
 	// 5. Self-critique detection
 	selfCritiqueStats := a.analyzeSelfCritique(high)
-	if selfCritiqueStats.ratio > 0.3 {
+	if selfCritiqueStats.ratio > 0.3 && significant(evidence, featureSelfCritique) {
 		patterns = append(patterns,
 			fmt.Sprintf("Re-read output for self-critique (%.0f%% of sessions)",
 				selfCritiqueStats.ratio*100))
@@ -185,17 +385,26 @@ func (a *Analyzer) extractPatterns(high []*types.Session, isHigh bool) []string
 
 	// 6. Checkpoint detection
 	checkpointStats := a.analyzeCheckpoints(high)
-	if checkpointStats.ratio > 0.4 {
+	if checkpointStats.ratio > 0.4 && significant(evidence, featureCheckpoints) {
 		patterns = append(patterns,
 			fmt.Sprintf("Work incrementally with checkpoints (%.0f%% of sessions)",
 				checkpointStats.ratio*100))
 	}
 
+	// 7. Error recovery
+	failureStats := a.analyzeFailures(high)
+	if failureStats.failures > 0 && failureStats.recoveryRate >= 0.6 && significant(evidence, featureErrorRecovery) {
+		patterns = append(patterns,
+			fmt.Sprintf("Recover from errors by diagnosing and retrying (%.0f%% error recovery rate)",
+				failureStats.recoveryRate*100))
+	}
+
 	return patterns
 }
 
-// extractAntiPatterns identifies what low-scoring sessions did wrong.
-func (a *Analyzer) extractAntiPatterns(low, high []*types.Session) []string {
+// extractAntiPatterns identifies what low-scoring sessions did wrong, where
+// the difference is significant.
+func (a *Analyzer) extractAntiPatterns(low, high []*types.Session, evidence []types.PatternEvidence) []string {
 	if len(low) == 0 {
 		return nil
 	}
@@ -205,7 +414,7 @@ func (a *Analyzer) extractAntiPatterns(low, high []*types.Session) []string {
 	// Compare against high-scoring sessions
 	lowRead := a.analyzeReadBeforeWrite(low)
 	highRead := a.analyzeReadBeforeWrite(high)
-	if lowRead.avgReads < highRead.avgReads-1 && highRead.avgReads > 1 {
+	if lowRead.avgReads < highRead.avgReads-1 && highRead.avgReads > 1 && significant(evidence, featureReadsBeforeWrite) {
 		antiPatterns = append(antiPatterns,
 			fmt.Sprintf("Insufficient research before writing (%.1f reads vs %.1f in successful sessions)",
 				lowRead.avgReads, highRead.avgReads))
@@ -213,14 +422,14 @@ func (a *Analyzer) extractAntiPatterns(low, high []*types.Session) []string {
 
 	lowRevision := a.analyzeRevisions(low)
 	highRevision := a.analyzeRevisions(high)
-	if lowRevision.ratio < highRevision.ratio-0.2 {
+	if lowRevision.ratio < highRevision.ratio-0.2 && significant(evidence, featureRevisions) {
 		antiPatterns = append(antiPatterns,
 			"No revision pass - submitted first draft without review")
 	}
 
 	lowDiversity := a.analyzeToolDiversity(low)
 	highDiversity := a.analyzeToolDiversity(high)
-	if lowDiversity.avgDistinct < highDiversity.avgDistinct-1 {
+	if lowDiversity.avgDistinct < highDiversity.avgDistinct-1 && significant(evidence, featureToolDiversity) {
 		antiPatterns = append(antiPatterns,
 			fmt.Sprintf("Limited tool usage (%.1f tools vs %.1f in successful sessions)",
 				lowDiversity.avgDistinct, highDiversity.avgDistinct))
@@ -228,12 +437,40 @@ func (a *Analyzer) extractAntiPatterns(low, high []*types.Session) []string {
 
 	lowSteps := a.analyzeStepCount(low)
 	highSteps := a.analyzeStepCount(high)
-	if lowSteps.avg < highSteps.avg*0.5 {
+	if lowSteps.avg < highSteps.avg*0.5 && significant(evidence, featureStepCount) {
 		antiPatterns = append(antiPatterns,
 			fmt.Sprintf("Rushed execution (%.0f steps vs %.0f in successful sessions)",
 				lowSteps.avg, highSteps.avg))
 	}
 
+	lowFailures := a.analyzeFailures(low)
+	highFailures := a.analyzeFailures(high)
+	if lowFailures.failures > 0 && lowFailures.recoveryRate < highFailures.recoveryRate-0.3 &&
+		significant(evidence, featureErrorRecovery) {
+		antiPatterns = append(antiPatterns,
+			fmt.Sprintf("Gave up after errors (%.0f%% error recovery rate vs %.0f%% in successful sessions)",
+				lowFailures.recoveryRate*100, highFailures.recoveryRate*100))
+	}
+	if lowFailures.avgMaxStreak >= 2 && lowFailures.avgMaxStreak > highFailures.avgMaxStreak+1 &&
+		significant(evidence, featureFailureStreak) {
+		antiPatterns = append(antiPatterns,
+			fmt.Sprintf("Repeated consecutive failures (%.1f in a row vs %.1f in successful sessions)",
+				lowFailures.avgMaxStreak, highFailures.avgMaxStreak))
+	}
+
+	lowTiming := a.analyzeTiming(low)
+	highTiming := a.analyzeTiming(high)
+	if highTiming.avgStepMs > 0 && lowTiming.avgStepMs > highTiming.avgStepMs*2 && significant(evidence, featureStepDuration) {
+		antiPatterns = append(antiPatterns,
+			fmt.Sprintf("Slow, long-running calls (%.1fs average vs %.1fs in successful sessions)",
+				lowTiming.avgStepMs/1000, highTiming.avgStepMs/1000))
+	}
+	if lowTiming.avgIncomplete > highTiming.avgIncomplete+0.5 && significant(evidence, featureIncompleteSteps) {
+		antiPatterns = append(antiPatterns,
+			fmt.Sprintf("Interrupted or denied calls (%.1f per session vs %.1f in successful sessions)",
+				lowTiming.avgIncomplete, highTiming.avgIncomplete))
+	}
+
 	return antiPatterns
 }
 
@@ -245,6 +482,12 @@ func (a *Analyzer) generateRecommendations(patterns, antiPatterns []string) []st
 
 	// Convert anti-patterns to positive recommendations
 	for _, anti := range antiPatterns {
+		// Sequences name tools, which would trip the keyword checks below
+		if run, ok := strings.CutPrefix(anti, sequenceAntiPatternPrefix); ok {
+			run, _, _ = strings.Cut(run, " (")
+			recommendations = append(recommendations, "Avoid the sequence "+run)
+			continue
+		}
 		if strings.Contains(strings.ToLower(anti), "research") || strings.Contains(strings.ToLower(anti), "read") {
 			recommendations = append(recommendations, "Read all available context before starting work")
 		}
@@ -257,6 +500,15 @@ func (a *Analyzer) generateRecommendations(patterns, antiPatterns []string) []st
 		if strings.Contains(strings.ToLower(anti), "rushed") || strings.Contains(strings.ToLower(anti), "steps") {
 			recommendations = append(recommendations, "Take time for thorough analysis - avoid rushing")
 		}
+		if strings.Contains(strings.ToLower(anti), "errors") || strings.Contains(strings.ToLower(anti), "failures") {
+			recommendations = append(recommendations, "When a command fails, read the error and change approach before retrying")
+		}
+		if strings.Contains(strings.ToLower(anti), "slow") {
+			recommendations = append(recommendations, "Prefer fast, targeted commands over long-running ones")
+		}
+		if strings.Contains(strings.ToLower(anti), "interrupted") {
+			recommendations = append(recommendations, "Confirm the approach before running actions that may be denied")
+		}
 	}
 
 	// Deduplicate
@@ -273,12 +525,12 @@ func (a *Analyzer) generateRecommendations(patterns, antiPatterns []string) []st
 }
 
 // curateExamples selects the best examples for few-shot learning.
-func (a *Analyzer) curateExamples(high, low []*types.Session) []types.CuratedExample {
+func (a *Analyzer) curateExamples(high, low []*types.Session, dimension string) []types.CuratedExample {
 	var examples []types.CuratedExample
 
 	// Sort high by score descending
 	sort.Slice(high, func(i, j int) bool {
-		return high[i].Outcome.Score > high[j].Outcome.Score
+		return sessionScore(high[i], dimension) > sessionScore(high[j], dimension)
 	})
 
 	// Select up to 3 high-scoring examples with diversity
@@ -292,9 +544,9 @@ func (a *Analyzer) curateExamples(high, low []*types.Session) []types.CuratedExa
 			SessionID:   s.ID,
 			TaskPrompt:  s.TaskPrompt,
 			Summary:     s.Summary,
-			Score:       s.Outcome.Score,
+			Score:       sessionScore(s, dimension),
 			Notes:       notes,
-			WhySelected: generateSelectionReason(s, true),
+			WhySelected: generateSelectionReason(s, true, dimension),
 		})
 	}
 
@@ -302,7 +554,7 @@ func (a *Analyzer) curateExamples(high, low []*types.Session) []types.CuratedExa
 	if len(low) > 0 {
 		// Sort by score ascending to get lowest
 		sort.Slice(low, func(i, j int) bool {
-			return low[i].Outcome.Score < low[j].Outcome.Score
+			return sessionScore(low[i], dimension) < sessionScore(low[j], dimension)
 		})
 
 		// Find one with a summary
@@ -316,9 +568,10 @@ func (a *Analyzer) curateExamples(high, low []*types.Session) []types.CuratedExa
 					SessionID:   s.ID,
 					TaskPrompt:  s.TaskPrompt,
 					Summary:     s.Summary,
-					Score:       s.Outcome.Score,
+					Score:       sessionScore(s, dimension),
 					Notes:       notes,
-					WhySelected: generateSelectionReason(s, false),
+					WhySelected: generateSelectionReason(s, false, dimension),
+					Negative:    true,
 				})
 				break
 			}
@@ -376,13 +629,16 @@ func (a *Analyzer) selectDiverse(sessions []*types.Session, n int) []*types.Sess
 }
 
 // generateSelectionReason creates a rationale for why a session was selected.
-func generateSelectionReason(s *types.Session, isPositive bool) string {
+func generateSelectionReason(s *types.Session, isPositive bool, dimension string) string {
+	score := fmt.Sprintf("%.0f%%", sessionScore(s, dimension)*100)
+	if dimension != "" {
+		score += " " + dimension
+	}
 	if isPositive {
-		return fmt.Sprintf("High-scoring session (%.0f%%) with %d steps demonstrating thorough approach",
-			s.Outcome.Score*100, len(s.Steps))
+		return fmt.Sprintf("High-scoring session (%s) with %d steps demonstrating thorough approach",
+			score, len(s.Steps))
 	}
-	return fmt.Sprintf("Low-scoring session (%.0f%%) showing common pitfalls to avoid",
-		s.Outcome.Score*100)
+	return fmt.Sprintf("Low-scoring session (%s) showing common pitfalls to avoid", score)
 }
 
 // Analysis helper structs and functions
@@ -397,10 +653,10 @@ func (a *Analyzer) analyzeReadBeforeWrite(sessions []*types.Session) readStats {
 		return readStats{}
 	}
 
-	var withReads int
-	var totalReads int
-
+	var withReads, totalReads, total float64
 	for _, s := range sessions {
+		w := a.weight(s)
+		total += w
 		readsBeforeWrite := 0
 		for _, step := range s.Steps {
 			if isWriteTool(step.ToolName) {
@@ -411,15 +667,15 @@ func (a *Analyzer) analyzeReadBeforeWrite(sessions []*types.Session) readStats {
 			}
 		}
 		if readsBeforeWrite > 0 {
-			withReads++
-			totalReads += readsBeforeWrite
+			withReads += w
+			totalReads += w * float64(readsBeforeWrite)
 		}
 	}
 
-	ratio := float64(withReads) / float64(len(sessions))
+	ratio := withReads / total
 	avgReads := 0.0
 	if withReads > 0 {
-		avgReads = float64(totalReads) / float64(withReads)
+		avgReads = totalReads / withReads
 	}
 
 	return readStats{ratio: ratio, avgReads: avgReads}
@@ -434,8 +690,10 @@ func (a *Analyzer) analyzeRevisions(sessions []*types.Session) revisionStats {
 		return revisionStats{}
 	}
 
-	var withRevisions int
+	var withRevisions, total float64
 	for _, s := range sessions {
+		w := a.weight(s)
+		total += w
 		writtenFiles := make(map[string]int)
 		for _, step := range s.Steps {
 			if isWriteTool(step.ToolName) {
@@ -447,13 +705,13 @@ func (a *Analyzer) analyzeRevisions(sessions []*types.Session) revisionStats {
 		// Check if any file was written multiple times
 		for _, count := range writtenFiles {
 			if count > 1 {
-				withRevisions++
+				withRevisions += w
 				break
 			}
 		}
 	}
 
-	return revisionStats{ratio: float64(withRevisions) / float64(len(sessions))}
+	return revisionStats{ratio: withRevisions / total}
 }
 
 type diversityStats struct {
@@ -465,16 +723,18 @@ func (a *Analyzer) analyzeToolDiversity(sessions []*types.Session) diversityStat
 		return diversityStats{}
 	}
 
-	var totalDistinct int
+	var totalDistinct, total float64
 	for _, s := range sessions {
+		w := a.weight(s)
+		total += w
 		tools := make(map[string]bool)
 		for _, step := range s.Steps {
 			tools[step.ToolName] = true
 		}
-		totalDistinct += len(tools)
+		totalDistinct += w * float64(len(tools))
 	}
 
-	return diversityStats{avgDistinct: float64(totalDistinct) / float64(len(sessions))}
+	return diversityStats{avgDistinct: totalDistinct / total}
 }
 
 type stepCountStats struct {
@@ -486,12 +746,14 @@ func (a *Analyzer) analyzeStepCount(sessions []*types.Session) stepCountStats {
 		return stepCountStats{}
 	}
 
-	var totalSteps int
+	var totalSteps, total float64
 	for _, s := range sessions {
-		totalSteps += len(s.Steps)
+		w := a.weight(s)
+		total += w
+		totalSteps += w * float64(len(s.Steps))
 	}
 
-	return stepCountStats{avg: float64(totalSteps) / float64(len(sessions))}
+	return stepCountStats{avg: totalSteps / total}
 }
 
 type selfCritiqueStats struct {
@@ -503,8 +765,10 @@ func (a *Analyzer) analyzeSelfCritique(sessions []*types.Session) selfCritiqueSt
 		return selfCritiqueStats{}
 	}
 
-	var withSelfCritique int
+	var withSelfCritique, total float64
 	for _, s := range sessions {
+		w := a.weight(s)
+		total += w
 		recentWrites := make(map[string]bool)
 		for _, step := range s.Steps {
 			if isWriteTool(step.ToolName) {
@@ -514,14 +778,14 @@ func (a *Analyzer) analyzeSelfCritique(sessions []*types.Session) selfCritiqueSt
 			if isReadTool(step.ToolName) {
 				path := extractFilePath(step.InputSummary)
 				if recentWrites[path] {
-					withSelfCritique++
+					withSelfCritique += w
 					break
 				}
 			}
 		}
 	}
 
-	return selfCritiqueStats{ratio: float64(withSelfCritique) / float64(len(sessions))}
+	return selfCritiqueStats{ratio: withSelfCritique / total}
 }
 
 type checkpointStats struct {
@@ -533,8 +797,10 @@ func (a *Analyzer) analyzeCheckpoints(sessions []*types.Session) checkpointStats
 		return checkpointStats{}
 	}
 
-	var withCheckpoints int
+	var withCheckpoints, total float64
 	for _, s := range sessions {
+		w := a.weight(s)
+		total += w
 		writeIndices := []int{}
 		for i, step := range s.Steps {
 			if isWriteTool(step.ToolName) {
@@ -545,14 +811,99 @@ func (a *Analyzer) analyzeCheckpoints(sessions []*types.Session) checkpointStats
 		if len(writeIndices) >= 2 {
 			for i := 1; i < len(writeIndices); i++ {
 				if writeIndices[i]-writeIndices[i-1] > 1 {
-					withCheckpoints++
+					withCheckpoints += w
 					break
 				}
 			}
 		}
 	}
 
-	return checkpointStats{ratio: float64(withCheckpoints) / float64(len(sessions))}
+	return checkpointStats{ratio: withCheckpoints / total}
+}
+
+type failureStats struct {
+	failures     int     // failed steps across all sessions
+	recoveryRate float64 // proportion of failed steps later followed by a success of the same tool
+	avgMaxStreak float64 // average longest run of consecutive failed steps per session
+}
+
+func (a *Analyzer) analyzeFailures(sessions []*types.Session) failureStats {
+	if len(sessions) == 0 {
+		return failureStats{}
+	}
+
+	var failures int
+	var weightedFailures, recovered, totalMaxStreak, total float64
+	for _, s := range sessions {
+		w := a.weight(s)
+		total += w
+		streak, maxStreak := 0, 0
+		for i, step := range s.Steps {
+			if !step.IsFailed() {
+				if !step.IsIncomplete() {
+					streak = 0
+				}
+				continue
+			}
+
+			failures++
+			weightedFailures += w
+			streak++
+			if streak > maxStreak {
+				maxStreak = streak
+			}
+
+			for _, later := range s.Steps[i+1:] {
+				if later.ToolName == step.ToolName && !later.IsFailed() && !later.IsIncomplete() {
+					recovered += w
+					break
+				}
+			}
+		}
+		totalMaxStreak += w * float64(maxStreak)
+	}
+
+	stats := failureStats{
+		failures:     failures,
+		avgMaxStreak: totalMaxStreak / total,
+	}
+	if failures > 0 {
+		stats.recoveryRate = recovered / weightedFailures
+	}
+	return stats
+}
+
+type timingStats struct {
+	avgStepMs     float64 // average duration of timed steps
+	avgIncomplete float64 // average steps per session that never finished
+}
+
+func (a *Analyzer) analyzeTiming(sessions []*types.Session) timingStats {
+	if len(sessions) == 0 {
+		return timingStats{}
+	}
+
+	var totalMs, timedSteps, incomplete, total float64
+	for _, s := range sessions {
+		w := a.weight(s)
+		total += w
+		for _, step := range s.Steps {
+			if step.IsIncomplete() {
+				incomplete += w
+				continue
+			}
+			if step.DurationMs > 0 {
+				totalMs += w * float64(step.DurationMs)
+				timedSteps += w
+			}
+		}
+	}
+
+	stats := timingStats{avgIncomplete: incomplete / total}
+	if timedSteps > 0 {
+		stats.avgStepMs = totalMs / timedSteps
+	}
+	return stats
 }
 
 // Helper functions
@@ -581,25 +932,16 @@ func extractFilePath(summary string) string {
 	return summary
 }
 
+// tokenize splits a task prompt into the words compared between prompts,
+// leaving out words too short to tell tasks apart.
 func tokenize(text string) []string {
-	// Simple word tokenization
-	text = strings.ToLower(text)
-	words := strings.FieldsFunc(text, func(r rune) bool {
-		return !((r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'))
-	})
-	// Filter common stop words
-	stopWords := map[string]bool{
-		"the": true, "a": true, "an": true, "and": true, "or": true,
-		"to": true, "in": true, "for": true, "of": true, "on": true,
-		"with": true, "this": true, "that": true, "is": true, "are": true,
-	}
-	var filtered []string
-	for _, w := range words {
-		if !stopWords[w] && len(w) > 2 {
-			filtered = append(filtered, w)
+	var words []string
+	for _, w := range embed.Tokenize(text) {
+		if len(w) > 2 {
+			words = append(words, w)
 		}
 	}
-	return filtered
+	return words
 }
 
 func jaccardSimilarity(a, b []string) float64 {
@@ -1,16 +1,20 @@
 package optimizer
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"testing"
 	"time"
 
+	"github.com/johncarpenter/trajectory-memory/internal/store"
 	"github.com/johncarpenter/trajectory-memory/internal/types"
 )
 
 // mockStore implements store.Store for testing
 type mockStore struct {
-	sessions map[string]*types.Session
+	sessions    map[string]*types.Session
+	comparisons []types.Comparison
 }
 
 func newMockStore() *mockStore {
@@ -29,6 +33,10 @@ func (m *mockStore) GetSession(id string) (*types.Session, error) {
 	return nil, nil
 }
 
+func (m *mockStore) GetSessionHeader(id string) (*types.Session, error) {
+	return m.GetSession(id)
+}
+
 func (m *mockStore) UpdateSession(s *types.Session) error {
 	m.sessions[s.ID] = s
 	return nil
@@ -38,16 +46,28 @@ func (m *mockStore) AppendStep(sessionID string, step types.TrajectoryStep) erro
 	return nil
 }
 
+func (m *mockStore) UpdateStep(sessionID string, step types.TrajectoryStep) error {
+	return nil
+}
+
+func (m *mockStore) FindStep(sessionID string, toolUseID string) (*types.TrajectoryStep, error) {
+	return nil, store.ErrStepNotFound
+}
+
+func (m *mockStore) ForEachStep(sessionID string, fn func(step types.TrajectoryStep) error) error {
+	return nil
+}
+
 func (m *mockStore) ListSessions(limit int, offset int) ([]types.SessionMetadata, error) {
 	return nil, nil
 }
 
-func (m *mockStore) SearchSessions(query string, limit int) ([]types.SessionMetadata, error) {
-	var results []types.SessionMetadata
+func (m *mockStore) SearchSessions(query string, limit int) ([]store.SearchResult, error) {
+	var results []store.SearchResult
 	for _, s := range m.sessions {
 		for _, tag := range s.Tags {
 			if tag == query {
-				results = append(results, s.ToMetadata())
+				results = append(results, store.SearchResult{SessionMetadata: s.ToMetadata()})
 				break
 			}
 		}
@@ -55,10 +75,52 @@ func (m *mockStore) SearchSessions(query string, limit int) ([]types.SessionMeta
 	return results, nil
 }
 
+func (m *mockStore) QuerySessions(q store.Query) ([]types.SessionMetadata, error) {
+	var results []types.SessionMetadata
+	for _, s := range m.sessions {
+		matched := 0
+		for _, want := range q.Tags {
+			for _, tag := range s.Tags {
+				if tag == want {
+					matched++
+					break
+				}
+			}
+		}
+		if matched == len(q.Tags) {
+			results = append(results, s.ToMetadata())
+		}
+	}
+	return results, nil
+}
+
+func (m *mockStore) SimilarSessions(text string, q store.Query) ([]store.SimilarResult, error) {
+	return nil, nil
+}
+
 func (m *mockStore) SetOutcome(sessionID string, outcome types.Outcome) error {
 	return nil
 }
 
+func (m *mockStore) SetAutoOutcome(sessionID string, outcome *types.AutoOutcome) error {
+	return nil
+}
+
+func (m *mockStore) RecordComparison(c *types.Comparison) error {
+	m.comparisons = append(m.comparisons, *c)
+	return nil
+}
+
+func (m *mockStore) ListComparisons(tag string) ([]types.Comparison, error) {
+	var results []types.Comparison
+	for _, c := range m.comparisons {
+		if tag == "" || c.Tag == tag {
+			results = append(results, c)
+		}
+	}
+	return results, nil
+}
+
 func (m *mockStore) GetActiveSession() (*types.Session, error) {
 	return nil, nil
 }
@@ -71,6 +133,38 @@ func (m *mockStore) ClearActiveSession() error {
 	return nil
 }
 
+func (m *mockStore) GetActiveSessionFor(claudeSessionID string) (*types.Session, error) {
+	return nil, nil
+}
+
+func (m *mockStore) SetActiveSessionFor(claudeSessionID string, sessionID string) error {
+	return nil
+}
+
+func (m *mockStore) ClearActiveSessionFor(claudeSessionID string) error {
+	return nil
+}
+
+func (m *mockStore) ListActiveSessions() (map[string]string, error) {
+	return nil, nil
+}
+
+func (m *mockStore) SetPendingSession(owner string, sessionID string) error {
+	return nil
+}
+
+func (m *mockStore) GetRecordingFor(owner string) (*types.Session, error) {
+	return nil, store.ErrNoActiveSession
+}
+
+func (m *mockStore) BindPendingSession(claudeSessionID string, sessionID string) error {
+	return nil
+}
+
+func (m *mockStore) ClearRecording(sessionID string) error {
+	return nil
+}
+
 func (m *mockStore) DeleteSession(id string) error {
 	return nil
 }
@@ -171,6 +265,208 @@ func TestAnalyzer_Analyze_AverageScores(t *testing.T) {
 	}
 }
 
+func TestAnalyzer_AnalyzeDimension(t *testing.T) {
+	store := newMockStore()
+
+	// Correct but slow, and fast but sloppy
+	scores := []struct {
+		id                      string
+		correctness, efficiency float64
+	}{
+		{"1", 0.9, 0.2},
+		{"2", 0.9, 0.3},
+		{"3", 0.8, 0.2},
+		{"4", 0.2, 0.9},
+		{"5", 0.3, 0.9},
+	}
+	for _, sc := range scores {
+		session := createTestSession(sc.id, "backend", (sc.correctness+sc.efficiency)/2)
+		session.Outcome.Dimensions = map[string]float64{
+			"correctness": sc.correctness,
+			"efficiency":  sc.efficiency,
+		}
+		store.CreateSession(session)
+	}
+	// Scored without dimensions; only counts toward the composite
+	store.CreateSession(createTestSession("6", "backend", 0.95))
+
+	analyzer := NewAnalyzer(store)
+
+	correctness, err := analyzer.AnalyzeDimension("backend", "correctness", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if correctness.Dimension != "correctness" || correctness.TotalSessions != 5 {
+		t.Errorf("expected 5 sessions scored on correctness, got %d", correctness.TotalSessions)
+	}
+	if correctness.HighScoreSessions != 3 || correctness.LowScoreSessions != 2 {
+		t.Errorf("expected 3 high and 2 low, got %d and %d", correctness.HighScoreSessions, correctness.LowScoreSessions)
+	}
+
+	efficiency, err := analyzer.AnalyzeDimension("backend", "efficiency", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if efficiency.HighScoreSessions != 2 || efficiency.LowScoreSessions != 3 {
+		t.Errorf("expected 2 high and 3 low, got %d and %d", efficiency.HighScoreSessions, efficiency.LowScoreSessions)
+	}
+	if efficiency.AvgScoreHigh < 0.89 || efficiency.AvgScoreHigh > 0.91 {
+		t.Errorf("expected avg high ~0.9, got %.2f", efficiency.AvgScoreHigh)
+	}
+
+	composite, err := analyzer.Analyze("backend", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if composite.TotalSessions != 6 || composite.HighScoreSessions != 1 {
+		t.Errorf("expected 6 sessions with 1 high on the composite, got %d and %d", composite.TotalSessions, composite.HighScoreSessions)
+	}
+
+	if _, err := analyzer.AnalyzeDimension("backend", "style", 5); !errors.Is(err, ErrInsufficientData) {
+		t.Errorf("expected insufficient data for unscored dimension, got: %v", err)
+	}
+}
+
+func TestAnalyzer_IncludeAutoScored(t *testing.T) {
+	store := newMockStore()
+
+	store.CreateSession(createTestSession("1", "research", 0.9))
+	store.CreateSession(createTestSession("2", "research", 0.3))
+	for _, id := range []string{"3", "4", "5"} {
+		session := createTestSession(id, "research", 0)
+		session.Outcome = nil
+		session.AutoOutcome = &types.AutoOutcome{Score: 0.8, Scorer: "heuristic/v1"}
+		store.CreateSession(session)
+	}
+	// Human scores take precedence over auto scores
+	both := createTestSession("6", "research", 0.2)
+	both.AutoOutcome = &types.AutoOutcome{Score: 0.95}
+	store.CreateSession(both)
+
+	analyzer := NewAnalyzer(store)
+	if _, err := analyzer.Analyze("research", 5); !errors.Is(err, ErrInsufficientData) {
+		t.Errorf("expected auto-scored sessions to be left out by default, got: %v", err)
+	}
+
+	analyzer.IncludeAutoScored = true
+	analysis, err := analyzer.Analyze("research", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if analysis.TotalSessions != 6 || analysis.AutoScoredSessions != 3 {
+		t.Errorf("expected 6 sessions with 3 auto-scored, got %d and %d", analysis.TotalSessions, analysis.AutoScoredSessions)
+	}
+	if analysis.HighScoreSessions != 4 || analysis.LowScoreSessions != 2 {
+		t.Errorf("expected 4 high and 2 low, got %d and %d", analysis.HighScoreSessions, analysis.LowScoreSessions)
+	}
+}
+
+func TestAnalyzer_UsePreferences(t *testing.T) {
+	store := newMockStore()
+
+	// Every session got the same absolute score, but reviewers preferred 1 over 3
+	for _, id := range []string{"1", "2", "3"} {
+		store.CreateSession(createTestSession(id, "research", 0.6))
+	}
+	store.CreateSession(createTestSession("4", "research", 0.9))
+	for i := 0; i < 3; i++ {
+		store.RecordComparison(&types.Comparison{Tag: "research", Winner: "1", Loser: "2"})
+		store.RecordComparison(&types.Comparison{Tag: "research", Winner: "1", Loser: "3"})
+		store.RecordComparison(&types.Comparison{Tag: "research", Winner: "2", Loser: "3"})
+	}
+	store.RecordComparison(&types.Comparison{Tag: "research", Winner: "2", Loser: "3"})
+	store.RecordComparison(&types.Comparison{Tag: "other", Winner: "3", Loser: "1"})
+
+	analyzer := NewAnalyzer(store)
+	analysis, err := analyzer.Analyze("research", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if analysis.HighScoreSessions != 1 || analysis.PreferenceSessions != 0 {
+		t.Errorf("expected preferences to be ignored by default, got %+v", analysis)
+	}
+
+	analyzer.UsePreferences = true
+	analysis, err = analyzer.Analyze("research", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if analysis.TotalSessions != 4 || analysis.PreferenceSessions != 3 {
+		t.Errorf("expected 4 sessions with 3 scored from preferences, got %d and %d",
+			analysis.TotalSessions, analysis.PreferenceSessions)
+	}
+	// Session 1 joins the uncompared 0.9 session in the high cohort and 3 drops to low
+	if analysis.HighScoreSessions != 2 || analysis.LowScoreSessions != 1 {
+		t.Errorf("expected 2 high and 1 low, got %d and %d", analysis.HighScoreSessions, analysis.LowScoreSessions)
+	}
+}
+
+func TestAnalyzer_UsePreferences_SplitsByRank(t *testing.T) {
+	store := newMockStore()
+
+	// A single judgment leaves both preference scores near 0.5, well inside
+	// the absolute thresholds, but still ranks the winner first
+	store.CreateSession(createTestSession("1", "research", 0.6))
+	store.CreateSession(createTestSession("2", "research", 0.6))
+	store.CreateSession(createTestSession("3", "research", 0.7))
+	store.RecordComparison(&types.Comparison{Tag: "research", Winner: "1", Loser: "2"})
+
+	analyzer := NewAnalyzer(store)
+	analyzer.UsePreferences = true
+	analysis, err := analyzer.Analyze("research", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if analysis.HighScoreSessions != 1 || analysis.LowScoreSessions != 1 || analysis.AvgScoreHigh <= analysis.AvgScoreLow {
+		t.Errorf("expected the winner high and the loser low, got %+v", analysis)
+	}
+}
+
+func TestAnalyzer_UsePreferences_SeparatesEvidence(t *testing.T) {
+	store := newMockStore()
+
+	withSteps := func(id string, score float64, steps int) {
+		s := createTestSession(id, "research", score)
+		s.Steps = make([]types.TrajectoryStep, steps)
+		for i := range s.Steps {
+			s.Steps[i] = types.TrajectoryStep{ToolName: "Read"}
+		}
+		store.CreateSession(s)
+	}
+	// Longer sessions score higher on the absolute scale...
+	for i := 0; i < 8; i++ {
+		withSteps(fmt.Sprintf("a%d", i), 0.2+0.1*float64(i), i+1)
+	}
+	// ...while reviewers preferred the shorter of the longest sessions, so
+	// pooled together the step count would look unrelated to score
+	for i := 0; i < 6; i++ {
+		withSteps(fmt.Sprintf("c%d", i), 0.5, 9+i)
+		for j := 0; j < i; j++ {
+			for k := 0; k < 2; k++ {
+				store.RecordComparison(&types.Comparison{Tag: "research", Winner: fmt.Sprintf("c%d", j), Loser: fmt.Sprintf("c%d", i)})
+			}
+		}
+	}
+
+	analyzer := NewAnalyzer(store)
+	analyzer.UsePreferences = true
+	analysis, err := analyzer.Analyze("research", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if analysis.PreferenceSessions != 6 {
+		t.Fatalf("expected 6 sessions scored from preferences, got %d", analysis.PreferenceSessions)
+	}
+	for _, e := range analysis.Evidence {
+		if e.Feature != featureStepCount {
+			continue
+		}
+		if e.Sessions != 8 || e.Effect <= 0 || !e.Significant {
+			t.Errorf("expected step count to track the absolute scores, got %+v", e)
+		}
+	}
+}
+
 func TestAnalyzer_PatternExtraction_ReadBeforeWrite(t *testing.T) {
 	store := newMockStore()
 
@@ -223,6 +519,42 @@ func TestAnalyzer_PatternExtraction_ReadBeforeWrite(t *testing.T) {
 	}
 }
 
+func TestAnalyzer_SuppressesInsignificantPatterns(t *testing.T) {
+	store := newMockStore()
+
+	// Every session reads the same amount first, whatever its score
+	for i, score := range []float64{0.9, 0.85, 0.8, 0.3, 0.2, 0.6} {
+		s := createTestSession(string(rune('1'+i)), "research", score)
+		s.Steps = []types.TrajectoryStep{
+			{ToolName: "Read", InputSummary: "file1.md"},
+			{ToolName: "Read", InputSummary: "file2.md"},
+			{ToolName: "Read", InputSummary: "file3.md"},
+			{ToolName: "Write", InputSummary: "output.md"},
+		}
+		store.CreateSession(s)
+	}
+
+	analyzer := NewAnalyzer(store)
+	analysis, err := analyzer.Analyze("research", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, p := range analysis.HighScorePatterns {
+		if containsString(p, "Read source material") {
+			t.Errorf("expected read-before-write pattern to be suppressed, got %v", analysis.HighScorePatterns)
+		}
+	}
+	if len(analysis.Evidence) == 0 {
+		t.Fatal("expected evidence in the analysis")
+	}
+	for _, e := range analysis.Evidence {
+		if e.Feature == featureReadsBeforeWrite && (e.Significant || e.Sessions != 6) {
+			t.Errorf("expected insignificant evidence over 6 sessions, got %+v", e)
+		}
+	}
+}
+
 func TestAnalyzer_PatternExtraction_Revisions(t *testing.T) {
 	store := newMockStore()
 
@@ -242,10 +574,19 @@ func TestAnalyzer_PatternExtraction_Revisions(t *testing.T) {
 	}
 	store.CreateSession(s2)
 
-	// Add more to meet minimum
-	store.CreateSession(createTestSession("3", "research", 0.60))
-	store.CreateSession(createTestSession("4", "research", 0.55))
-	store.CreateSession(createTestSession("5", "research", 0.30))
+	// Enough sessions for the revision feature to survive adjustment for
+	// every feature tested
+	for i, score := range []float64{0.95, 0.88, 0.80} {
+		s := createTestSession(fmt.Sprintf("r%d", i), "research", score)
+		s.Steps = []types.TrajectoryStep{
+			{ToolName: "Write", InputSummary: "notes.md"},
+			{ToolName: "Write", InputSummary: "notes.md"}, // Revision
+		}
+		store.CreateSession(s)
+	}
+	for i, score := range []float64{0.60, 0.55, 0.50, 0.40, 0.30} {
+		store.CreateSession(createTestSession(fmt.Sprintf("n%d", i), "research", score))
+	}
 
 	analyzer := NewAnalyzer(store)
 	analysis, err := analyzer.Analyze("research", 5)
@@ -320,6 +661,103 @@ func TestAnalyzer_PatternExtraction_ToolDiversity(t *testing.T) {
 	}
 }
 
+func TestAnalyzer_AntiPatterns_Timing(t *testing.T) {
+	store := newMockStore()
+
+	for _, id := range []string{"1", "2"} {
+		s := createTestSession(id, "timing", 0.9)
+		s.Steps = []types.TrajectoryStep{
+			{ToolName: "Read", DurationMs: 100, Status: types.StepStatusCompleted},
+			{ToolName: "Write", DurationMs: 200, Status: types.StepStatusCompleted},
+		}
+		store.CreateSession(s)
+	}
+
+	s3 := createTestSession("3", "timing", 0.2)
+	s3.Steps = []types.TrajectoryStep{
+		{ToolName: "Bash", DurationMs: 30000, Status: types.StepStatusCompleted},
+		{ToolName: "Bash", Status: types.StepStatusIncomplete},
+		{ToolName: "Bash", Status: types.StepStatusIncomplete},
+	}
+	store.CreateSession(s3)
+
+	analyzer := NewAnalyzer(store)
+	analysis, err := analyzer.Analyze("timing", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var slow, interrupted bool
+	for _, p := range analysis.LowScoreAntiPatterns {
+		if containsString(p, "Slow") {
+			slow = true
+		}
+		if containsString(p, "Interrupted") {
+			interrupted = true
+		}
+	}
+	if !slow {
+		t.Errorf("expected slow call anti-pattern, got %v", analysis.LowScoreAntiPatterns)
+	}
+	if !interrupted {
+		t.Errorf("expected interrupted call anti-pattern, got %v", analysis.LowScoreAntiPatterns)
+	}
+}
+
+func TestAnalyzer_Patterns_ErrorRecovery(t *testing.T) {
+	store := newMockStore()
+
+	for _, id := range []string{"1", "2"} {
+		s := createTestSession(id, "errors", 0.9)
+		s.Steps = []types.TrajectoryStep{
+			{ToolName: "Bash", Status: types.StepStatusFailed, ExitCode: 1},
+			{ToolName: "Edit", Status: types.StepStatusCompleted},
+			{ToolName: "Bash", Status: types.StepStatusCompleted},
+		}
+		store.CreateSession(s)
+	}
+
+	s3 := createTestSession("3", "errors", 0.2)
+	s3.Steps = []types.TrajectoryStep{
+		{ToolName: "Bash", Status: types.StepStatusFailed, ExitCode: 1},
+		{ToolName: "Bash", Status: types.StepStatusFailed, ExitCode: 1},
+		{ToolName: "Bash", Status: types.StepStatusFailed, ExitCode: 1},
+	}
+	store.CreateSession(s3)
+
+	analyzer := NewAnalyzer(store)
+	analysis, err := analyzer.Analyze("errors", 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found := false
+	for _, p := range analysis.HighScorePatterns {
+		if containsString(p, "error recovery rate") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected error recovery pattern, got %v", analysis.HighScorePatterns)
+	}
+
+	var gaveUp, streak bool
+	for _, p := range analysis.LowScoreAntiPatterns {
+		if containsString(p, "Gave up") {
+			gaveUp = true
+		}
+		if containsString(p, "consecutive failures") {
+			streak = true
+		}
+	}
+	if !gaveUp {
+		t.Errorf("expected gave-up anti-pattern, got %v", analysis.LowScoreAntiPatterns)
+	}
+	if !streak {
+		t.Errorf("expected consecutive failures anti-pattern, got %v", analysis.LowScoreAntiPatterns)
+	}
+}
+
 func TestAnalyzer_CuratedExamples(t *testing.T) {
 	store := newMockStore()
 
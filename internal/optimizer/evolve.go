@@ -0,0 +1,359 @@
+package optimizer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/store"
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+// Thresholds for strategy evolution.
+const (
+	// MinStrategySessions is how many scored sessions a strategy needs
+	// before evolution judges it.
+	MinStrategySessions = 3
+
+	// UnderperformGap is how far below the best average score a strategy
+	// must fall to be retired.
+	UnderperformGap = 0.1
+
+	// maxParentStrategies is how many top performers new strategies are
+	// bred from.
+	maxParentStrategies = 2
+)
+
+var (
+	// ErrNoUnderperformers is returned when every judged strategy scores
+	// close to the best, so there is nothing to replace.
+	ErrNoUnderperformers = errors.New("no strategy underperforms the best")
+	// ErrInvalidStrategies is returned when proposed strategies can't be
+	// parsed or are incomplete.
+	ErrInvalidStrategies = errors.New("invalid strategies content")
+)
+
+// EvolveResult contains the result of proposing new strategies for a tag.
+type EvolveResult struct {
+	Target          types.StrategiesTarget
+	Strategies      []types.Strategy // current strategies with their stats
+	TopPerformers   []types.Strategy // parents for the new strategies
+	Underperformers []types.Strategy // strategies to retire
+	Record          *types.OptimizationRecord
+	Prompt          string // Meta-prompt for the model to generate the new strategies
+}
+
+// ProposeStrategies compares a tag's strategies by their scored sessions
+// and generates a meta-prompt to replace the underperformers with
+// mutations and crossbreeds of the top performers. The proposal is saved
+// with the strategies to retire; SaveStrategiesProposal fills in the
+// generated strategies.
+func (o *Optimizer) ProposeStrategies(target types.StrategiesTarget) (*EvolveResult, error) {
+	strategies, err := o.parser.ParseStrategies(target.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse strategies: %w", err)
+	}
+
+	stats, err := o.currentVersionStats(target.Tag, strategies)
+	if err != nil {
+		return nil, err
+	}
+
+	var judged []types.Strategy
+	totalSessions := 0
+	for i := range strategies {
+		if stat, ok := stats[strategies[i].Name]; ok {
+			strategies[i].AvgScore = stat.AvgScore
+			strategies[i].SessionCount = stat.SessionCount
+			strategies[i].ScoredCount = stat.ScoredCount
+			totalSessions += stat.SessionCount
+		}
+		if strategies[i].ScoredCount >= MinStrategySessions {
+			judged = append(judged, strategies[i])
+		}
+	}
+	if len(judged) < 2 {
+		return nil, fmt.Errorf("%w: need at least 2 strategies with %d scored sessions each, have %d",
+			ErrInsufficientData, MinStrategySessions, len(judged))
+	}
+
+	sort.SliceStable(judged, func(i, j int) bool {
+		return judged[i].AvgScore > judged[j].AvgScore
+	})
+	best := judged[0].AvgScore
+
+	result := &EvolveResult{Target: target, Strategies: strategies}
+	for _, s := range judged {
+		if best-s.AvgScore >= UnderperformGap {
+			result.Underperformers = append(result.Underperformers, s)
+		} else if len(result.TopPerformers) < maxParentStrategies {
+			result.TopPerformers = append(result.TopPerformers, s)
+		}
+	}
+	if len(result.Underperformers) == 0 {
+		return nil, fmt.Errorf("%w: every strategy with %d scored sessions is within %.2f of the best (%.2f)",
+			ErrNoUnderperformers, MinStrategySessions, UnderperformGap, best)
+	}
+
+	var retired []string
+	for _, s := range result.Underperformers {
+		retired = append(retired, s.Name)
+	}
+	worst := result.Underperformers[len(result.Underperformers)-1]
+	result.Record = &types.OptimizationRecord{
+		ID:              store.NewULID(),
+		TargetFile:      target.FilePath,
+		Tag:             target.Tag,
+		Kind:            types.OptKindStrategies,
+		Retired:         retired,
+		SessionsUsed:    totalSessions,
+		AvgScoreHigh:    best,
+		AvgScoreLow:     worst.AvgScore,
+		PreviousContent: target.Content,
+		Status:          types.OptStatusProposed,
+		CreatedAt:       time.Now(),
+	}
+	if err := o.store.CreateOptimization(result.Record); err != nil {
+		return nil, fmt.Errorf("failed to save proposal: %w", err)
+	}
+	result.Prompt = generateEvolvePrompt(result)
+	return result, nil
+}
+
+// SaveStrategiesProposal adds generated strategies to a proposal made by
+// ProposeStrategies. The new content must drop exactly the strategies the
+// proposal retires.
+func (o *Optimizer) SaveStrategiesProposal(recordID string, newContent string) (*types.OptimizationRecord, error) {
+	record, err := o.store.GetOptimization(recordID)
+	if err != nil {
+		return nil, err
+	}
+	if record.Kind != types.OptKindStrategies {
+		return nil, fmt.Errorf("%w: record %s is not a strategies proposal", ErrInvalidStrategies, recordID)
+	}
+	if record.Status != types.OptStatusProposed {
+		return nil, ErrOptimizationNotProposed
+	}
+
+	proposed, err := o.parser.ParseStrategies(newContent)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStrategies, err)
+	}
+	if len(proposed) == 0 {
+		return nil, fmt.Errorf("%w: no strategies found", ErrInvalidStrategies)
+	}
+	kept := make(map[string]bool)
+	for _, s := range proposed {
+		if s.ApproachPrompt == "" {
+			return nil, fmt.Errorf("%w: strategy %q has no approach_prompt", ErrInvalidStrategies, s.Name)
+		}
+		if kept[s.Name] {
+			return nil, fmt.Errorf("%w: strategy %q is defined twice", ErrInvalidStrategies, s.Name)
+		}
+		kept[s.Name] = true
+	}
+
+	current, err := o.parser.ParseStrategies(record.PreviousContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse strategies: %w", err)
+	}
+	retire := make(map[string]bool)
+	for _, name := range record.Retired {
+		retire[name] = true
+	}
+	for _, s := range current {
+		if kept[s.Name] && retire[s.Name] {
+			return nil, fmt.Errorf("%w: %q underperformed and must be replaced", ErrInvalidStrategies, s.Name)
+		}
+		if !kept[s.Name] && !retire[s.Name] {
+			return nil, fmt.Errorf("%w: %q is not being retired and must be kept", ErrInvalidStrategies, s.Name)
+		}
+	}
+
+	record.NewContent = newContent
+	record.Diff = generateUnifiedDiff(record.PreviousContent, newContent, "current", "proposed")
+	if err := o.store.UpdateOptimization(record); err != nil {
+		return nil, fmt.Errorf("failed to save proposal: %w", err)
+	}
+	return record, nil
+}
+
+// replaceStrategies swaps the content of the strategies section a record
+// targets.
+func (o *Optimizer) replaceStrategies(record *types.OptimizationRecord, content string) error {
+	targets, err := o.parser.FindStrategiesTargets(record.TargetFile)
+	if err != nil {
+		return fmt.Errorf("failed to parse file: %w", err)
+	}
+	for _, t := range targets {
+		if t.Tag == record.Tag {
+			return o.parser.ReplaceStrategiesTarget(record.TargetFile, t, content)
+		}
+	}
+	return ErrTargetNotFound
+}
+
+// archiveRetired archives the strategies an applied record retired, with
+// the stats they had.
+func (o *Optimizer) archiveRetired(record *types.OptimizationRecord) error {
+	if len(record.Retired) == 0 {
+		return nil
+	}
+
+	previous, err := o.parser.ParseStrategies(record.PreviousContent)
+	if err != nil {
+		return fmt.Errorf("failed to parse strategies: %w", err)
+	}
+	stats, err := o.currentVersionStats(record.Tag, previous)
+	if err != nil {
+		return err
+	}
+
+	retired := make(map[string]bool)
+	for _, name := range record.Retired {
+		retired[name] = true
+	}
+	var archived []types.ArchivedStrategy
+	for _, s := range previous {
+		if !retired[s.Name] {
+			continue
+		}
+		if stat, ok := stats[s.Name]; ok {
+			s.AvgScore, s.SessionCount, s.ScoredCount = stat.AvgScore, stat.SessionCount, stat.ScoredCount
+		}
+		archived = append(archived, types.ArchivedStrategy{
+			Tag:      record.Tag,
+			Strategy: s,
+			RecordID: record.ID,
+		})
+	}
+	return o.store.ArchiveStrategies(archived)
+}
+
+// currentVersionStats returns the stats of each strategy's current version,
+// keyed by name, so a strategy isn't judged by sessions that ran an approach
+// prompt it no longer has.
+func (o *Optimizer) currentVersionStats(tag string, strategies []types.Strategy) (map[string]types.Strategy, error) {
+	versions, err := o.store.GetStrategyVersionStats(tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get strategy stats: %w", err)
+	}
+
+	current := make(map[string]string)
+	for _, s := range strategies {
+		current[s.Name] = s.Version
+	}
+	stats := make(map[string]types.Strategy)
+	for _, v := range versions {
+		if version, ok := current[v.Name]; ok && v.Version == version {
+			stats[v.Name] = v
+		}
+	}
+	return stats, nil
+}
+
+// generateEvolvePrompt creates the prompt for the model to generate new
+// strategies.
+func generateEvolvePrompt(result *EvolveResult) string {
+	var buf bytes.Buffer
+
+	buf.WriteString("## Strategy Evolution Request\n\n")
+	buf.WriteString(fmt.Sprintf("You are evolving the strategies for \"%s\" tasks from how the sessions that used them scored.\n\n", result.Target.Tag))
+
+	buf.WriteString("### Current Strategies\n\n")
+	buf.WriteString("| Strategy | Sessions | Scored | Avg Score | Verdict |\n")
+	buf.WriteString("|----------|----------|--------|-----------|---------|\n")
+	for _, s := range result.Strategies {
+		buf.WriteString(fmt.Sprintf("| %s | %d | %d | %s | %s |\n",
+			s.Name, s.SessionCount, s.ScoredCount, FormatStrategyScore(s), strategyVerdict(result, s.Name)))
+	}
+	buf.WriteString(fmt.Sprintf("\nStrategies need %d scored sessions to be judged; those with fewer are kept as they are.\n\n", MinStrategySessions))
+
+	buf.WriteString("### Top Performers\n\n")
+	for _, s := range result.TopPerformers {
+		writeStrategyForPrompt(&buf, s)
+	}
+
+	buf.WriteString("### Underperformers to Retire\n\n")
+	for _, s := range result.Underperformers {
+		writeStrategyForPrompt(&buf, s)
+	}
+
+	buf.WriteString("### Your Task\n\n")
+	buf.WriteString(fmt.Sprintf(`Write a new strategies section that replaces the %d underperforming
+strategies with the same number of new candidates. Each candidate should:
+
+1. Either mutate one top performer (change one or two aspects of its approach)
+   or crossbreed two top performers (combine the strongest parts of each)
+2. Avoid what the underperformers did differently from the top performers
+3. Have a new, short, lowercase name that isn't used above
+4. Say in its description which strategies it came from
+
+Keep every other strategy exactly as it is, including its name, so its
+history carries over.
+
+Output ONLY the strategies YAML, starting with "strategies:", in the same
+format as the current section (name, description and an approach_prompt
+block using "|"), no preamble or explanation.
+`, len(result.Underperformers)))
+
+	return buf.String()
+}
+
+// strategyVerdict says what evolution does with a strategy.
+func strategyVerdict(result *EvolveResult, name string) string {
+	for _, s := range result.TopPerformers {
+		if s.Name == name {
+			return "parent"
+		}
+	}
+	for _, s := range result.Underperformers {
+		if s.Name == name {
+			return "retire"
+		}
+	}
+	return "keep"
+}
+
+// FormatStrategyScore returns a strategy's average score, or N/A before it
+// has been scored.
+func FormatStrategyScore(s types.Strategy) string {
+	if s.ScoredCount == 0 {
+		return "N/A"
+	}
+	return fmt.Sprintf("%.2f", s.AvgScore)
+}
+
+func writeStrategyForPrompt(buf *bytes.Buffer, s types.Strategy) {
+	buf.WriteString(fmt.Sprintf("**%s** (avg %.2f over %d scored sessions)", s.Name, s.AvgScore, s.ScoredCount))
+	if s.Description != "" {
+		buf.WriteString(" — " + s.Description)
+	}
+	buf.WriteString("\n```\n")
+	buf.WriteString(s.ApproachPrompt)
+	buf.WriteString("\n```\n\n")
+}
+
+// FormatEvolveForCLI formats a strategies proposal for CLI output.
+func FormatEvolveForCLI(filePath string, result *EvolveResult) string {
+	var buf bytes.Buffer
+
+	buf.WriteString(fmt.Sprintf("Strategy evolution for %s in %s\n\n", result.Target.Tag, filePath))
+	for _, s := range result.Strategies {
+		avg := "  n/a"
+		if s.ScoredCount > 0 {
+			avg = fmt.Sprintf("%5.2f", s.AvgScore)
+		}
+		buf.WriteString(fmt.Sprintf("  %-7s %s  %s (%d scored sessions)\n",
+			strategyVerdict(result, s.Name), avg, s.Name, s.ScoredCount))
+	}
+
+	buf.WriteString("\nMeta-prompt:\n\n")
+	buf.WriteString(result.Prompt)
+	buf.WriteString("\nGenerate the new strategies from the prompt above, save them to a file, then run:\n")
+	buf.WriteString(fmt.Sprintf("  trajectory-memory optimize strategies --record %s --save <generated.yaml>\n", result.Record.ID))
+	return strings.TrimRight(buf.String(), "\n") + "\n"
+}
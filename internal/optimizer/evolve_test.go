@@ -0,0 +1,205 @@
+package optimizer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/johncarpenter/trajectory-memory/internal/store"
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+func TestEvolveStrategies(t *testing.T) {
+	s, err := store.NewBoltStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	content := `# Project
+
+<!-- trajectory-strategies:backend -->
+strategies:
+  - name: careful
+    description: Read first
+    approach_prompt: |
+      Read every related file first
+  - name: fast
+    approach_prompt: |
+      Edit straight away
+  - name: tested
+    approach_prompt: |
+      Write the test first
+  - name: fresh
+    approach_prompt: |
+      Plan first
+<!-- /trajectory-strategies:backend -->
+`
+	filePath := filepath.Join(t.TempDir(), "CLAUDE.md")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	scores := map[string][]float64{
+		"careful": {0.9, 0.8, 0.85},
+		"fast":    {0.4, 0.3, 0.5},
+		"tested":  {0.8, 0.85, 0.8},
+		"fresh":   {0.2},
+	}
+	o := NewOptimizer(s)
+	targets, err := o.parser.FindStrategiesTargets(filePath)
+	if err != nil || len(targets) != 1 {
+		t.Fatalf("expected one strategies target, got %v, %v", targets, err)
+	}
+	strategies, _ := o.parser.ParseStrategies(targets[0].Content)
+	for _, strat := range strategies {
+		for i, score := range scores[strat.Name] {
+			s.RecordStrategyUsage(types.StrategyUsage{Tag: "backend", StrategyName: strat.Name, Version: strat.Version, SessionID: fmt.Sprintf("%s%d", strat.Name, i), Score: score})
+		}
+	}
+
+	result, err := o.ProposeStrategies(targets[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.TopPerformers) != 2 || result.TopPerformers[0].Name != "careful" || result.TopPerformers[1].Name != "tested" {
+		t.Errorf("expected careful and tested as parents, got %+v", result.TopPerformers)
+	}
+	// fresh scores worst but has too few sessions to judge
+	if len(result.Underperformers) != 1 || result.Underperformers[0].Name != "fast" {
+		t.Errorf("expected only fast to be retired, got %+v", result.Underperformers)
+	}
+	if !strings.Contains(result.Prompt, "| fresh | 1 | 1 | 0.20 | keep |") || !strings.Contains(result.Prompt, "Read every related file first") {
+		t.Errorf("unexpected prompt: %s", result.Prompt)
+	}
+
+	// The proposal is saved as soon as it is made, but can't be applied yet
+	saved, err := s.GetOptimization(result.Record.ID)
+	if err != nil || len(saved.Retired) != 1 || saved.Retired[0] != "fast" || saved.SessionsUsed != 10 {
+		t.Fatalf("expected the proposal to be saved with its stats, got %+v (%v)", saved, err)
+	}
+	if err := o.Apply(result.Record.ID); !errors.Is(err, ErrInvalidStrategies) {
+		t.Errorf("expected applying without strategies to fail, got %v", err)
+	}
+
+	if _, err := o.SaveStrategiesProposal(result.Record.ID, "no strategies here"); !errors.Is(err, ErrInvalidStrategies) {
+		t.Errorf("expected ErrInvalidStrategies, got %v", err)
+	}
+	// Dropping a strategy that held up, or keeping one that didn't, is rejected
+	for _, content := range []string{
+		"strategies:\n  - name: careful\n    approach_prompt: |\n      Read\n  - name: fresh\n    approach_prompt: |\n      Plan\n",
+		"strategies:\n  - name: careful\n    approach_prompt: |\n      Read\n  - name: fast\n    approach_prompt: |\n      Edit\n  - name: tested\n    approach_prompt: |\n      Test\n  - name: fresh\n    approach_prompt: |\n      Plan\n",
+	} {
+		if _, err := o.SaveStrategiesProposal(result.Record.ID, content); !errors.Is(err, ErrInvalidStrategies) {
+			t.Errorf("expected the retired strategies to be checked, got %v", err)
+		}
+	}
+
+	evolved := `strategies:
+  - name: careful
+    description: Read first
+    approach_prompt: |
+      Read every related file first
+  - name: tested
+    approach_prompt: |
+      Write the test first
+  - name: fresh
+    approach_prompt: |
+      Plan first
+  - name: careful-tested
+    description: Crossbreed of careful and tested
+    approach_prompt: |
+      Read the related files, then write the test first`
+	record, err := o.SaveStrategiesProposal(result.Record.ID, evolved)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if record.ID != result.Record.ID || record.SessionsUsed != 10 || record.NewContent != evolved {
+		t.Errorf("expected the proposal to be updated in place, got %+v", record)
+	}
+
+	if err := o.Apply(record.ID); err != nil {
+		t.Fatalf("failed to apply: %v", err)
+	}
+	data, _ := os.ReadFile(filePath)
+	if !strings.Contains(string(data), "careful-tested") || strings.Contains(string(data), "fast") || !strings.HasPrefix(string(data), "# Project") {
+		t.Errorf("expected the strategies section to be replaced, got:\n%s", data)
+	}
+
+	archived, err := s.ListArchivedStrategies("backend")
+	if err != nil {
+		t.Fatalf("failed to list archive: %v", err)
+	}
+	if len(archived) != 1 || archived[0].Strategy.Name != "fast" || archived[0].Strategy.ApproachPrompt != "Edit straight away" ||
+		archived[0].Strategy.ScoredCount != 3 || archived[0].RecordID != record.ID {
+		t.Errorf("expected fast to be archived with its stats, got %+v", archived)
+	}
+
+	if err := o.Rollback(record.ID); err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	data, _ = os.ReadFile(filePath)
+	if string(data) != content {
+		t.Errorf("expected the original file back, got:\n%s", data)
+	}
+	if archived, _ := s.ListArchivedStrategies("backend"); len(archived) != 0 {
+		t.Errorf("expected the archive to be emptied by rollback, got %+v", archived)
+	}
+}
+
+func TestEvolveStrategies_NoUnderperformers(t *testing.T) {
+	s, err := store.NewBoltStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	for _, name := range []string{"a", "b"} {
+		for i := 0; i < MinStrategySessions; i++ {
+			s.RecordStrategyUsage(types.StrategyUsage{Tag: "backend", StrategyName: name, Version: StrategyVersion(""), SessionID: fmt.Sprintf("%s%d", name, i), Score: 0.8})
+		}
+	}
+
+	target := types.StrategiesTarget{Tag: "backend", Content: "strategies:\n  - name: a\n  - name: b\n"}
+	if _, err := NewOptimizer(s).ProposeStrategies(target); !errors.Is(err, ErrNoUnderperformers) {
+		t.Errorf("expected ErrNoUnderperformers, got %v", err)
+	}
+}
+
+func TestEvolveStrategies_JudgesCurrentVersion(t *testing.T) {
+	s, err := store.NewBoltStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	target := types.StrategiesTarget{
+		Tag:     "backend",
+		Content: "strategies:\n  - name: careful\n    approach_prompt: |\n      Read the tests first\n  - name: fast\n    approach_prompt: |\n      Edit straight away\n",
+	}
+	record := func(name, prompt string, scores ...float64) {
+		for _, score := range scores {
+			s.RecordStrategyUsage(types.StrategyUsage{Tag: "backend", StrategyName: name, Version: StrategyVersion(prompt), SessionID: store.NewULID(), Score: score, Scored: true})
+		}
+	}
+	// careful did badly before its approach prompt was rewritten, and well since
+	record("careful", "Read everything first", 0.1, 0.1, 0.1, 0.1, 0.1, 0.1)
+	record("careful", "Read the tests first", 0.9, 0.8, 0.9)
+	// Across both versions careful would average below fast; zero scores
+	// still count as scored sessions
+	record("fast", "Edit straight away", 0.7, 0.7, 0.6, 0)
+
+	result, err := NewOptimizer(s).ProposeStrategies(target)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Underperformers) != 1 || result.Underperformers[0].Name != "fast" || result.Underperformers[0].ScoredCount != 4 {
+		t.Errorf("expected fast to be retired on its four sessions, got %+v", result.Underperformers)
+	}
+	if len(result.TopPerformers) != 1 || result.TopPerformers[0].Name != "careful" || result.TopPerformers[0].ScoredCount != 3 {
+		t.Errorf("expected careful judged on its current version, got %+v", result.TopPerformers)
+	}
+}
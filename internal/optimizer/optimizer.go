@@ -25,6 +25,7 @@ type Optimizer struct {
 	store    *store.BoltStore
 	analyzer *Analyzer
 	parser   *Parser
+	settings Settings
 }
 
 // NewOptimizer creates a new Optimizer instance.
@@ -36,6 +37,12 @@ func NewOptimizer(s *store.BoltStore) *Optimizer {
 	}
 }
 
+// SetSettings sets per-tag analyzer settings. Settings from a target's
+// marker attributes override them.
+func (o *Optimizer) SetSettings(settings Settings) {
+	o.settings = settings
+}
+
 // ProposeResult contains the result of a propose operation.
 type ProposeResult struct {
 	Target   types.OptimizationTarget
@@ -47,7 +54,11 @@ type ProposeResult struct {
 // Propose analyzes trajectories and generates a meta-prompt for optimization.
 func (o *Optimizer) Propose(target types.OptimizationTarget) (*ProposeResult, error) {
 	// Run analysis
-	analysis, err := o.analyzer.Analyze(target.Tag, target.MinSessions)
+	analyzer := *o.analyzer
+	analyzer.IncludeAutoScored = target.IncludeAutoScored
+	analyzer.UsePreferences = target.UsePreferences
+	analyzer.Configure(MergeSettings(o.settings.For(target.Tag), target.Settings))
+	analysis, err := analyzer.AnalyzeDimension(target.Tag, target.Dimension, target.MinSessions)
 	if err != nil {
 		return nil, fmt.Errorf("analysis failed: %w", err)
 	}
@@ -108,6 +119,22 @@ func (o *Optimizer) Apply(recordID string) error {
 		return ErrOptimizationNotProposed
 	}
 
+	if record.Kind == types.OptKindStrategies {
+		if record.NewContent == "" {
+			return fmt.Errorf("%w: no strategies have been saved for this proposal", ErrInvalidStrategies)
+		}
+		if err := o.replaceStrategies(record, record.NewContent); err != nil {
+			return fmt.Errorf("failed to replace strategies: %w", err)
+		}
+		if err := o.archiveRetired(record); err != nil {
+			return fmt.Errorf("failed to archive retired strategies: %w", err)
+		}
+		now := time.Now()
+		record.Status = types.OptStatusAccepted
+		record.AppliedAt = &now
+		return o.store.UpdateOptimization(record)
+	}
+
 	// Find the target in the file
 	targets, err := o.parser.FindTargets(record.TargetFile)
 	if err != nil {
@@ -165,6 +192,19 @@ func (o *Optimizer) Rollback(recordID string) error {
 		return ErrOptimizationNotApplied
 	}
 
+	if record.Kind == types.OptKindStrategies {
+		if err := o.replaceStrategies(record, record.PreviousContent); err != nil {
+			return fmt.Errorf("failed to restore strategies: %w", err)
+		}
+		if err := o.store.UnarchiveStrategies(record.ID); err != nil {
+			return fmt.Errorf("failed to unarchive strategies: %w", err)
+		}
+		now := time.Now()
+		record.Status = types.OptStatusRolledBack
+		record.RolledBackAt = &now
+		return o.store.UpdateOptimization(record)
+	}
+
 	// Find the target in the file
 	targets, err := o.parser.FindTargets(record.TargetFile)
 	if err != nil {
@@ -242,9 +282,29 @@ func (o *Optimizer) generateMetaPrompt(target types.OptimizationTarget, analysis
 	buf.WriteString("\n```\n\n")
 
 	buf.WriteString(fmt.Sprintf("### Trajectory Analysis for \"%s\" tasks\n\n", analysis.Tag))
+	if analysis.Dimension != "" {
+		buf.WriteString(fmt.Sprintf("Sessions are split on their **%s** score; focus the instructions on improving it.\n\n", analysis.Dimension))
+	}
+	if settings := analysis.Settings; settings.CohortQuantile > 0 ||
+		(settings.HighThreshold > 0 && settings.HighThreshold != HighScoreThreshold) ||
+		(settings.LowThreshold > 0 && settings.LowThreshold != LowScoreThreshold) {
+		buf.WriteString(fmt.Sprintf("Cohorts for this tag are the %s.\n\n", describeCohorts(settings)))
+	}
+	if analysis.Settings.RecencyHalfLifeDays > 0 {
+		buf.WriteString(fmt.Sprintf("Averages and pattern rates favor recent sessions: a session's weight halves every %g days.\n\n",
+			analysis.Settings.RecencyHalfLifeDays))
+	}
 
 	buf.WriteString(fmt.Sprintf("**%d high-scoring sessions (avg %.0f%%):**\n",
 		analysis.HighScoreSessions, analysis.AvgScoreHigh*100))
+	if analysis.AutoScoredSessions > 0 {
+		buf.WriteString(fmt.Sprintf("(%d of the %d sessions are auto-scored from trajectory signals, not rated by a person.)\n",
+			analysis.AutoScoredSessions, analysis.TotalSessions))
+	}
+	if analysis.PreferenceSessions > 0 {
+		buf.WriteString(fmt.Sprintf("(%d of the %d sessions are scored from pairwise comparisons between sessions.)\n",
+			analysis.PreferenceSessions, analysis.TotalSessions))
+	}
 	buf.WriteString("Patterns observed:\n")
 	for _, pattern := range analysis.HighScorePatterns {
 		buf.WriteString(fmt.Sprintf("- %s\n", pattern))
@@ -259,15 +319,64 @@ func (o *Optimizer) generateMetaPrompt(target types.OptimizationTarget, analysis
 	}
 	buf.WriteString("\n")
 
+	if len(analysis.Evidence) > 0 {
+		buf.WriteString("### Statistical Evidence\n\n")
+		buf.WriteString("How each trajectory feature tracks score across all analyzed sessions: Spearman correlation, its 95% bootstrap interval, and a one-sided p-value, also adjusted for the number of features tested. ")
+		buf.WriteString("Patterns above are only listed when their feature is significant after adjustment; don't base instructions on features that aren't.\n\n")
+		buf.WriteString("| Feature | Sessions | Correlation | 95% CI | p | Adjusted p | Significant |\n")
+		buf.WriteString("|---------|----------|-------------|--------|---|------------|-------------|\n")
+		for _, e := range analysis.Evidence {
+			significant := "no"
+			if e.Significant {
+				significant = "yes"
+			}
+			buf.WriteString(fmt.Sprintf("| %s | %d | %+.2f | [%+.2f, %+.2f] | %s | %s | %s |\n",
+				e.Description, e.Sessions, e.Effect, e.CILow, e.CIHigh, formatPValue(e.PValue), formatPValue(e.AdjustedPValue), significant))
+		}
+		buf.WriteString("\n")
+	}
+
+	if seq := analysis.Sequences; seq != nil && (len(seq.Discriminative) > 0 || len(seq.FrequentSequences) > 0) {
+		buf.WriteString("### Tool Sequences\n\n")
+		buf.WriteString("Steps are shown as tool names, with Bash commands grouped by purpose (e.g. Bash(test)). ")
+		buf.WriteString("Order matters here: these runs of consecutive steps are what the patterns above are built from. ")
+		buf.WriteString("Only unbroken runs are counted, so a habit interleaved with other steps won't show up.\n\n")
+		if len(seq.Discriminative) > 0 {
+			buf.WriteString("Runs that separate the cohorts (one-sided Fisher exact test, adjusted for the number of runs tested):\n\n")
+			buf.WriteString("| Sequence | High | Low | p | Adjusted p |\n")
+			buf.WriteString("|----------|------|-----|---|------------|\n")
+			for _, s := range seq.Discriminative {
+				buf.WriteString(fmt.Sprintf("| %s | %.0f%% | %.0f%% | %s | %s |\n",
+					formatSequence(s.Tokens), s.HighSupport*100, s.LowSupport*100, formatPValue(s.PValue), formatPValue(s.AdjustedPValue)))
+			}
+			buf.WriteString("\n")
+		}
+		if shifts := transitionShifts(seq, 5); len(shifts) > 0 {
+			buf.WriteString("Next-step probabilities that differ most between cohorts:\n")
+			for _, t := range shifts {
+				buf.WriteString(fmt.Sprintf("- %s → %s: %.0f%% in high-scoring vs %.0f%% in low-scoring sessions\n",
+					t.from, t.to, t.high*100, t.low*100))
+			}
+			buf.WriteString("\n")
+		}
+		if len(seq.FrequentSequences) > 0 {
+			buf.WriteString("Most common runs across all sessions:\n")
+			for _, s := range seq.FrequentSequences {
+				buf.WriteString(fmt.Sprintf("- %s (%.0f%%)\n", formatSequence(s.Tokens), s.Support*100))
+			}
+			buf.WriteString("\n")
+		}
+	}
+
 	// Add curated examples
 	hasHighExamples := false
 	hasLowExample := false
 	for _, ex := range analysis.CuratedExamples {
-		if ex.Score >= HighScoreThreshold && !hasHighExamples {
+		if !ex.Negative && !hasHighExamples {
 			buf.WriteString("### Example High-Scoring Sessions\n\n")
 			hasHighExamples = true
 		}
-		if ex.Score >= HighScoreThreshold {
+		if !ex.Negative {
 			buf.WriteString(fmt.Sprintf("**Score: %.0f%%** — %s\n", ex.Score*100, ex.TaskPrompt))
 			if ex.Summary != "" {
 				buf.WriteString(ex.Summary)
@@ -281,7 +390,7 @@ func (o *Optimizer) generateMetaPrompt(target types.OptimizationTarget, analysis
 	}
 
 	for _, ex := range analysis.CuratedExamples {
-		if ex.Score < LowScoreThreshold && !hasLowExample {
+		if ex.Negative && !hasLowExample {
 			buf.WriteString("### Example Low-Scoring Session\n\n")
 			hasLowExample = true
 			buf.WriteString(fmt.Sprintf("**Score: %.0f%%** — %s\n", ex.Score*100, ex.TaskPrompt))
@@ -349,6 +458,12 @@ func FormatAnalysisForCLI(filePath string, target types.OptimizationTarget, anal
 		analysis.HighScoreSessions,
 		analysis.TotalSessions-analysis.HighScoreSessions-analysis.LowScoreSessions,
 		analysis.LowScoreSessions))
+	if analysis.Settings.HighThreshold > 0 || analysis.Settings.CohortQuantile > 0 {
+		buf.WriteString(fmt.Sprintf("Cohorts: %s\n", describeCohorts(analysis.Settings)))
+	}
+	if analysis.Settings.RecencyHalfLifeDays > 0 {
+		buf.WriteString(fmt.Sprintf("Recency half-life: %g days\n", analysis.Settings.RecencyHalfLifeDays))
+	}
 	buf.WriteString(fmt.Sprintf("High-scoring average: %.2f\n", analysis.AvgScoreHigh))
 	buf.WriteString(fmt.Sprintf("Low-scoring average: %.2f\n\n", analysis.AvgScoreLow))
 
@@ -364,6 +479,28 @@ func FormatAnalysisForCLI(filePath string, target types.OptimizationTarget, anal
 	}
 	buf.WriteString("\n")
 
+	if len(analysis.Evidence) > 0 {
+		buf.WriteString("Feature correlation with score (* significant):\n")
+		for _, e := range analysis.Evidence {
+			mark := " "
+			if e.Significant {
+				mark = "*"
+			}
+			buf.WriteString(fmt.Sprintf("  %s %-30s n=%-3d r=%+.2f [%+.2f, %+.2f] p=%s adjusted p=%s\n",
+				mark, e.Description, e.Sessions, e.Effect, e.CILow, e.CIHigh, formatPValue(e.PValue), formatPValue(e.AdjustedPValue)))
+		}
+		buf.WriteString("\n")
+	}
+
+	if seq := analysis.Sequences; seq != nil && len(seq.Discriminative) > 0 {
+		buf.WriteString("Runs of consecutive tool calls by cohort (high / low):\n")
+		for _, s := range seq.Discriminative {
+			buf.WriteString(fmt.Sprintf("  %-40s %3.0f%% / %3.0f%% p=%s adjusted p=%s\n",
+				formatSequence(s.Tokens), s.HighSupport*100, s.LowSupport*100, formatPValue(s.PValue), formatPValue(s.AdjustedPValue)))
+		}
+		buf.WriteString("\n")
+	}
+
 	buf.WriteString(fmt.Sprintf("Current content (lines %d-%d of %s):\n",
 		target.StartLine+1, target.EndLine-1, filePath))
 	for _, line := range strings.Split(target.Content, "\n") {
@@ -3,11 +3,14 @@ package optimizer
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -44,19 +47,39 @@ var (
 	examplesEndPatternLegacy   = regexp.MustCompile(`<!--\s*trajectory-examples:end\s*-->`)
 
 	// <!-- trajectory-strategies:daily-briefing -->
-	strategiesStartPattern = regexp.MustCompile(`<!--\s*trajectory-strategies:(\S+)\s*-->`)
+	strategiesStartPattern = regexp.MustCompile(`<!--\s*trajectory-strategies:(\S+?)(\s+[^>]*?)?\s*-->`)
 	strategiesEndPattern   = regexp.MustCompile(`<!--\s*/trajectory-strategies:\S+\s*-->`)
 
+	// <!-- trajectory-rubric:backend -->
+	rubricStartPattern = regexp.MustCompile(`<!--\s*trajectory-rubric:(\S+)\s*-->`)
+	rubricEndPattern   = regexp.MustCompile(`<!--\s*/trajectory-rubric:\S+\s*-->`)
+
 	// Attribute patterns
 	tagAttrPattern             = regexp.MustCompile(`tag\s*=\s*"([^"]+)"`)
 	minSessionsAttrPattern     = regexp.MustCompile(`min_sessions\s*=\s*(\d+)`)
 	maxAttrPattern             = regexp.MustCompile(`max\s*=\s*(\d+)`)
 	includeNegativeAttrPattern = regexp.MustCompile(`include_negative\s*=\s*(true|false)`)
+	dimensionAttrPattern       = regexp.MustCompile(`dimension\s*=\s*"?([\w-]+)"?`)
+	autoScoredAttrPattern      = regexp.MustCompile(`auto_scored\s*=\s*(true|false)`)
+	preferencesAttrPattern     = regexp.MustCompile(`preferences\s*=\s*(true|false)`)
+	modeAttrPattern            = regexp.MustCompile(`\bmode\s*=\s*"?([\w-]+)"?`)
+
+	// Analyzer settings attributes
+	highThresholdAttrPattern     = regexp.MustCompile(`\bhigh_threshold\s*=\s*"?([\d.]+)"?`)
+	lowThresholdAttrPattern      = regexp.MustCompile(`\blow_threshold\s*=\s*"?([\d.]+)"?`)
+	cohortQuantileAttrPattern    = regexp.MustCompile(`\bcohort_quantile\s*=\s*"?([\d.]+)"?`)
+	halfLifeAttrPattern          = regexp.MustCompile(`\brecency_half_life_days\s*=\s*"?([\d.]+)"?`)
+	significanceAttrPattern      = regexp.MustCompile(`\bsignificance\s*=\s*"?([\d.]+)"?`)
+	detectorsAttrPattern         = regexp.MustCompile(`\bdetectors\s*=\s*"([^"]*)"`)
+	disabledDetectorsAttrPattern = regexp.MustCompile(`\bdisabled_detectors\s*=\s*"([^"]*)"`)
 
 	// Strategy content patterns (simple YAML-like parsing)
-	strategyNamePattern        = regexp.MustCompile(`^\s*-\s*name:\s*(.+)$`)
-	strategyDescPattern        = regexp.MustCompile(`^\s*description:\s*(.+)$`)
-	strategyApproachPattern    = regexp.MustCompile(`^\s*approach_prompt:\s*\|?\s*$`)
+	strategyNamePattern     = regexp.MustCompile(`^\s*-\s*name:\s*(.+)$`)
+	strategyDescPattern     = regexp.MustCompile(`^\s*description:\s*(.+)$`)
+	strategyApproachPattern = regexp.MustCompile(`^\s*approach_prompt:\s*\|?\s*$`)
+
+	// Rubric dimension lines: "- correctness: 0.4 Produces the right result"
+	rubricDimensionPattern = regexp.MustCompile(`^\s*-\s*([\w-]+)\s*:\s*(\d*\.?\d+)\s*(.*)$`)
 )
 
 // Parser provides methods for finding and replacing optimization targets in markdown files.
@@ -101,12 +124,16 @@ func (p *Parser) FindTargets(filePath string) ([]types.OptimizationTarget, error
 			}
 
 			targets = append(targets, types.OptimizationTarget{
-				FilePath:    currentStart.filePath,
-				Tag:         currentStart.tag,
-				MinSessions: currentStart.minSessions,
-				StartLine:   currentStart.startLine,
-				EndLine:     lineNum,
-				Content:     strings.TrimSpace(currentStart.content.String()),
+				FilePath:          currentStart.filePath,
+				Tag:               currentStart.tag,
+				MinSessions:       currentStart.minSessions,
+				Dimension:         currentStart.dimension,
+				IncludeAutoScored: currentStart.includeAutoScored,
+				UsePreferences:    currentStart.usePreferences,
+				Settings:          currentStart.settings,
+				StartLine:         currentStart.startLine,
+				EndLine:           lineNum,
+				Content:           strings.TrimSpace(currentStart.content.String()),
 			})
 			currentStart = nil
 			continue
@@ -123,12 +150,16 @@ func (p *Parser) FindTargets(filePath string) ([]types.OptimizationTarget, error
 			}
 
 			targets = append(targets, types.OptimizationTarget{
-				FilePath:    currentStart.filePath,
-				Tag:         currentStart.tag,
-				MinSessions: currentStart.minSessions,
-				StartLine:   currentStart.startLine,
-				EndLine:     lineNum,
-				Content:     strings.TrimSpace(currentStart.content.String()),
+				FilePath:          currentStart.filePath,
+				Tag:               currentStart.tag,
+				MinSessions:       currentStart.minSessions,
+				Dimension:         currentStart.dimension,
+				IncludeAutoScored: currentStart.includeAutoScored,
+				UsePreferences:    currentStart.usePreferences,
+				Settings:          currentStart.settings,
+				StartLine:         currentStart.startLine,
+				EndLine:           lineNum,
+				Content:           strings.TrimSpace(currentStart.content.String()),
 			})
 			currentStart = nil
 			continue
@@ -155,12 +186,21 @@ func (p *Parser) FindTargets(filePath string) ([]types.OptimizationTarget, error
 				}
 			}
 
+			settings, err := parseSettingsAttrs(match[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+
 			currentStart = &pendingTarget{
-				filePath:    filePath,
-				tag:         tag,
-				minSessions: minSessions,
-				startLine:   lineNum,
-				content:     strings.Builder{},
+				filePath:          filePath,
+				tag:               tag,
+				minSessions:       minSessions,
+				dimension:         parseDimensionAttr(match[2]),
+				includeAutoScored: parseAutoScoredAttr(match[2]),
+				usePreferences:    parsePreferencesAttr(match[2]),
+				settings:          settings,
+				startLine:         lineNum,
+				content:           strings.Builder{},
 			}
 			useLegacyEnd = false
 			continue
@@ -178,13 +218,21 @@ func (p *Parser) FindTargets(filePath string) ([]types.OptimizationTarget, error
 			if err != nil {
 				return nil, fmt.Errorf("line %d: %w", lineNum, err)
 			}
+			settings, err := parseSettingsAttrs(attrs)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
 
 			currentStart = &pendingTarget{
-				filePath:    filePath,
-				tag:         tag,
-				minSessions: minSessions,
-				startLine:   lineNum,
-				content:     strings.Builder{},
+				filePath:          filePath,
+				tag:               tag,
+				minSessions:       minSessions,
+				dimension:         parseDimensionAttr(attrs),
+				includeAutoScored: parseAutoScoredAttr(attrs),
+				usePreferences:    parsePreferencesAttr(attrs),
+				settings:          settings,
+				startLine:         lineNum,
+				content:           strings.Builder{},
 			}
 			useLegacyEnd = true
 			continue
@@ -486,11 +534,15 @@ func atomicWrite(filePath, content string) error {
 // Helper types for parsing
 
 type pendingTarget struct {
-	filePath    string
-	tag         string
-	minSessions int
-	startLine   int
-	content     strings.Builder
+	filePath          string
+	tag               string
+	minSessions       int
+	dimension         string
+	includeAutoScored bool
+	usePreferences    bool
+	settings          types.AnalyzerSettings
+	startLine         int
+	content           strings.Builder
 }
 
 type pendingExamplesTarget struct {
@@ -503,10 +555,82 @@ type pendingExamplesTarget struct {
 }
 
 type pendingStrategiesTarget struct {
-	filePath  string
-	tag       string
-	startLine int
-	content   strings.Builder
+	filePath    string
+	tag         string
+	defaultMode types.StrategySelectionMode
+	startLine   int
+	content     strings.Builder
+}
+
+// parseDimensionAttr extracts the optional rubric dimension to split
+// cohorts on; empty means the composite score.
+func parseDimensionAttr(attrs string) string {
+	if match := dimensionAttrPattern.FindStringSubmatch(attrs); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// parseAutoScoredAttr reports whether a target opts in to counting
+// auto-scored sessions.
+func parseAutoScoredAttr(attrs string) bool {
+	match := autoScoredAttrPattern.FindStringSubmatch(attrs)
+	return match != nil && match[1] == "true"
+}
+
+// parsePreferencesAttr reports whether a target opts in to scoring compared
+// sessions by their preference rating.
+func parsePreferencesAttr(attrs string) bool {
+	match := preferencesAttrPattern.FindStringSubmatch(attrs)
+	return match != nil && match[1] == "true"
+}
+
+// parseSettingsAttrs extracts analyzer settings from marker attributes, e.g.
+// cohort_quantile=0.25 disabled_detectors="step_count,sequences".
+func parseSettingsAttrs(attrs string) (types.AnalyzerSettings, error) {
+	var settings types.AnalyzerSettings
+	numbers := []struct {
+		pattern *regexp.Regexp
+		name    string
+		field   *float64
+	}{
+		{highThresholdAttrPattern, "high_threshold", &settings.HighThreshold},
+		{lowThresholdAttrPattern, "low_threshold", &settings.LowThreshold},
+		{cohortQuantileAttrPattern, "cohort_quantile", &settings.CohortQuantile},
+		{halfLifeAttrPattern, "recency_half_life_days", &settings.RecencyHalfLifeDays},
+		{significanceAttrPattern, "significance", &settings.Significance},
+	}
+	for _, n := range numbers {
+		match := n.pattern.FindStringSubmatch(attrs)
+		if match == nil {
+			continue
+		}
+		v, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return settings, fmt.Errorf("%w: invalid %s %q", ErrInvalidSettings, n.name, match[1])
+		}
+		*n.field = v
+	}
+
+	if match := detectorsAttrPattern.FindStringSubmatch(attrs); match != nil {
+		settings.Detectors = splitList(match[1])
+	}
+	if match := disabledDetectorsAttrPattern.FindStringSubmatch(attrs); match != nil {
+		settings.DisabledDetectors = splitList(match[1])
+	}
+
+	return settings, ValidateSettings(settings)
+}
+
+// splitList splits a comma-separated attribute value, dropping blanks.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // parseOptimizeAttrs extracts tag and min_sessions from attribute string.
@@ -578,11 +702,20 @@ func (p *Parser) FindStrategiesTargets(filePath string) ([]types.StrategiesTarge
 				return nil, fmt.Errorf("%w: nested start marker at line %d", ErrNestedMarkers, lineNum)
 			}
 
+			var mode types.StrategySelectionMode
+			if m := modeAttrPattern.FindStringSubmatch(match[2]); m != nil {
+				mode = types.StrategySelectionMode(m[1])
+				if !mode.Valid() {
+					return nil, fmt.Errorf("%w: unknown strategy mode %q at line %d", ErrInvalidMarker, m[1], lineNum)
+				}
+			}
+
 			currentStart = &pendingStrategiesTarget{
-				filePath:  filePath,
-				tag:       match[1],
-				startLine: lineNum,
-				content:   strings.Builder{},
+				filePath:    filePath,
+				tag:         match[1],
+				defaultMode: mode,
+				startLine:   lineNum,
+				content:     strings.Builder{},
 			}
 			continue
 		}
@@ -594,11 +727,12 @@ func (p *Parser) FindStrategiesTargets(filePath string) ([]types.StrategiesTarge
 			}
 
 			targets = append(targets, types.StrategiesTarget{
-				FilePath:  currentStart.filePath,
-				Tag:       currentStart.tag,
-				StartLine: currentStart.startLine,
-				EndLine:   lineNum,
-				Content:   strings.TrimSpace(currentStart.content.String()),
+				FilePath:    currentStart.filePath,
+				Tag:         currentStart.tag,
+				DefaultMode: currentStart.defaultMode,
+				StartLine:   currentStart.startLine,
+				EndLine:     lineNum,
+				Content:     strings.TrimSpace(currentStart.content.String()),
 			})
 			currentStart = nil
 			continue
@@ -715,9 +849,71 @@ func (p *Parser) ParseStrategies(content string) ([]types.Strategy, error) {
 		strategies = append(strategies, *current)
 	}
 
+	for i := range strategies {
+		strategies[i].Version = StrategyVersion(strategies[i].ApproachPrompt)
+	}
+
 	return strategies, nil
 }
 
+// StrategyVersion identifies a revision of a strategy by a short hash of its
+// approach prompt, so editing the prompt starts a new version.
+func StrategyVersion(approachPrompt string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(approachPrompt)))
+	return hex.EncodeToString(sum[:4])
+}
+
+// StrategyVersions maps each strategy under a tag in a file to the version
+// currently defined there.
+func (p *Parser) StrategyVersions(filePath, tag string) (map[string]string, error) {
+	targets, err := p.FindStrategiesTargets(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]string)
+	for _, t := range targets {
+		if t.Tag != tag {
+			continue
+		}
+		strategies, err := p.ParseStrategies(t.Content)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range strategies {
+			versions[s.Name] = s.Version
+		}
+	}
+	return versions, nil
+}
+
+// WithCurrentVersions adds an empty entry to per-version stats for each
+// current version that hasn't been used yet, keeping the stats ordered by
+// strategy name.
+func WithCurrentVersions(stats []types.Strategy, current map[string]string) []types.Strategy {
+	used := make(map[string]bool)
+	for _, s := range stats {
+		if s.Version == current[s.Name] {
+			used[s.Name] = true
+		}
+	}
+
+	var unused []string
+	for name := range current {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+
+	merged := append([]types.Strategy{}, stats...)
+	for _, name := range unused {
+		merged = append(merged, types.Strategy{Name: name, Version: current[name]})
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+	return merged
+}
+
 // ReplaceStrategiesTarget replaces the content between strategies markers with new content.
 func (p *Parser) ReplaceStrategiesTarget(filePath string, target types.StrategiesTarget, newContent string) error {
 	// Read the entire file
@@ -757,3 +953,74 @@ func (p *Parser) ReplaceStrategiesTarget(filePath string, target types.Strategie
 	// Write atomically
 	return atomicWrite(filePath, result.String())
 }
+
+// FindRubrics scans a markdown file for rubric markers and parses the
+// dimensions defined in each.
+//
+//	<!-- trajectory-rubric:backend -->
+//	- correctness: 0.5 Produces the right result
+//	- style: 0.2
+//	<!-- /trajectory-rubric:backend -->
+func (p *Parser) FindRubrics(filePath string) ([]types.Rubric, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	var rubrics []types.Rubric
+	var current *types.Rubric
+	var startLine int
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+
+		// Check for start marker: <!-- trajectory-rubric:tag -->
+		if match := rubricStartPattern.FindStringSubmatch(line); match != nil {
+			if current != nil {
+				return nil, fmt.Errorf("%w: nested start marker at line %d", ErrNestedMarkers, lineNum)
+			}
+			current = &types.Rubric{Tag: match[1]}
+			startLine = lineNum
+			continue
+		}
+
+		// Check for end marker: <!-- /trajectory-rubric:tag -->
+		if rubricEndPattern.MatchString(line) {
+			if current == nil {
+				return nil, fmt.Errorf("%w: end marker without start at line %d", ErrUnpairedMarkers, lineNum)
+			}
+			rubrics = append(rubrics, *current)
+			current = nil
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+		if match := rubricDimensionPattern.FindStringSubmatch(line); match != nil {
+			weight, err := strconv.ParseFloat(match[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid weight at line %d", ErrInvalidMarker, lineNum)
+			}
+			current.Dimensions = append(current.Dimensions, types.RubricDimension{
+				Name:        match[1],
+				Weight:      weight,
+				Description: strings.TrimSpace(match[3]),
+			})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	if current != nil {
+		return nil, fmt.Errorf("%w: start marker at line %d without end marker", ErrUnpairedMarkers, startLine)
+	}
+
+	return rubrics, nil
+}
@@ -1,10 +1,13 @@
 package optimizer
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
 )
 
 func TestParser_FindTargets_SingleTarget(t *testing.T) {
@@ -581,6 +584,44 @@ strategies:
 	}
 }
 
+func TestParser_FindStrategiesTargets_Mode(t *testing.T) {
+	content := `<!-- trajectory-strategies:research mode=thompson -->
+strategies:
+  - name: deep
+<!-- /trajectory-strategies:research -->
+
+<!-- trajectory-strategies:writing -->
+strategies:
+  - name: quick
+<!-- /trajectory-strategies:writing -->
+`
+	filePath := writeTempFile(t, content)
+	defer os.Remove(filePath)
+
+	p := NewParser()
+	targets, err := p.FindStrategiesTargets(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(targets))
+	}
+	if targets[0].Tag != "research" || targets[0].DefaultMode != types.StrategyModeThompson {
+		t.Errorf("expected research to default to thompson, got %+v", targets[0])
+	}
+	if targets[1].DefaultMode != "" {
+		t.Errorf("expected no default mode for writing, got %q", targets[1].DefaultMode)
+	}
+
+	filePath = writeTempFile(t, `<!-- trajectory-strategies:research mode=softmax -->
+<!-- /trajectory-strategies:research -->
+`)
+	defer os.Remove(filePath)
+	if _, err := p.FindStrategiesTargets(filePath); !errors.Is(err, ErrInvalidMarker) {
+		t.Errorf("expected ErrInvalidMarker for unknown mode, got %v", err)
+	}
+}
+
 func TestParser_ParseStrategies_SingleStrategy(t *testing.T) {
 	content := `strategies:
   - name: comprehensive
@@ -903,3 +944,161 @@ Legacy format
 		t.Errorf("second target mismatch: tag=%s, max=%d", targets[1].Tag, targets[1].MaxExamples)
 	}
 }
+
+func TestParser_FindRubrics(t *testing.T) {
+	content := `# Test File
+
+<!-- trajectory-rubric:backend -->
+- correctness: 0.5 Produces the right result
+- efficiency: 0.3
+- style: .2 Matches the codebase
+<!-- /trajectory-rubric:backend -->
+
+<!-- trajectory-optimize:backend dimension=correctness -->
+Content
+<!-- /trajectory-optimize:backend -->
+`
+	filePath := writeTempFile(t, content)
+	defer os.Remove(filePath)
+
+	p := NewParser()
+	rubrics, err := p.FindRubrics(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rubrics) != 1 || rubrics[0].Tag != "backend" {
+		t.Fatalf("expected one backend rubric, got %+v", rubrics)
+	}
+
+	dims := rubrics[0].Dimensions
+	if len(dims) != 3 {
+		t.Fatalf("expected 3 dimensions, got %d", len(dims))
+	}
+	if dims[0].Name != "correctness" || dims[0].Weight != 0.5 || dims[0].Description != "Produces the right result" {
+		t.Errorf("unexpected first dimension: %+v", dims[0])
+	}
+	if dims[1].Name != "efficiency" || dims[1].Description != "" {
+		t.Errorf("unexpected second dimension: %+v", dims[1])
+	}
+	if dims[2].Weight != 0.2 {
+		t.Errorf("expected weight 0.2, got %f", dims[2].Weight)
+	}
+
+	targets, err := p.FindTargets(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(targets) != 1 || targets[0].Dimension != "correctness" {
+		t.Errorf("expected target to split on correctness, got %+v", targets)
+	}
+}
+
+func TestParser_FindRubrics_UnpairedMarker(t *testing.T) {
+	content := `<!-- trajectory-rubric:backend -->
+- correctness: 1
+`
+	filePath := writeTempFile(t, content)
+	defer os.Remove(filePath)
+
+	p := NewParser()
+	_, err := p.FindRubrics(filePath)
+	if err == nil || !strings.Contains(err.Error(), "without end marker") {
+		t.Errorf("expected unpaired marker error, got: %v", err)
+	}
+}
+
+func TestParser_FindTargets_ScoreSourceAttrs(t *testing.T) {
+	content := `<!-- trajectory-optimize:backend auto_scored=true preferences=true -->
+Content
+<!-- /trajectory-optimize:backend -->
+
+<!-- trajectory-optimize:start tag="docs" preferences=false -->
+Content
+<!-- trajectory-optimize:end -->
+`
+	filePath := writeTempFile(t, content)
+	defer os.Remove(filePath)
+
+	targets, err := NewParser().FindTargets(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(targets))
+	}
+	if !targets[0].IncludeAutoScored || !targets[0].UsePreferences {
+		t.Errorf("expected backend to opt in to both, got %+v", targets[0])
+	}
+	if targets[1].IncludeAutoScored || targets[1].UsePreferences {
+		t.Errorf("expected docs to opt in to neither, got %+v", targets[1])
+	}
+}
+
+func TestParser_FindTargets_SettingsAttrs(t *testing.T) {
+	content := `<!-- trajectory-optimize:backend high_threshold=0.6 low_threshold=0.3 recency_half_life_days=14 disabled_detectors="step_count, sequences" -->
+Content
+<!-- /trajectory-optimize:backend -->
+
+<!-- trajectory-optimize:start tag="docs" cohort_quantile=0.25 significance=0.1 detectors="reads_before_write" -->
+Content
+<!-- trajectory-optimize:end -->
+`
+	filePath := writeTempFile(t, content)
+	defer os.Remove(filePath)
+
+	targets, err := NewParser().FindTargets(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(targets))
+	}
+
+	backend := targets[0].Settings
+	if backend.HighThreshold != 0.6 || backend.LowThreshold != 0.3 || backend.RecencyHalfLifeDays != 14 {
+		t.Errorf("unexpected backend settings %+v", backend)
+	}
+	if len(backend.Detectors) != 0 || len(backend.DisabledDetectors) != 2 || backend.DisabledDetectors[1] != DetectorSequences {
+		t.Errorf("expected two disabled detectors, got %+v", backend)
+	}
+
+	docs := targets[1].Settings
+	if docs.CohortQuantile != 0.25 || docs.Significance != 0.1 || len(docs.Detectors) != 1 {
+		t.Errorf("unexpected docs settings %+v", docs)
+	}
+
+	for _, attrs := range []string{`cohort_quantile=0.75`, `detectors="nonsense"`, `low_threshold=0.9 high_threshold=0.6`} {
+		filePath := writeTempFile(t, "<!-- trajectory-optimize:backend "+attrs+" -->\n<!-- /trajectory-optimize:backend -->\n")
+		defer os.Remove(filePath)
+		if _, err := NewParser().FindTargets(filePath); !errors.Is(err, ErrInvalidSettings) {
+			t.Errorf("expected %s to be invalid, got %v", attrs, err)
+		}
+	}
+}
+
+func TestParser_ParseStrategies_Version(t *testing.T) {
+	p := NewParser()
+	parse := func(content string) types.Strategy {
+		t.Helper()
+		strategies, err := p.ParseStrategies(content)
+		if err != nil || len(strategies) != 1 {
+			t.Fatalf("expected one strategy, got %v (%v)", strategies, err)
+		}
+		return strategies[0]
+	}
+
+	original := parse("strategies:\n  - name: careful\n    approach_prompt: |\n      Read first\n")
+	if original.Version != StrategyVersion("Read first") || len(original.Version) != 8 {
+		t.Errorf("expected an 8 character hash of the prompt, got %q", original.Version)
+	}
+
+	// Only the approach prompt decides the version
+	described := parse("strategies:\n  - name: careful\n    description: Careful\n    approach_prompt: |\n      Read first\n\n")
+	if described.Version != original.Version {
+		t.Errorf("expected the same version, got %q and %q", original.Version, described.Version)
+	}
+	edited := parse("strategies:\n  - name: careful\n    approach_prompt: |\n      Read first, then test\n")
+	if edited.Version == original.Version {
+		t.Errorf("expected an edited prompt to change the version, got %q", edited.Version)
+	}
+}
@@ -0,0 +1,388 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/johncarpenter/trajectory-memory/internal/autoscore"
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+// Limits for sequence mining.
+const (
+	minSequenceLength = 2
+	maxSequenceLength = 4
+
+	// maxFrequentSequences is the number of most common runs reported.
+	maxFrequentSequences = 10
+
+	// maxSequencePatterns is the number of discriminative runs reported as
+	// patterns, and separately as anti-patterns.
+	maxSequencePatterns = 3
+
+	// minSequenceSupport is the share of a cohort that must contain a run
+	// for it to describe that cohort.
+	minSequenceSupport = 0.5
+
+	// minSequenceLift is how much more common a run must be in one cohort
+	// than in the other.
+	minSequenceLift = 0.3
+)
+
+// Bash commands are reduced to what they were for, so "go test ./..." and
+// "npm test" both become Bash(test).
+var bashCategories = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"test", autoscore.DefaultTestPattern},
+	{"git", regexp.MustCompile(`(^|[\s;&|(])git\s`)},
+	{"build", regexp.MustCompile(`(^|[\s;&|(])(go build|go vet|cargo build|npm run build|yarn build|pnpm build|tsc|make|mvn (package|compile)|gradle build|dotnet build)\b`)},
+	{"install", regexp.MustCompile(`(^|[\s;&|(])(npm (install|ci|i)|yarn add|pnpm (add|install)|pip3? install|go get|go mod|cargo add|bundle install)\b`)},
+}
+
+// stepToken reduces a step to its tool name and, for Bash, the kind of
+// command it ran.
+func stepToken(step types.TrajectoryStep) string {
+	if step.ToolName != "Bash" {
+		return step.ToolName
+	}
+	for _, c := range bashCategories {
+		if c.pattern.MatchString(step.InputSummary) {
+			return "Bash(" + c.name + ")"
+		}
+	}
+	return "Bash"
+}
+
+// sessionTokens returns the token for each step of a session in order.
+func sessionTokens(s *types.Session) []string {
+	tokens := make([]string, 0, len(s.Steps))
+	for _, step := range s.Steps {
+		if step.ToolName != "" {
+			tokens = append(tokens, stepToken(step))
+		}
+	}
+	return tokens
+}
+
+// sequenceKey joins tokens into a map key. The separator can't appear in
+// tool names.
+func sequenceKey(tokens []string) string {
+	return strings.Join(tokens, "\x00")
+}
+
+// formatSequence renders tokens the way patterns show them.
+func formatSequence(tokens []string) string {
+	return strings.Join(tokens, " → ")
+}
+
+// sessionSequences returns every distinct run of consecutive tokens in a
+// session between minSequenceLength and maxSequenceLength long.
+func sessionSequences(tokens []string) map[string][]string {
+	runs := make(map[string][]string)
+	for n := minSequenceLength; n <= maxSequenceLength; n++ {
+		for i := 0; i+n <= len(tokens); i++ {
+			run := tokens[i : i+n]
+			runs[sequenceKey(run)] = run
+		}
+	}
+	return runs
+}
+
+// countSequences counts the sessions containing each run, along with the
+// run's tokens.
+func countSequences(sessions []*types.Session) (map[string]int, map[string][]string) {
+	counts := make(map[string]int)
+	tokens := make(map[string][]string)
+	for _, s := range sessions {
+		for key, run := range sessionSequences(sessionTokens(s)) {
+			counts[key]++
+			tokens[key] = run
+		}
+	}
+	return counts, tokens
+}
+
+// transitionMatrix returns the probability of each token following another
+// across sessions.
+func transitionMatrix(sessions []*types.Session) map[string]map[string]float64 {
+	counts := make(map[string]map[string]int)
+	for _, s := range sessions {
+		tokens := sessionTokens(s)
+		for i := 0; i+1 < len(tokens); i++ {
+			from, to := tokens[i], tokens[i+1]
+			if counts[from] == nil {
+				counts[from] = make(map[string]int)
+			}
+			counts[from][to]++
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+
+	matrix := make(map[string]map[string]float64, len(counts))
+	for from, row := range counts {
+		total := 0
+		for _, n := range row {
+			total += n
+		}
+		matrix[from] = make(map[string]float64, len(row))
+		for to, n := range row {
+			matrix[from][to] = float64(n) / float64(total)
+		}
+	}
+	return matrix
+}
+
+// mineSequences finds runs of tool calls that are common overall and runs
+// that separate high-scoring from low-scoring sessions. Runs are consecutive
+// steps only; a run broken by any other step doesn't count.
+func (a *Analyzer) mineSequences(all, high, low []*types.Session) *types.SequenceAnalysis {
+	analysis := &types.SequenceAnalysis{
+		HighTransitions: transitionMatrix(high),
+		LowTransitions:  transitionMatrix(low),
+	}
+
+	allCounts, tokens := countSequences(all)
+	highCounts, _ := countSequences(high)
+	lowCounts, _ := countSequences(low)
+
+	stat := func(key string) types.SequenceStat {
+		return types.SequenceStat{
+			Tokens:      tokens[key],
+			Support:     share(allCounts[key], len(all)),
+			HighSupport: share(highCounts[key], len(high)),
+			LowSupport:  share(lowCounts[key], len(low)),
+		}
+	}
+
+	// Most common runs seen in at least two sessions
+	for key, n := range allCounts {
+		if n >= 2 {
+			analysis.FrequentSequences = append(analysis.FrequentSequences, stat(key))
+		}
+	}
+	sortSequences(analysis.FrequentSequences, func(s types.SequenceStat) float64 { return -s.Support })
+	if len(analysis.FrequentSequences) > maxFrequentSequences {
+		analysis.FrequentSequences = analysis.FrequentSequences[:maxFrequentSequences]
+	}
+
+	if len(high) == 0 || len(low) == 0 {
+		return analysis
+	}
+
+	alpha := a.Significance
+	if alpha <= 0 {
+		alpha = DefaultSignificance
+	}
+
+	// Every run seen in at least two sessions is tested in the direction its
+	// cohorts lean, and the p-values are adjusted together so that mining
+	// many runs doesn't turn up significant ones by chance
+	var tested []types.SequenceStat
+	for key, n := range allCounts {
+		if n < 2 {
+			continue
+		}
+		s := stat(key)
+		h, l := highCounts[key], lowCounts[key]
+		if s.HighSupport >= s.LowSupport {
+			s.PValue = fisherExact(h, len(high)-h, l, len(low)-l)
+		} else {
+			s.PValue = fisherExact(l, len(low)-l, h, len(high)-h)
+		}
+		tested = append(tested, s)
+	}
+	pvalues := make([]float64, len(tested))
+	for i, s := range tested {
+		pvalues[i] = s.PValue
+	}
+	for i, adjusted := range adjustPValues(pvalues) {
+		tested[i].AdjustedPValue = adjusted
+	}
+
+	var favored, disfavored []types.SequenceStat
+	for _, s := range tested {
+		if s.AdjustedPValue >= alpha {
+			continue
+		}
+		switch {
+		case s.HighSupport >= minSequenceSupport && s.HighSupport-s.LowSupport >= minSequenceLift:
+			favored = append(favored, s)
+		case s.LowSupport >= minSequenceSupport && s.LowSupport-s.HighSupport >= minSequenceLift:
+			disfavored = append(disfavored, s)
+		}
+	}
+
+	analysis.Discriminative = append(distinctSequences(favored), distinctSequences(disfavored)...)
+	return analysis
+}
+
+// distinctSequences keeps the strongest runs, dropping any that overlap a
+// run already kept so one habit isn't reported several times. Longer runs
+// win ties since they say more.
+func distinctSequences(stats []types.SequenceStat) []types.SequenceStat {
+	sortSequences(stats, func(s types.SequenceStat) float64 { return s.PValue })
+
+	var kept []types.SequenceStat
+	for _, s := range stats {
+		overlaps := false
+		for _, k := range kept {
+			if containsRun(k.Tokens, s.Tokens) || containsRun(s.Tokens, k.Tokens) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			kept = append(kept, s)
+		}
+		if len(kept) == maxSequencePatterns {
+			break
+		}
+	}
+	return kept
+}
+
+// sortSequences orders stats by key ascending, then longest first, then
+// alphabetically so results are stable.
+func sortSequences(stats []types.SequenceStat, key func(types.SequenceStat) float64) {
+	sort.Slice(stats, func(i, j int) bool {
+		ki, kj := key(stats[i]), key(stats[j])
+		if ki != kj {
+			return ki < kj
+		}
+		if len(stats[i].Tokens) != len(stats[j].Tokens) {
+			return len(stats[i].Tokens) > len(stats[j].Tokens)
+		}
+		return sequenceKey(stats[i].Tokens) < sequenceKey(stats[j].Tokens)
+	})
+}
+
+// containsRun reports whether sub appears as consecutive tokens in run.
+func containsRun(run, sub []string) bool {
+	for i := 0; i+len(sub) <= len(run); i++ {
+		match := true
+		for j := range sub {
+			if run[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// sequenceAntiPatternPrefix starts every sequence anti-pattern.
+const sequenceAntiPatternPrefix = "Fell into the sequence "
+
+// sequencePatterns describes discriminative runs as patterns for the high
+// cohort and anti-patterns for the low one.
+func sequencePatterns(analysis *types.SequenceAnalysis) (patterns, antiPatterns []string) {
+	if analysis == nil {
+		return nil, nil
+	}
+	for _, s := range analysis.Discriminative {
+		if s.HighSupport > s.LowSupport {
+			patterns = append(patterns,
+				fmt.Sprintf("Followed the sequence %s (%.0f%% of high-scoring vs %.0f%% of low-scoring sessions)",
+					formatSequence(s.Tokens), s.HighSupport*100, s.LowSupport*100))
+		} else {
+			antiPatterns = append(antiPatterns,
+				fmt.Sprintf(sequenceAntiPatternPrefix+"%s (%.0f%% of low-scoring vs %.0f%% of high-scoring sessions)",
+					formatSequence(s.Tokens), s.LowSupport*100, s.HighSupport*100))
+		}
+	}
+	return patterns, antiPatterns
+}
+
+// fisherExact returns the one-sided p-value of Fisher's exact test that
+// the first group (a with, b without) contains a run more often than the
+// second (c with, d without).
+func fisherExact(a, b, c, d int) float64 {
+	withRun := a + c
+	firstGroup := a + b
+	total := a + b + c + d
+
+	p := 0.0
+	for x := a; x <= withRun && x <= firstGroup; x++ {
+		p += math.Exp(logChoose(firstGroup, x) + logChoose(total-firstGroup, withRun-x) - logChoose(total, withRun))
+	}
+	return math.Min(p, 1)
+}
+
+// logChoose returns the log of n choose k.
+func logChoose(n, k int) float64 {
+	if k < 0 || k > n {
+		return math.Inf(-1)
+	}
+	ln, _ := math.Lgamma(float64(n + 1))
+	lk, _ := math.Lgamma(float64(k + 1))
+	lnk, _ := math.Lgamma(float64(n - k + 1))
+	return ln - lk - lnk
+}
+
+// share returns n as a fraction of total, or zero for an empty total.
+func share(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// transitionShift is a step-to-step transition whose probability differs
+// between cohorts.
+type transitionShift struct {
+	from, to  string
+	high, low float64
+}
+
+// transitionShifts returns up to n transitions whose probability differs
+// most between the cohorts, counting only steps both cohorts took.
+func transitionShifts(analysis *types.SequenceAnalysis, n int) []transitionShift {
+	if analysis == nil {
+		return nil
+	}
+
+	var shifts []transitionShift
+	for from, highRow := range analysis.HighTransitions {
+		lowRow, ok := analysis.LowTransitions[from]
+		if !ok {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, row := range []map[string]float64{highRow, lowRow} {
+			for to := range row {
+				if seen[to] {
+					continue
+				}
+				seen[to] = true
+				if math.Abs(highRow[to]-lowRow[to]) >= minSequenceLift {
+					shifts = append(shifts, transitionShift{from: from, to: to, high: highRow[to], low: lowRow[to]})
+				}
+			}
+		}
+	}
+
+	sort.Slice(shifts, func(i, j int) bool {
+		di, dj := math.Abs(shifts[i].high-shifts[i].low), math.Abs(shifts[j].high-shifts[j].low)
+		if di != dj {
+			return di > dj
+		}
+		if shifts[i].from != shifts[j].from {
+			return shifts[i].from < shifts[j].from
+		}
+		return shifts[i].to < shifts[j].to
+	})
+	if len(shifts) > n {
+		shifts = shifts[:n]
+	}
+	return shifts
+}
@@ -0,0 +1,178 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+// sequenceSession builds a scored session whose steps use the given tools.
+// Bash steps take their command after a colon, e.g. "Bash:go test ./...".
+func sequenceSession(id string, score float64, tools ...string) *types.Session {
+	s := createTestSession(id, "refactor", score)
+	s.Steps = nil
+	for _, tool := range tools {
+		step := types.TrajectoryStep{ToolName: tool}
+		if command, ok := strings.CutPrefix(tool, "Bash:"); ok {
+			step.ToolName, step.InputSummary = "Bash", command
+		}
+		s.Steps = append(s.Steps, step)
+	}
+	return s
+}
+
+func TestStepToken(t *testing.T) {
+	tests := []struct {
+		step types.TrajectoryStep
+		want string
+	}{
+		{types.TrajectoryStep{ToolName: "Read", InputSummary: "main.go"}, "Read"},
+		{types.TrajectoryStep{ToolName: "Bash", InputSummary: "go test ./..."}, "Bash(test)"},
+		{types.TrajectoryStep{ToolName: "Bash", InputSummary: "cd web && npm test"}, "Bash(test)"},
+		{types.TrajectoryStep{ToolName: "Bash", InputSummary: "git status"}, "Bash(git)"},
+		{types.TrajectoryStep{ToolName: "Bash", InputSummary: "go build ./cmd/tm"}, "Bash(build)"},
+		{types.TrajectoryStep{ToolName: "Bash", InputSummary: "pip install requests"}, "Bash(install)"},
+		{types.TrajectoryStep{ToolName: "Bash", InputSummary: "ls -la"}, "Bash"},
+	}
+	for _, tc := range tests {
+		if got := stepToken(tc.step); got != tc.want {
+			t.Errorf("stepToken(%q) = %q, want %q", tc.step.InputSummary, got, tc.want)
+		}
+	}
+}
+
+func TestFisherExact(t *testing.T) {
+	tests := []struct {
+		a, b, c, d int
+		want       float64
+	}{
+		// Complete separation of 3 vs 3 is one arrangement out of C(6,3)
+		{3, 0, 0, 3, 1.0 / 20},
+		{5, 0, 0, 5, 1.0 / 252},
+		// The reverse direction can't be less likely than chance
+		{0, 3, 3, 0, 1},
+		// (C(4,2)² + C(4,3)·C(4,1) + 1) / C(8,4)
+		{2, 2, 2, 2, 53.0 / 70},
+	}
+	for _, tc := range tests {
+		got := fisherExact(tc.a, tc.b, tc.c, tc.d)
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("fisherExact(%d, %d, %d, %d) = %f, want %f", tc.a, tc.b, tc.c, tc.d, got, tc.want)
+		}
+	}
+}
+
+func TestMineSequences(t *testing.T) {
+	var high, low []*types.Session
+	for i := 0; i < 5; i++ {
+		high = append(high, sequenceSession(fmt.Sprintf("h%d", i), 0.9,
+			"Grep", "Read", "Edit", "Bash:go test ./..."))
+		low = append(low, sequenceSession(fmt.Sprintf("l%d", i), 0.2,
+			"Read", "Write", "Write", "Write"))
+	}
+	all := append(append([]*types.Session{}, high...), low...)
+
+	analysis := NewAnalyzer(newMockStore()).mineSequences(all, high, low)
+
+	// Overlapping runs are reported once, as the longest
+	if len(analysis.Discriminative) != 2 {
+		t.Fatalf("expected one run per cohort, got %+v", analysis.Discriminative)
+	}
+	favored, disfavored := analysis.Discriminative[0], analysis.Discriminative[1]
+	if got := formatSequence(favored.Tokens); got != "Grep → Read → Edit → Bash(test)" {
+		t.Errorf("unexpected favored run %q", got)
+	}
+	// Every run tested has the same p-value, so adjusting leaves it unchanged
+	if favored.HighSupport != 1 || favored.LowSupport != 0 || math.Abs(favored.PValue-1.0/252) > 1e-9 ||
+		math.Abs(favored.AdjustedPValue-favored.PValue) > 1e-9 {
+		t.Errorf("unexpected favored stats %+v", favored)
+	}
+	if got := formatSequence(disfavored.Tokens); got != "Read → Write → Write → Write" {
+		t.Errorf("unexpected disfavored run %q", got)
+	}
+
+	if p := analysis.HighTransitions["Edit"]["Bash(test)"]; p != 1 {
+		t.Errorf("expected Edit to always lead to tests in high sessions, got %f", p)
+	}
+	if p := analysis.LowTransitions["Write"]["Write"]; p != 1 {
+		t.Errorf("expected Write to always follow Write in low sessions, got %f", p)
+	}
+	shifts := transitionShifts(analysis, 5)
+	if len(shifts) == 0 || shifts[0].from != "Read" {
+		t.Errorf("expected the Read transition to differ most, got %+v", shifts)
+	}
+
+	if len(analysis.FrequentSequences) == 0 || analysis.FrequentSequences[0].Support != 0.5 {
+		t.Errorf("expected frequent runs in half the sessions, got %+v", analysis.FrequentSequences)
+	}
+
+	// Each run clears p < 0.015 alone (1/70), but not once it's adjusted
+	// for the eleven runs tested
+	high, low = nil, nil
+	for i := 0; i < 4; i++ {
+		high = append(high, sequenceSession(fmt.Sprintf("h%d", i), 0.9, "Grep", "Read", "Edit", "Write"))
+		low = append(low, sequenceSession(fmt.Sprintf("l%d", i), 0.2, "Glob", "Edit", "Write", "Bash"))
+	}
+	analyzer := NewAnalyzer(newMockStore())
+	analyzer.Significance = 0.015
+	adjusted := analyzer.mineSequences(append(append([]*types.Session{}, high...), low...), high, low)
+	if len(adjusted.Discriminative) != 0 {
+		t.Errorf("expected no runs to survive adjustment, got %+v", adjusted.Discriminative)
+	}
+
+	// Too few sessions to rule out chance
+	analysis = NewAnalyzer(newMockStore()).mineSequences(all[4:6], all[4:5], all[5:6])
+	if len(analysis.Discriminative) != 0 {
+		t.Errorf("expected no significant runs from two sessions, got %+v", analysis.Discriminative)
+	}
+}
+
+func TestAnalyzer_SequencePatterns(t *testing.T) {
+	store := newMockStore()
+	for i := 0; i < 5; i++ {
+		store.CreateSession(sequenceSession(fmt.Sprintf("h%d", i), 0.9,
+			"Grep", "Read", "Edit", "Bash:go test ./..."))
+		store.CreateSession(sequenceSession(fmt.Sprintf("l%d", i), 0.2,
+			"Read", "Write", "Write", "Write"))
+	}
+
+	analysis, err := NewAnalyzer(store).Analyze("refactor", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantPattern := "Followed the sequence Grep → Read → Edit → Bash(test) (100% of high-scoring vs 0% of low-scoring sessions)"
+	if !hasEntry(analysis.HighScorePatterns, wantPattern) {
+		t.Errorf("expected sequence pattern, got %v", analysis.HighScorePatterns)
+	}
+	wantAnti := "Fell into the sequence Read → Write → Write → Write (100% of low-scoring vs 0% of high-scoring sessions)"
+	if !hasEntry(analysis.LowScoreAntiPatterns, wantAnti) {
+		t.Errorf("expected sequence anti-pattern, got %v", analysis.LowScoreAntiPatterns)
+	}
+	if !hasEntry(analysis.RecommendedPractices, "Avoid the sequence Read → Write → Write → Write") {
+		t.Errorf("expected a recommendation against the sequence, got %v", analysis.RecommendedPractices)
+	}
+
+	prompt := (&Optimizer{}).generateMetaPrompt(types.OptimizationTarget{Tag: "refactor"}, analysis)
+	for _, want := range []string{
+		"### Tool Sequences",
+		"| Grep → Read → Edit → Bash(test) | 100% | 0% | 0.004 |",
+		"- Read → Edit: 100% in high-scoring vs 0% in low-scoring sessions",
+	} {
+		if !containsString(prompt, want) {
+			t.Errorf("expected prompt to contain %q", want)
+		}
+	}
+}
+
+func hasEntry(list []string, want string) bool {
+	for _, s := range list {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
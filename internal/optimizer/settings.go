@@ -0,0 +1,221 @@
+package optimizer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+// ErrInvalidSettings is returned when analyzer settings are out of range or
+// name an unknown pattern detector.
+var ErrInvalidSettings = errors.New("invalid analyzer settings")
+
+// DetectorSequences is the pattern detector that mines tool-call sequences.
+// The other detectors are named after the features in stats.go.
+const DetectorSequences = "sequences"
+
+// DefaultSettingsTag is the tag whose settings apply to every tag.
+const DefaultSettingsTag = "*"
+
+// SettingsConfig is the format of the analyzer config file:
+//
+//	{"analyzers": [{"tag": "backend", "cohort_quantile": 0.25, "disabled_detectors": ["step_count"]}]}
+type SettingsConfig struct {
+	Analyzers []types.AnalyzerSettings `json:"analyzers"`
+}
+
+// Settings maps tags to their analyzer settings.
+type Settings map[string]types.AnalyzerSettings
+
+// LoadSettings reads an analyzer config file.
+// A missing file yields no settings.
+func LoadSettings(path string) (Settings, error) {
+	settings := make(Settings)
+	if path == "" {
+		return settings, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil
+		}
+		return nil, fmt.Errorf("failed to read analyzer config: %w", err)
+	}
+
+	var cfg SettingsConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse analyzer config: %w", err)
+	}
+	for _, s := range cfg.Analyzers {
+		if s.Tag == "" {
+			return nil, fmt.Errorf("%w: analyzer config entry without a tag", ErrInvalidSettings)
+		}
+		if err := ValidateSettings(s); err != nil {
+			return nil, fmt.Errorf("tag %s: %w", s.Tag, err)
+		}
+		settings[s.Tag] = s
+	}
+	return settings, nil
+}
+
+// For returns the settings for a tag, layered over the default settings.
+func (s Settings) For(tag string) types.AnalyzerSettings {
+	merged := MergeSettings(s[DefaultSettingsTag], s[tag])
+	merged.Tag = tag
+	return merged
+}
+
+// MergeSettings returns base with every field set in override replacing it.
+func MergeSettings(base, override types.AnalyzerSettings) types.AnalyzerSettings {
+	merged := base
+	if override.Tag != "" {
+		merged.Tag = override.Tag
+	}
+	if override.HighThreshold > 0 {
+		merged.HighThreshold = override.HighThreshold
+	}
+	if override.LowThreshold > 0 {
+		merged.LowThreshold = override.LowThreshold
+	}
+	if override.CohortQuantile > 0 {
+		merged.CohortQuantile = override.CohortQuantile
+	}
+	if override.RecencyHalfLifeDays > 0 {
+		merged.RecencyHalfLifeDays = override.RecencyHalfLifeDays
+	}
+	if override.Significance > 0 {
+		merged.Significance = override.Significance
+	}
+	if len(override.Detectors) > 0 {
+		merged.Detectors = override.Detectors
+	}
+	if len(override.DisabledDetectors) > 0 {
+		merged.DisabledDetectors = override.DisabledDetectors
+	}
+	return merged
+}
+
+// ValidateSettings checks that settings are in range and only name known
+// detectors.
+func ValidateSettings(s types.AnalyzerSettings) error {
+	switch {
+	case s.HighThreshold < 0 || s.HighThreshold > 1:
+		return fmt.Errorf("%w: high_threshold must be between 0 and 1", ErrInvalidSettings)
+	case s.LowThreshold < 0 || s.LowThreshold > 1:
+		return fmt.Errorf("%w: low_threshold must be between 0 and 1", ErrInvalidSettings)
+	case s.HighThreshold > 0 && s.LowThreshold > s.HighThreshold:
+		return fmt.Errorf("%w: low_threshold can't be above high_threshold", ErrInvalidSettings)
+	case s.CohortQuantile < 0 || s.CohortQuantile > 0.5:
+		return fmt.Errorf("%w: cohort_quantile must be between 0 and 0.5", ErrInvalidSettings)
+	case s.RecencyHalfLifeDays < 0:
+		return fmt.Errorf("%w: recency_half_life_days can't be negative", ErrInvalidSettings)
+	case s.Significance < 0 || s.Significance >= 1:
+		return fmt.Errorf("%w: significance must be between 0 and 1", ErrInvalidSettings)
+	}
+
+	known := make(map[string]bool)
+	for _, name := range DetectorNames() {
+		known[name] = true
+	}
+	for _, name := range append(append([]string{}, s.Detectors...), s.DisabledDetectors...) {
+		if !known[name] {
+			return fmt.Errorf("%w: unknown detector %q (known: %s)",
+				ErrInvalidSettings, name, strings.Join(DetectorNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// DetectorNames lists every pattern detector, sorted.
+func DetectorNames() []string {
+	names := []string{DetectorSequences}
+	for _, f := range features {
+		names = append(names, f.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Configure applies settings to the analyzer. Zero values leave the
+// analyzer's current behavior in place.
+func (a *Analyzer) Configure(s types.AnalyzerSettings) {
+	if s.HighThreshold > 0 {
+		a.HighThreshold = s.HighThreshold
+	}
+	if s.LowThreshold > 0 {
+		a.LowThreshold = s.LowThreshold
+	}
+	if s.CohortQuantile > 0 {
+		a.CohortQuantile = s.CohortQuantile
+	}
+	if s.RecencyHalfLifeDays > 0 {
+		a.RecencyHalfLife = time.Duration(s.RecencyHalfLifeDays * float64(24*time.Hour))
+	}
+	if s.Significance > 0 {
+		a.Significance = s.Significance
+	}
+
+	if len(s.Detectors) == 0 && len(s.DisabledDetectors) == 0 {
+		return
+	}
+	enabled := make(map[string]bool)
+	if len(s.Detectors) > 0 {
+		for _, name := range s.Detectors {
+			enabled[name] = true
+		}
+	} else {
+		for _, name := range DetectorNames() {
+			enabled[name] = true
+		}
+	}
+	for _, name := range s.DisabledDetectors {
+		delete(enabled, name)
+	}
+	a.Detectors = enabled
+}
+
+// settings reports the analyzer's effective settings, with defaults filled
+// in. Thresholds are left out when cohorts are split by quantile.
+func (a *Analyzer) settings(tag string) types.AnalyzerSettings {
+	s := types.AnalyzerSettings{
+		Tag:                 tag,
+		CohortQuantile:      a.CohortQuantile,
+		RecencyHalfLifeDays: a.RecencyHalfLife.Hours() / 24,
+		Significance:        a.Significance,
+	}
+	if s.Significance <= 0 {
+		s.Significance = DefaultSignificance
+	}
+	if a.CohortQuantile <= 0 {
+		s.HighThreshold, s.LowThreshold = HighScoreThreshold, LowScoreThreshold
+		if a.HighThreshold > 0 {
+			s.HighThreshold = a.HighThreshold
+		}
+		if a.LowThreshold > 0 {
+			s.LowThreshold = a.LowThreshold
+		}
+	}
+	if a.Detectors != nil {
+		for _, name := range DetectorNames() {
+			if a.Detectors[name] {
+				s.Detectors = append(s.Detectors, name)
+			}
+		}
+	}
+	return s
+}
+
+// describeCohorts says how sessions were split into cohorts.
+func describeCohorts(s types.AnalyzerSettings) string {
+	if s.CohortQuantile > 0 {
+		return fmt.Sprintf("top and bottom %.0f%% of sessions by score", s.CohortQuantile*100)
+	}
+	return fmt.Sprintf("high at or above %.2f, low below %.2f", s.HighThreshold, s.LowThreshold)
+}
@@ -0,0 +1,150 @@
+package optimizer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+func TestLoadSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analyzers.json")
+	config := `{"analyzers": [
+		{"tag": "*", "cohort_quantile": 0.25, "significance": 0.1},
+		{"tag": "backend", "significance": 0.01, "disabled_detectors": ["sequences"]}
+	]}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := LoadSettings(path)
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	backend := settings.For("backend")
+	if backend.Tag != "backend" || backend.CohortQuantile != 0.25 || backend.Significance != 0.01 || len(backend.DisabledDetectors) != 1 {
+		t.Errorf("expected backend layered over the defaults, got %+v", backend)
+	}
+	if docs := settings.For("docs"); docs.CohortQuantile != 0.25 || docs.Significance != 0.1 {
+		t.Errorf("expected docs to get the defaults, got %+v", docs)
+	}
+
+	// Marker attributes override the config file
+	merged := MergeSettings(backend, types.AnalyzerSettings{Significance: 0.2})
+	if merged.Significance != 0.2 || merged.CohortQuantile != 0.25 {
+		t.Errorf("expected the override to win, got %+v", merged)
+	}
+
+	if settings, err := LoadSettings(filepath.Join(t.TempDir(), "missing.json")); err != nil || len(settings) != 0 {
+		t.Errorf("expected a missing file to yield no settings, got %v, %v", settings, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"analyzers": [{"tag": "backend", "detectors": ["psychic"]}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSettings(path); !errors.Is(err, ErrInvalidSettings) {
+		t.Errorf("expected an unknown detector to be rejected, got %v", err)
+	}
+}
+
+func TestAnalyzer_CohortSettings(t *testing.T) {
+	// A harshly graded tag where nothing reaches the default high threshold
+	store := newMockStore()
+	for i, score := range []float64{0.1, 0.15, 0.2, 0.25, 0.3, 0.35, 0.4, 0.45} {
+		store.CreateSession(createTestSession(fmt.Sprintf("s%d", i), "harsh", score))
+	}
+
+	analyzer := NewAnalyzer(store)
+	analysis, err := analyzer.Analyze("harsh", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if analysis.HighScoreSessions != 0 || analysis.LowScoreSessions != 8 {
+		t.Fatalf("expected no high cohort by default, got %d high and %d low", analysis.HighScoreSessions, analysis.LowScoreSessions)
+	}
+
+	analyzer.Configure(types.AnalyzerSettings{HighThreshold: 0.4, LowThreshold: 0.2})
+	analysis, _ = analyzer.Analyze("harsh", 5)
+	if analysis.HighScoreSessions != 2 || analysis.LowScoreSessions != 2 {
+		t.Errorf("expected 2 high and 2 low with thresholds, got %d and %d", analysis.HighScoreSessions, analysis.LowScoreSessions)
+	}
+
+	analyzer = NewAnalyzer(store)
+	analyzer.Configure(types.AnalyzerSettings{CohortQuantile: 0.25})
+	analysis, _ = analyzer.Analyze("harsh", 5)
+	if analysis.HighScoreSessions != 2 || analysis.LowScoreSessions != 2 {
+		t.Errorf("expected the top and bottom 2 sessions, got %d and %d", analysis.HighScoreSessions, analysis.LowScoreSessions)
+	}
+	if math.Abs(analysis.AvgScoreHigh-0.425) > 1e-9 || math.Abs(analysis.AvgScoreLow-0.125) > 1e-9 {
+		t.Errorf("unexpected cohort averages %.3f and %.3f", analysis.AvgScoreHigh, analysis.AvgScoreLow)
+	}
+	if analysis.Settings.CohortQuantile != 0.25 || analysis.Settings.HighThreshold != 0 {
+		t.Errorf("expected the analysis to report quantile cohorts, got %+v", analysis.Settings)
+	}
+}
+
+func TestAnalyzer_RecencyWeighting(t *testing.T) {
+	store := newMockStore()
+	old := createTestSession("old", "research", 0.8)
+	old.StartedAt = time.Now().Add(-20 * 24 * time.Hour)
+	recent := createTestSession("recent", "research", 1.0)
+	store.CreateSession(old)
+	store.CreateSession(recent)
+
+	analyzer := NewAnalyzer(store)
+	analysis, _ := analyzer.Analyze("research", 2)
+	if math.Abs(analysis.AvgScoreHigh-0.9) > 1e-9 {
+		t.Errorf("expected an unweighted average of 0.9, got %f", analysis.AvgScoreHigh)
+	}
+
+	// Two half-lives old counts a quarter as much
+	analyzer.Configure(types.AnalyzerSettings{RecencyHalfLifeDays: 10})
+	analysis, _ = analyzer.Analyze("research", 2)
+	want := (0.8*0.25 + 1.0) / 1.25
+	if math.Abs(analysis.AvgScoreHigh-want) > 1e-3 {
+		t.Errorf("expected a weighted average of %.3f, got %f", want, analysis.AvgScoreHigh)
+	}
+}
+
+func TestAnalyzer_Detectors(t *testing.T) {
+	store := newMockStore()
+	for i := 0; i < 5; i++ {
+		store.CreateSession(sequenceSession(fmt.Sprintf("h%d", i), 0.9,
+			"Grep", "Read", "Edit", "Bash:go test ./..."))
+		store.CreateSession(sequenceSession(fmt.Sprintf("l%d", i), 0.2,
+			"Read", "Write", "Write", "Write"))
+	}
+
+	analyzer := NewAnalyzer(store)
+	analyzer.Configure(types.AnalyzerSettings{DisabledDetectors: []string{DetectorSequences, featureStepCount}})
+	analysis, err := analyzer.Analyze("refactor", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if analysis.Sequences != nil {
+		t.Errorf("expected sequence mining to be skipped, got %+v", analysis.Sequences)
+	}
+	if len(analysis.Evidence) != len(features)-1 {
+		t.Errorf("expected evidence for every feature but step count, got %d", len(analysis.Evidence))
+	}
+	for _, e := range analysis.Evidence {
+		if e.Feature == featureStepCount {
+			t.Errorf("expected step count to be skipped")
+		}
+	}
+
+	analyzer = NewAnalyzer(store)
+	analyzer.Configure(types.AnalyzerSettings{Detectors: []string{DetectorSequences}})
+	analysis, _ = analyzer.Analyze("refactor", 5)
+	if analysis.Sequences == nil || len(analysis.Evidence) != 0 {
+		t.Errorf("expected only sequence mining to run, got %d evidence", len(analysis.Evidence))
+	}
+	if len(analysis.Settings.Detectors) != 1 {
+		t.Errorf("expected the analysis to report its detectors, got %+v", analysis.Settings)
+	}
+}
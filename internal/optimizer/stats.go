@@ -0,0 +1,360 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+// DefaultSignificance is the p-value below which a feature's link to score
+// is treated as real.
+const DefaultSignificance = 0.05
+
+// bootstrapResamples is the number of resamples used for confidence intervals.
+const bootstrapResamples = 1000
+
+// Trajectory features measured against score. Each pattern and anti-pattern
+// is only reported when its feature's evidence is significant. The names
+// double as pattern detector names in AnalyzerSettings.
+const (
+	featureReadsBeforeWrite = "reads_before_write"
+	featureRevisions        = "revisions"
+	featureToolDiversity    = "tool_diversity"
+	featureStepCount        = "step_count"
+	featureSelfCritique     = "self_critique"
+	featureCheckpoints      = "checkpoints"
+	featureErrorRecovery    = "error_recovery"
+	featureFailureStreak    = "failure_streak"
+	featureStepDuration     = "step_duration"
+	featureIncompleteSteps  = "incomplete_steps"
+)
+
+// feature is a per-session measurement and the direction in which it is
+// expected to go with higher scores.
+type feature struct {
+	name        string
+	description string
+	direction   float64 // +1 if higher values should mean higher scores, -1 if lower
+	// value measures the feature in one session. ok is false when it
+	// doesn't apply, e.g. error recovery in a session without errors.
+	value func(a *Analyzer, s *types.Session) (v float64, ok bool)
+}
+
+var features = []feature{
+	{featureReadsBeforeWrite, "Reads before the first write", 1, func(a *Analyzer, s *types.Session) (float64, bool) {
+		stats := a.analyzeReadBeforeWrite([]*types.Session{s})
+		return stats.avgReads, true
+	}},
+	{featureRevisions, "Revised a written file", 1, func(a *Analyzer, s *types.Session) (float64, bool) {
+		return a.analyzeRevisions([]*types.Session{s}).ratio, true
+	}},
+	{featureToolDiversity, "Distinct tools used", 1, func(a *Analyzer, s *types.Session) (float64, bool) {
+		return a.analyzeToolDiversity([]*types.Session{s}).avgDistinct, true
+	}},
+	{featureStepCount, "Number of steps", 1, func(a *Analyzer, s *types.Session) (float64, bool) {
+		return float64(len(s.Steps)), true
+	}},
+	{featureSelfCritique, "Re-read own output", 1, func(a *Analyzer, s *types.Session) (float64, bool) {
+		return a.analyzeSelfCritique([]*types.Session{s}).ratio, true
+	}},
+	{featureCheckpoints, "Worked in checkpoints", 1, func(a *Analyzer, s *types.Session) (float64, bool) {
+		return a.analyzeCheckpoints([]*types.Session{s}).ratio, true
+	}},
+	{featureErrorRecovery, "Error recovery rate", 1, func(a *Analyzer, s *types.Session) (float64, bool) {
+		stats := a.analyzeFailures([]*types.Session{s})
+		return stats.recoveryRate, stats.failures > 0
+	}},
+	{featureFailureStreak, "Longest run of failed steps", -1, func(a *Analyzer, s *types.Session) (float64, bool) {
+		return a.analyzeFailures([]*types.Session{s}).avgMaxStreak, true
+	}},
+	{featureStepDuration, "Average step duration", -1, func(a *Analyzer, s *types.Session) (float64, bool) {
+		stats := a.analyzeTiming([]*types.Session{s})
+		return stats.avgStepMs, stats.avgStepMs > 0
+	}},
+	{featureIncompleteSteps, "Interrupted or denied calls", -1, func(a *Analyzer, s *types.Session) (float64, bool) {
+		return a.analyzeTiming([]*types.Session{s}).avgIncomplete, true
+	}},
+}
+
+// measureEvidence correlates every feature with score across sessions.
+func (a *Analyzer) measureEvidence(sessions []*types.Session, dimension string) []types.PatternEvidence {
+	alpha := a.Significance
+	if alpha <= 0 {
+		alpha = DefaultSignificance
+	}
+
+	evidence := make([]types.PatternEvidence, 0, len(features))
+	for _, f := range features {
+		if !a.detects(f.name) {
+			continue
+		}
+		var values, scores []float64
+		for _, s := range sessions {
+			if v, ok := f.value(a, s); ok {
+				values = append(values, v)
+				scores = append(scores, sessionScore(s, dimension))
+			}
+		}
+
+		e := types.PatternEvidence{
+			Feature:     f.name,
+			Description: f.description,
+			Sessions:    len(values),
+			PValue:      1,
+		}
+		if rho, ok := spearman(values, scores); ok {
+			e.Effect = rho
+			e.PValue = correlationPValue(rho, len(values), f.direction)
+			e.CILow, e.CIHigh = bootstrapCI(values, scores)
+		}
+		evidence = append(evidence, e)
+	}
+
+	// Every feature is tested at once, so significance is judged on p-values
+	// adjusted across all of them
+	pvalues := make([]float64, len(evidence))
+	for i, e := range evidence {
+		pvalues[i] = e.PValue
+	}
+	for i, adjusted := range adjustPValues(pvalues) {
+		evidence[i].AdjustedPValue = adjusted
+		evidence[i].Significant = adjusted < alpha
+	}
+	return evidence
+}
+
+// significant reports whether a feature's evidence is significant.
+func significant(evidence []types.PatternEvidence, name string) bool {
+	for _, e := range evidence {
+		if e.Feature == name {
+			return e.Significant
+		}
+	}
+	return false
+}
+
+// spearman returns the Spearman rank correlation of x and y. ok is false
+// when there are too few values or either side is constant.
+func spearman(x, y []float64) (rho float64, ok bool) {
+	return pearson(ranks(x), ranks(y))
+}
+
+// pearson returns the Pearson correlation of x and y.
+func pearson(x, y []float64) (r float64, ok bool) {
+	n := len(x)
+	if n < 3 || n != len(y) {
+		return 0, false
+	}
+
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	var sxy, sxx, syy float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, false
+	}
+	return sxy / math.Sqrt(sxx*syy), true
+}
+
+// ranks returns the 1-based ranks of values, averaging ties.
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return values[order[i]] < values[order[j]]
+	})
+
+	r := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		avg := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			r[order[k]] = avg
+		}
+		i = j + 1
+	}
+	return r
+}
+
+// adjustPValues applies the Benjamini-Hochberg procedure to a family of
+// p-values, returning adjusted p-values that control the false discovery
+// rate when compared against the significance level.
+func adjustPValues(pvalues []float64) []float64 {
+	order := make([]int, len(pvalues))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return pvalues[order[i]] < pvalues[order[j]]
+	})
+
+	// Walk down from the largest so each adjusted value is the smallest
+	// bound among the p-values ranked at or above it
+	adjusted := make([]float64, len(pvalues))
+	bound := 1.0
+	for rank := len(order); rank >= 1; rank-- {
+		i := order[rank-1]
+		bound = math.Min(bound, pvalues[i]*float64(len(pvalues))/float64(rank))
+		adjusted[i] = bound
+	}
+	return adjusted
+}
+
+// correlationPValue returns the one-sided p-value of a correlation r over n
+// pairs against no correlation, using the t-distribution with n-2 degrees
+// of freedom. direction is +1 to test for a positive correlation and -1
+// for a negative one.
+func correlationPValue(r float64, n int, direction float64) float64 {
+	if n < 3 {
+		return 1
+	}
+	r *= direction
+	if r >= 1 {
+		return 0
+	}
+	if r <= -1 {
+		return 1
+	}
+
+	df := float64(n - 2)
+	t := r * math.Sqrt(df/(1-r*r))
+	tail := 0.5 * regularizedIncompleteBeta(df/(df+t*t), df/2, 0.5)
+	if t < 0 {
+		return 1 - tail
+	}
+	return tail
+}
+
+// bootstrapCI returns a 95% percentile bootstrap confidence interval for the
+// Spearman correlation of x and y. Resamples where either side is constant
+// are skipped. The seed is fixed so analyses are reproducible.
+func bootstrapCI(x, y []float64) (low, high float64) {
+	rng := rand.New(rand.NewSource(1))
+	n := len(x)
+	sx, sy := make([]float64, n), make([]float64, n)
+
+	var samples []float64
+	for i := 0; i < bootstrapResamples; i++ {
+		for j := 0; j < n; j++ {
+			k := rng.Intn(n)
+			sx[j], sy[j] = x[k], y[k]
+		}
+		if rho, ok := spearman(sx, sy); ok {
+			samples = append(samples, rho)
+		}
+	}
+	if len(samples) == 0 {
+		return -1, 1
+	}
+
+	sort.Float64s(samples)
+	return percentile(samples, 0.025), percentile(samples, 0.975)
+}
+
+// percentile returns the p-th percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	i := int(math.Round(p * float64(len(sorted)-1)))
+	return sorted[i]
+}
+
+// regularizedIncompleteBeta computes I_x(a, b) with the continued fraction
+// from Numerical Recipes.
+func regularizedIncompleteBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	lgab, _ := math.Lgamma(a + b)
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+
+	// The continued fraction converges quickly only below this point
+	if x > (a+1)/(a+b+2) {
+		return 1 - front*betaContinuedFraction(1-x, b, a)/b
+	}
+	return front * betaContinuedFraction(x, a, b) / a
+}
+
+// betaContinuedFraction evaluates the continued fraction for the incomplete
+// beta function with the modified Lentz method.
+func betaContinuedFraction(x, a, b float64) float64 {
+	const (
+		maxIterations = 200
+		epsilon       = 1e-14
+		tiny          = 1e-300
+	)
+
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+
+		// Even step
+		num := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+
+		// Odd step
+		num = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
+
+// formatPValue formats a p-value for reports, flooring tiny values.
+func formatPValue(p float64) string {
+	if p < 0.001 {
+		return "<0.001"
+	}
+	return fmt.Sprintf("%.3f", p)
+}
@@ -0,0 +1,148 @@
+package optimizer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+func TestRanks(t *testing.T) {
+	got := ranks([]float64{3, 1, 2, 1})
+	want := []float64{4, 1.5, 3, 1.5}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestSpearman(t *testing.T) {
+	rho, ok := spearman([]float64{1, 2, 3, 4}, []float64{10, 20, 30, 100})
+	if !ok || math.Abs(rho-1) > 1e-12 {
+		t.Errorf("expected perfect rank correlation, got %f, %v", rho, ok)
+	}
+	if _, ok := spearman([]float64{1, 1, 1}, []float64{1, 2, 3}); ok {
+		t.Error("expected constant values to have no correlation")
+	}
+	if _, ok := spearman([]float64{1, 2}, []float64{1, 2}); ok {
+		t.Error("expected two values to be too few")
+	}
+}
+
+func TestAdjustPValues(t *testing.T) {
+	got := adjustPValues([]float64{0.01, 0.04, 0.03, 0.005})
+	want := []float64{0.02, 0.04, 0.04, 0.02}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-12 {
+			t.Errorf("adjustPValues = %v, want %v", got, want)
+			break
+		}
+	}
+	if got := adjustPValues(nil); len(got) != 0 {
+		t.Errorf("expected no adjusted p-values, got %v", got)
+	}
+}
+
+func TestCorrelationPValue(t *testing.T) {
+	tests := []struct {
+		r         float64
+		n         int
+		direction float64
+		want      float64
+	}{
+		// t = 1 with 1 degree of freedom is the Cauchy distribution's quartile
+		{1 / math.Sqrt2, 3, 1, 0.25},
+		// t = 2 with 2 degrees of freedom has a closed form
+		{math.Sqrt(2.0 / 3), 4, 1, 0.5 - 1/math.Sqrt(6)},
+		{0.5, 10, 1, 0.0705},
+		{0.5, 10, -1, 1 - 0.0705},
+		{1, 5, 1, 0},
+		{1, 5, -1, 1},
+		{0.9, 2, 1, 1},
+	}
+	for _, tc := range tests {
+		got := correlationPValue(tc.r, tc.n, tc.direction)
+		if math.Abs(got-tc.want) > 5e-4 {
+			t.Errorf("correlationPValue(%.3f, %d, %+.0f) = %.4f, want %.4f", tc.r, tc.n, tc.direction, got, tc.want)
+		}
+	}
+}
+
+func TestBootstrapCI(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	y := []float64{0.1, 0.3, 0.2, 0.4, 0.6, 0.5, 0.7, 0.9, 0.8, 1.0}
+
+	rho, _ := spearman(x, y)
+	low, high := bootstrapCI(x, y)
+	if low > rho || high < rho || low < -1 || high > 1 {
+		t.Errorf("expected interval around %.2f, got [%.2f, %.2f]", rho, low, high)
+	}
+	if low2, high2 := bootstrapCI(x, y); low2 != low || high2 != high {
+		t.Error("expected the interval to be reproducible")
+	}
+}
+
+func TestMeasureEvidence(t *testing.T) {
+	var sessions []*types.Session
+	for i, score := range []float64{0.1, 0.2, 0.3, 0.5, 0.4, 0.6, 0.7, 0.8} {
+		s := createTestSession(string(rune('a'+i)), "research", score)
+		// Reads mostly go up with score; step duration doesn't vary
+		s.Steps = nil
+		for r := 0; r < i; r++ {
+			s.Steps = append(s.Steps, types.TrajectoryStep{ToolName: "Read", DurationMs: 100})
+		}
+		s.Steps = append(s.Steps, types.TrajectoryStep{ToolName: "Write", DurationMs: 100})
+		sessions = append(sessions, s)
+	}
+
+	analyzer := NewAnalyzer(newMockStore())
+	evidence := analyzer.measureEvidence(sessions, "")
+	if len(evidence) != len(features) {
+		t.Fatalf("expected evidence for every feature, got %d", len(evidence))
+	}
+
+	byName := make(map[string]types.PatternEvidence)
+	for _, e := range evidence {
+		byName[e.Feature] = e
+	}
+	reads := byName[featureReadsBeforeWrite]
+	if !reads.Significant || reads.Effect < 0.9 || reads.Sessions != 8 || reads.PValue > 0.001 || reads.AdjustedPValue < reads.PValue {
+		t.Errorf("expected strong evidence for reads, got %+v", reads)
+	}
+	if duration := byName[featureStepDuration]; duration.Significant || duration.PValue != 1 {
+		t.Errorf("expected no evidence for a constant feature, got %+v", duration)
+	}
+	// Sessions without errors can't show recovery
+	if recovery := byName[featureErrorRecovery]; recovery.Sessions != 0 || recovery.Significant {
+		t.Errorf("expected error recovery to be unmeasured, got %+v", recovery)
+	}
+
+	// A stricter significance level can suppress the same evidence
+	analyzer.Significance = reads.AdjustedPValue / 2
+	if significant(analyzer.measureEvidence(sessions, ""), featureReadsBeforeWrite) {
+		t.Errorf("expected reads to miss a %g significance level", analyzer.Significance)
+	}
+}
+
+func TestGenerateMetaPrompt_Evidence(t *testing.T) {
+	analysis := &types.TrajectoryAnalysis{
+		Tag: "research",
+		Evidence: []types.PatternEvidence{
+			{Feature: featureReadsBeforeWrite, Description: "Reads before the first write", Sessions: 12,
+				Effect: 0.64, CILow: 0.21, CIHigh: 0.88, PValue: 0.0004, AdjustedPValue: 0.004, Significant: true},
+			{Feature: featureStepCount, Description: "Number of steps", Sessions: 12, Effect: 0.1, CILow: -0.5, CIHigh: 0.6, PValue: 0.38, AdjustedPValue: 0.6},
+		},
+	}
+
+	prompt := (&Optimizer{}).generateMetaPrompt(types.OptimizationTarget{Tag: "research"}, analysis)
+	for _, want := range []string{
+		"### Statistical Evidence",
+		"| Reads before the first write | 12 | +0.64 | [+0.21, +0.88] | <0.001 | 0.004 | yes |",
+		"| Number of steps | 12 | +0.10 | [-0.50, +0.60] | 0.380 | 0.600 | no |",
+	} {
+		if !containsString(prompt, want) {
+			t.Errorf("expected prompt to contain %q", want)
+		}
+	}
+}
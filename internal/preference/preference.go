@@ -0,0 +1,158 @@
+// Package preference turns pairwise comparisons between sessions into
+// calibrated scores with a Bradley-Terry fit, so reviewers can say which of
+// two sessions was better instead of grading each on an absolute scale.
+package preference
+
+import (
+	"math"
+	"sort"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+// Fit settings. Every session plays one virtual win and one virtual loss
+// against a typical session of strength 1, which keeps sessions that never
+// lost (or never won) at a finite strength and anchors the scale.
+const (
+	priorGames    = 2.0
+	maxIterations = 1000
+	tolerance     = 1e-9
+)
+
+// Rating is a session's fitted strength.
+type Rating struct {
+	SessionID string  `json:"session_id"`
+	Strength  float64 `json:"strength"` // Bradley-Terry strength; a typical session is 1
+	Score     float64 `json:"score"`    // probability of beating a typical session, 0.0 to 1.0
+	Wins      int     `json:"wins"`
+	Losses    int     `json:"losses"`
+	Ties      int     `json:"ties"`
+}
+
+// Comparisons returns how many times the session has been compared.
+func (r Rating) Comparisons() int {
+	return r.Wins + r.Losses + r.Ties
+}
+
+// Ratings maps session IDs to their ratings.
+type Ratings map[string]Rating
+
+// Sorted returns the ratings from highest to lowest score.
+func (rs Ratings) Sorted() []Rating {
+	sorted := make([]Rating, 0, len(rs))
+	for _, r := range rs {
+		sorted = append(sorted, r)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Score != sorted[j].Score {
+			return sorted[i].Score > sorted[j].Score
+		}
+		return sorted[i].SessionID < sorted[j].SessionID
+	})
+	return sorted
+}
+
+// Fit estimates a rating for every session that appears in comparisons.
+// A tie counts as half a win for each session.
+func Fit(comparisons []types.Comparison) Ratings {
+	ratings := make(Ratings)
+	wins := make(map[string]float64)
+	games := make(map[string]map[string]float64)
+
+	addGame := func(a, b string) {
+		if games[a] == nil {
+			games[a] = make(map[string]float64)
+		}
+		games[a][b]++
+	}
+	for _, c := range comparisons {
+		if c.Winner == "" || c.Loser == "" || c.Winner == c.Loser {
+			continue
+		}
+		winner, loser := ratings[c.Winner], ratings[c.Loser]
+		winner.SessionID, loser.SessionID = c.Winner, c.Loser
+		if c.Tie {
+			winner.Ties++
+			loser.Ties++
+			wins[c.Winner] += 0.5
+			wins[c.Loser] += 0.5
+		} else {
+			winner.Wins++
+			loser.Losses++
+			wins[c.Winner]++
+		}
+		ratings[c.Winner], ratings[c.Loser] = winner, loser
+		addGame(c.Winner, c.Loser)
+		addGame(c.Loser, c.Winner)
+	}
+
+	// Minorization-maximization updates (Hunter, 2004), with the prior games
+	// played against the fixed anchor
+	strength := make(map[string]float64, len(ratings))
+	for id := range ratings {
+		strength[id] = 1
+	}
+	for iter := 0; iter < maxIterations; iter++ {
+		next := make(map[string]float64, len(strength))
+		maxChange := 0.0
+		for id, p := range strength {
+			denom := priorGames / (p + 1)
+			for other, n := range games[id] {
+				denom += n / (p + strength[other])
+			}
+			next[id] = (wins[id] + priorGames/2) / denom
+			maxChange = math.Max(maxChange, math.Abs(next[id]-p)/p)
+		}
+		strength = next
+		if maxChange < tolerance {
+			break
+		}
+	}
+
+	for id, r := range ratings {
+		r.Strength = strength[id]
+		r.Score = r.Strength / (r.Strength + 1)
+		ratings[id] = r
+	}
+	return ratings
+}
+
+// NextPair picks two sessions to compare next: the least-compared session
+// and the closest-rated session it hasn't been compared with yet, since
+// close matchups say the most about the ranking. ok is false when every pair
+// has already been compared.
+func NextPair(sessionIDs []string, comparisons []types.Comparison) (a, b string, ok bool) {
+	ratings := Fit(comparisons)
+	compared := make(map[[2]string]bool)
+	for _, c := range comparisons {
+		compared[[2]string{c.Winner, c.Loser}] = true
+		compared[[2]string{c.Loser, c.Winner}] = true
+	}
+	score := func(id string) float64 {
+		if r, ok := ratings[id]; ok {
+			return r.Score
+		}
+		return 0.5
+	}
+
+	order := append([]string(nil), sessionIDs...)
+	sort.SliceStable(order, func(i, j int) bool {
+		return ratings[order[i]].Comparisons() < ratings[order[j]].Comparisons()
+	})
+
+	for _, first := range order {
+		best, bestGap := "", math.Inf(1)
+		for _, other := range order {
+			if other == first || compared[[2]string{first, other}] {
+				continue
+			}
+			if gap := math.Abs(score(first) - score(other)); gap < bestGap {
+				best, bestGap = other, gap
+			}
+		}
+		if best != "" {
+			return first, best, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,79 @@
+package preference
+
+import (
+	"math"
+	"testing"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+func beats(winner, loser string) types.Comparison {
+	return types.Comparison{Tag: "backend", Winner: winner, Loser: loser}
+}
+
+func TestFit(t *testing.T) {
+	ratings := Fit([]types.Comparison{
+		beats("a", "b"),
+		beats("a", "c"),
+		beats("b", "c"),
+		beats("a", "b"),
+		beats("c", "b"),
+	})
+
+	sorted := ratings.Sorted()
+	// b and c split their games, but b lost to a more often
+	if len(sorted) != 3 || sorted[0].SessionID != "a" || sorted[1].SessionID != "c" || sorted[2].SessionID != "b" {
+		t.Fatalf("unexpected ranking: %+v", sorted)
+	}
+	a := ratings["a"]
+	if a.Wins != 3 || a.Losses != 0 || a.Comparisons() != 3 {
+		t.Errorf("unexpected record for a: %+v", a)
+	}
+	// The prior keeps an undefeated session short of a certain win
+	if a.Score <= 0.5 || a.Score >= 1 || math.IsInf(a.Strength, 0) {
+		t.Errorf("expected a finite score above 0.5, got %+v", a)
+	}
+	for _, r := range sorted {
+		if math.Abs(r.Score-r.Strength/(r.Strength+1)) > 1e-12 {
+			t.Errorf("score doesn't match strength: %+v", r)
+		}
+	}
+}
+
+func TestFit_Ties(t *testing.T) {
+	ratings := Fit([]types.Comparison{
+		{Winner: "a", Loser: "b", Tie: true},
+		{Winner: "b", Loser: "a", Tie: true},
+		{Winner: "a", Loser: "a"},
+	})
+	if len(ratings) != 2 {
+		t.Fatalf("expected self-comparisons to be skipped, got %+v", ratings)
+	}
+	if math.Abs(ratings["a"].Score-0.5) > 1e-9 || math.Abs(ratings["b"].Score-0.5) > 1e-9 {
+		t.Errorf("expected tied sessions to score 0.5, got %+v", ratings)
+	}
+	if ratings["a"].Ties != 2 || ratings["a"].Wins != 0 {
+		t.Errorf("unexpected record for a: %+v", ratings["a"])
+	}
+}
+
+func TestNextPair(t *testing.T) {
+	ids := []string{"a", "b", "c"}
+
+	a, b, ok := NextPair(ids, nil)
+	if !ok || a == b {
+		t.Fatalf("expected a pair, got %s, %s, %v", a, b, ok)
+	}
+
+	// c hasn't been compared, so it goes next
+	comparisons := []types.Comparison{beats("a", "b")}
+	a, b, ok = NextPair(ids, comparisons)
+	if !ok || a != "c" {
+		t.Errorf("expected the least-compared session first, got %s, %s", a, b)
+	}
+
+	comparisons = append(comparisons, beats("a", "c"), beats("c", "b"))
+	if a, b, ok = NextPair(ids, comparisons); ok {
+		t.Errorf("expected no pairs left, got %s, %s", a, b)
+	}
+}
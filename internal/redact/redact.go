@@ -0,0 +1,183 @@
+// Package redact removes secrets and personal data from captured tool activity.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+// Rule is a named pattern whose matches are replaced before storage.
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// Built-in detector names
+const (
+	RuleAWSAccessKey = "aws_access_key"
+	RuleAWSSecretKey = "aws_secret_key"
+	RuleGitHubToken  = "github_token"
+	RuleJWT          = "jwt"
+	RulePrivateKey   = "private_key"
+	RuleEmail        = "email"
+)
+
+// BuiltinRules returns the default set of secret and PII detectors.
+func BuiltinRules() []Rule {
+	return []Rule{
+		// Private keys first so their bodies aren't partially matched by other rules
+		{Name: RulePrivateKey, Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`)},
+		{Name: RuleAWSAccessKey, Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{Name: RuleAWSSecretKey, Pattern: regexp.MustCompile(`(?i)aws_secret_access_key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}`)},
+		{Name: RuleGitHubToken, Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+		{Name: RuleJWT, Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`)},
+		{Name: RuleEmail, Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	}
+}
+
+// Config configures the redaction pipeline.
+// It is read from redaction.json in the data directory.
+type Config struct {
+	// DisableBuiltin lists built-in detectors to turn off (e.g. "email").
+	DisableBuiltin []string `json:"disable_builtin,omitempty"`
+	// Rules are additional user-defined patterns.
+	Rules []RuleConfig `json:"rules,omitempty"`
+}
+
+// RuleConfig is a user-defined redaction rule.
+type RuleConfig struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// Redactor applies a set of rules to text.
+type Redactor struct {
+	rules []Rule
+}
+
+// New creates a Redactor from explicit rules.
+func New(rules []Rule) *Redactor {
+	return &Redactor{rules: rules}
+}
+
+// NewDefault creates a Redactor with only the built-in detectors.
+func NewDefault() *Redactor {
+	return New(BuiltinRules())
+}
+
+// FromConfig creates a Redactor from built-in detectors plus user rules.
+func FromConfig(cfg *Config) (*Redactor, error) {
+	disabled := make(map[string]bool)
+	for _, name := range cfg.DisableBuiltin {
+		disabled[name] = true
+	}
+
+	var rules []Rule
+	for _, rule := range BuiltinRules() {
+		if !disabled[rule.Name] {
+			rules = append(rules, rule)
+		}
+	}
+
+	for i, rc := range cfg.Rules {
+		if rc.Pattern == "" {
+			return nil, fmt.Errorf("rule %d: pattern is required", i+1)
+		}
+		pattern, err := regexp.Compile(rc.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): invalid pattern: %w", i+1, rc.Name, err)
+		}
+		name := rc.Name
+		if name == "" {
+			name = fmt.Sprintf("custom_%d", i+1)
+		}
+		rules = append(rules, Rule{Name: name, Pattern: pattern})
+	}
+
+	return New(rules), nil
+}
+
+// LoadConfig reads a redaction config file.
+// A missing file yields an empty config (built-in detectors only).
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("failed to read redaction config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse redaction config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// Load reads a redaction config file and builds a Redactor from it.
+func Load(path string) (*Redactor, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromConfig(cfg)
+}
+
+// Rules returns the rule names in the order they are applied.
+func (r *Redactor) Rules() []string {
+	if r == nil {
+		return nil
+	}
+	names := make([]string, len(r.rules))
+	for i, rule := range r.rules {
+		names[i] = rule.Name
+	}
+	return names
+}
+
+// Redact replaces every rule match in s and returns the number of replacements.
+// A nil Redactor returns s unchanged.
+func (r *Redactor) Redact(s string) (string, int) {
+	if r == nil || s == "" {
+		return s, 0
+	}
+
+	count := 0
+	for _, rule := range r.rules {
+		replacement := "[REDACTED:" + rule.Name + "]"
+		s = rule.Pattern.ReplaceAllStringFunc(s, func(string) string {
+			count++
+			return replacement
+		})
+	}
+	return s, count
+}
+
+// RedactSession redacts a session's prompt, summary and step summaries in place.
+// Each step's RedactionCount is incremented by the replacements made in it.
+// Returns the total number of replacements.
+func (r *Redactor) RedactSession(s *types.Session) int {
+	total := 0
+
+	var n int
+	s.TaskPrompt, n = r.Redact(s.TaskPrompt)
+	total += n
+	s.Summary, n = r.Redact(s.Summary)
+	total += n
+
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		var in, out int
+		step.InputSummary, in = r.Redact(step.InputSummary)
+		step.OutputSummary, out = r.Redact(step.OutputSummary)
+		step.RedactionCount += in + out
+		total += in + out
+	}
+
+	return total
+}
@@ -0,0 +1,212 @@
+// Package rubric resolves per-tag scoring rubrics and combines dimension
+// scores into a session's composite score.
+package rubric
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/optimizer"
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+var (
+	// ErrUnknownDimension is returned when a score names a dimension the
+	// session's rubric doesn't define.
+	ErrUnknownDimension = errors.New("unknown rubric dimension")
+	// ErrNoScore is returned when neither a score nor dimension scores are given.
+	ErrNoScore = errors.New("a score or dimension scores are required")
+	// ErrInvalidRubric is returned when a rubric's weights can't be combined
+	// into a composite score.
+	ErrInvalidRubric = errors.New("invalid rubric")
+)
+
+// Config is the format of the rubric config file:
+//
+//	{"rubrics": [{"tag": "backend", "dimensions": [{"name": "correctness", "weight": 0.5}]}]}
+type Config struct {
+	Rubrics []types.Rubric `json:"rubrics"`
+}
+
+// LoadConfig reads a rubric config file.
+// A missing file yields an empty config.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("failed to read rubric config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse rubric config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// Set maps tags to their rubrics.
+type Set map[string]types.Rubric
+
+// Load reads rubrics from a config file and from trajectory-rubric marker
+// blocks in markdown files. Marker blocks override the config for the same
+// tag, and later files override earlier ones. Missing files are skipped.
+func Load(configPath string, markdownPaths ...string) (Set, error) {
+	set := make(Set)
+
+	if configPath != "" {
+		cfg, err := LoadConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range cfg.Rubrics {
+			if err := validate(r); err != nil {
+				return nil, fmt.Errorf("%s: %w", configPath, err)
+			}
+			set[r.Tag] = r
+		}
+	}
+
+	parser := optimizer.NewParser()
+	for _, path := range markdownPaths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		rubrics, err := parser.FindRubrics(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rubrics from %s: %w", path, err)
+		}
+		for _, r := range rubrics {
+			if err := validate(r); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			set[r.Tag] = r
+		}
+	}
+
+	return set, nil
+}
+
+// validate checks that every weight is a non-negative number and that they
+// don't all sum to zero.
+func validate(r types.Rubric) error {
+	var total float64
+	for _, d := range r.Dimensions {
+		if math.IsNaN(d.Weight) || math.IsInf(d.Weight, 0) || d.Weight < 0 {
+			return fmt.Errorf("%w for tag %q: dimension %q has weight %v, must be a non-negative number",
+				ErrInvalidRubric, r.Tag, d.Name, d.Weight)
+		}
+		total += d.Weight
+	}
+	if total == 0 {
+		return fmt.Errorf("%w for tag %q: dimension weights must not all be zero", ErrInvalidRubric, r.Tag)
+	}
+	return nil
+}
+
+// ForTags returns the rubric for the first tag that has one.
+func (s Set) ForTags(tags []string) (types.Rubric, bool) {
+	for _, tag := range tags {
+		if r, ok := s[tag]; ok {
+			return r, true
+		}
+	}
+	return types.Rubric{}, false
+}
+
+// Composite returns the weighted average of the dimension scores. Weights
+// are renormalized over the dimensions that were scored, so a partially
+// scored rubric still yields a 0-1 composite. A nil rubric weights every
+// dimension equally.
+func Composite(r *types.Rubric, scores map[string]float64) (float64, error) {
+	if len(scores) == 0 {
+		return 0, ErrNoScore
+	}
+
+	weights := make(map[string]float64, len(scores))
+	if r == nil {
+		for name := range scores {
+			weights[name] = 1
+		}
+	} else {
+		for _, d := range r.Dimensions {
+			weights[d.Name] = d.Weight
+		}
+	}
+
+	var sum, total float64
+	for _, name := range sortedNames(scores) {
+		weight, ok := weights[name]
+		if !ok {
+			return 0, fmt.Errorf("%w %q for tag %q (expected %v)", ErrUnknownDimension, name, r.Tag, dimensionNames(r))
+		}
+		sum += weight * scores[name]
+		total += weight
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("scored dimensions have no weight")
+	}
+	return sum / total, nil
+}
+
+// NewOutcome builds an outcome from an explicit score, dimension scores or
+// both. Without an explicit score, the composite of the dimension scores is
+// used, weighted by the rubric for the session's tags.
+func (s Set) NewOutcome(tags []string, score *float64, dimensions map[string]float64, notes string) (types.Outcome, error) {
+	if score == nil && len(dimensions) == 0 {
+		return types.Outcome{}, ErrNoScore
+	}
+	if score != nil && (*score < 0 || *score > 1) {
+		return types.Outcome{}, fmt.Errorf("score must be between 0.0 and 1.0")
+	}
+	for name, v := range dimensions {
+		if v < 0 || v > 1 {
+			return types.Outcome{}, fmt.Errorf("%s score must be between 0.0 and 1.0", name)
+		}
+	}
+
+	var r *types.Rubric
+	if rubric, ok := s.ForTags(tags); ok {
+		r = &rubric
+	}
+
+	outcome := types.Outcome{
+		Notes:    notes,
+		ScoredAt: time.Now(),
+	}
+	if len(dimensions) > 0 {
+		composite, err := Composite(r, dimensions)
+		if err != nil {
+			return types.Outcome{}, err
+		}
+		outcome.Score = composite
+		outcome.Dimensions = dimensions
+	}
+	if score != nil {
+		outcome.Score = *score
+	}
+	return outcome, nil
+}
+
+func dimensionNames(r *types.Rubric) []string {
+	names := make([]string, len(r.Dimensions))
+	for i, d := range r.Dimensions {
+		names[i] = d.Name
+	}
+	return names
+}
+
+func sortedNames(scores map[string]float64) []string {
+	names := make([]string, 0, len(scores))
+	for name := range scores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
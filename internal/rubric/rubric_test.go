@@ -0,0 +1,149 @@
+package rubric
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	configPath := writeFile(t, "rubrics.json", `{"rubrics": [
+		{"tag": "backend", "dimensions": [{"name": "correctness", "weight": 1}]},
+		{"tag": "docs", "dimensions": [{"name": "clarity", "weight": 1}]}
+	]}`)
+	markdownPath := writeFile(t, "CLAUDE.md", `<!-- trajectory-rubric:backend -->
+- correctness: 0.6
+- efficiency: 0.4
+<!-- /trajectory-rubric:backend -->
+`)
+
+	set, err := Load(configPath, markdownPath, filepath.Join(t.TempDir(), "missing.md"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(set["backend"].Dimensions) != 2 {
+		t.Errorf("expected marker block to override config, got %+v", set["backend"])
+	}
+	if len(set["docs"].Dimensions) != 1 {
+		t.Errorf("expected config rubric for docs, got %+v", set["docs"])
+	}
+
+	r, ok := set.ForTags([]string{"frontend", "docs"})
+	if !ok || r.Tag != "docs" {
+		t.Errorf("expected docs rubric for tags, got %+v", r)
+	}
+
+	set, err = Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || len(set) != 0 {
+		t.Errorf("expected empty set for missing config, got %v, %v", set, err)
+	}
+}
+
+func TestLoad_InvalidWeights(t *testing.T) {
+	for name, dimensions := range map[string]string{
+		"negative": `[{"name": "correctness", "weight": 1}, {"name": "speed", "weight": -0.5}]`,
+		"all zero": `[{"name": "correctness", "weight": 0}, {"name": "speed", "weight": 0}]`,
+		"empty":    `[]`,
+	} {
+		configPath := writeFile(t, "rubrics.json", `{"rubrics": [{"tag": "backend", "dimensions": `+dimensions+`}]}`)
+		if _, err := Load(configPath); !errors.Is(err, ErrInvalidRubric) {
+			t.Errorf("%s: expected ErrInvalidRubric, got %v", name, err)
+		}
+	}
+
+	markdownPath := writeFile(t, "CLAUDE.md", `<!-- trajectory-rubric:backend -->
+- correctness: NaN
+<!-- /trajectory-rubric:backend -->
+`)
+	if _, err := Load("", markdownPath); err == nil {
+		t.Error("expected a NaN weight to be rejected")
+	}
+}
+
+func TestComposite(t *testing.T) {
+	r := &types.Rubric{
+		Tag: "backend",
+		Dimensions: []types.RubricDimension{
+			{Name: "correctness", Weight: 0.6},
+			{Name: "efficiency", Weight: 0.2},
+			{Name: "style", Weight: 0.2},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		rubric *types.Rubric
+		scores map[string]float64
+		want   float64
+	}{
+		{"weighted", r, map[string]float64{"correctness": 1, "efficiency": 0.5, "style": 0}, 0.7},
+		{"partial", r, map[string]float64{"correctness": 1, "efficiency": 0}, 0.75},
+		{"no rubric", nil, map[string]float64{"speed": 1, "care": 0.5}, 0.75},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Composite(tc.rubric, tc.scores)
+			if err != nil {
+				t.Fatalf("Composite failed: %v", err)
+			}
+			if math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("expected %.2f, got %.2f", tc.want, got)
+			}
+		})
+	}
+
+	if _, err := Composite(r, map[string]float64{"speed": 1}); !errors.Is(err, ErrUnknownDimension) {
+		t.Errorf("expected unknown dimension error, got: %v", err)
+	}
+}
+
+func TestNewOutcome(t *testing.T) {
+	set := Set{"backend": {
+		Tag: "backend",
+		Dimensions: []types.RubricDimension{
+			{Name: "correctness", Weight: 3},
+			{Name: "efficiency", Weight: 1},
+		},
+	}}
+	dims := map[string]float64{"correctness": 1, "efficiency": 0}
+
+	outcome, err := set.NewOutcome([]string{"backend"}, nil, dims, "slow")
+	if err != nil {
+		t.Fatalf("NewOutcome failed: %v", err)
+	}
+	if outcome.Score != 0.75 || outcome.Dimensions["correctness"] != 1 || outcome.Notes != "slow" {
+		t.Errorf("unexpected outcome: %+v", outcome)
+	}
+
+	score := 0.5
+	outcome, err = set.NewOutcome([]string{"backend"}, &score, dims, "")
+	if err != nil {
+		t.Fatalf("NewOutcome failed: %v", err)
+	}
+	if outcome.Score != 0.5 || len(outcome.Dimensions) != 2 {
+		t.Errorf("expected explicit score to override composite, got %+v", outcome)
+	}
+
+	if _, err := set.NewOutcome(nil, nil, nil, ""); !errors.Is(err, ErrNoScore) {
+		t.Errorf("expected no score error, got: %v", err)
+	}
+	if _, err := set.NewOutcome(nil, nil, map[string]float64{"correctness": 1.5}, ""); err == nil {
+		t.Error("expected error for dimension score > 1")
+	}
+	if _, err := set.NewOutcome([]string{"backend"}, nil, map[string]float64{"style": 1}, ""); !errors.Is(err, ErrUnknownDimension) {
+		t.Errorf("expected unknown dimension error, got: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// ErrInvalidComparison is returned when a comparison doesn't name two different sessions.
+	ErrInvalidComparison = errors.New("comparison must name two different sessions")
+)
+
+// bucketComparisons holds pairwise comparisons keyed by ID.
+var bucketComparisons = []byte("comparisons")
+
+// RecordComparison stores a pairwise comparison between two sessions.
+func (s *BoltStore) RecordComparison(c *types.Comparison) error {
+	if c.Winner == "" || c.Loser == "" || c.Winner == c.Loser {
+		return ErrInvalidComparison
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketComparisons)
+
+		if c.ID == "" {
+			c.ID = NewULID()
+		}
+		if c.CreatedAt.IsZero() {
+			c.CreatedAt = time.Now()
+		}
+
+		data, err := json.Marshal(c)
+		if err != nil {
+			return fmt.Errorf("failed to marshal comparison: %w", err)
+		}
+
+		return b.Put([]byte(c.ID), data)
+	})
+}
+
+// ListComparisons lists comparisons oldest first, optionally filtered by tag.
+func (s *BoltStore) ListComparisons(tag string) ([]types.Comparison, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []types.Comparison
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketComparisons).ForEach(func(k, v []byte) error {
+			var c types.Comparison
+			if err := json.Unmarshal(v, &c); err != nil {
+				return nil
+			}
+			if tag != "" && c.Tag != tag {
+				return nil
+			}
+			results = append(results, c)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// deleteComparisons removes every comparison involving a session, so the
+// preference fit doesn't rate sessions that no longer exist.
+func deleteComparisons(tx *bolt.Tx, sessionID string) error {
+	b := tx.Bucket(bucketComparisons)
+	var keys [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		var c types.Comparison
+		if err := json.Unmarshal(v, &c); err != nil {
+			return nil
+		}
+		if c.Winner == sessionID || c.Loser == sessionID {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
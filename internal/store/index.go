@@ -0,0 +1,308 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Secondary index buckets. Keys are <value><sep><session ID> with empty
+// values, so a prefix or range scan yields matching session IDs in order.
+var (
+	bucketIdxTag     = []byte("idx_tag")
+	bucketIdxStatus  = []byte("idx_status")
+	bucketIdxScore   = []byte("idx_score")
+	bucketIdxStarted = []byte("idx_started")
+)
+
+// indexSep separates the indexed value from the session ID in index keys.
+const indexSep = 0x00
+
+// Query selects sessions using the secondary indexes. Zero-valued fields
+// don't filter. Results are ordered by StartedAt, most recent first.
+type Query struct {
+	// Tags requires sessions to have every listed tag (exact match).
+	Tags []string
+	// Status requires an exact status match.
+	Status types.SessionStatus
+	// MinScore and MaxScore bound the outcome score (inclusive).
+	// Setting either excludes unscored sessions.
+	MinScore *float64
+	MaxScore *float64
+	// Since and Until bound StartedAt (Since inclusive, Until exclusive).
+	Since time.Time
+	Until time.Time
+	// Limit caps the number of results (0 = no limit).
+	Limit  int
+	Offset int
+}
+
+// QuerySessions returns metadata for sessions matching the query.
+func (s *BoltStore) QuerySessions(q Query) ([]types.SessionMetadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []types.SessionMetadata
+	err := s.db.View(func(tx *bolt.Tx) error {
+		// Narrow down with the selective indexes first
+		candidates := q.candidates(tx)
+		if candidates != nil && len(candidates) == 0 {
+			return nil
+		}
+
+		// Walk the time index newest first to order the results
+		index := tx.Bucket(bucketIndex)
+		c := tx.Bucket(bucketIdxStarted).Cursor()
+
+		var k []byte
+		if q.Until.IsZero() {
+			k, _ = c.Last()
+		} else {
+			// Seek lands on the first key at or after Until; step back past it
+			k, _ = c.Seek(encodeTime(q.Until))
+			if k == nil {
+				k, _ = c.Last()
+			}
+			for k != nil && bytes.Compare(k[:8], encodeTime(q.Until)) >= 0 {
+				k, _ = c.Prev()
+			}
+		}
+
+		var since []byte
+		if !q.Since.IsZero() {
+			since = encodeTime(q.Since)
+		}
+
+		skipped := 0
+		for ; k != nil; k, _ = c.Prev() {
+			if since != nil && bytes.Compare(k[:8], since) < 0 {
+				break
+			}
+			id := string(k[9:])
+			if candidates != nil && !candidates[id] {
+				continue
+			}
+			if skipped < q.Offset {
+				skipped++
+				continue
+			}
+
+			var meta types.SessionMetadata
+			if err := json.Unmarshal(index.Get([]byte(id)), &meta); err != nil {
+				continue // Skip malformed entries
+			}
+			results = append(results, meta)
+			if q.Limit > 0 && len(results) >= q.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// candidates returns the session IDs matching the query's tag, status and
+// score filters, or nil if it has none. Time bounds are not applied.
+func (q Query) candidates(tx *bolt.Tx) map[string]bool {
+	var candidates map[string]bool
+	for _, tag := range q.Tags {
+		candidates = intersect(candidates, scanPrefix(tx.Bucket(bucketIdxTag), []byte(tag)))
+	}
+	if q.Status != "" {
+		candidates = intersect(candidates, scanPrefix(tx.Bucket(bucketIdxStatus), []byte(q.Status)))
+	}
+	if q.MinScore != nil || q.MaxScore != nil {
+		candidates = intersect(candidates, scanScores(tx.Bucket(bucketIdxScore), q.MinScore, q.MaxScore))
+	}
+	return candidates
+}
+
+// putMetadata writes a session's metadata to the index bucket and brings
+// the secondary indexes in line with it. The step count is taken from the
+// steps bucket, so the session's steps needn't be loaded.
+func putMetadata(tx *bolt.Tx, session *types.Session) error {
+	index := tx.Bucket(bucketIndex)
+	id := []byte(session.ID)
+
+	if old := index.Get(id); old != nil {
+		var oldMeta types.SessionMetadata
+		if err := json.Unmarshal(old, &oldMeta); err == nil {
+			if err := updateIndexes(tx, &oldMeta, (*bolt.Bucket).Delete); err != nil {
+				return err
+			}
+		}
+	}
+
+	meta := session.ToMetadata()
+	meta.StepCount = stepCount(tx, session.ID)
+	metaData, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if err := index.Put(id, metaData); err != nil {
+		return fmt.Errorf("failed to store metadata: %w", err)
+	}
+
+	return updateIndexes(tx, &meta, putEmpty)
+}
+
+// deleteMetadata removes a session's metadata and secondary index entries.
+func deleteMetadata(tx *bolt.Tx, id string) error {
+	index := tx.Bucket(bucketIndex)
+
+	if old := index.Get([]byte(id)); old != nil {
+		var oldMeta types.SessionMetadata
+		if err := json.Unmarshal(old, &oldMeta); err == nil {
+			if err := updateIndexes(tx, &oldMeta, (*bolt.Bucket).Delete); err != nil {
+				return err
+			}
+		}
+	}
+	return index.Delete([]byte(id))
+}
+
+// rebuildIndexes regenerates the secondary indexes from the index bucket.
+func rebuildIndexes(tx *bolt.Tx) error {
+	for _, name := range [][]byte{bucketIdxTag, bucketIdxStatus, bucketIdxScore, bucketIdxStarted} {
+		if tx.Bucket(name) != nil {
+			if err := tx.DeleteBucket(name); err != nil {
+				return fmt.Errorf("failed to clear bucket %s: %w", name, err)
+			}
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", name, err)
+		}
+	}
+
+	return tx.Bucket(bucketIndex).ForEach(func(k, v []byte) error {
+		var meta types.SessionMetadata
+		if err := json.Unmarshal(v, &meta); err != nil {
+			return nil // Skip malformed entries
+		}
+		return updateIndexes(tx, &meta, putEmpty)
+	})
+}
+
+func putEmpty(b *bolt.Bucket, key []byte) error {
+	return b.Put(key, []byte{})
+}
+
+// updateIndexes applies op to every secondary index key for a session.
+func updateIndexes(tx *bolt.Tx, meta *types.SessionMetadata, op func(*bolt.Bucket, []byte) error) error {
+	id := meta.ID
+
+	for _, tag := range meta.Tags {
+		if err := op(tx.Bucket(bucketIdxTag), indexKey([]byte(tag), id)); err != nil {
+			return fmt.Errorf("failed to update tag index: %w", err)
+		}
+	}
+	if meta.Status != "" {
+		if err := op(tx.Bucket(bucketIdxStatus), indexKey([]byte(meta.Status), id)); err != nil {
+			return fmt.Errorf("failed to update status index: %w", err)
+		}
+	}
+	if meta.Score != nil {
+		if err := op(tx.Bucket(bucketIdxScore), indexKey(encodeScore(*meta.Score), id)); err != nil {
+			return fmt.Errorf("failed to update score index: %w", err)
+		}
+	}
+	if err := op(tx.Bucket(bucketIdxStarted), indexKey(encodeTime(meta.StartedAt), id)); err != nil {
+		return fmt.Errorf("failed to update time index: %w", err)
+	}
+	return nil
+}
+
+func indexKey(value []byte, id string) []byte {
+	key := make([]byte, 0, len(value)+1+len(id))
+	key = append(key, value...)
+	key = append(key, indexSep)
+	return append(key, id...)
+}
+
+// scanPrefix returns the session IDs indexed under an exact value.
+func scanPrefix(b *bolt.Bucket, value []byte) map[string]bool {
+	ids := make(map[string]bool)
+	prefix := append(append([]byte(nil), value...), indexSep)
+
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		ids[string(k[len(prefix):])] = true
+	}
+	return ids
+}
+
+// scanScores returns the session IDs with scores in [min, max].
+func scanScores(b *bolt.Bucket, min, max *float64) map[string]bool {
+	ids := make(map[string]bool)
+
+	c := b.Cursor()
+	var k []byte
+	if min != nil {
+		k, _ = c.Seek(encodeScore(*min))
+	} else {
+		k, _ = c.First()
+	}
+	for ; k != nil; k, _ = c.Next() {
+		if max != nil && bytes.Compare(k[:8], encodeScore(*max)) > 0 {
+			break
+		}
+		ids[string(k[9:])] = true
+	}
+	return ids
+}
+
+// intersect narrows a candidate set; a nil set means "no filter yet".
+func intersect(candidates, ids map[string]bool) map[string]bool {
+	if candidates == nil {
+		return ids
+	}
+	for id := range candidates {
+		if !ids[id] {
+			delete(candidates, id)
+		}
+	}
+	return candidates
+}
+
+// encodeScore encodes a float so byte order matches numeric order.
+func encodeScore(score float64) []byte {
+	bits := math.Float64bits(score)
+	if score >= 0 {
+		bits ^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, bits)
+	return buf
+}
+
+// Bounds of the times encodeTime can represent: UnixNano is undefined
+// outside about 1678 to 2262, which includes the zero time.
+var (
+	minIndexTime = time.Unix(0, math.MinInt64)
+	maxIndexTime = time.Unix(0, math.MaxInt64)
+)
+
+// encodeTime encodes a time so byte order matches chronological order.
+// Times outside the representable range are clamped to its bounds.
+func encodeTime(t time.Time) []byte {
+	switch {
+	case t.Before(minIndexTime):
+		t = minIndexTime
+	case t.After(maxIndexTime):
+		t = maxIndexTime
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(t.UnixNano())^(1<<63))
+	return buf
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+func queryIDs(t *testing.T, store *BoltStore, q Query) []string {
+	t.Helper()
+	results, err := store.QuerySessions(q)
+	if err != nil {
+		t.Fatalf("QuerySessions failed: %v", err)
+	}
+	var ids []string
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func equalIDs(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestQuerySessions(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	ids := []string{"a", "b", "c", "d"}
+	tags := [][]string{{"coding"}, {"coding", "go"}, {"docs"}, {"coding", "go"}}
+	for i, id := range ids {
+		session := createTestSession(id)
+		session.Tags = tags[i]
+		session.StartedAt = base.Add(time.Duration(i) * 24 * time.Hour)
+		if err := store.CreateSession(session); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+	}
+	store.SetOutcome("a", types.Outcome{Score: 0.9})
+	store.SetOutcome("b", types.Outcome{Score: 0.4})
+	store.SetOutcome("c", types.Outcome{Score: 0.75})
+
+	low, high := 0.5, 0.8
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"all, newest first", Query{}, []string{"d", "c", "b", "a"}},
+		{"tag", Query{Tags: []string{"coding"}}, []string{"d", "b", "a"}},
+		{"all tags", Query{Tags: []string{"coding", "go"}}, []string{"d", "b"}},
+		{"unknown tag", Query{Tags: []string{"cod"}}, nil},
+		{"status", Query{Status: types.StatusScored}, []string{"c", "b", "a"}},
+		{"min score", Query{MinScore: &low}, []string{"c", "a"}},
+		{"score range", Query{MinScore: &low, MaxScore: &high}, []string{"c"}},
+		{"max score", Query{MaxScore: &high}, []string{"c", "b"}},
+		{"since", Query{Since: base.Add(48 * time.Hour)}, []string{"d", "c"}},
+		{"until", Query{Until: base.Add(48 * time.Hour)}, []string{"b", "a"}},
+		{"combined", Query{Tags: []string{"coding"}, Status: types.StatusRecording}, []string{"d"}},
+		{"limit and offset", Query{Limit: 2, Offset: 1}, []string{"c", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := queryIDs(t, store, tt.q); !equalIDs(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestQuerySessions_IndexesStayConsistent(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	session := createTestSession(NewULID())
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	// Retagging drops the old tag from the index
+	session.Tags = []string{"refactor"}
+	session.Status = types.StatusCompleted
+	if err := store.UpdateSession(session); err != nil {
+		t.Fatalf("failed to update session: %v", err)
+	}
+	if got := queryIDs(t, store, Query{Tags: []string{"coding"}}); len(got) != 0 {
+		t.Errorf("expected old tag to be unindexed, got %v", got)
+	}
+	if got := queryIDs(t, store, Query{Status: types.StatusRecording}); len(got) != 0 {
+		t.Errorf("expected old status to be unindexed, got %v", got)
+	}
+	if got := queryIDs(t, store, Query{Tags: []string{"refactor"}, Status: types.StatusCompleted}); len(got) != 1 {
+		t.Errorf("expected session under new tag and status, got %v", got)
+	}
+
+	// Rescoring moves the score entry
+	store.SetOutcome(session.ID, types.Outcome{Score: 0.2})
+	store.SetOutcome(session.ID, types.Outcome{Score: 0.8})
+	min := 0.1
+	max := 0.3
+	if got := queryIDs(t, store, Query{MinScore: &min, MaxScore: &max}); len(got) != 0 {
+		t.Errorf("expected old score to be unindexed, got %v", got)
+	}
+
+	if err := store.DeleteSession(session.ID); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+	for _, name := range [][]byte{bucketIdxTag, bucketIdxStatus, bucketIdxScore, bucketIdxStarted} {
+		store.db.View(func(tx *bolt.Tx) error {
+			if n := tx.Bucket(name).Stats().KeyN; n != 0 {
+				t.Errorf("expected %s to be empty after delete, has %d keys", name, n)
+			}
+			return nil
+		})
+	}
+}
+
+func TestQuerySessions_BuildsIndexesForExistingDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	session := createTestSession(NewULID())
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	// Simulate a database from before the secondary indexes existed
+	store.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketIdxTag, bucketIdxStatus, bucketIdxScore, bucketIdxStarted} {
+			tx.DeleteBucket(name)
+		}
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, []byte("3"))
+	})
+	store.Close()
+
+	store, err = NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	if got := queryIDs(t, store, Query{Tags: []string{"math"}}); !equalIDs(got, []string{session.ID}) {
+		t.Errorf("expected rebuilt tag index to find session, got %v", got)
+	}
+	store.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketMeta).Get(keyRebuildIndexes) != nil {
+			t.Error("expected the rebuild flag to be cleared")
+		}
+		return nil
+	})
+}
+
+func TestEncodeTime_ClampsOutOfRange(t *testing.T) {
+	times := []time.Time{
+		{},
+		time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2500, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for i := 1; i < len(times); i++ {
+		if bytes.Compare(encodeTime(times[i-1]), encodeTime(times[i])) > 0 {
+			t.Errorf("expected %v to sort before %v", times[i-1], times[i])
+		}
+	}
+	if !bytes.Equal(encodeTime(time.Time{}), encodeTime(minIndexTime)) {
+		t.Error("expected the zero time to clamp to the earliest indexable time")
+	}
+}
+
+func TestQuerySessions_ReindexesOutOfRangeTimes(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	recent, future := createTestSession("recent"), createTestSession("future")
+	recent.StartedAt = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	future.StartedAt = time.Date(2500, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.CreateSession(recent)
+	store.CreateSession(future)
+
+	// Before v14 the start time index held overflowed keys for such times
+	store.db.Update(func(tx *bolt.Tx) error {
+		tx.DeleteBucket(bucketIdxStarted)
+		tx.CreateBucket(bucketIdxStarted)
+		tx.Bucket(bucketIdxStarted).Put(indexKey(make([]byte, 8), "future"), nil)
+		tx.Bucket(bucketIdxStarted).Put(indexKey(encodeTime(recent.StartedAt), "recent"), nil)
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, []byte("13"))
+	})
+	store.Close()
+
+	store, err = NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	if got := queryIDs(t, store, Query{}); !equalIDs(got, []string{"future", "recent"}) {
+		t.Errorf("expected the start time index to be rebuilt in order, got %v", got)
+	}
+}
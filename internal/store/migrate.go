@@ -0,0 +1,451 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/embed"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// ErrSchemaTooNew is returned when a database was written by a newer version.
+	ErrSchemaTooNew = errors.New("database schema is newer than this version supports")
+	// ErrDatabaseLocked is returned when another process holds the database.
+	ErrDatabaseLocked = errors.New("database is locked by another process (is trajectory-memory serve running?)")
+)
+
+// bucketMeta holds database-wide settings such as the schema version.
+var bucketMeta = []byte("meta")
+
+var keySchemaVersion = []byte("schema_version")
+
+// Meta keys set by migrations whose derived data is rebuilt once migrating
+// finishes, so it is built by this build's code rather than frozen in them.
+var (
+	keyRebuildIndexes     = []byte("rebuild_indexes")
+	keyRebuildSearchIndex = []byte("rebuild_search_index")
+	keyRebuildVectors     = []byte("rebuild_vectors")
+)
+
+// Migration upgrades the database from Version-1 to Version.
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(tx *bolt.Tx) error
+}
+
+// migrations are applied in order, each in its own transaction along with
+// the version bump. Append new migrations; never reorder or edit old ones.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Create session buckets",
+		Migrate: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketSessions, bucketActive, bucketIndex, bucketStrategyUsage)
+		},
+	},
+	{
+		Version:     2,
+		Description: "Create optimization buckets",
+		Migrate: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketOptimizations, bucketCuratedExamples, bucketTriggerConfig)
+		},
+	},
+	{
+		Version:     3,
+		Description: "Move steps out of session records into per-session buckets",
+		Migrate: func(tx *bolt.Tx) error {
+			// Databases opened by a build that already moved steps have the bucket
+			if tx.Bucket(bucketSteps) != nil {
+				return nil
+			}
+			if err := createBuckets(tx, bucketSteps); err != nil {
+				return err
+			}
+			return migrateSteps(tx)
+		},
+	},
+	{
+		Version:     4,
+		Description: "Build tag, status, score and start time indexes",
+		Migrate: func(tx *bolt.Tx) error {
+			if err := createBuckets(tx, []byte("idx_tag"), []byte("idx_status"), []byte("idx_score"), []byte("idx_started")); err != nil {
+				return err
+			}
+			return tx.Bucket([]byte("meta")).Put([]byte("rebuild_indexes"), []byte("1"))
+		},
+	},
+	{
+		Version:     5,
+		Description: "Build full-text search index",
+		Migrate: func(tx *bolt.Tx) error {
+			if err := createBuckets(tx, []byte("fts_postings"), []byte("fts_docs")); err != nil {
+				return err
+			}
+			return tx.Bucket([]byte("meta")).Put([]byte("rebuild_search_index"), []byte("1"))
+		},
+	},
+	{
+		Version:     6,
+		Description: "Embed sessions for similarity search",
+		Migrate: func(tx *bolt.Tx) error {
+			if err := createBuckets(tx, []byte("vectors")); err != nil {
+				return err
+			}
+			return tx.Bucket([]byte("meta")).Put([]byte("rebuild_vectors"), []byte("1"))
+		},
+	},
+	{
+		Version:     7,
+		Description: "Create comparisons bucket",
+		Migrate: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketComparisons)
+		},
+	},
+	{
+		Version:     8,
+		Description: "Create strategy models bucket",
+		Migrate: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketStrategyModels)
+		},
+	},
+	{
+		Version:     9,
+		Description: "Create strategy archive bucket",
+		Migrate: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketStrategyArchive)
+		},
+	},
+	{
+		Version:     10,
+		Description: "Index steps by tool use ID",
+		Migrate: func(tx *bolt.Tx) error {
+			index, err := tx.CreateBucketIfNotExists([]byte("step_index"))
+			if err != nil {
+				return fmt.Errorf("failed to create bucket step_index: %w", err)
+			}
+			return tx.Bucket([]byte("steps")).ForEachBucket(func(sessionID []byte) error {
+				sessionIndex, err := index.CreateBucketIfNotExists(sessionID)
+				if err != nil {
+					return err
+				}
+				// Later steps overwrite earlier ones, so the latest wins
+				return tx.Bucket([]byte("steps")).Bucket(sessionID).ForEach(func(k, v []byte) error {
+					var step struct {
+						ToolUseID string `json:"tool_use_id"`
+					}
+					if err := json.Unmarshal(v, &step); err != nil || step.ToolUseID == "" {
+						return nil
+					}
+					return sessionIndex.Put([]byte(step.ToolUseID), append([]byte(nil), k...))
+				})
+			})
+		},
+	},
+	{
+		Version:     11,
+		Description: "Prefix recording bindings with claude:",
+		Migrate: func(tx *bolt.Tx) error {
+			active := tx.Bucket([]byte("active"))
+			var keys [][]byte
+			if err := active.ForEach(func(k, v []byte) error {
+				if string(k) != "current" {
+					keys = append(keys, append([]byte(nil), k...))
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			for _, k := range keys {
+				v := append([]byte(nil), active.Get(k)...)
+				if err := active.Delete(k); err != nil {
+					return err
+				}
+				if err := active.Put(append([]byte("claude:"), k...), v); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     12,
+		Description: "Index adjacent word pairs for phrase search",
+		Migrate: func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte("meta")).Put([]byte("rebuild_search_index"), []byte("1"))
+		},
+	},
+	{
+		Version:     13,
+		Description: "Remove comparisons of deleted sessions",
+		Migrate: func(tx *bolt.Tx) error {
+			sessions, comparisons := tx.Bucket([]byte("sessions")), tx.Bucket([]byte("comparisons"))
+			var orphans [][]byte
+			if err := comparisons.ForEach(func(k, v []byte) error {
+				var c struct {
+					Winner string `json:"winner"`
+					Loser  string `json:"loser"`
+				}
+				if err := json.Unmarshal(v, &c); err != nil {
+					return nil
+				}
+				if sessions.Get([]byte(c.Winner)) == nil || sessions.Get([]byte(c.Loser)) == nil {
+					orphans = append(orphans, append([]byte(nil), k...))
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			for _, k := range orphans {
+				if err := comparisons.Delete(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     14,
+		Description: "Re-index start times outside 1678 to 2262",
+		Migrate: func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte("meta")).Put([]byte("rebuild_indexes"), []byte("1"))
+		},
+	},
+	{
+		Version:     15,
+		Description: "Re-index search terms with the shared tokenizer",
+		Migrate: func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte("meta")).Put([]byte("rebuild_search_index"), []byte("1"))
+		},
+	},
+	{
+		Version:     16,
+		Description: "Re-embed sessions and record the embedding model",
+		Migrate: func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte("meta")).Put([]byte("rebuild_vectors"), []byte("1"))
+		},
+	},
+	{
+		Version:     17,
+		Description: "Mark strategy usage of scored sessions as scored",
+		Migrate: func(tx *bolt.Tx) error {
+			sessions, usage := tx.Bucket([]byte("sessions")), tx.Bucket([]byte("strategy_usage"))
+			updates := make(map[string][]byte)
+			if err := usage.ForEach(func(k, v []byte) error {
+				var record map[string]interface{}
+				if err := json.Unmarshal(v, &record); err != nil {
+					return nil
+				}
+				id, _ := record["session_id"].(string)
+				var session struct {
+					Outcome *struct {
+						Score float64 `json:"score"`
+					} `json:"outcome"`
+				}
+				data := sessions.Get([]byte(id))
+				if data == nil || json.Unmarshal(data, &session) != nil || session.Outcome == nil {
+					return nil
+				}
+				record["score"] = session.Outcome.Score
+				record["scored"] = true
+				updated, err := json.Marshal(record)
+				if err != nil {
+					return err
+				}
+				updates[string(k)] = updated
+				return nil
+			}); err != nil {
+				return err
+			}
+			for k, v := range updates {
+				if err := usage.Put([]byte(k), v); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// SchemaVersion is the schema version this build writes.
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// PendingMigrations reports a database's schema version and the migrations
+// opening it would apply, without modifying it. A missing database is at
+// version 0.
+func PendingMigrations(dbPath string) (int, []Migration, error) {
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		return 0, migrations, nil
+	}
+
+	db, err := openDB(dbPath, &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
+	if err != nil {
+		return 0, nil, err
+	}
+	defer db.Close()
+
+	var version int
+	err = db.View(func(tx *bolt.Tx) error {
+		var err error
+		version, err = schemaVersion(tx)
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := checkVersion(version); err != nil {
+		return version, nil, err
+	}
+	return version, pendingFrom(version), nil
+}
+
+// openDB opens a bolt database, reporting a lock held by another process as
+// ErrDatabaseLocked.
+func openDB(dbPath string, opts *bolt.Options) (*bolt.DB, error) {
+	db, err := bolt.Open(dbPath, 0600, opts)
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("failed to open %s: %w", dbPath, ErrDatabaseLocked)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
+// migrate brings the database up to the current schema version, logging
+// each migration it applies to an existing database, and then rebuilds any
+// derived data the migrations flagged.
+func migrate(db *bolt.DB) error {
+	var version int
+	var existing bool
+	err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(bucketMeta); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", bucketMeta, err)
+		}
+		// Databases from before versioning are at version 0 but have sessions
+		existing = tx.Bucket(bucketSessions) != nil
+		var err error
+		version, err = schemaVersion(tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(version); err != nil {
+		return err
+	}
+
+	for _, m := range pendingFrom(version) {
+		if existing {
+			log.Printf("Migrating database %s to schema v%d: %s", db.Path(), m.Version, m.Description)
+		}
+		err := db.Update(func(tx *bolt.Tx) error {
+			if err := m.Migrate(tx); err != nil {
+				return err
+			}
+			return tx.Bucket(bucketMeta).Put(keySchemaVersion, []byte(strconv.Itoa(m.Version)))
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+	}
+	return rebuildDerived(db)
+}
+
+// rebuildDerived rebuilds the secondary indexes, search index and session
+// vectors if a migration flagged them. The flag is cleared with the
+// rebuild, so an interrupted rebuild is retried on the next open.
+func rebuildDerived(db *bolt.DB) error {
+	var indexes, searchIndex, vectors bool
+	db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		indexes = meta.Get(keyRebuildIndexes) != nil
+		searchIndex = meta.Get(keyRebuildSearchIndex) != nil
+		vectors = meta.Get(keyRebuildVectors) != nil
+		return nil
+	})
+	if !indexes && !searchIndex && !vectors {
+		return nil
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		if indexes {
+			if err := rebuildIndexes(tx); err != nil {
+				return fmt.Errorf("failed to rebuild indexes: %w", err)
+			}
+			if err := meta.Delete(keyRebuildIndexes); err != nil {
+				return err
+			}
+		}
+		if searchIndex {
+			if err := rebuildSearchIndex(tx); err != nil {
+				return fmt.Errorf("failed to rebuild search index: %w", err)
+			}
+			if err := meta.Delete(keyRebuildSearchIndex); err != nil {
+				return err
+			}
+		}
+		if vectors {
+			if err := embedSessions(tx, embed.Default(), true); err != nil {
+				return fmt.Errorf("failed to embed sessions: %w", err)
+			}
+			if err := meta.Delete(keyRebuildVectors); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// schemaVersion reads the stored schema version; databases from before
+// versioning have none and are at version 0.
+func schemaVersion(tx *bolt.Tx) (int, error) {
+	meta := tx.Bucket(bucketMeta)
+	if meta == nil {
+		return 0, nil
+	}
+	data := meta.Get(keySchemaVersion)
+	if data == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %w", data, err)
+	}
+	return version, nil
+}
+
+func checkVersion(version int) error {
+	if version > SchemaVersion() {
+		return fmt.Errorf("%w (database is v%d, supported up to v%d)", ErrSchemaTooNew, version, SchemaVersion())
+	}
+	return nil
+}
+
+func pendingFrom(version int) []Migration {
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+func createBuckets(tx *bolt.Tx, names ...[]byte) error {
+	for _, name := range names {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", name, err)
+		}
+	}
+	return nil
+}
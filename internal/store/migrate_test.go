@@ -0,0 +1,222 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMigrate_RecordsSchemaVersion(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	store.db.View(func(tx *bolt.Tx) error {
+		version, err := schemaVersion(tx)
+		if err != nil || version != SchemaVersion() {
+			t.Errorf("expected schema version %d, got %d (%v)", SchemaVersion(), version, err)
+		}
+		for _, name := range [][]byte{bucketOptimizations, bucketCuratedExamples, bucketTriggerConfig} {
+			if tx.Bucket(name) == nil {
+				t.Errorf("expected bucket %s to be created by migrations", name)
+			}
+		}
+		return nil
+	})
+}
+
+func TestMigrate_OrderedVersions(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d; versions must be sequential", i, m.Version)
+		}
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	version, pending, err := PendingMigrations(dbPath)
+	if err != nil || version != 0 || len(pending) != len(migrations) {
+		t.Errorf("expected all migrations pending for a new database, got v%d, %d pending, %v", version, len(pending), err)
+	}
+
+	store, err := NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	// A running server holds the lock, which a dry run reports plainly
+	if _, _, err := PendingMigrations(dbPath); !errors.Is(err, ErrDatabaseLocked) {
+		t.Errorf("expected ErrDatabaseLocked while the store is open, got %v", err)
+	}
+	// Roll back the version to simulate an older database
+	store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, []byte("2"))
+	})
+	store.Close()
+
+	version, pending, err = PendingMigrations(dbPath)
+	if err != nil {
+		t.Fatalf("PendingMigrations failed: %v", err)
+	}
+	if version != 2 || len(pending) != SchemaVersion()-2 || pending[0].Version != 3 {
+		t.Errorf("expected migrations from v3 pending, got v%d with %+v", version, pending)
+	}
+
+	// A dry run leaves the database alone
+	_, again, _ := PendingMigrations(dbPath)
+	if len(again) != len(pending) {
+		t.Errorf("expected dry run not to apply migrations")
+	}
+}
+
+func TestNewBoltStore_RefusesNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	store, err := NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, []byte(strconv.Itoa(SchemaVersion()+1)))
+	})
+	store.Close()
+
+	if _, err := NewBoltStore(dbPath); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("expected ErrSchemaTooNew, got %v", err)
+	}
+	if _, _, err := PendingMigrations(dbPath); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("expected ErrSchemaTooNew from dry run, got %v", err)
+	}
+}
+
+func TestMigrate_PrefixesRecordingBindings(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	session := createTestSession(NewULID())
+	store.CreateSession(session)
+
+	// Bindings written before v11 used the bare Claude session ID
+	store.db.Update(func(tx *bolt.Tx) error {
+		tx.Bucket(bucketActive).Put([]byte("claude-a"), []byte(session.ID))
+		tx.Bucket(bucketActive).Put([]byte(defaultActiveKey), []byte(session.ID))
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, []byte("10"))
+	})
+	store.Close()
+
+	store, err = NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	bindings, err := store.ListActiveSessions()
+	if err != nil || len(bindings) != 2 || bindings["claude-a"] != session.ID || bindings[""] != session.ID {
+		t.Errorf("expected the bindings to survive the migration, got %v (%v)", bindings, err)
+	}
+}
+
+func TestMigrate_RemovesOrphanedComparisons(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	a, b := createTestSession(NewULID()), createTestSession(NewULID())
+	store.CreateSession(a)
+	store.CreateSession(b)
+	store.RecordComparison(&types.Comparison{Tag: "backend", Winner: a.ID, Loser: b.ID})
+
+	// Before v13, deleting a session left its comparisons behind
+	store.RecordComparison(&types.Comparison{Tag: "backend", Winner: a.ID, Loser: "deleted"})
+	store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, []byte("12"))
+	})
+	store.Close()
+
+	store, err = NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	comparisons, err := store.ListComparisons("")
+	if err != nil || len(comparisons) != 1 || comparisons[0].Loser != b.ID {
+		t.Errorf("expected only the comparison between existing sessions, got %+v (%v)", comparisons, err)
+	}
+}
+
+func TestMigrate_MarksScoredStrategyUsage(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	session := createTestSession(NewULID())
+	store.CreateSession(session)
+	store.SetOutcome(session.ID, types.Outcome{Score: 0})
+
+	// Before v17 a score of 0 was recorded as no score at all
+	store.RecordStrategyUsage(types.StrategyUsage{Tag: "coding", StrategyName: "careful", SessionID: session.ID})
+	store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, []byte("16"))
+	})
+	store.Close()
+
+	store, err = NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	stats, err := store.GetStrategyStats("coding")
+	if err != nil || stats["careful"] == nil || stats["careful"].ScoredCount != 1 {
+		t.Errorf("expected the zero score to count, got %+v (%v)", stats["careful"], err)
+	}
+}
+
+func TestMigrate_RebuildsDerivedData(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	session := createTestSession(NewULID())
+	session.TaskPrompt = "Fix the flaky websocket reconnect"
+	store.CreateSession(session)
+
+	// A database from before search and similarity had neither
+	store.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketFTSPostings, bucketFTSDocs, bucketVectors} {
+			tx.DeleteBucket(name)
+		}
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, []byte("4"))
+	})
+	store.Close()
+
+	store, err = NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	if results, err := store.SearchSessions("websocket", 10); err != nil || len(results) != 1 {
+		t.Errorf("expected the search index to be rebuilt, got %v (%v)", results, err)
+	}
+	if results, err := store.SimilarSessions("websocket reconnect", Query{}); err != nil || len(results) != 1 {
+		t.Errorf("expected sessions to be embedded, got %v (%v)", results, err)
+	}
+	store.db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		if meta.Get(keyRebuildSearchIndex) != nil || meta.Get(keyRebuildVectors) != nil {
+			t.Error("expected the rebuild flags to be cleared")
+		}
+		return nil
+	})
+}
@@ -58,28 +58,11 @@ func DefaultTriggerConfig() *TriggerConfig {
 	}
 }
 
-// EnsureOptimizationBuckets creates the optimization-related buckets if they don't exist.
-func (s *BoltStore) EnsureOptimizationBuckets() error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		for _, bucket := range [][]byte{bucketOptimizations, bucketCuratedExamples, bucketTriggerConfig} {
-			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
-				return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
-			}
-		}
-		return nil
-	})
-}
-
 // CreateOptimization creates a new optimization record.
 func (s *BoltStore) CreateOptimization(r *types.OptimizationRecord) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Ensure buckets exist
-	if err := s.EnsureOptimizationBuckets(); err != nil {
-		return err
-	}
-
 	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketOptimizations)
 
@@ -102,11 +85,6 @@ func (s *BoltStore) GetOptimization(id string) (*types.OptimizationRecord, error
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Ensure buckets exist
-	if err := s.EnsureOptimizationBuckets(); err != nil {
-		return nil, err
-	}
-
 	var record types.OptimizationRecord
 	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketOptimizations)
@@ -147,11 +125,6 @@ func (s *BoltStore) ListOptimizations(filePath string, tag string, limit int) ([
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Ensure buckets exist
-	if err := s.EnsureOptimizationBuckets(); err != nil {
-		return nil, err
-	}
-
 	var results []types.OptimizationRecord
 	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketOptimizations)
@@ -199,11 +172,6 @@ func (s *BoltStore) SaveCuratedExamples(tag string, examples []types.CuratedExam
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Ensure buckets exist
-	if err := s.EnsureOptimizationBuckets(); err != nil {
-		return err
-	}
-
 	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketCuratedExamples)
 
@@ -221,11 +189,6 @@ func (s *BoltStore) GetCuratedExamples(tag string) ([]types.CuratedExample, erro
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Ensure buckets exist
-	if err := s.EnsureOptimizationBuckets(); err != nil {
-		return nil, err
-	}
-
 	var examples []types.CuratedExample
 	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketCuratedExamples)
@@ -243,11 +206,6 @@ func (s *BoltStore) GetTriggerConfig() (*TriggerConfig, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Ensure buckets exist
-	if err := s.EnsureOptimizationBuckets(); err != nil {
-		return nil, err
-	}
-
 	var config TriggerConfig
 	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketTriggerConfig)
@@ -267,11 +225,6 @@ func (s *BoltStore) SaveTriggerConfig(config *TriggerConfig) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Ensure buckets exist
-	if err := s.EnsureOptimizationBuckets(); err != nil {
-		return err
-	}
-
 	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketTriggerConfig)
 
@@ -0,0 +1,471 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Full-text search buckets. Postings are keyed <term><sep><session ID> with
+// the weighted term frequency as a uvarint value; fts_docs records each
+// session's term frequencies so its postings can be updated in place.
+var (
+	bucketFTSPostings = []byte("fts_postings")
+	bucketFTSDocs     = []byte("fts_docs")
+)
+
+// Corpus statistics for BM25, kept in the meta bucket.
+var (
+	keyFTSDocCount = []byte("fts_doc_count")
+	keyFTSTotalLen = []byte("fts_total_len")
+)
+
+// Field weights: a match in the task prompt counts for more than one buried
+// in a step's output.
+const (
+	weightPrompt  = 3
+	weightSummary = 2
+	weightTag     = 2
+	weightNotes   = 1
+	weightStep    = 1
+)
+
+// BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// fileTools are tools whose input summary is a file path.
+var fileTools = map[string]bool{
+	"Read": true, "Write": true, "Edit": true, "MultiEdit": true, "NotebookEdit": true,
+}
+
+// SearchResult is a session matching a search, with its relevance score.
+type SearchResult struct {
+	types.SessionMetadata
+	Relevance float64 `json:"relevance"`
+}
+
+// ftsDoc is the indexed form of a session.
+type ftsDoc struct {
+	Length int            `json:"length"`
+	Terms  map[string]int `json:"terms"`
+}
+
+// termCounts maps index terms to weighted frequencies.
+type termCounts map[string]int
+
+func (c termCounts) addText(text string, weight int) {
+	terms := tokenize(text)
+	for i, term := range terms {
+		c[term] += weight
+		if i > 0 {
+			c[pairTerm(terms[i-1], term)]++
+		}
+	}
+}
+
+// merge adds other's counts, scaled by sign (+1 to add, -1 to subtract).
+func (c termCounts) merge(other termCounts, sign int) {
+	for term, n := range other {
+		c[term] += sign * n
+	}
+}
+
+// headerTerms returns the index terms for a session's own fields.
+func headerTerms(session *types.Session) termCounts {
+	c := termCounts{}
+	c.addText(session.TaskPrompt, weightPrompt)
+	c.addText(session.Summary, weightSummary)
+	for _, tag := range session.Tags {
+		c.addText(tag, weightTag)
+		c[fieldTerm("tag", tag)]++
+	}
+	if session.Outcome != nil {
+		c.addText(session.Outcome.Notes, weightNotes)
+	}
+	return c
+}
+
+// stepTerms returns the index terms for a step.
+func stepTerms(step types.TrajectoryStep) termCounts {
+	c := termCounts{}
+	c.addText(step.InputSummary, weightStep)
+	c.addText(step.OutputSummary, weightStep)
+	if step.ToolName != "" {
+		c[fieldTerm("tool", step.ToolName)]++
+	}
+	if fileTools[step.ToolName] {
+		for _, term := range fileTerms(step.InputSummary) {
+			c[term]++
+		}
+	}
+	return c
+}
+
+// updateSearchIndex applies a change in a session's term counts to the index.
+func updateSearchIndex(tx *bolt.Tx, sessionID string, delta termCounts) error {
+	docs := tx.Bucket(bucketFTSDocs)
+	postings := tx.Bucket(bucketFTSPostings)
+	meta := tx.Bucket(bucketMeta)
+
+	doc := ftsDoc{Terms: map[string]int{}}
+	isNew := true
+	if data := docs.Get([]byte(sessionID)); data != nil {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("failed to unmarshal search document: %w", err)
+		}
+		isNew = false
+	}
+
+	lengthDelta := 0
+	for term, n := range delta {
+		if n == 0 {
+			continue
+		}
+		before := doc.Terms[term]
+		after := before + n
+		if after < 0 {
+			after = 0
+		}
+		if !isFieldTerm(term) {
+			lengthDelta += after - before
+		}
+
+		key := indexKey([]byte(term), sessionID)
+		if after == 0 {
+			delete(doc.Terms, term)
+			if err := postings.Delete(key); err != nil {
+				return fmt.Errorf("failed to update search index: %w", err)
+			}
+			continue
+		}
+		doc.Terms[term] = after
+		if err := postings.Put(key, binary.AppendUvarint(nil, uint64(after))); err != nil {
+			return fmt.Errorf("failed to update search index: %w", err)
+		}
+	}
+	doc.Length += lengthDelta
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal search document: %w", err)
+	}
+	if err := docs.Put([]byte(sessionID), data); err != nil {
+		return fmt.Errorf("failed to store search document: %w", err)
+	}
+
+	countDelta := 0
+	if isNew {
+		countDelta = 1
+	}
+	return updateCorpusStats(meta, countDelta, lengthDelta)
+}
+
+// removeFromSearchIndex drops a session from the index.
+func removeFromSearchIndex(tx *bolt.Tx, sessionID string) error {
+	docs := tx.Bucket(bucketFTSDocs)
+	data := docs.Get([]byte(sessionID))
+	if data == nil {
+		return nil
+	}
+
+	var doc ftsDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to unmarshal search document: %w", err)
+	}
+
+	postings := tx.Bucket(bucketFTSPostings)
+	for term := range doc.Terms {
+		if err := postings.Delete(indexKey([]byte(term), sessionID)); err != nil {
+			return fmt.Errorf("failed to update search index: %w", err)
+		}
+	}
+	if err := docs.Delete([]byte(sessionID)); err != nil {
+		return err
+	}
+	return updateCorpusStats(tx.Bucket(bucketMeta), -1, -doc.Length)
+}
+
+// rebuildSearchIndex indexes every session from scratch.
+func rebuildSearchIndex(tx *bolt.Tx) error {
+	for _, name := range [][]byte{bucketFTSPostings, bucketFTSDocs} {
+		if tx.Bucket(name) != nil {
+			if err := tx.DeleteBucket(name); err != nil {
+				return fmt.Errorf("failed to clear bucket %s: %w", name, err)
+			}
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", name, err)
+		}
+	}
+	meta := tx.Bucket(bucketMeta)
+	meta.Delete(keyFTSDocCount)
+	meta.Delete(keyFTSTotalLen)
+
+	// Collect IDs first; bbolt doesn't allow modifying a bucket during ForEach
+	var ids [][]byte
+	tx.Bucket(bucketSessions).ForEach(func(k, v []byte) error {
+		ids = append(ids, append([]byte(nil), k...))
+		return nil
+	})
+
+	for _, id := range ids {
+		session, err := loadSession(tx, id)
+		if err != nil {
+			continue // Skip malformed entries
+		}
+		terms := headerTerms(session)
+		for _, step := range session.Steps {
+			terms.merge(stepTerms(step), 1)
+		}
+		if err := updateSearchIndex(tx, session.ID, terms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isFieldTerm(term string) bool {
+	return strings.Contains(term, ":")
+}
+
+func updateCorpusStats(meta *bolt.Bucket, countDelta, lengthDelta int) error {
+	if countDelta == 0 && lengthDelta == 0 {
+		return nil
+	}
+	count, length := corpusStats(meta)
+	if err := meta.Put(keyFTSDocCount, []byte(strconv.Itoa(count+countDelta))); err != nil {
+		return err
+	}
+	return meta.Put(keyFTSTotalLen, []byte(strconv.Itoa(length+lengthDelta)))
+}
+
+func corpusStats(meta *bolt.Bucket) (count int, length int) {
+	count, _ = strconv.Atoi(string(meta.Get(keyFTSDocCount)))
+	length, _ = strconv.Atoi(string(meta.Get(keyFTSTotalLen)))
+	return count, length
+}
+
+// SearchSessions returns sessions matching a search query, most relevant
+// first. See searchQuery for the query syntax. Queries with only filters
+// return the most recent matching sessions.
+func (s *BoltStore) SearchSessions(query string, limit int) ([]SearchResult, error) {
+	q, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []SearchResult
+	err = s.db.View(func(tx *bolt.Tx) error {
+		scores, err := q.evaluate(tx)
+		if err != nil {
+			return err
+		}
+
+		index := tx.Bucket(bucketIndex)
+		for id, score := range scores {
+			var meta types.SessionMetadata
+			if err := json.Unmarshal(index.Get([]byte(id)), &meta); err != nil {
+				continue // Skip malformed entries
+			}
+			if q.status != "" && strings.ToLower(meta.Status) != q.status || q.excludesStatus(meta.Status) {
+				continue
+			}
+			if !q.matchesScore(meta.Score) {
+				continue
+			}
+			results = append(results, SearchResult{SessionMetadata: meta, Relevance: score})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Relevance != results[j].Relevance {
+			return results[i].Relevance > results[j].Relevance
+		}
+		return results[i].StartedAt.After(results[j].StartedAt)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// evaluate returns the BM25 score of every session matching the query.
+func (q *searchQuery) evaluate(tx *bolt.Tx) (map[string]float64, error) {
+	postings := tx.Bucket(bucketFTSPostings)
+	docs := tx.Bucket(bucketFTSDocs)
+
+	count, totalLen := corpusStats(tx.Bucket(bucketMeta))
+	avgLen := 1.0
+	if count > 0 && totalLen > 0 {
+		avgLen = float64(totalLen) / float64(count)
+	}
+
+	docLens := make(map[string]int)
+	docLen := func(id string) int {
+		if n, ok := docLens[id]; ok {
+			return n
+		}
+		var doc ftsDoc
+		json.Unmarshal(docs.Get([]byte(id)), &doc)
+		docLens[id] = doc.Length
+		return doc.Length
+	}
+
+	// bm25 scores one term's postings into a map of session ID -> score
+	bm25 := func(term searchTerm) map[string]float64 {
+		scores := make(map[string]float64)
+		for _, ids := range scanTerm(postings, term) {
+			df := float64(len(ids))
+			idf := math.Log(1 + (float64(count)-df+0.5)/(df+0.5))
+			for id, tf := range ids {
+				norm := bm25K1 * (1 - bm25B + bm25B*float64(docLen(id))/avgLen)
+				scores[id] += idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + norm)
+			}
+		}
+		return scores
+	}
+
+	var scores map[string]float64
+	combine := func(group map[string]float64) {
+		if scores == nil {
+			scores = group
+			return
+		}
+		for id := range scores {
+			if s, ok := group[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	for _, group := range q.groups {
+		union := make(map[string]float64)
+		for _, term := range group {
+			for id, s := range bm25(term) {
+				union[id] += s
+			}
+		}
+		combine(union)
+	}
+	for _, phrase := range q.phrases {
+		// Sessions with every adjacent pair of the phrase, scored by its words
+		var matched map[string]bool
+		for i := 1; i < len(phrase); i++ {
+			matched = intersect(matched, scanIDs(postings, pairTerm(phrase[i-1], phrase[i])))
+		}
+		all := make(map[string]float64)
+		for id := range matched {
+			all[id] = 0
+		}
+		for _, word := range phrase {
+			for id, s := range bm25(searchTerm{text: word}) {
+				if _, ok := all[id]; ok {
+					all[id] += s
+				}
+			}
+		}
+		combine(all)
+	}
+
+	// Filters narrow without contributing to the score
+	for _, filter := range q.filters {
+		matched := make(map[string]float64)
+		for id := range scanIDs(postings, filter) {
+			matched[id] = 0
+		}
+		combine(matched)
+	}
+
+	// No text or field filters: the status and score indexes give the
+	// candidates, or failing that every session is one
+	if scores == nil {
+		scores = make(map[string]float64)
+		candidates := Query{Status: types.SessionStatus(q.status), MinScore: q.minScore, MaxScore: q.maxScore}.candidates(tx)
+		if candidates != nil {
+			for id := range candidates {
+				scores[id] = 0
+			}
+		} else {
+			tx.Bucket(bucketIndex).ForEach(func(k, v []byte) error {
+				scores[string(k)] = 0
+				return nil
+			})
+		}
+	}
+
+	for _, term := range q.excludes {
+		for _, ids := range scanTerm(postings, term) {
+			for id := range ids {
+				delete(scores, id)
+			}
+		}
+	}
+	for _, filter := range q.excludeFilters {
+		for id := range scanIDs(postings, filter) {
+			delete(scores, id)
+		}
+	}
+
+	return scores, nil
+}
+
+// scanIDs returns the sessions indexed under an exact term.
+func scanIDs(postings *bolt.Bucket, term string) map[string]bool {
+	ids := make(map[string]bool)
+	for _, posting := range scanTerm(postings, searchTerm{text: term}) {
+		for id := range posting {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// scanTerm returns the postings for a term (or every term with its prefix),
+// as term -> session ID -> frequency.
+func scanTerm(postings *bolt.Bucket, term searchTerm) map[string]map[string]int {
+	results := make(map[string]map[string]int)
+
+	seek := []byte(term.text)
+	if !term.prefix {
+		seek = append(seek, indexSep)
+	}
+
+	c := postings.Cursor()
+	for k, v := c.Seek(seek); k != nil && bytes.HasPrefix(k, seek); k, v = c.Next() {
+		sep := bytes.IndexByte(k, indexSep)
+		if sep < 0 {
+			continue
+		}
+		t := string(k[:sep])
+		// Field terms only match field filters, not free text
+		if term.prefix && isFieldTerm(t) != isFieldTerm(term.text) {
+			continue
+		}
+		tf, _ := binary.Uvarint(v)
+		if results[t] == nil {
+			results[t] = make(map[string]int)
+		}
+		results[t][string(k[sep+1:])] = int(tf)
+	}
+	return results
+}
@@ -0,0 +1,256 @@
+package store
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/johncarpenter/trajectory-memory/internal/embed"
+)
+
+// searchQuery is a parsed search string.
+//
+// Bare words must all match (AND); "a OR b" matches either; a trailing *
+// matches any term with that prefix; "quoted phrases" must have each pair
+// of adjacent words together, in order, within one field; a leading -
+// excludes sessions with the term or field.
+// Field filters narrow the results without affecting ranking: tag:, tool:,
+// file:, status: and score>, score>=, score<, score<=, score=.
+type searchQuery struct {
+	// groups are ANDed; the terms within a group are ORed.
+	groups         [][]searchTerm
+	phrases        [][]string
+	excludes       []searchTerm
+	filters        []string // field terms such as "tag:go", all required
+	excludeFilters []string // field terms such as "tag:go", all excluded
+	status         string
+	excludeStatus  []string
+	minScore       *float64
+	maxScore       *float64
+	// minExclusive and maxExclusive make the score bounds strict.
+	minExclusive bool
+	maxExclusive bool
+}
+
+// searchTerm is a single index term, optionally matched as a prefix.
+type searchTerm struct {
+	text   string
+	prefix bool
+}
+
+// isEmpty reports whether the query has no text to rank by.
+func (q *searchQuery) isEmpty() bool {
+	return len(q.groups) == 0 && len(q.phrases) == 0
+}
+
+// parseSearchQuery parses the search syntax described on searchQuery.
+func parseSearchQuery(input string) (*searchQuery, error) {
+	q := &searchQuery{}
+
+	tokens := splitQuery(input)
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		if strings.HasPrefix(tok, `"`) {
+			words := tokenize(strings.Trim(tok, `"`))
+			if len(words) == 1 {
+				q.groups = append(q.groups, []searchTerm{{text: words[0]}})
+			} else if len(words) > 1 {
+				q.phrases = append(q.phrases, words)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(tok, "score") && len(tok) > len("score") && strings.ContainsAny(tok[5:6], "<>=") {
+			if err := q.parseScore(tok[len("score"):]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// A leading - excludes a field as well as a word
+		exclude := strings.HasPrefix(tok, "-")
+		if field, value, ok := strings.Cut(strings.TrimPrefix(tok, "-"), ":"); ok && value != "" {
+			switch strings.ToLower(field) {
+			case "tag", "tool", "file":
+				if exclude {
+					q.excludeFilters = append(q.excludeFilters, fieldTerm(field, value))
+				} else {
+					q.filters = append(q.filters, fieldTerm(field, value))
+				}
+				continue
+			case "status":
+				if exclude {
+					q.excludeStatus = append(q.excludeStatus, strings.ToLower(value))
+				} else {
+					q.status = strings.ToLower(value)
+				}
+				continue
+			}
+		}
+
+		if tok == "OR" {
+			// Join the next term onto the previous group
+			if i+1 < len(tokens) && len(q.groups) > 0 {
+				i++
+				q.groups[len(q.groups)-1] = append(q.groups[len(q.groups)-1], parseTerms(tokens[i])...)
+			}
+			continue
+		}
+
+		if exclude && len(tok) > 1 {
+			q.excludes = append(q.excludes, parseTerms(tok[1:])...)
+			continue
+		}
+
+		// A word like "foo-bar" tokenizes to several terms, all required
+		for _, term := range parseTerms(tok) {
+			q.groups = append(q.groups, []searchTerm{term})
+		}
+	}
+
+	return q, nil
+}
+
+// scorePattern matches the comparison after "score", such as ">=0.7".
+var scorePattern = regexp.MustCompile(`^(>=|<=|==|>|<|=)([-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?)$`)
+
+// parseScore parses the comparison after "score", such as ">=0.7".
+func (q *searchQuery) parseScore(expr string) error {
+	match := scorePattern.FindStringSubmatch(expr)
+	if match == nil {
+		return fmt.Errorf("invalid score filter %q", "score"+expr)
+	}
+	op := match[1]
+	value, err := strconv.ParseFloat(match[2], 64)
+	if err != nil {
+		return fmt.Errorf("invalid score filter %q", "score"+expr)
+	}
+
+	switch op {
+	case ">":
+		q.minScore, q.minExclusive = &value, true
+	case ">=":
+		q.minScore, q.minExclusive = &value, false
+	case "<":
+		q.maxScore, q.maxExclusive = &value, true
+	case "<=":
+		q.maxScore, q.maxExclusive = &value, false
+	case "=", "==":
+		q.minScore, q.maxScore = &value, &value
+		q.minExclusive, q.maxExclusive = false, false
+	default:
+		return fmt.Errorf("invalid score filter %q", "score"+expr)
+	}
+	return nil
+}
+
+// excludesStatus reports whether the query excludes sessions with a status.
+func (q *searchQuery) excludesStatus(status string) bool {
+	for _, excluded := range q.excludeStatus {
+		if strings.ToLower(status) == excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesScore reports whether a score satisfies the query's score bounds.
+func (q *searchQuery) matchesScore(score *float64) bool {
+	if q.minScore == nil && q.maxScore == nil {
+		return true
+	}
+	if score == nil {
+		return false
+	}
+	if q.minScore != nil && (*score < *q.minScore || q.minExclusive && *score == *q.minScore) {
+		return false
+	}
+	if q.maxScore != nil && (*score > *q.maxScore || q.maxExclusive && *score == *q.maxScore) {
+		return false
+	}
+	return true
+}
+
+// parseTerms tokenizes a query word, keeping a trailing * as a prefix match.
+func parseTerms(word string) []searchTerm {
+	prefix := strings.HasSuffix(word, "*")
+	words := tokenize(strings.TrimSuffix(word, "*"))
+
+	terms := make([]searchTerm, len(words))
+	for i, w := range words {
+		terms[i] = searchTerm{text: w}
+	}
+	if prefix && len(terms) > 0 {
+		terms[len(terms)-1].prefix = true
+	}
+	return terms
+}
+
+// splitQuery splits a query on whitespace, keeping quoted phrases together.
+func splitQuery(input string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuote := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range input {
+		switch {
+		case r == '"':
+			if inQuote {
+				current.WriteRune(r)
+				flush()
+			} else {
+				flush()
+				current.WriteRune(r)
+			}
+			inQuote = !inQuote
+		case unicode.IsSpace(r) && !inQuote:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// tokenize splits text into index terms the same way sessions are split
+// for embedding, so search and similarity agree on what a word is.
+func tokenize(text string) []string {
+	return embed.Tokenize(text)
+}
+
+// fieldTerm returns the index term for a field filter such as tag:go.
+// Fields are indexed verbatim (lowercased), not tokenized.
+func fieldTerm(field, value string) string {
+	return strings.ToLower(field) + ":" + strings.ToLower(value)
+}
+
+// pairTerm returns the index term for two adjacent words of a field, which
+// lets phrases be matched from the index alone.
+func pairTerm(first, second string) string {
+	return fieldTerm("pair", first+" "+second)
+}
+
+// fileTerms returns the field terms for a file path: the full path and its
+// base name, so file:store.go and file:internal/store/store.go both match.
+func fileTerms(filePath string) []string {
+	if filePath == "" {
+		return nil
+	}
+	terms := []string{fieldTerm("file", filePath)}
+	if base := path.Base(filePath); base != filePath {
+		terms = append(terms, fieldTerm("file", base))
+	}
+	return terms
+}
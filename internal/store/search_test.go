@@ -0,0 +1,236 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+func searchIDs(t *testing.T, store *BoltStore, query string) []string {
+	t.Helper()
+	results, err := store.SearchSessions(query, 0)
+	if err != nil {
+		t.Fatalf("SearchSessions(%q) failed: %v", query, err)
+	}
+	var ids []string
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func scoreOf(v float64) *float64 { return &v }
+
+func setupSearchStore(t *testing.T) (*BoltStore, []*types.Session, func()) {
+	t.Helper()
+	store, cleanup := setupTestStore(t)
+
+	base := time.Now().Add(-time.Hour)
+	sessions := []*types.Session{
+		{
+			ID:         NewULID(),
+			TaskPrompt: "Add connection pooling to the database layer",
+			Summary:    "Pooled postgres connections",
+			Tags:       []string{"database", "go"},
+			Status:     types.StatusCompleted,
+			StartedAt:  base,
+			Outcome:    &types.Outcome{Score: 0.9},
+		},
+		{
+			ID:         NewULID(),
+			TaskPrompt: "Fix flaky test",
+			Summary:    "The database connection was not closed between tests",
+			Tags:       []string{"testing"},
+			Status:     types.StatusCompleted,
+			StartedAt:  base.Add(time.Minute),
+			Outcome:    &types.Outcome{Score: 0.4},
+		},
+		{
+			ID:         NewULID(),
+			TaskPrompt: "Write a pool of workers for the queue",
+			Tags:       []string{"go"},
+			Status:     types.StatusRecording,
+			StartedAt:  base.Add(2 * time.Minute),
+		},
+	}
+	for _, s := range sessions {
+		if err := store.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+	}
+
+	steps := []types.TrajectoryStep{
+		{ToolName: "Read", ToolUseID: "t1", InputSummary: "internal/db/pool.go", OutputSummary: "package db"},
+		{ToolName: "Bash", ToolUseID: "t2", InputSummary: "go test ./...", OutputSummary: "ok"},
+	}
+	for _, step := range steps {
+		if err := store.AppendStep(sessions[0].ID, step); err != nil {
+			t.Fatalf("AppendStep failed: %v", err)
+		}
+	}
+
+	return store, sessions, cleanup
+}
+
+func TestSearchSessions_Ranking(t *testing.T) {
+	store, sessions, cleanup := setupSearchStore(t)
+	defer cleanup()
+
+	// The prompt match outweighs a match in the summary
+	ids := searchIDs(t, store, "database connection")
+	if !equalIDs(ids, []string{sessions[0].ID, sessions[1].ID}) {
+		t.Errorf("expected prompt match ranked first, got %v", ids)
+	}
+
+	results, err := store.SearchSessions("database connection", 0)
+	if err != nil {
+		t.Fatalf("SearchSessions failed: %v", err)
+	}
+	if results[0].Relevance <= results[1].Relevance || results[1].Relevance <= 0 {
+		t.Errorf("expected descending positive relevance, got %v and %v", results[0].Relevance, results[1].Relevance)
+	}
+}
+
+func TestSearchSessions_Syntax(t *testing.T) {
+	store, sessions, cleanup := setupSearchStore(t)
+	defer cleanup()
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"pool*", []string{sessions[0].ID, sessions[2].ID}},
+		{"pool* -database", []string{sessions[2].ID}},
+		{`"connection pooling"`, []string{sessions[0].ID}},
+		{`"pooling connection"`, nil},
+		{"flaky OR queue", []string{sessions[2].ID, sessions[1].ID}}, // shorter session first
+		{"tag:go", []string{sessions[2].ID, sessions[0].ID}},
+		{"tag:go pool*", []string{sessions[0].ID, sessions[2].ID}},
+		{"tool:bash", []string{sessions[0].ID}},
+		{"file:pool.go", []string{sessions[0].ID}},
+		{"file:internal/db/pool.go", []string{sessions[0].ID}},
+		{"status:recording", []string{sessions[2].ID}},
+		{"database score>0.5", []string{sessions[0].ID}},
+		{"database score<=0.4", []string{sessions[1].ID}},
+		{"postgres", []string{sessions[0].ID}},
+		{"status:recording the", []string{sessions[2].ID}}, // stopwords are ignored
+		{`"database connection"`, []string{sessions[1].ID}},
+		{`"connection pooling database"`, []string{sessions[0].ID}}, // stopwords are ignored in phrases too
+		{`"layer pooled"`, nil},                                     // the prompt and summary are separate fields
+		{"tag:go -tag:database", []string{sessions[2].ID}},
+		{"pool* -tool:bash", []string{sessions[2].ID}},
+		{"-status:recording", []string{sessions[1].ID, sessions[0].ID}},
+		{"score>=1e-05", []string{sessions[1].ID, sessions[0].ID}},
+		{"score>0.5", []string{sessions[0].ID}},
+		{"status:completed score<0.5", []string{sessions[1].ID}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			ids := searchIDs(t, store, tc.query)
+			if !equalIDs(ids, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, ids)
+			}
+		})
+	}
+
+	if _, err := store.SearchSessions("score>abc", 0); err == nil {
+		t.Error("expected error for invalid score filter")
+	}
+}
+
+func TestSearchSessions_IndexStaysConsistent(t *testing.T) {
+	store, sessions, cleanup := setupSearchStore(t)
+	defer cleanup()
+
+	// Updating the header replaces its terms but keeps the steps' terms
+	header, err := store.GetSessionHeader(sessions[1].ID)
+	if err != nil {
+		t.Fatalf("GetSessionHeader failed: %v", err)
+	}
+	header.TaskPrompt = "Speed up the linter"
+	header.Summary = ""
+	if err := store.UpdateSession(header); err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+	if ids := searchIDs(t, store, "flaky"); len(ids) != 0 {
+		t.Errorf("expected old prompt to be unindexed, got %v", ids)
+	}
+	if ids := searchIDs(t, store, "linter"); !equalIDs(ids, []string{sessions[1].ID}) {
+		t.Errorf("expected new prompt to be indexed, got %v", ids)
+	}
+	if ids := searchIDs(t, store, "tool:bash"); !equalIDs(ids, []string{sessions[0].ID}) {
+		t.Errorf("expected steps to stay indexed, got %v", ids)
+	}
+
+	// Updating a step replaces its output terms
+	step := types.TrajectoryStep{ToolName: "Bash", ToolUseID: "t2", InputSummary: "go test ./...", OutputSummary: "panic: nil pointer"}
+	if err := store.UpdateStep(sessions[0].ID, step); err != nil {
+		t.Fatalf("UpdateStep failed: %v", err)
+	}
+	if ids := searchIDs(t, store, "panic"); !equalIDs(ids, []string{sessions[0].ID}) {
+		t.Errorf("expected updated step output to be indexed, got %v", ids)
+	}
+
+	// Appending a step indexes it
+	if err := store.AppendStep(sessions[2].ID, types.TrajectoryStep{ToolName: "Grep", InputSummary: "semaphore"}); err != nil {
+		t.Fatalf("AppendStep failed: %v", err)
+	}
+	if ids := searchIDs(t, store, "semaphore tool:grep"); !equalIDs(ids, []string{sessions[2].ID}) {
+		t.Errorf("expected appended step to be indexed, got %v", ids)
+	}
+
+	// Deleting a session removes it
+	if err := store.DeleteSession(sessions[0].ID); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if ids := searchIDs(t, store, "postgres OR panic OR tool:bash"); len(ids) != 0 {
+		t.Errorf("expected deleted session to be unindexed, got %v", ids)
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	q, err := parseSearchQuery(`"Exact Phrase" foo OR bar* -baz tag:Go score>=0.5`)
+	if err != nil {
+		t.Fatalf("parseSearchQuery failed: %v", err)
+	}
+
+	if len(q.phrases) != 1 || len(q.phrases[0]) != 2 || q.phrases[0][0] != "exact" {
+		t.Errorf("unexpected phrases: %v", q.phrases)
+	}
+	if len(q.groups) != 1 || len(q.groups[0]) != 2 || !q.groups[0][1].prefix {
+		t.Errorf("unexpected groups: %v", q.groups)
+	}
+	if len(q.excludes) != 1 || q.excludes[0].text != "baz" {
+		t.Errorf("unexpected excludes: %v", q.excludes)
+	}
+	if len(q.filters) != 1 || q.filters[0] != "tag:go" {
+		t.Errorf("unexpected filters: %v", q.filters)
+	}
+	if q.minScore == nil || *q.minScore != 0.5 || q.minExclusive {
+		t.Errorf("unexpected score bound: %v", q.minScore)
+	}
+	if !q.matchesScore(scoreOf(0.5)) || q.matchesScore(scoreOf(0.4)) || q.matchesScore(nil) {
+		t.Error("score bounds not applied correctly")
+	}
+
+	q, err = parseSearchQuery(`-tag:go -status:scored score>-0.5 score<=1E-3`)
+	if err != nil {
+		t.Fatalf("parseSearchQuery failed: %v", err)
+	}
+	if len(q.excludes) != 0 || len(q.excludeFilters) != 1 || q.excludeFilters[0] != "tag:go" {
+		t.Errorf("expected tag:go excluded as a field, got %v and %v", q.excludes, q.excludeFilters)
+	}
+	if len(q.excludeStatus) != 1 || q.excludeStatus[0] != "scored" {
+		t.Errorf("unexpected excluded statuses: %v", q.excludeStatus)
+	}
+	if q.minScore == nil || *q.minScore != -0.5 || q.maxScore == nil || *q.maxScore != 0.001 {
+		t.Errorf("unexpected score bounds: %v, %v", q.minScore, q.maxScore)
+	}
+	for _, bad := range []string{"score>=0.5.5", "score>=", "score=>0.5"} {
+		if _, err := parseSearchQuery(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucketSteps holds one nested bucket per session, keyed by session ID.
+// Steps within it are keyed by big-endian sequence number, so appending a
+// step doesn't rewrite the rest of the session. The nested bucket's
+// sequence always equals its step count.
+var bucketSteps = []byte("steps")
+
+// bucketStepIndex holds one nested bucket per session mapping each tool use
+// ID to the key of its most recent step, so a step can be found without
+// scanning the session.
+var bucketStepIndex = []byte("step_index")
+
+// ForEachStep streams a session's steps in order without loading the
+// whole trajectory. Returning an error from fn stops the iteration and
+// is returned to the caller.
+func (s *BoltStore) ForEachStep(sessionID string, fn func(step types.TrajectoryStep) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketSessions).Get([]byte(sessionID)) == nil {
+			return ErrSessionNotFound
+		}
+
+		steps := tx.Bucket(bucketSteps).Bucket([]byte(sessionID))
+		if steps == nil {
+			return nil
+		}
+		return steps.ForEach(func(k, v []byte) error {
+			var step types.TrajectoryStep
+			if err := json.Unmarshal(v, &step); err != nil {
+				return fmt.Errorf("failed to unmarshal step: %w", err)
+			}
+			return fn(step)
+		})
+	})
+}
+
+// FindStep returns the most recent step with the given tool use ID.
+func (s *BoltStore) FindStep(sessionID string, toolUseID string) (*types.TrajectoryStep, error) {
+	if toolUseID == "" {
+		return nil, ErrStepNotFound
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *types.TrajectoryStep
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketSessions).Get([]byte(sessionID)) == nil {
+			return ErrSessionNotFound
+		}
+		_, step, err := findStep(tx, sessionID, toolUseID)
+		found = step
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// findStep looks up the most recent step with a tool use ID in the step
+// index, returning the step's key along with the step.
+func findStep(tx *bolt.Tx, sessionID string, toolUseID string) ([]byte, *types.TrajectoryStep, error) {
+	index := tx.Bucket(bucketStepIndex).Bucket([]byte(sessionID))
+	steps := tx.Bucket(bucketSteps).Bucket([]byte(sessionID))
+	if index == nil || steps == nil {
+		return nil, nil, ErrStepNotFound
+	}
+
+	key := index.Get([]byte(toolUseID))
+	if key == nil {
+		return nil, nil, ErrStepNotFound
+	}
+	data := steps.Get(key)
+	if data == nil {
+		return nil, nil, ErrStepNotFound
+	}
+
+	var step types.TrajectoryStep
+	if err := json.Unmarshal(data, &step); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal step: %w", err)
+	}
+	return append([]byte(nil), key...), &step, nil
+}
+
+// loadSession reads a session and assembles its steps.
+func loadSession(tx *bolt.Tx, id []byte) (*types.Session, error) {
+	session, err := loadSessionHeader(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	session.Steps = []types.TrajectoryStep{}
+	err = forEachStoredStep(tx, string(id), func(step types.TrajectoryStep) {
+		session.Steps = append(session.Steps, step)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// loadSessionHeader reads a session without its steps.
+func loadSessionHeader(tx *bolt.Tx, id []byte) (*types.Session, error) {
+	data := tx.Bucket(bucketSessions).Get(id)
+	if data == nil {
+		return nil, ErrSessionNotFound
+	}
+
+	var session types.Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session: %w", err)
+	}
+	session.Steps = nil
+	return &session, nil
+}
+
+// putSession stores a session and its metadata. The session's steps replace
+// any stored steps unless session.Steps is nil, in which case they are kept.
+func putSession(tx *bolt.Tx, session *types.Session) error {
+	// Work out how the searchable text changes before overwriting anything
+	delta := headerTerms(session)
+	if old, err := loadSessionHeader(tx, []byte(session.ID)); err == nil {
+		delta.merge(headerTerms(old), -1)
+	}
+	if session.Steps != nil {
+		if err := forEachStoredStep(tx, session.ID, func(step types.TrajectoryStep) {
+			delta.merge(stepTerms(step), -1)
+		}); err != nil {
+			return err
+		}
+		for _, step := range session.Steps {
+			delta.merge(stepTerms(step), 1)
+		}
+	}
+
+	header := *session
+	header.Steps = nil
+
+	data, err := json.Marshal(&header)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	if err := tx.Bucket(bucketSessions).Put([]byte(session.ID), data); err != nil {
+		return fmt.Errorf("failed to store session: %w", err)
+	}
+
+	if session.Steps != nil {
+		if err := putSteps(tx, session.ID, session.Steps); err != nil {
+			return err
+		}
+	}
+
+	if err := updateSearchIndex(tx, session.ID, delta); err != nil {
+		return err
+	}
+	return putMetadata(tx, session)
+}
+
+// forEachStoredStep calls fn with each stored step of a session.
+func forEachStoredStep(tx *bolt.Tx, sessionID string, fn func(step types.TrajectoryStep)) error {
+	steps := tx.Bucket(bucketSteps).Bucket([]byte(sessionID))
+	if steps == nil {
+		return nil
+	}
+	return steps.ForEach(func(k, v []byte) error {
+		var step types.TrajectoryStep
+		if err := json.Unmarshal(v, &step); err != nil {
+			return fmt.Errorf("failed to unmarshal step: %w", err)
+		}
+		fn(step)
+		return nil
+	})
+}
+
+// putSteps replaces all of a session's stored steps.
+func putSteps(tx *bolt.Tx, sessionID string, steps []types.TrajectoryStep) error {
+	if err := deleteSteps(tx, sessionID); err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		if err := appendStep(tx, sessionID, step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendStep adds a step to the end of a session's steps.
+func appendStep(tx *bolt.Tx, sessionID string, step types.TrajectoryStep) error {
+	steps, err := tx.Bucket(bucketSteps).CreateBucketIfNotExists([]byte(sessionID))
+	if err != nil {
+		return fmt.Errorf("failed to create steps bucket: %w", err)
+	}
+
+	seq, err := steps.NextSequence()
+	if err != nil {
+		return fmt.Errorf("failed to allocate step sequence: %w", err)
+	}
+
+	data, err := json.Marshal(step)
+	if err != nil {
+		return fmt.Errorf("failed to marshal step: %w", err)
+	}
+	if err := steps.Put(stepKey(seq), data); err != nil {
+		return fmt.Errorf("failed to store step: %w", err)
+	}
+	return indexStep(tx, sessionID, step.ToolUseID, stepKey(seq))
+}
+
+// indexStep points a tool use ID at the key of its latest step.
+func indexStep(tx *bolt.Tx, sessionID string, toolUseID string, key []byte) error {
+	if toolUseID == "" {
+		return nil
+	}
+	parent := tx.Bucket(bucketStepIndex)
+	if parent == nil {
+		return nil // Migrations before the index existed; it is built once it does
+	}
+	index, err := parent.CreateBucketIfNotExists([]byte(sessionID))
+	if err != nil {
+		return fmt.Errorf("failed to create step index bucket: %w", err)
+	}
+	if err := index.Put([]byte(toolUseID), key); err != nil {
+		return fmt.Errorf("failed to index step: %w", err)
+	}
+	return nil
+}
+
+// deleteSteps removes a session's steps and their index.
+func deleteSteps(tx *bolt.Tx, sessionID string) error {
+	for _, name := range [][]byte{bucketSteps, bucketStepIndex} {
+		parent := tx.Bucket(name)
+		if parent == nil || parent.Bucket([]byte(sessionID)) == nil {
+			continue
+		}
+		if err := parent.DeleteBucket([]byte(sessionID)); err != nil {
+			return fmt.Errorf("failed to clear steps: %w", err)
+		}
+	}
+	return nil
+}
+
+// stepCount returns the number of stored steps for a session.
+func stepCount(tx *bolt.Tx, sessionID string) int {
+	steps := tx.Bucket(bucketSteps).Bucket([]byte(sessionID))
+	if steps == nil {
+		return 0
+	}
+	return int(steps.Sequence())
+}
+
+func stepKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// migrateSteps moves steps embedded in session records into the steps bucket.
+func migrateSteps(tx *bolt.Tx) error {
+	sessions := tx.Bucket(bucketSessions)
+
+	// Collect first; bbolt doesn't allow modifying a bucket during ForEach
+	var embedded []*types.Session
+	err := sessions.ForEach(func(k, v []byte) error {
+		var session types.Session
+		if err := json.Unmarshal(v, &session); err != nil {
+			return nil // Skip malformed entries
+		}
+		embedded = append(embedded, &session)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Step counts are unchanged, so the metadata index is left alone
+	for _, session := range embedded {
+		steps := session.Steps
+		session.Steps = nil
+
+		data, err := json.Marshal(session)
+		if err != nil {
+			return fmt.Errorf("failed to marshal session: %w", err)
+		}
+		if err := sessions.Put([]byte(session.ID), data); err != nil {
+			return fmt.Errorf("failed to migrate session %s: %w", session.ID, err)
+		}
+		if err := putSteps(tx, session.ID, steps); err != nil {
+			return fmt.Errorf("failed to migrate session %s: %w", session.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestForEachStep(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	session := createTestSession(NewULID())
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		step := types.TrajectoryStep{ToolName: "Read", InputSummary: fmt.Sprintf("file%d.go", i)}
+		if err := store.AppendStep(session.ID, step); err != nil {
+			t.Fatalf("failed to append step: %v", err)
+		}
+	}
+
+	var seen []string
+	err := store.ForEachStep(session.ID, func(step types.TrajectoryStep) error {
+		seen = append(seen, step.InputSummary)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachStep failed: %v", err)
+	}
+	if len(seen) != 5 || seen[0] != "file0.go" || seen[4] != "file4.go" {
+		t.Errorf("expected steps in order, got %v", seen)
+	}
+
+	// Returning an error stops the iteration
+	stop := errors.New("stop")
+	count := 0
+	err = store.ForEachStep(session.ID, func(step types.TrajectoryStep) error {
+		count++
+		if count == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 2 {
+		t.Errorf("expected iteration to stop after 2 steps with the callback error, got %d, %v", count, err)
+	}
+
+	if err := store.ForEachStep("missing", func(types.TrajectoryStep) error { return nil }); err != ErrSessionNotFound {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestFindStep(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	session := createTestSession(NewULID())
+	store.CreateSession(session)
+	store.AppendStep(session.ID, types.TrajectoryStep{ToolName: "Bash", ToolUseID: "toolu_1", InputSummary: "first"})
+	store.AppendStep(session.ID, types.TrajectoryStep{ToolName: "Bash", ToolUseID: "toolu_1", InputSummary: "second"})
+
+	step, err := store.FindStep(session.ID, "toolu_1")
+	if err != nil {
+		t.Fatalf("FindStep failed: %v", err)
+	}
+	if step.InputSummary != "second" {
+		t.Errorf("expected most recent step, got %q", step.InputSummary)
+	}
+
+	if _, err := store.FindStep(session.ID, "toolu_missing"); err != ErrStepNotFound {
+		t.Errorf("expected ErrStepNotFound, got %v", err)
+	}
+
+	// Replacing the steps replaces the index
+	got, _ := store.GetSession(session.ID)
+	got.Steps = []types.TrajectoryStep{{ToolName: "Read", ToolUseID: "toolu_2"}}
+	store.UpdateSession(got)
+	if _, err := store.FindStep(session.ID, "toolu_1"); err != ErrStepNotFound {
+		t.Errorf("expected the replaced step to be unindexed, got %v", err)
+	}
+	if step, err := store.FindStep(session.ID, "toolu_2"); err != nil || step.ToolName != "Read" {
+		t.Errorf("expected the new step, got %+v (%v)", step, err)
+	}
+
+	// Deleting the session drops its index
+	store.DeleteSession(session.ID)
+	store.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketStepIndex).Bucket([]byte(session.ID)) != nil {
+			t.Error("expected the step index to be deleted with the session")
+		}
+		return nil
+	})
+}
+
+func TestUpdateSession_KeepsStepsWhenNotLoaded(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	session := createTestSession(NewULID())
+	store.CreateSession(session)
+	store.AppendStep(session.ID, types.TrajectoryStep{ToolName: "Read"})
+
+	header, err := store.GetSessionHeader(session.ID)
+	if err != nil {
+		t.Fatalf("GetSessionHeader failed: %v", err)
+	}
+	if header.Steps != nil {
+		t.Errorf("expected header without steps, got %d", len(header.Steps))
+	}
+
+	header.Summary = "updated"
+	if err := store.UpdateSession(header); err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+
+	got, _ := store.GetSession(session.ID)
+	if got.Summary != "updated" || len(got.Steps) != 1 {
+		t.Errorf("expected summary updated and step kept, got %q with %d steps", got.Summary, len(got.Steps))
+	}
+
+	// Loaded steps replace the stored ones
+	got.Steps = got.Steps[:0]
+	store.UpdateSession(got)
+	list, _ := store.ListSessions(1, 0)
+	if got, _ := store.GetSession(session.ID); len(got.Steps) != 0 || list[0].StepCount != 0 {
+		t.Errorf("expected steps replaced, got %d (metadata %d)", len(got.Steps), list[0].StepCount)
+	}
+}
+
+func TestMigrateEmbeddedSteps(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Write a database the way older versions did: steps inside the session record
+	db, err := bolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	session := createTestSession(NewULID())
+	session.Steps = []types.TrajectoryStep{
+		{ToolName: "Read", ToolUseID: "toolu_1", InputSummary: "a.go"},
+		{ToolName: "Edit", ToolUseID: "toolu_2", InputSummary: "a.go"},
+	}
+	db.Update(func(tx *bolt.Tx) error {
+		sessions, _ := tx.CreateBucketIfNotExists(bucketSessions)
+		index, _ := tx.CreateBucketIfNotExists(bucketIndex)
+		data, _ := json.Marshal(session)
+		sessions.Put([]byte(session.ID), data)
+		meta, _ := json.Marshal(session.ToMetadata())
+		return index.Put([]byte(session.ID), meta)
+	})
+	db.Close()
+
+	store, err := NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	got, err := store.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if len(got.Steps) != 2 || got.Steps[1].ToolName != "Edit" {
+		t.Errorf("expected migrated steps, got %+v", got.Steps)
+	}
+
+	store.db.View(func(tx *bolt.Tx) error {
+		var stored types.Session
+		json.Unmarshal(tx.Bucket(bucketSessions).Get([]byte(session.ID)), &stored)
+		if len(stored.Steps) != 0 {
+			t.Errorf("expected steps removed from session record, found %d", len(stored.Steps))
+		}
+		return nil
+	})
+
+	// Migrated steps are indexed by tool use ID
+	if step, err := store.FindStep(session.ID, "toolu_2"); err != nil || step.ToolName != "Edit" {
+		t.Errorf("expected to find the migrated step by tool use ID, got %+v (%v)", step, err)
+	}
+
+	// New steps continue the migrated sequence
+	store.AppendStep(session.ID, types.TrajectoryStep{ToolName: "Bash"})
+	got, _ = store.GetSession(session.ID)
+	if len(got.Steps) != 3 || got.Steps[2].ToolName != "Bash" {
+		t.Errorf("expected appended step after migrated ones, got %+v", got.Steps)
+	}
+	list, _ := store.ListSessions(1, 0)
+	if list[0].StepCount != 3 {
+		t.Errorf("expected step count 3, got %d", list[0].StepCount)
+	}
+}
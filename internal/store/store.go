@@ -14,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/johncarpenter/trajectory-memory/internal/embed"
 	"github.com/johncarpenter/trajectory-memory/internal/types"
 	bolt "go.etcd.io/bbolt"
 )
@@ -25,6 +26,8 @@ var (
 	ErrNoActiveSession = errors.New("no active session")
 	// ErrSessionAlreadyActive is returned when trying to start while recording.
 	ErrSessionAlreadyActive = errors.New("a session is already recording")
+	// ErrStepNotFound is returned when no step matches a tool use ID.
+	ErrStepNotFound = errors.New("step not found")
 )
 
 // Bucket names
@@ -35,18 +38,59 @@ var (
 	bucketStrategyUsage  = []byte("strategy_usage")
 )
 
+// defaultActiveKey is the active bucket key used for recordings that are not
+// bound to a specific Claude session. It matches the key used by older
+// databases, which only supported a single recording at a time.
+const defaultActiveKey = "current"
+
+// Active bucket key prefixes. Claude session IDs are prefixed so none can
+// collide with defaultActiveKey; pending recordings are still waiting for
+// the hook event that tells them which Claude session started them; owner
+// keys remember which MCP client started a recording, so a restarted MCP
+// server can find it again.
+const (
+	claudeKeyPrefix  = "claude:"
+	pendingKeyPrefix = "pending:"
+	ownerKeyPrefix   = "owner:"
+)
+
+// activeKey returns the active bucket key for a Claude session ID.
+func activeKey(claudeSessionID string) []byte {
+	if claudeSessionID == "" {
+		return []byte(defaultActiveKey)
+	}
+	return []byte(claudeKeyPrefix + claudeSessionID)
+}
+
 // Store defines the interface for session persistence.
 type Store interface {
 	CreateSession(s *types.Session) error
 	GetSession(id string) (*types.Session, error)
+	GetSessionHeader(id string) (*types.Session, error)
 	UpdateSession(s *types.Session) error
 	AppendStep(sessionID string, step types.TrajectoryStep) error
+	UpdateStep(sessionID string, step types.TrajectoryStep) error
+	FindStep(sessionID string, toolUseID string) (*types.TrajectoryStep, error)
+	ForEachStep(sessionID string, fn func(step types.TrajectoryStep) error) error
 	ListSessions(limit int, offset int) ([]types.SessionMetadata, error)
-	SearchSessions(query string, limit int) ([]types.SessionMetadata, error)
+	SearchSessions(query string, limit int) ([]SearchResult, error)
+	QuerySessions(q Query) ([]types.SessionMetadata, error)
+	SimilarSessions(text string, q Query) ([]SimilarResult, error)
 	SetOutcome(sessionID string, outcome types.Outcome) error
+	SetAutoOutcome(sessionID string, outcome *types.AutoOutcome) error
+	RecordComparison(c *types.Comparison) error
+	ListComparisons(tag string) ([]types.Comparison, error)
 	GetActiveSession() (*types.Session, error)
 	SetActiveSession(sessionID string) error
 	ClearActiveSession() error
+	GetActiveSessionFor(claudeSessionID string) (*types.Session, error)
+	SetActiveSessionFor(claudeSessionID string, sessionID string) error
+	ClearActiveSessionFor(claudeSessionID string) error
+	ListActiveSessions() (map[string]string, error)
+	SetPendingSession(owner string, sessionID string) error
+	GetRecordingFor(owner string) (*types.Session, error)
+	BindPendingSession(claudeSessionID string, sessionID string) error
+	ClearRecording(sessionID string) error
 	DeleteSession(id string) error
 	ExportAll(w io.Writer) error
 	ImportAll(r io.Reader) error
@@ -55,8 +99,9 @@ type Store interface {
 
 // BoltStore implements Store using BBolt.
 type BoltStore struct {
-	db *bolt.DB
-	mu sync.RWMutex
+	db       *bolt.DB
+	mu       sync.RWMutex
+	embedder embed.Embedder
 }
 
 // NewBoltStore creates a new BBolt-backed store.
@@ -67,26 +112,18 @@ func NewBoltStore(dbPath string) (*BoltStore, error) {
 		return nil, fmt.Errorf("failed to create db directory: %w", err)
 	}
 
-	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	db, err := openDB(dbPath, &bolt.Options{Timeout: 1 * time.Second})
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	// Create buckets
-	err = db.Update(func(tx *bolt.Tx) error {
-		for _, bucket := range [][]byte{bucketSessions, bucketActive, bucketIndex, bucketStrategyUsage} {
-			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
-				return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
-			}
-		}
-		return nil
-	})
-	if err != nil {
+	// Create or upgrade the schema
+	if err := migrate(db); err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	return &BoltStore{db: db}, nil
+	return &BoltStore{db: db, embedder: embed.Default()}, nil
 }
 
 // CreateSession creates a new session in the store.
@@ -95,138 +132,141 @@ func (s *BoltStore) CreateSession(session *types.Session) error {
 	defer s.mu.Unlock()
 
 	return s.db.Update(func(tx *bolt.Tx) error {
-		sessions := tx.Bucket(bucketSessions)
-		index := tx.Bucket(bucketIndex)
-
 		// Check if session already exists
-		if sessions.Get([]byte(session.ID)) != nil {
+		if tx.Bucket(bucketSessions).Get([]byte(session.ID)) != nil {
 			return fmt.Errorf("session %s already exists", session.ID)
 		}
 
-		// Store the full session
-		data, err := json.Marshal(session)
-		if err != nil {
-			return fmt.Errorf("failed to marshal session: %w", err)
-		}
-		if err := sessions.Put([]byte(session.ID), data); err != nil {
-			return fmt.Errorf("failed to store session: %w", err)
+		// Store the session, its steps, the metadata index and its embedding
+		if err := putSession(tx, session); err != nil {
+			return err
 		}
+		return putVector(tx, s.embedder, session)
+	})
+}
 
-		// Store the metadata index
-		meta := session.ToMetadata()
-		metaData, err := json.Marshal(meta)
-		if err != nil {
-			return fmt.Errorf("failed to marshal metadata: %w", err)
-		}
-		if err := index.Put([]byte(session.ID), metaData); err != nil {
-			return fmt.Errorf("failed to store metadata: %w", err)
-		}
+// GetSession retrieves a session by ID, assembling its steps.
+func (s *BoltStore) GetSession(id string) (*types.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-		return nil
+	var session *types.Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		session, err = loadSession(tx, []byte(id))
+		return err
 	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
 }
 
-// GetSession retrieves a session by ID.
-func (s *BoltStore) GetSession(id string) (*types.Session, error) {
+// GetSessionHeader retrieves a session by ID without loading its steps.
+// Steps is nil on the returned session, so passing it back to UpdateSession
+// leaves the stored steps untouched.
+func (s *BoltStore) GetSessionHeader(id string) (*types.Session, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var session types.Session
+	var session *types.Session
 	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucketSessions)
-		data := b.Get([]byte(id))
-		if data == nil {
-			return ErrSessionNotFound
-		}
-		return json.Unmarshal(data, &session)
+		var err error
+		session, err = loadSessionHeader(tx, []byte(id))
+		return err
 	})
 	if err != nil {
 		return nil, err
 	}
-	return &session, nil
+	return session, nil
 }
 
-// UpdateSession updates an existing session.
+// UpdateSession updates an existing session. Its steps replace the stored
+// steps unless session.Steps is nil.
 func (s *BoltStore) UpdateSession(session *types.Session) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	return s.db.Update(func(tx *bolt.Tx) error {
-		sessions := tx.Bucket(bucketSessions)
-		index := tx.Bucket(bucketIndex)
-
 		// Check if session exists
-		if sessions.Get([]byte(session.ID)) == nil {
+		if tx.Bucket(bucketSessions).Get([]byte(session.ID)) == nil {
 			return ErrSessionNotFound
 		}
 
-		// Update the full session
-		data, err := json.Marshal(session)
-		if err != nil {
-			return fmt.Errorf("failed to marshal session: %w", err)
+		// Update the session, its steps, the metadata index and its embedding
+		if err := putSession(tx, session); err != nil {
+			return err
 		}
-		if err := sessions.Put([]byte(session.ID), data); err != nil {
-			return fmt.Errorf("failed to update session: %w", err)
+		if err := syncStrategyUsage(tx, session); err != nil {
+			return err
 		}
+		return putVector(tx, s.embedder, session)
+	})
+}
 
-		// Update the metadata index
-		meta := session.ToMetadata()
-		metaData, err := json.Marshal(meta)
+// AppendStep adds a step to an existing session.
+func (s *BoltStore) AppendStep(sessionID string, step types.TrajectoryStep) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		session, err := loadSessionHeader(tx, []byte(sessionID))
 		if err != nil {
-			return fmt.Errorf("failed to marshal metadata: %w", err)
+			return err
 		}
-		if err := index.Put([]byte(session.ID), metaData); err != nil {
-			return fmt.Errorf("failed to update metadata: %w", err)
+
+		// Truncate input/output summaries
+		step.InputSummary = types.TruncateString(step.InputSummary, types.MaxInputSummaryLen)
+		step.OutputSummary = types.TruncateString(step.OutputSummary, types.MaxOutputSummaryLen)
+
+		// Append step without rewriting the rest of the trajectory
+		if err := appendStep(tx, sessionID, step); err != nil {
+			return err
+		}
+		if err := updateSearchIndex(tx, sessionID, stepTerms(step)); err != nil {
+			return err
 		}
 
-		return nil
+		// Update metadata (step count changed)
+		return putMetadata(tx, session)
 	})
 }
 
-// AppendStep adds a step to an existing session.
-func (s *BoltStore) AppendStep(sessionID string, step types.TrajectoryStep) error {
+// UpdateStep replaces the most recent step with the same ToolUseID.
+func (s *BoltStore) UpdateStep(sessionID string, step types.TrajectoryStep) error {
+	if step.ToolUseID == "" {
+		return ErrStepNotFound
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	return s.db.Update(func(tx *bolt.Tx) error {
-		sessions := tx.Bucket(bucketSessions)
-		index := tx.Bucket(bucketIndex)
-
-		// Get existing session
-		data := sessions.Get([]byte(sessionID))
-		if data == nil {
+		if tx.Bucket(bucketSessions).Get([]byte(sessionID)) == nil {
 			return ErrSessionNotFound
 		}
 
-		var session types.Session
-		if err := json.Unmarshal(data, &session); err != nil {
-			return fmt.Errorf("failed to unmarshal session: %w", err)
+		key, old, err := findStep(tx, sessionID, step.ToolUseID)
+		if err != nil {
+			return err
 		}
 
-		// Truncate input/output summaries
 		step.InputSummary = types.TruncateString(step.InputSummary, types.MaxInputSummaryLen)
 		step.OutputSummary = types.TruncateString(step.OutputSummary, types.MaxOutputSummaryLen)
 
-		// Append step
-		session.Steps = append(session.Steps, step)
-
-		// Save updated session
-		updatedData, err := json.Marshal(session)
-		if err != nil {
-			return fmt.Errorf("failed to marshal session: %w", err)
-		}
-		if err := sessions.Put([]byte(sessionID), updatedData); err != nil {
-			return fmt.Errorf("failed to update session: %w", err)
+		delta := stepTerms(step)
+		delta.merge(stepTerms(*old), -1)
+		if err := updateSearchIndex(tx, sessionID, delta); err != nil {
+			return err
 		}
 
-		// Update metadata (step count changed)
-		meta := session.ToMetadata()
-		metaData, err := json.Marshal(meta)
+		// Step count is unchanged, so the metadata index doesn't need updating
+		data, err := json.Marshal(step)
 		if err != nil {
-			return fmt.Errorf("failed to marshal metadata: %w", err)
+			return fmt.Errorf("failed to marshal step: %w", err)
 		}
-		if err := index.Put([]byte(sessionID), metaData); err != nil {
-			return fmt.Errorf("failed to update metadata: %w", err)
+		if err := tx.Bucket(bucketSteps).Bucket([]byte(sessionID)).Put(key, data); err != nil {
+			return fmt.Errorf("failed to update step: %w", err)
 		}
 
 		return nil
@@ -270,125 +310,186 @@ func (s *BoltStore) ListSessions(limit int, offset int) ([]types.SessionMetadata
 	return results, nil
 }
 
-// SearchSessions searches sessions by keyword in task_prompt, summary, and tags.
-func (s *BoltStore) SearchSessions(query string, limit int) ([]types.SessionMetadata, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// SetOutcome sets or updates the outcome for a session.
+func (s *BoltStore) SetOutcome(sessionID string, outcome types.Outcome) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	query = strings.ToLower(query)
-	var results []types.SessionMetadata
+	return s.db.Update(func(tx *bolt.Tx) error {
+		session, err := loadSessionHeader(tx, []byte(sessionID))
+		if err != nil {
+			return err
+		}
 
-	err := s.db.View(func(tx *bolt.Tx) error {
-		sessions := tx.Bucket(bucketSessions)
-		c := sessions.Cursor()
+		// Set outcome and update status
+		session.Outcome = &outcome
+		session.Status = types.StatusScored
 
-		// Search through all sessions (reverse order for most recent first)
-		for k, v := c.Last(); k != nil && len(results) < limit; k, v = c.Prev() {
-			var session types.Session
-			if err := json.Unmarshal(v, &session); err != nil {
-				continue
-			}
+		// Save updated session (steps are left as stored)
+		if err := putSession(tx, session); err != nil {
+			return err
+		}
+		return syncStrategyUsage(tx, session)
+	})
+}
 
-			// Case-insensitive search across multiple fields
-			if s.matchesQuery(&session, query) {
-				results = append(results, session.ToMetadata())
-			}
+// SetAutoOutcome sets or clears the provisional auto-scored outcome for a
+// session. Unlike SetOutcome, the session's status is left unchanged.
+func (s *BoltStore) SetAutoOutcome(sessionID string, outcome *types.AutoOutcome) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		session, err := loadSessionHeader(tx, []byte(sessionID))
+		if err != nil {
+			return err
 		}
-		return nil
+
+		session.AutoOutcome = outcome
+		return putSession(tx, session)
 	})
-	if err != nil {
-		return nil, err
-	}
-	return results, nil
 }
 
-// matchesQuery checks if a session matches the search query.
-func (s *BoltStore) matchesQuery(session *types.Session, query string) bool {
-	// Check task prompt
-	if strings.Contains(strings.ToLower(session.TaskPrompt), query) {
-		return true
-	}
-	// Check summary
-	if strings.Contains(strings.ToLower(session.Summary), query) {
-		return true
-	}
-	// Check tags
-	for _, tag := range session.Tags {
-		if strings.Contains(strings.ToLower(tag), query) {
-			return true
+// SetGitOutcome sets or clears the outcome derived from a session's git
+// history. Like SetAutoOutcome, the session's status is left unchanged.
+func (s *BoltStore) SetGitOutcome(sessionID string, outcome *types.AutoOutcome) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		session, err := loadSessionHeader(tx, []byte(sessionID))
+		if err != nil {
+			return err
 		}
+
+		session.GitOutcome = outcome
+		return putSession(tx, session)
+	})
+}
+
+// GetActiveSession returns the recording session that is not bound to a
+// specific Claude session, if any.
+func (s *BoltStore) GetActiveSession() (*types.Session, error) {
+	return s.GetActiveSessionFor("")
+}
+
+// SetActiveSession marks a session as the default (unbound) recording.
+func (s *BoltStore) SetActiveSession(sessionID string) error {
+	return s.SetActiveSessionFor("", sessionID)
+}
+
+// ClearActiveSession clears the default (unbound) recording marker.
+func (s *BoltStore) ClearActiveSession() error {
+	return s.ClearActiveSessionFor("")
+}
+
+// GetActiveSessionFor returns the session recording for a Claude session ID.
+// An empty claudeSessionID refers to the default (unbound) recording.
+func (s *BoltStore) GetActiveSessionFor(claudeSessionID string) (*types.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var session *types.Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		active := tx.Bucket(bucketActive)
+		sessionID := active.Get(activeKey(claudeSessionID))
+		if len(sessionID) == 0 {
+			return ErrNoActiveSession
+		}
+
+		var err error
+		session, err = loadSession(tx, sessionID)
+		if err == ErrSessionNotFound {
+			return ErrNoActiveSession
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	return false
+	return session, nil
 }
 
-// SetOutcome sets or updates the outcome for a session.
-func (s *BoltStore) SetOutcome(sessionID string, outcome types.Outcome) error {
+// SetActiveSessionFor binds a recording session to a Claude session ID.
+// Each Claude session may have at most one recording at a time, but
+// different Claude sessions can record concurrently.
+func (s *BoltStore) SetActiveSessionFor(claudeSessionID string, sessionID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	return s.db.Update(func(tx *bolt.Tx) error {
+		active := tx.Bucket(bucketActive)
 		sessions := tx.Bucket(bucketSessions)
-		index := tx.Bucket(bucketIndex)
 
-		// Get existing session
-		data := sessions.Get([]byte(sessionID))
-		if data == nil {
+		// Check if session exists
+		if sessions.Get([]byte(sessionID)) == nil {
 			return ErrSessionNotFound
 		}
 
-		var session types.Session
-		if err := json.Unmarshal(data, &session); err != nil {
-			return fmt.Errorf("failed to unmarshal session: %w", err)
+		// Check if this Claude session is already recording something else
+		key := activeKey(claudeSessionID)
+		current := active.Get(key)
+		if len(current) > 0 && string(current) != sessionID {
+			return ErrSessionAlreadyActive
 		}
 
-		// Set outcome and update status
-		session.Outcome = &outcome
-		session.Status = types.StatusScored
+		return active.Put(key, []byte(sessionID))
+	})
+}
 
-		// Save updated session
-		updatedData, err := json.Marshal(session)
-		if err != nil {
-			return fmt.Errorf("failed to marshal session: %w", err)
-		}
-		if err := sessions.Put([]byte(sessionID), updatedData); err != nil {
-			return fmt.Errorf("failed to update session: %w", err)
-		}
+// ClearActiveSessionFor removes the recording binding for a Claude session ID.
+func (s *BoltStore) ClearActiveSessionFor(claudeSessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		// Update metadata
-		meta := session.ToMetadata()
-		metaData, err := json.Marshal(meta)
-		if err != nil {
-			return fmt.Errorf("failed to marshal metadata: %w", err)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		active := tx.Bucket(bucketActive)
+		return active.Delete(activeKey(claudeSessionID))
+	})
+}
+
+// SetPendingSession marks a session as recording before its Claude session
+// is known. It receives no steps until BindPendingSession binds it. A
+// non-empty owner identifies the MCP client that started it, for
+// GetRecordingFor.
+func (s *BoltStore) SetPendingSession(owner string, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketSessions).Get([]byte(sessionID)) == nil {
+			return ErrSessionNotFound
 		}
-		if err := index.Put([]byte(sessionID), metaData); err != nil {
-			return fmt.Errorf("failed to update metadata: %w", err)
+		active := tx.Bucket(bucketActive)
+		if owner != "" {
+			if err := active.Put([]byte(ownerKeyPrefix+owner), []byte(sessionID)); err != nil {
+				return err
+			}
 		}
-
-		return nil
+		return active.Put([]byte(pendingKeyPrefix+sessionID), []byte(sessionID))
 	})
 }
 
-// GetActiveSession returns the currently recording session, if any.
-func (s *BoltStore) GetActiveSession() (*types.Session, error) {
+// GetRecordingFor returns the recording an MCP client started, pending or
+// bound, without its steps. It returns ErrNoActiveSession once the
+// recording has been stopped.
+func (s *BoltStore) GetRecordingFor(owner string) (*types.Session, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var session *types.Session
 	err := s.db.View(func(tx *bolt.Tx) error {
-		active := tx.Bucket(bucketActive)
-		sessionID := active.Get([]byte("current"))
-		if sessionID == nil || len(sessionID) == 0 {
+		sessionID := tx.Bucket(bucketActive).Get([]byte(ownerKeyPrefix + owner))
+		if len(sessionID) == 0 {
 			return ErrNoActiveSession
 		}
 
-		sessions := tx.Bucket(bucketSessions)
-		data := sessions.Get(sessionID)
-		if data == nil {
+		var err error
+		session, err = loadSessionHeader(tx, sessionID)
+		if err == ErrSessionNotFound || (err == nil && session.Status != types.StatusRecording) {
 			return ErrNoActiveSession
 		}
-
-		session = &types.Session{}
-		return json.Unmarshal(data, session)
+		return err
 	})
 	if err != nil {
 		return nil, err
@@ -396,39 +497,131 @@ func (s *BoltStore) GetActiveSession() (*types.Session, error) {
 	return session, nil
 }
 
-// SetActiveSession sets the active recording session.
-func (s *BoltStore) SetActiveSession(sessionID string) error {
+// BindPendingSession binds a pending recording to the Claude session that
+// started it, taken from a hook event, and records the Claude session ID on
+// the session. An empty claudeSessionID binds it as the default recording.
+// Binding a recording again to the same Claude session is a no-op.
+//
+// A Claude session only starts a recording once its MCP server has none,
+// so a recording still bound to it is stale, for instance left behind by a
+// server that exited without stopping it. It is marked completed and
+// replaced.
+func (s *BoltStore) BindPendingSession(claudeSessionID string, sessionID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	return s.db.Update(func(tx *bolt.Tx) error {
 		active := tx.Bucket(bucketActive)
-		sessions := tx.Bucket(bucketSessions)
+		key := activeKey(claudeSessionID)
+		current := active.Get(key)
+		if string(current) == sessionID {
+			return nil
+		}
 
-		// Check if session exists
-		if sessions.Get([]byte(sessionID)) == nil {
-			return ErrSessionNotFound
+		pending := []byte(pendingKeyPrefix + sessionID)
+		if active.Get(pending) == nil {
+			return ErrNoActiveSession
+		}
+		if len(current) > 0 {
+			if err := closeStaleRecording(tx, string(current)); err != nil {
+				return err
+			}
 		}
 
-		// Check if there's already an active session
-		current := active.Get([]byte("current"))
-		if current != nil && len(current) > 0 && string(current) != sessionID {
-			return ErrSessionAlreadyActive
+		session, err := loadSessionHeader(tx, []byte(sessionID))
+		if err != nil {
+			return err
+		}
+		session.ClaudeSessionID = claudeSessionID
+		if err := putSession(tx, session); err != nil {
+			return err
 		}
 
-		return active.Put([]byte("current"), []byte(sessionID))
+		if err := active.Delete(pending); err != nil {
+			return err
+		}
+		return active.Put(key, []byte(sessionID))
 	})
 }
 
-// ClearActiveSession clears the active session marker.
-func (s *BoltStore) ClearActiveSession() error {
+// closeStaleRecording marks a recording completed and removes its bindings.
+func closeStaleRecording(tx *bolt.Tx, sessionID string) error {
+	if err := clearBindings(tx.Bucket(bucketActive), sessionID); err != nil {
+		return err
+	}
+	session, err := loadSessionHeader(tx, []byte(sessionID))
+	if err == ErrSessionNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if session.Status != types.StatusRecording {
+		return nil
+	}
+	now := time.Now()
+	session.Status = types.StatusCompleted
+	session.CompletedAt = &now
+	return putSession(tx, session)
+}
+
+// ClearRecording removes every recording binding, pending or not, that
+// points at a session.
+func (s *BoltStore) ClearRecording(sessionID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	return s.db.Update(func(tx *bolt.Tx) error {
-		active := tx.Bucket(bucketActive)
-		return active.Delete([]byte("current"))
+		return clearBindings(tx.Bucket(bucketActive), sessionID)
+	})
+}
+
+// clearBindings deletes the active bucket entries that point at a session.
+func clearBindings(active *bolt.Bucket, sessionID string) error {
+	var staleKeys [][]byte
+	if err := active.ForEach(func(k, v []byte) error {
+		if string(v) == sessionID {
+			staleKeys = append(staleKeys, append([]byte(nil), k...))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, k := range staleKeys {
+		if err := active.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListActiveSessions returns all recording bindings, keyed by Claude session
+// ID. The default (unbound) recording is returned under the empty key.
+// Pending recordings aren't bound yet and are left out.
+func (s *BoltStore) ListActiveSessions() (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bindings := make(map[string]string)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketActive).ForEach(func(k, v []byte) error {
+			if len(v) == 0 {
+				return nil
+			}
+			key := string(k)
+			switch {
+			case key == defaultActiveKey:
+				bindings[""] = string(v)
+			case strings.HasPrefix(key, claudeKeyPrefix):
+				bindings[strings.TrimPrefix(key, claudeKeyPrefix)] = string(v)
+			}
+			return nil
+		})
 	})
+	if err != nil {
+		return nil, err
+	}
+	return bindings, nil
 }
 
 // DeleteSession removes a session from the store.
@@ -438,7 +631,6 @@ func (s *BoltStore) DeleteSession(id string) error {
 
 	return s.db.Update(func(tx *bolt.Tx) error {
 		sessions := tx.Bucket(bucketSessions)
-		index := tx.Bucket(bucketIndex)
 		active := tx.Bucket(bucketActive)
 
 		// Check if session exists
@@ -446,19 +638,28 @@ func (s *BoltStore) DeleteSession(id string) error {
 			return ErrSessionNotFound
 		}
 
-		// Clear active if this is the active session
-		current := active.Get([]byte("current"))
-		if current != nil && string(current) == id {
-			if err := active.Delete([]byte("current")); err != nil {
-				return err
-			}
+		// Clear any recording bindings that point at this session
+		if err := clearBindings(active, id); err != nil {
+			return err
 		}
 
-		// Delete from both buckets
+		// Delete the session, its steps, its comparisons and its index entries
 		if err := sessions.Delete([]byte(id)); err != nil {
 			return err
 		}
-		return index.Delete([]byte(id))
+		if err := removeFromSearchIndex(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketVectors).Delete([]byte(id)); err != nil {
+			return err
+		}
+		if err := deleteSteps(tx, id); err != nil {
+			return err
+		}
+		if err := deleteComparisons(tx, id); err != nil {
+			return err
+		}
+		return deleteMetadata(tx, id)
 	})
 }
 
@@ -471,9 +672,9 @@ func (s *BoltStore) ExportAll(w io.Writer) error {
 		b := tx.Bucket(bucketSessions)
 		c := b.Cursor()
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			var session types.Session
-			if err := json.Unmarshal(v, &session); err != nil {
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			session, err := loadSession(tx, k)
+			if err != nil {
 				continue
 			}
 			line, err := json.Marshal(session)
@@ -595,7 +796,7 @@ func (s *BoltStore) GetStrategyStats(tag string) (map[string]*types.Strategy, er
 				}
 			}
 
-			if usage.Score > 0 {
+			if usage.IsScored() {
 				scoreSums[usage.StrategyName] += usage.Score
 				scoreCounts[usage.StrategyName]++
 			}
@@ -606,6 +807,7 @@ func (s *BoltStore) GetStrategyStats(tag string) (map[string]*types.Strategy, er
 		for name, strat := range stats {
 			if count := scoreCounts[name]; count > 0 {
 				strat.AvgScore = scoreSums[name] / float64(count)
+				strat.ScoredCount = count
 			}
 		}
 
@@ -633,6 +835,7 @@ func (s *BoltStore) UpdateStrategyUsageScore(sessionID string, score float64) er
 
 			if usage.SessionID == sessionID {
 				usage.Score = score
+				usage.Scored = true
 				data, err := json.Marshal(usage)
 				if err != nil {
 					return err
@@ -647,7 +850,13 @@ func (s *BoltStore) UpdateStrategyUsageScore(sessionID string, score float64) er
 
 // Using a simple implementation to avoid extra dependencies.
 func NewULID() string {
-	t := time.Now().UTC()
+	return NewULIDAt(time.Now())
+}
+
+// NewULIDAt generates a ULID for the given time, so sessions recorded in the
+// past sort alongside ones recorded at that time.
+func NewULIDAt(t time.Time) string {
+	t = t.UTC()
 	timestamp := uint64(t.UnixMilli())
 
 	// Encode timestamp (10 chars in base32)
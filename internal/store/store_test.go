@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/johncarpenter/trajectory-memory/internal/types"
+	bolt "go.etcd.io/bbolt"
 )
 
 func setupTestStore(t *testing.T) (*BoltStore, func()) {
@@ -246,6 +247,53 @@ func TestAppendStep_NotFound(t *testing.T) {
 	}
 }
 
+func TestUpdateStep(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	session := createTestSession(NewULID())
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	started := types.TrajectoryStep{
+		Timestamp: time.Now(),
+		ToolName:  "Bash",
+		ToolUseID: "toolu_1",
+		Status:    types.StepStatusStarted,
+	}
+	if err := store.AppendStep(session.ID, started); err != nil {
+		t.Fatalf("AppendStep failed: %v", err)
+	}
+
+	completed := started
+	completed.OutputSummary = "PASS"
+	completed.DurationMs = 1200
+	completed.Status = types.StepStatusCompleted
+	if err := store.UpdateStep(session.ID, completed); err != nil {
+		t.Fatalf("UpdateStep failed: %v", err)
+	}
+
+	retrieved, err := store.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if len(retrieved.Steps) != 1 {
+		t.Fatalf("Expected 1 step, got %d", len(retrieved.Steps))
+	}
+	if retrieved.Steps[0].Status != types.StepStatusCompleted || retrieved.Steps[0].DurationMs != 1200 {
+		t.Errorf("Step not updated: %+v", retrieved.Steps[0])
+	}
+
+	completed.ToolUseID = "toolu_unknown"
+	if err := store.UpdateStep(session.ID, completed); err != ErrStepNotFound {
+		t.Errorf("Expected ErrStepNotFound, got %v", err)
+	}
+	if err := store.UpdateStep("nonexistent", started); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
 func TestListSessions(t *testing.T) {
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
@@ -334,7 +382,8 @@ func TestSearchSessions(t *testing.T) {
 		{"math", 1},           // tag search
 		{"endpoint", 1},       // summary search
 		{"nonexistent", 0},
-		{"a", 3},              // all contain 'a' somewhere
+		{"connection pooling", 1},   // all terms required
+		{"fibonacci OR endpoint", 2}, // either term
 	}
 
 	for _, tc := range tests {
@@ -398,6 +447,60 @@ func TestSetOutcome_NotFound(t *testing.T) {
 	}
 }
 
+func TestSetAutoOutcome(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	session := createTestSession(NewULID())
+	session.Status = types.StatusCompleted
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	outcome := &types.AutoOutcome{Score: 0.6, Scorer: "heuristic/v1", ScoredAt: time.Now()}
+	if err := store.SetAutoOutcome(session.ID, outcome); err != nil {
+		t.Fatalf("SetAutoOutcome failed: %v", err)
+	}
+
+	retrieved, err := store.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if retrieved.AutoOutcome == nil || retrieved.AutoOutcome.Score != 0.6 {
+		t.Errorf("expected auto outcome 0.6, got %+v", retrieved.AutoOutcome)
+	}
+	if retrieved.Outcome != nil || retrieved.Status != types.StatusCompleted {
+		t.Errorf("auto outcome should leave outcome and status alone, got %+v, %s", retrieved.Outcome, retrieved.Status)
+	}
+
+	// Auto scores are listed but don't satisfy score filters
+	minScore := 0.5
+	results, err := store.QuerySessions(Query{MinScore: &minScore})
+	if err != nil {
+		t.Fatalf("QuerySessions failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected auto score to be excluded from score filters, got %d results", len(results))
+	}
+	results, err = store.QuerySessions(Query{})
+	if err != nil || len(results) != 1 || results[0].AutoScore == nil {
+		t.Errorf("expected auto score in metadata, got %+v, %v", results, err)
+	}
+
+	if err := store.SetAutoOutcome("nonexistent", outcome); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+
+	// A git outcome takes precedence over the heuristic one
+	if err := store.SetGitOutcome(session.ID, &types.AutoOutcome{Score: 0.2, Scorer: "git/v1"}); err != nil {
+		t.Fatalf("SetGitOutcome failed: %v", err)
+	}
+	results, err = store.QuerySessions(Query{})
+	if err != nil || len(results) != 1 || results[0].AutoScore == nil || *results[0].AutoScore != 0.2 {
+		t.Errorf("expected git score in metadata, got %+v, %v", results, err)
+	}
+}
+
 func TestActiveSession(t *testing.T) {
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
@@ -467,6 +570,10 @@ func TestDeleteSession(t *testing.T) {
 		t.Fatalf("SetActiveSession failed: %v", err)
 	}
 
+	// Compare it with another session
+	store.RecordComparison(&types.Comparison{Tag: "backend", Winner: session.ID, Loser: "other"})
+	store.RecordComparison(&types.Comparison{Tag: "backend", Winner: "other", Loser: "third"})
+
 	// Delete
 	if err := store.DeleteSession(session.ID); err != nil {
 		t.Fatalf("DeleteSession failed: %v", err)
@@ -483,6 +590,11 @@ func TestDeleteSession(t *testing.T) {
 	if err != ErrNoActiveSession {
 		t.Errorf("Expected ErrNoActiveSession after delete, got %v", err)
 	}
+
+	// Verify its comparisons went with it
+	if comparisons, _ := store.ListComparisons(""); len(comparisons) != 1 || comparisons[0].Winner != "other" {
+		t.Errorf("Expected only the unrelated comparison to remain, got %+v", comparisons)
+	}
 }
 
 func TestDeleteSession_NotFound(t *testing.T) {
@@ -609,3 +721,414 @@ func TestNewULID(t *testing.T) {
 		t.Errorf("Later ULID should be >= earlier ULID: %s < %s", id2, id1)
 	}
 }
+
+func TestActiveSessionPerClaudeSession(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	sessionA := createTestSession(NewULID())
+	sessionB := createTestSession(NewULID())
+	for _, s := range []*types.Session{sessionA, sessionB} {
+		if err := store.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+	}
+
+	// Two Claude sessions can record concurrently
+	if err := store.SetActiveSessionFor("claude-a", sessionA.ID); err != nil {
+		t.Fatalf("SetActiveSessionFor(a) failed: %v", err)
+	}
+	if err := store.SetActiveSessionFor("claude-b", sessionB.ID); err != nil {
+		t.Fatalf("SetActiveSessionFor(b) failed: %v", err)
+	}
+
+	active, err := store.GetActiveSessionFor("claude-a")
+	if err != nil {
+		t.Fatalf("GetActiveSessionFor(a) failed: %v", err)
+	}
+	if active.ID != sessionA.ID {
+		t.Errorf("claude-a bound to %s, want %s", active.ID, sessionA.ID)
+	}
+
+	active, err = store.GetActiveSessionFor("claude-b")
+	if err != nil {
+		t.Fatalf("GetActiveSessionFor(b) failed: %v", err)
+	}
+	if active.ID != sessionB.ID {
+		t.Errorf("claude-b bound to %s, want %s", active.ID, sessionB.ID)
+	}
+
+	// Bound recordings don't occupy the default slot
+	if _, err := store.GetActiveSession(); err != ErrNoActiveSession {
+		t.Errorf("Expected ErrNoActiveSession for default slot, got %v", err)
+	}
+
+	// A Claude session can't bind a second recording
+	if err := store.SetActiveSessionFor("claude-a", sessionB.ID); err != ErrSessionAlreadyActive {
+		t.Errorf("Expected ErrSessionAlreadyActive, got %v", err)
+	}
+
+	bindings, err := store.ListActiveSessions()
+	if err != nil {
+		t.Fatalf("ListActiveSessions failed: %v", err)
+	}
+	if len(bindings) != 2 || bindings["claude-a"] != sessionA.ID || bindings["claude-b"] != sessionB.ID {
+		t.Errorf("unexpected bindings: %v", bindings)
+	}
+
+	// Clearing one binding leaves the other
+	if err := store.ClearActiveSessionFor("claude-a"); err != nil {
+		t.Fatalf("ClearActiveSessionFor failed: %v", err)
+	}
+	if _, err := store.GetActiveSessionFor("claude-a"); err != ErrNoActiveSession {
+		t.Errorf("Expected ErrNoActiveSession after clear, got %v", err)
+	}
+	if _, err := store.GetActiveSessionFor("claude-b"); err != nil {
+		t.Errorf("claude-b should still be recording: %v", err)
+	}
+
+	// Deleting a session removes its binding
+	if err := store.DeleteSession(sessionB.ID); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	bindings, _ = store.ListActiveSessions()
+	if len(bindings) != 0 {
+		t.Errorf("expected no bindings after delete, got %v", bindings)
+	}
+}
+
+func TestBindPendingSession(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	sessionA := createTestSession(NewULID())
+	sessionB := createTestSession(NewULID())
+	for _, s := range []*types.Session{sessionA, sessionB} {
+		store.CreateSession(s)
+		if err := store.SetPendingSession("", s.ID); err != nil {
+			t.Fatalf("SetPendingSession failed: %v", err)
+		}
+	}
+
+	// Pending recordings receive nothing until bound
+	if bindings, _ := store.ListActiveSessions(); len(bindings) != 0 {
+		t.Errorf("expected pending recordings to be unbound, got %v", bindings)
+	}
+
+	// A Claude session ID that happens to be "current" doesn't take the default slot
+	if err := store.BindPendingSession("current", sessionA.ID); err != nil {
+		t.Fatalf("BindPendingSession failed: %v", err)
+	}
+	if err := store.BindPendingSession("current", sessionA.ID); err != nil {
+		t.Errorf("binding again should be a no-op, got %v", err)
+	}
+	if _, err := store.GetActiveSession(); err != ErrNoActiveSession {
+		t.Errorf("expected the default slot to stay empty, got %v", err)
+	}
+	if active, err := store.GetActiveSessionFor("current"); err != nil || active.ID != sessionA.ID || active.ClaudeSessionID != "current" {
+		t.Errorf("expected A bound to its Claude session, got %+v (%v)", active, err)
+	}
+
+	if err := store.BindPendingSession("claude-b", NewULID()); err != ErrNoActiveSession {
+		t.Errorf("expected ErrNoActiveSession for a recording that isn't pending, got %v", err)
+	}
+
+	if err := store.ClearRecording(sessionA.ID); err != nil {
+		t.Fatalf("ClearRecording failed: %v", err)
+	}
+	if err := store.ClearRecording(sessionB.ID); err != nil {
+		t.Fatalf("ClearRecording failed: %v", err)
+	}
+	if err := store.BindPendingSession("claude-b", sessionB.ID); err != ErrNoActiveSession {
+		t.Errorf("expected a cleared recording not to bind, got %v", err)
+	}
+	if bindings, _ := store.ListActiveSessions(); len(bindings) != 0 {
+		t.Errorf("expected no bindings, got %v", bindings)
+	}
+}
+
+func TestBindPendingSession_ReplacesStaleRecording(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	stale := createTestSession(NewULID())
+	fresh := createTestSession(NewULID())
+	for _, s := range []*types.Session{stale, fresh} {
+		store.CreateSession(s)
+		if err := store.SetPendingSession("window-1", s.ID); err != nil {
+			t.Fatalf("SetPendingSession failed: %v", err)
+		}
+	}
+	if err := store.BindPendingSession("claude-a", stale.ID); err != nil {
+		t.Fatalf("BindPendingSession failed: %v", err)
+	}
+
+	// The owner key follows the latest recording its client started
+	if recording, err := store.GetRecordingFor("window-1"); err != nil || recording.ID != fresh.ID {
+		t.Errorf("expected the latest recording for the owner, got %+v (%v)", recording, err)
+	}
+	if _, err := store.GetRecordingFor("window-2"); err != ErrNoActiveSession {
+		t.Errorf("expected ErrNoActiveSession for another owner, got %v", err)
+	}
+
+	if err := store.BindPendingSession("claude-a", fresh.ID); err != nil {
+		t.Fatalf("expected the stale recording to be replaced, got %v", err)
+	}
+	if active, err := store.GetActiveSessionFor("claude-a"); err != nil || active.ID != fresh.ID {
+		t.Errorf("expected the new recording bound, got %+v (%v)", active, err)
+	}
+	old, err := store.GetSession(stale.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if old.Status != types.StatusCompleted || old.CompletedAt == nil {
+		t.Errorf("expected the stale recording to be completed, got %s", old.Status)
+	}
+
+	if err := store.ClearRecording(fresh.ID); err != nil {
+		t.Fatalf("ClearRecording failed: %v", err)
+	}
+	if _, err := store.GetRecordingFor("window-1"); err != ErrNoActiveSession {
+		t.Errorf("expected no recording after it was cleared, got %v", err)
+	}
+}
+
+func TestComparisons(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	for _, c := range []*types.Comparison{
+		{Tag: "backend", Winner: "a", Loser: "b"},
+		{Tag: "frontend", Winner: "c", Loser: "d"},
+		{Tag: "backend", Winner: "b", Loser: "c", Tie: true},
+	} {
+		if err := store.RecordComparison(c); err != nil {
+			t.Fatalf("RecordComparison failed: %v", err)
+		}
+		if c.ID == "" || c.CreatedAt.IsZero() {
+			t.Errorf("expected ID and timestamp to be set, got %+v", c)
+		}
+	}
+
+	comparisons, err := store.ListComparisons("backend")
+	if err != nil {
+		t.Fatalf("ListComparisons failed: %v", err)
+	}
+	ties := 0
+	for _, c := range comparisons {
+		if c.Tie {
+			ties++
+		}
+	}
+	if len(comparisons) != 2 || ties != 1 {
+		t.Errorf("expected 2 backend comparisons with 1 tie, got %+v", comparisons)
+	}
+	if all, _ := store.ListComparisons(""); len(all) != 3 {
+		t.Errorf("expected 3 comparisons, got %d", len(all))
+	}
+
+	if err := store.RecordComparison(&types.Comparison{Tag: "backend", Winner: "a", Loser: "a"}); err != ErrInvalidComparison {
+		t.Errorf("expected ErrInvalidComparison, got %v", err)
+	}
+}
+
+func TestStrategyModels(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	if model, err := store.GetStrategyModel("backend"); err != nil || model != nil {
+		t.Fatalf("expected no model yet, got %+v (%v)", model, err)
+	}
+
+	model := &types.StrategyModel{
+		Tag:      "backend",
+		Features: []string{"bias"},
+		Alpha:    1,
+		Arms:     []types.StrategyArm{{Name: "careful", A: [][]float64{{3}}, B: []float64{1.8}, Weights: []float64{0.6}, Pulls: 2}},
+		Samples:  2,
+	}
+	if err := store.SaveStrategyModel(model); err != nil {
+		t.Fatalf("SaveStrategyModel failed: %v", err)
+	}
+
+	got, err := store.GetStrategyModel("backend")
+	if err != nil {
+		t.Fatalf("GetStrategyModel failed: %v", err)
+	}
+	if got == nil || len(got.Arms) != 1 || got.Arms[0].Weights[0] != 0.6 || got.Samples != 2 {
+		t.Errorf("expected the saved model back, got %+v", got)
+	}
+	if other, _ := store.GetStrategyModel("frontend"); other != nil {
+		t.Errorf("expected models to be kept per tag, got %+v", other)
+	}
+}
+
+func TestStrategyVersionStats(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	base := time.Now().Add(-time.Hour)
+	usages := []types.StrategyUsage{
+		{StrategyName: "careful", Version: "v2", SessionID: "s1", Score: 0.9, UsedAt: base.Add(30 * time.Minute)},
+		{StrategyName: "careful", Version: "v1", SessionID: "s2", Score: 0.4, UsedAt: base},
+		{StrategyName: "careful", Version: "v1", SessionID: "s3", Score: 0.6, UsedAt: base.Add(time.Minute)},
+		{StrategyName: "careful", Version: "v2", SessionID: "s4", UsedAt: base.Add(40 * time.Minute)},
+		{StrategyName: "careful", SessionID: "s5", Score: 0.2, UsedAt: base.Add(-time.Hour)},
+		{StrategyName: "fast", Version: "v9", SessionID: "s6", Score: 0.7, UsedAt: base},
+	}
+	for _, u := range usages {
+		u.Tag = "coding"
+		store.RecordStrategyUsage(u)
+	}
+
+	stats, err := store.GetStrategyVersionStats("coding")
+	if err != nil {
+		t.Fatalf("GetStrategyVersionStats failed: %v", err)
+	}
+	if len(stats) != 4 {
+		t.Fatalf("expected 4 versions, got %+v", stats)
+	}
+	// Versions of a strategy are ordered by first use, unversioned usage first here
+	if stats[0].Version != "" || stats[1].Version != "v1" || stats[2].Version != "v2" || stats[3].Name != "fast" {
+		t.Errorf("expected versions in order of first use, got %+v", stats)
+	}
+	if stats[1].SessionCount != 2 || stats[1].AvgScore != 0.5 {
+		t.Errorf("expected v1 to average 0.5 over 2 sessions, got %+v", stats[1])
+	}
+	if stats[2].SessionCount != 2 || stats[2].ScoredCount != 1 || stats[2].AvgScore != 0.9 {
+		t.Errorf("expected v2 to average its one scored session, got %+v", stats[2])
+	}
+
+	// The aggregate stats still combine every version
+	if all, _ := store.GetStrategyStats("coding"); all["careful"].SessionCount != 5 {
+		t.Errorf("expected 5 careful sessions across versions, got %+v", all["careful"])
+	}
+}
+
+func TestStrategyStats_CountsZeroScores(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	// Every session bound to this strategy scored 0
+	for i := 0; i < 3; i++ {
+		session := createTestSession(NewULID())
+		store.CreateSession(session)
+		store.SetSessionStrategy(session.ID, "coding", "broken", "v1")
+		if err := store.SetOutcome(session.ID, types.Outcome{Score: 0}); err != nil {
+			t.Fatalf("SetOutcome failed: %v", err)
+		}
+	}
+	store.RecordStrategyUsage(types.StrategyUsage{Tag: "coding", StrategyName: "broken", Version: "v1", SessionID: "unscored"})
+
+	stats, err := store.GetStrategyStats("coding")
+	if err != nil {
+		t.Fatalf("GetStrategyStats failed: %v", err)
+	}
+	if broken := stats["broken"]; broken.SessionCount != 4 || broken.ScoredCount != 3 || broken.AvgScore != 0 {
+		t.Errorf("expected 3 sessions scored 0, got %+v", broken)
+	}
+	versions, err := store.GetStrategyVersionStats("coding")
+	if err != nil {
+		t.Fatalf("GetStrategyVersionStats failed: %v", err)
+	}
+	if len(versions) != 1 || versions[0].ScoredCount != 3 {
+		t.Errorf("expected 3 scored sessions for v1, got %+v", versions)
+	}
+}
+
+func TestStrategyArchive(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	err := store.ArchiveStrategies([]types.ArchivedStrategy{
+		{Tag: "backend", RecordID: "r1", Strategy: types.Strategy{Name: "fast", AvgScore: 0.4, ScoredCount: 3}},
+		{Tag: "backend", RecordID: "r2", Strategy: types.Strategy{Name: "sloppy"}},
+		{Tag: "frontend", RecordID: "r3", Strategy: types.Strategy{Name: "fast"}},
+	})
+	if err != nil {
+		t.Fatalf("ArchiveStrategies failed: %v", err)
+	}
+
+	archived, err := store.ListArchivedStrategies("backend")
+	if err != nil {
+		t.Fatalf("ListArchivedStrategies failed: %v", err)
+	}
+	if len(archived) != 2 || archived[0].Strategy.AvgScore != 0.4 || archived[0].ArchivedAt.IsZero() {
+		t.Errorf("expected both backend strategies with their stats, got %+v", archived)
+	}
+	if all, _ := store.ListArchivedStrategies(""); len(all) != 3 {
+		t.Errorf("expected 3 archived strategies across tags, got %d", len(all))
+	}
+
+	if err := store.UnarchiveStrategies("r1"); err != nil {
+		t.Fatalf("UnarchiveStrategies failed: %v", err)
+	}
+	if archived, _ := store.ListArchivedStrategies("backend"); len(archived) != 1 || archived[0].Strategy.Name != "sloppy" {
+		t.Errorf("expected only the other record's strategy to remain, got %+v", archived)
+	}
+}
+
+func TestSessionStrategyUsage(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	session := createTestSession(NewULID())
+	session.Strategy = ""
+	store.CreateSession(session)
+
+	// Binding records usage before the session has a score
+	if err := store.SetSessionStrategy(session.ID, "coding", "careful", "v1"); err != nil {
+		t.Fatalf("SetSessionStrategy failed: %v", err)
+	}
+	usages, _ := store.GetStrategyUsage("coding", 0)
+	if len(usages) != 1 || usages[0].StrategyName != "careful" || usages[0].Version != "v1" || usages[0].Score != 0 {
+		t.Fatalf("expected an unscored usage record, got %+v", usages)
+	}
+
+	// Scoring the session carries the score over
+	if err := store.SetOutcome(session.ID, types.Outcome{Score: 0.8}); err != nil {
+		t.Fatalf("SetOutcome failed: %v", err)
+	}
+	stats, _ := store.GetStrategyStats("coding")
+	if stats["careful"] == nil || stats["careful"].ScoredCount != 1 || stats["careful"].AvgScore != 0.8 {
+		t.Errorf("expected the score in the strategy stats, got %+v", stats["careful"])
+	}
+
+	// So does updating the whole session, as trajectory_stop does
+	updated, _ := store.GetSession(session.ID)
+	updated.Outcome.Score = 0.6
+	if err := store.UpdateSession(updated); err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+	if usages, _ := store.GetStrategyUsage("coding", 0); len(usages) != 1 || usages[0].Score != 0.6 {
+		t.Errorf("expected the updated score, got %+v", usages)
+	}
+
+	// Rebinding under another tag moves the usage
+	store.SetSessionStrategy(session.ID, "math", "quick", "v1")
+	if usages, _ := store.GetStrategyUsage("coding", 0); len(usages) != 0 {
+		t.Errorf("expected no usage left under the old tag, got %+v", usages)
+	}
+	if usages, _ := store.GetStrategyUsage("math", 0); len(usages) != 1 || usages[0].Score != 0.6 {
+		t.Errorf("expected the usage under the new tag, got %+v", usages)
+	}
+
+	// A strategy named without a tag can't be recorded automatically
+	legacy := createTestSession(NewULID())
+	store.CreateSession(legacy)
+	missing, err := store.SessionsWithoutStrategyUsage()
+	if err != nil {
+		t.Fatalf("SessionsWithoutStrategyUsage failed: %v", err)
+	}
+	if len(missing) != 1 || missing[0].ID != legacy.ID {
+		t.Errorf("expected only the legacy session, got %+v", missing)
+	}
+
+	// A corrupt usage record is reported rather than overwritten
+	store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStrategyUsage).Put([]byte("math:"+session.ID), []byte("{"))
+	})
+	if err := store.SetOutcome(session.ID, types.Outcome{Score: 0.7}); err == nil {
+		t.Error("expected an error for a corrupt usage record")
+	}
+}
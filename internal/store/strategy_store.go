@@ -0,0 +1,296 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Strategy bucket names
+var (
+	// bucketStrategyModels holds contextual strategy models keyed by tag.
+	bucketStrategyModels = []byte("strategy_models")
+	// bucketStrategyArchive holds retired strategies keyed by
+	// tag:record_id:name.
+	bucketStrategyArchive = []byte("strategy_archive")
+)
+
+// SaveStrategyModel stores the contextual model for a tag, replacing any
+// earlier one. The stored model is for inspection only; contextual selection
+// relearns it from usage every time.
+func (s *BoltStore) SaveStrategyModel(m *types.StrategyModel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("failed to marshal strategy model: %w", err)
+		}
+		return tx.Bucket(bucketStrategyModels).Put([]byte(m.Tag), data)
+	})
+}
+
+// GetStrategyModel retrieves the contextual model for a tag, or nil if none
+// has been learned yet.
+func (s *BoltStore) GetStrategyModel(tag string) (*types.StrategyModel, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var model *types.StrategyModel
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketStrategyModels).Get([]byte(tag))
+		if data == nil {
+			return nil
+		}
+		model = &types.StrategyModel{}
+		return json.Unmarshal(data, model)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// syncStrategyUsage records the strategy a session is bound to along with
+// its score, so usage isn't lost when it was never recorded by hand.
+func syncStrategyUsage(tx *bolt.Tx, session *types.Session) error {
+	if session.Strategy == "" || session.StrategyTag == "" {
+		return nil
+	}
+
+	bucket := tx.Bucket(bucketStrategyUsage)
+	key := []byte(fmt.Sprintf("%s:%s", session.StrategyTag, session.ID))
+
+	usage := types.StrategyUsage{UsedAt: session.StartedAt}
+	if data := bucket.Get(key); data != nil {
+		if err := json.Unmarshal(data, &usage); err != nil {
+			return fmt.Errorf("failed to unmarshal strategy usage: %w", err)
+		}
+	}
+	usage.Tag = session.StrategyTag
+	usage.StrategyName = session.Strategy
+	usage.SessionID = session.ID
+	if session.StrategyVersion != "" {
+		usage.Version = session.StrategyVersion
+	}
+	if session.Outcome != nil {
+		usage.Score = session.Outcome.Score
+		usage.Scored = true
+	}
+
+	data, err := json.Marshal(usage)
+	if err != nil {
+		return fmt.Errorf("failed to marshal strategy usage: %w", err)
+	}
+	return bucket.Put(key, data)
+}
+
+// SessionsWithoutStrategyUsage lists sessions that name a strategy but have
+// no usage record for it, so their scores never reach the strategy stats.
+func (s *BoltStore) SessionsWithoutStrategyUsage() ([]types.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var missing []types.Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		recorded := make(map[string]bool)
+		if err := tx.Bucket(bucketStrategyUsage).ForEach(func(k, v []byte) error {
+			var usage types.StrategyUsage
+			if err := json.Unmarshal(v, &usage); err == nil {
+				recorded[usage.SessionID] = true
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		return tx.Bucket(bucketSessions).ForEach(func(k, v []byte) error {
+			var session types.Session
+			if err := json.Unmarshal(v, &session); err != nil {
+				return nil
+			}
+			if session.Strategy != "" && !recorded[session.ID] {
+				missing = append(missing, session)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// SetSessionStrategy binds a session to a version of a strategy and records
+// its usage, carrying over any score the session already has.
+func (s *BoltStore) SetSessionStrategy(sessionID, tag, strategy, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		session, err := loadSessionHeader(tx, []byte(sessionID))
+		if err != nil {
+			return err
+		}
+
+		// A session uses one strategy, so drop usage recorded under another tag
+		if session.StrategyTag != "" && session.StrategyTag != tag {
+			key := []byte(fmt.Sprintf("%s:%s", session.StrategyTag, session.ID))
+			if err := tx.Bucket(bucketStrategyUsage).Delete(key); err != nil {
+				return err
+			}
+		}
+
+		session.Strategy = strategy
+		session.StrategyTag = tag
+		session.StrategyVersion = version
+		if err := putSession(tx, session); err != nil {
+			return err
+		}
+		return syncStrategyUsage(tx, session)
+	})
+}
+
+// GetStrategyVersionStats calculates statistics for each version of the
+// strategies under a tag, ordered by strategy name and then by when each
+// version was first used. Usage recorded without a version is grouped under
+// an empty version.
+func (s *BoltStore) GetStrategyVersionStats(tag string) ([]types.Strategy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var stats []types.Strategy
+	firstUsed := make(map[string]time.Time)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		index := make(map[string]int)
+		scoreSums := make(map[string]float64)
+
+		c := tx.Bucket(bucketStrategyUsage).Cursor()
+		prefix := []byte(tag + ":")
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var usage types.StrategyUsage
+			if err := json.Unmarshal(v, &usage); err != nil {
+				continue
+			}
+
+			key := usage.StrategyName + ":" + usage.Version
+			i, ok := index[key]
+			if !ok {
+				i = len(stats)
+				index[key] = i
+				stats = append(stats, types.Strategy{Name: usage.StrategyName, Version: usage.Version})
+				firstUsed[key] = usage.UsedAt
+			}
+			if usage.UsedAt.Before(firstUsed[key]) {
+				firstUsed[key] = usage.UsedAt
+			}
+
+			stats[i].SessionCount++
+			if usage.IsScored() {
+				scoreSums[key] += usage.Score
+				stats[i].ScoredCount++
+			}
+		}
+
+		for i := range stats {
+			if stats[i].ScoredCount > 0 {
+				stats[i].AvgScore = scoreSums[stats[i].Name+":"+stats[i].Version] / float64(stats[i].ScoredCount)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Name != stats[j].Name {
+			return stats[i].Name < stats[j].Name
+		}
+		return firstUsed[stats[i].Name+":"+stats[i].Version].Before(firstUsed[stats[j].Name+":"+stats[j].Version])
+	})
+	return stats, nil
+}
+
+// ArchiveStrategies stores strategies retired by a proposal.
+func (s *BoltStore) ArchiveStrategies(archived []types.ArchivedStrategy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketStrategyArchive)
+		for _, a := range archived {
+			if a.ArchivedAt.IsZero() {
+				a.ArchivedAt = time.Now()
+			}
+			data, err := json.Marshal(a)
+			if err != nil {
+				return fmt.Errorf("failed to marshal archived strategy: %w", err)
+			}
+			key := fmt.Sprintf("%s:%s:%s", a.Tag, a.RecordID, a.Strategy.Name)
+			if err := b.Put([]byte(key), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListArchivedStrategies lists retired strategies for a tag, or for every
+// tag when tag is empty.
+func (s *BoltStore) ListArchivedStrategies(tag string) ([]types.ArchivedStrategy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var archived []types.ArchivedStrategy
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStrategyArchive).ForEach(func(k, v []byte) error {
+			var a types.ArchivedStrategy
+			if err := json.Unmarshal(v, &a); err != nil {
+				return nil
+			}
+			if tag == "" || a.Tag == tag {
+				archived = append(archived, a)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return archived, nil
+}
+
+// UnarchiveStrategies removes the strategies a proposal retired, as when it
+// is rolled back.
+func (s *BoltStore) UnarchiveStrategies(recordID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketStrategyArchive)
+
+		var keys [][]byte
+		if err := b.ForEach(func(k, v []byte) error {
+			var a types.ArchivedStrategy
+			if err := json.Unmarshal(v, &a); err == nil && a.RecordID == recordID {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
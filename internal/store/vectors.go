@@ -0,0 +1,218 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/johncarpenter/trajectory-memory/internal/embed"
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// ErrEmbedderMismatch is returned when searching with a different
+	// embedder than the one the database's vectors came from.
+	ErrEmbedderMismatch = errors.New("database was embedded with a different model")
+)
+
+// bucketVectors holds each session's embedding, keyed by session ID.
+var bucketVectors = []byte("vectors")
+
+// keyEmbedder is the meta key naming the model every stored vector came
+// from. Vectors from different models aren't comparable, so a database only
+// ever holds one model's.
+var keyEmbedder = []byte("embedder")
+
+// storedVector is a session embedding along with what produced it, so it
+// can be skipped when the text is unchanged and redone when the model is.
+type storedVector struct {
+	Model    string    `json:"model"`
+	Checksum string    `json:"checksum"`
+	Vector   []float32 `json:"vector"`
+}
+
+// SimilarResult is a session similar to a prompt, with its cosine similarity.
+type SimilarResult struct {
+	types.SessionMetadata
+	Similarity float64 `json:"similarity"`
+}
+
+// SetEmbedder replaces the embedder used for similarity search. When the
+// database was embedded with a different model, every session is
+// re-embedded and the database records the new model, so other processes
+// using the old one stop writing and searching vectors.
+func (s *BoltStore) SetEmbedder(e embed.Embedder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.embedder = e
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return embedSessions(tx, e, embedderName(tx) != e.Name())
+	})
+}
+
+// SimilarSessions returns the sessions whose task is most similar to text,
+// most similar first. q filters the candidates as in QuerySessions; its
+// Limit and Offset apply to the ranked results. Sessions with no
+// similarity at all are left out.
+func (s *BoltStore) SimilarSessions(text string, q Query) ([]SimilarResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	target, err := s.embedder.Embed(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed prompt: %w", err)
+	}
+	model := s.embedder.Name()
+
+	var results []SimilarResult
+	err = s.db.View(func(tx *bolt.Tx) error {
+		if stored := embedderName(tx); stored != model {
+			return fmt.Errorf("%w: %s, searching with %s", ErrEmbedderMismatch, stored, model)
+		}
+
+		candidates := q.candidates(tx)
+		if candidates != nil && len(candidates) == 0 {
+			return nil
+		}
+
+		index := tx.Bucket(bucketIndex)
+		return tx.Bucket(bucketVectors).ForEach(func(k, v []byte) error {
+			id := string(k)
+			if candidates != nil && !candidates[id] {
+				return nil
+			}
+
+			var stored storedVector
+			if err := json.Unmarshal(v, &stored); err != nil || stored.Model != model {
+				return nil // Skip malformed or stale entries
+			}
+			similarity := embed.Cosine(target, stored.Vector)
+			if similarity <= 0 {
+				return nil
+			}
+
+			var meta types.SessionMetadata
+			if err := json.Unmarshal(index.Get(k), &meta); err != nil {
+				return nil
+			}
+			if !q.Since.IsZero() && meta.StartedAt.Before(q.Since) {
+				return nil
+			}
+			if !q.Until.IsZero() && !meta.StartedAt.Before(q.Until) {
+				return nil
+			}
+			results = append(results, SimilarResult{SessionMetadata: meta, Similarity: similarity})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Similarity != results[j].Similarity {
+			return results[i].Similarity > results[j].Similarity
+		}
+		return results[i].StartedAt.After(results[j].StartedAt)
+	})
+	if q.Offset >= len(results) {
+		return nil, nil
+	}
+	results = results[q.Offset:]
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
+
+// embedText is the text a session is embedded from: what the task was and
+// how it went.
+func embedText(session *types.Session) string {
+	parts := []string{session.TaskPrompt, session.Summary}
+	parts = append(parts, session.Tags...)
+	return strings.Join(parts, "\n")
+}
+
+// embedderName returns the model the database's vectors came from.
+func embedderName(tx *bolt.Tx) string {
+	return string(tx.Bucket(bucketMeta).Get(keyEmbedder))
+}
+
+// putVector embeds a session, unless its stored vector already came from
+// the same model and text. Sessions are left unembedded when the database
+// belongs to a different model; the process using it embeds them the next
+// time it sets its embedder.
+func putVector(tx *bolt.Tx, e embed.Embedder, session *types.Session) error {
+	if embedderName(tx) != e.Name() {
+		return nil
+	}
+	text := embedText(session)
+	sum := sha256.Sum256([]byte(text))
+	checksum := hex.EncodeToString(sum[:8])
+
+	b := tx.Bucket(bucketVectors)
+	if data := b.Get([]byte(session.ID)); data != nil {
+		var stored storedVector
+		if err := json.Unmarshal(data, &stored); err == nil && stored.Model == e.Name() && stored.Checksum == checksum {
+			return nil
+		}
+	}
+
+	vec, err := e.Embed(text)
+	if err != nil {
+		return fmt.Errorf("failed to embed session %s: %w", session.ID, err)
+	}
+	data, err := json.Marshal(storedVector{Model: e.Name(), Checksum: checksum, Vector: vec})
+	if err != nil {
+		return fmt.Errorf("failed to marshal vector: %w", err)
+	}
+	if err := b.Put([]byte(session.ID), data); err != nil {
+		return fmt.Errorf("failed to store vector: %w", err)
+	}
+	return nil
+}
+
+// embedSessions embeds every session that needs it. With force set,
+// existing vectors are discarded first and the database switches to e's
+// model; otherwise it must already be using it.
+func embedSessions(tx *bolt.Tx, e embed.Embedder, force bool) error {
+	if force {
+		if tx.Bucket(bucketVectors) != nil {
+			if err := tx.DeleteBucket(bucketVectors); err != nil {
+				return fmt.Errorf("failed to clear bucket %s: %w", bucketVectors, err)
+			}
+		}
+		if err := tx.Bucket(bucketMeta).Put(keyEmbedder, []byte(e.Name())); err != nil {
+			return err
+		}
+	} else if stored := embedderName(tx); stored != e.Name() {
+		return fmt.Errorf("%w: %s, embedding with %s", ErrEmbedderMismatch, stored, e.Name())
+	}
+	if err := createBuckets(tx, bucketVectors); err != nil {
+		return err
+	}
+
+	// Collect first; bbolt doesn't allow modifying a bucket during ForEach
+	var ids [][]byte
+	tx.Bucket(bucketSessions).ForEach(func(k, v []byte) error {
+		ids = append(ids, append([]byte(nil), k...))
+		return nil
+	})
+
+	for _, id := range ids {
+		session, err := loadSessionHeader(tx, id)
+		if err != nil {
+			continue // Skip malformed entries
+		}
+		if err := putVector(tx, e, session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
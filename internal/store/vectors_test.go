@@ -0,0 +1,210 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/embed"
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+func similarIDs(t *testing.T, store *BoltStore, text string, q Query) []string {
+	t.Helper()
+	results, err := store.SimilarSessions(text, q)
+	if err != nil {
+		t.Fatalf("SimilarSessions failed: %v", err)
+	}
+	var ids []string
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestSimilarSessions(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	base := time.Now().Add(-time.Hour)
+	sessions := []*types.Session{
+		{
+			ID:         NewULID(),
+			TaskPrompt: "Add connection pooling to the postgres client",
+			Tags:       []string{"database"},
+			Status:     types.StatusScored,
+			StartedAt:  base,
+			Outcome:    &types.Outcome{Score: 0.9},
+		},
+		{
+			ID:         NewULID(),
+			TaskPrompt: "Pool postgres connections for the worker",
+			Tags:       []string{"database", "worker"},
+			Status:     types.StatusScored,
+			StartedAt:  base.Add(time.Minute),
+			Outcome:    &types.Outcome{Score: 0.4},
+		},
+		{
+			ID:         NewULID(),
+			TaskPrompt: "Update README badges",
+			Tags:       []string{"docs"},
+			Status:     types.StatusCompleted,
+			StartedAt:  base.Add(2 * time.Minute),
+		},
+	}
+	for _, s := range sessions {
+		if err := store.CreateSession(s); err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+	}
+
+	ids := similarIDs(t, store, "add connection pooling to postgres", Query{})
+	if len(ids) < 2 || ids[0] != sessions[0].ID || ids[1] != sessions[1].ID {
+		t.Errorf("expected closest prompts first, got %v", ids)
+	}
+
+	minScore := 0.5
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"limit", Query{Limit: 1}, []string{sessions[0].ID}},
+		{"offset", Query{Limit: 1, Offset: 1}, []string{sessions[1].ID}},
+		{"tag", Query{Tags: []string{"worker"}}, []string{sessions[1].ID}},
+		{"min score", Query{MinScore: &minScore}, []string{sessions[0].ID}},
+		{"since", Query{Since: base.Add(30 * time.Second), Tags: []string{"database"}}, []string{sessions[1].ID}},
+		{"no candidates", Query{Tags: []string{"missing"}}, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := similarIDs(t, store, "postgres connection pool", tc.q)
+			if !equalIDs(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	results, err := store.SimilarSessions("postgres connection pool", Query{Limit: 1})
+	if err != nil {
+		t.Fatalf("SimilarSessions failed: %v", err)
+	}
+	if results[0].Similarity <= 0 || results[0].Similarity > 1.0001 {
+		t.Errorf("expected similarity in (0, 1], got %f", results[0].Similarity)
+	}
+}
+
+func TestSimilarSessions_VectorsStayCurrent(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	session := createTestSession(NewULID())
+	session.TaskPrompt = "Write a markdown parser"
+	session.Summary = ""
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// Changing the prompt re-embeds the session
+	session.TaskPrompt = "Tune the garbage collector"
+	if err := store.UpdateSession(session); err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+	if ids := similarIDs(t, store, "garbage collector tuning", Query{}); !equalIDs(ids, []string{session.ID}) {
+		t.Errorf("expected updated prompt to match, got %v", ids)
+	}
+
+	// Switching embedders re-embeds with the new model
+	if err := store.SetEmbedder(embed.NewHashing(64)); err != nil {
+		t.Fatalf("SetEmbedder failed: %v", err)
+	}
+	if ids := similarIDs(t, store, "garbage collector tuning", Query{}); !equalIDs(ids, []string{session.ID}) {
+		t.Errorf("expected session to be re-embedded, got %v", ids)
+	}
+
+	// Deleting the session removes its vector
+	if err := store.DeleteSession(session.ID); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	store.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketVectors).Get([]byte(session.ID)) != nil {
+			t.Error("expected vector to be deleted with the session")
+		}
+		return nil
+	})
+}
+
+func TestSimilarSessions_RefusesMixedModels(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	first := createTestSession(NewULID())
+	first.TaskPrompt = "Tune the garbage collector"
+	store.CreateSession(first)
+	if err := store.SetEmbedder(embed.NewHashing(64)); err != nil {
+		t.Fatalf("SetEmbedder failed: %v", err)
+	}
+	store.Close()
+
+	// A process still on the default model can neither search nor add vectors
+	store, err = NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.SimilarSessions("garbage collector", Query{}); !errors.Is(err, ErrEmbedderMismatch) {
+		t.Errorf("expected ErrEmbedderMismatch, got %v", err)
+	}
+	second := createTestSession(NewULID())
+	second.TaskPrompt = "Tune the garbage collector pauses"
+	if err := store.CreateSession(second); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	store.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketVectors).Get([]byte(second.ID)) != nil {
+			t.Error("expected no vector from the default model")
+		}
+		return nil
+	})
+
+	// Setting the database's model again embeds what was left out
+	if err := store.SetEmbedder(embed.NewHashing(64)); err != nil {
+		t.Fatalf("SetEmbedder failed: %v", err)
+	}
+	if ids := similarIDs(t, store, "garbage collector", Query{}); len(ids) != 2 {
+		t.Errorf("expected both sessions to be embedded, got %v", ids)
+	}
+}
+
+func TestSimilarSessions_EmbedsExistingDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	session := createTestSession(NewULID())
+	if err := store.CreateSession(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	// Simulate a database from before sessions were embedded
+	store.db.Update(func(tx *bolt.Tx) error {
+		tx.DeleteBucket(bucketVectors)
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, []byte("5"))
+	})
+	store.Close()
+
+	store, err = NewBoltStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	if ids := similarIDs(t, store, session.TaskPrompt, Query{}); !equalIDs(ids, []string{session.ID}) {
+		t.Errorf("expected migrated session to be embedded, got %v", ids)
+	}
+}
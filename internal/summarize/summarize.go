@@ -17,6 +17,9 @@ type FormatOptions struct {
 	MaxSteps int
 	// Verbose includes additional details like duration and loaded context.
 	Verbose bool
+	// HideScores leaves out outcomes and auto scores, so a reviewer
+	// comparing sessions isn't anchored by them.
+	HideScores bool
 }
 
 // DefaultOptions returns the default formatting options.
@@ -45,6 +48,9 @@ func FormatTrajectoryWithOptions(s *types.Session, opts FormatOptions) string {
 	if opts.Verbose {
 		sb.WriteString(fmt.Sprintf("**Working Directory:** %s\n", s.WorkingDir))
 		sb.WriteString(fmt.Sprintf("**Duration:** %s\n", formatDuration(s.StartedAt, s.CompletedAt)))
+		if toolTime := s.ToolTimeMs(); toolTime > 0 {
+			sb.WriteString(fmt.Sprintf("**Tool Time:** %s\n", formatMs(toolTime)))
+		}
 
 		if len(s.Tags) > 0 {
 			sb.WriteString(fmt.Sprintf("**Tags:** %s\n", strings.Join(s.Tags, ", ")))
@@ -77,12 +83,13 @@ func FormatTrajectoryWithOptions(s *types.Session, opts FormatOptions) string {
 
 	// Format steps
 	for i, step := range steps {
+		line := fmt.Sprintf("[%s] %s%s", step.ToolName, truncateStepSummary(step.InputSummary), formatStepStatus(step, opts.Verbose))
 		if totalSteps <= 50 {
 			// Numbered for shorter sessions
-			sb.WriteString(fmt.Sprintf("%d. [%s] %s\n", i+1, step.ToolName, truncateStepSummary(step.InputSummary)))
+			sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, line))
 		} else {
 			// Bulleted for longer sessions (order less meaningful)
-			sb.WriteString(fmt.Sprintf("- [%s] %s\n", step.ToolName, truncateStepSummary(step.InputSummary)))
+			sb.WriteString(fmt.Sprintf("- %s\n", line))
 		}
 
 		// Include output for Write operations
@@ -94,7 +101,7 @@ func FormatTrajectoryWithOptions(s *types.Session, opts FormatOptions) string {
 	}
 
 	// Outcome if scored
-	if s.Outcome != nil {
+	if s.Outcome != nil && !opts.HideScores {
 		sb.WriteString(fmt.Sprintf("\n**Outcome:** Score %.2f", s.Outcome.Score))
 		if s.Outcome.Notes != "" {
 			sb.WriteString(fmt.Sprintf(" - %s", s.Outcome.Notes))
@@ -102,6 +109,17 @@ func FormatTrajectoryWithOptions(s *types.Session, opts FormatOptions) string {
 		sb.WriteString("\n")
 	}
 
+	// Provisional scores from trajectory signals and git history
+	for _, auto := range []*types.AutoOutcome{s.AutoOutcome, s.GitOutcome} {
+		if auto == nil || opts.HideScores {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n**Auto score:** %.2f (%s)\n", auto.Score, auto.Scorer))
+		for _, signal := range auto.Signals {
+			sb.WriteString(fmt.Sprintf("- %s: %.2f - %s\n", signal.Rule, signal.Score, signal.Detail))
+		}
+	}
+
 	// Summarization prompt
 	if opts.IncludeSummarizationPrompt {
 		sb.WriteString("\n---\n\n")
@@ -112,6 +130,19 @@ func FormatTrajectoryWithOptions(s *types.Session, opts FormatOptions) string {
 	return sb.String()
 }
 
+// FormatComparison formats two sessions one after the other for a reviewer
+// to judge which went better. Scores are hidden.
+func FormatComparison(a, b *types.Session) string {
+	opts := FormatOptions{Verbose: true, HideScores: true}
+
+	var sb strings.Builder
+	sb.WriteString("# Session A\n\n")
+	sb.WriteString(FormatTrajectoryWithOptions(a, opts))
+	sb.WriteString("\n---\n\n# Session B\n\n")
+	sb.WriteString(FormatTrajectoryWithOptions(b, opts))
+	return sb.String()
+}
+
 // FormatCompactTrajectory returns a minimal trajectory representation.
 func FormatCompactTrajectory(s *types.Session) string {
 	var sb strings.Builder
@@ -146,7 +177,7 @@ func FormatCompactTrajectory(s *types.Session) string {
 }
 
 // selectRelevantSteps selects the most relevant steps for a long session.
-// Returns first 5, last 5, and all Write/Edit steps in between.
+// Returns first 5, last 5, and all failed and Write/Edit steps in between.
 func selectRelevantSteps(steps []types.TrajectoryStep) []types.TrajectoryStep {
 	if len(steps) <= 10 {
 		return steps
@@ -159,9 +190,13 @@ func selectRelevantSteps(steps []types.TrajectoryStep) []types.TrajectoryStep {
 		result = append(result, steps[i])
 	}
 
-	// Important steps in the middle (Write, Edit, Bash with significant output)
+	// Important steps in the middle (failures, Write, Edit, Bash with significant output)
 	for i := 5; i < len(steps)-5; i++ {
 		step := steps[i]
+		if step.IsFailed() {
+			result = append(result, step)
+			continue
+		}
 		switch step.ToolName {
 		case "Write", "Edit", "NotebookEdit":
 			result = append(result, step)
@@ -228,6 +263,39 @@ func formatDuration(start time.Time, end *time.Time) string {
 	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
 }
 
+// formatMs formats a millisecond duration compactly.
+func formatMs(ms int64) string {
+	if ms < 1000 {
+		return fmt.Sprintf("%dms", ms)
+	}
+	d := time.Duration(ms) * time.Millisecond
+	if d < time.Minute {
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	if d < time.Hour {
+		return fmt.Sprintf("%dm %ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// formatStepStatus returns a suffix noting a step's duration (verbose only),
+// that it failed, or that it never finished.
+func formatStepStatus(step types.TrajectoryStep, verbose bool) string {
+	if step.IsIncomplete() {
+		return " (incomplete)"
+	}
+	if step.IsFailed() {
+		if step.ExitCode != 0 {
+			return fmt.Sprintf(" (failed, exit %d)", step.ExitCode)
+		}
+		return " (failed)"
+	}
+	if verbose && step.DurationMs > 0 {
+		return fmt.Sprintf(" (%s)", formatMs(step.DurationMs))
+	}
+	return ""
+}
+
 // truncate truncates a string to maxLen with ellipsis.
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -56,6 +56,49 @@ func TestFormatTrajectoryForSummarization(t *testing.T) {
 	}
 }
 
+func TestFormatTrajectoryStepTiming(t *testing.T) {
+	session := createTestSession()
+	session.Steps = append(session.Steps, types.TrajectoryStep{
+		ToolName:     "Bash",
+		InputSummary: "rm -rf build",
+		Status:       types.StepStatusIncomplete,
+	})
+
+	result := FormatTrajectoryWithOptions(session, DefaultOptions())
+
+	if !strings.Contains(result, "**Tool Time:** 680ms") {
+		t.Error("expected total tool time in header")
+	}
+	if !strings.Contains(result, "[Bash] go test ./... (500ms)") {
+		t.Error("expected per-step duration")
+	}
+	if !strings.Contains(result, "[Bash] rm -rf build (incomplete)") {
+		t.Error("expected incomplete step to be marked")
+	}
+
+	result = FormatTrajectoryWithOptions(session, FormatOptions{Verbose: false})
+	if strings.Contains(result, "(500ms)") {
+		t.Error("non-verbose output should not include step durations")
+	}
+}
+
+func TestFormatTrajectoryFailedSteps(t *testing.T) {
+	session := createTestSession()
+	session.Steps = append(session.Steps,
+		types.TrajectoryStep{ToolName: "Bash", InputSummary: "go vet ./...", Status: types.StepStatusFailed, ExitCode: 1},
+		types.TrajectoryStep{ToolName: "Read", InputSummary: "missing.go", Status: types.StepStatusFailed},
+	)
+
+	result := FormatTrajectoryForSummarization(session)
+
+	if !strings.Contains(result, "[Bash] go vet ./... (failed, exit 1)") {
+		t.Error("expected failed step with exit code")
+	}
+	if !strings.Contains(result, "[Read] missing.go (failed)") {
+		t.Error("expected failed step without exit code")
+	}
+}
+
 func TestFormatTrajectoryWithOptions_NoPrompt(t *testing.T) {
 	session := createTestSession()
 	opts := FormatOptions{
@@ -155,6 +198,27 @@ func TestFormatTrajectoryWithOutcome(t *testing.T) {
 	}
 }
 
+func TestFormatComparison(t *testing.T) {
+	a := createTestSession()
+	a.Outcome = &types.Outcome{Score: 0.85, Notes: "Good implementation"}
+	b := createTestSession()
+	b.ID = "01HTEST999999999999999"
+	b.AutoOutcome = &types.AutoOutcome{Score: 0.4, Scorer: "heuristic/v1"}
+
+	output := FormatComparison(a, b)
+
+	for _, want := range []string{"# Session A", "# Session B", a.ID, b.ID} {
+		if !strings.Contains(output, want) {
+			t.Errorf("should contain %q", want)
+		}
+	}
+	for _, unwanted := range []string{"0.85", "Good implementation", "Auto score", "trajectory_summarize"} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("should not contain %q", unwanted)
+		}
+	}
+}
+
 func TestFormatCompactTrajectory(t *testing.T) {
 	session := createTestSession()
 	session.Summary = "Implemented recursive fibonacci with memoization"
@@ -0,0 +1,328 @@
+// Package transcript imports Claude Code session transcripts as trajectories.
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/johncarpenter/trajectory-memory/internal/ingestion"
+	"github.com/johncarpenter/trajectory-memory/internal/redact"
+	"github.com/johncarpenter/trajectory-memory/internal/store"
+	"github.com/johncarpenter/trajectory-memory/internal/types"
+)
+
+// ImportedTag is added to every session created from a transcript.
+const ImportedTag = "imported"
+
+// IsImported reports whether tags mark a session as imported from a
+// transcript.
+func IsImported(tags []string) bool {
+	for _, tag := range tags {
+		if tag == ImportedTag {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// ErrNoTask is returned when a transcript has no user prompt to use as the task.
+	ErrNoTask = errors.New("transcript has no user prompt")
+)
+
+// entry is a single line of a Claude Code transcript.
+type entry struct {
+	Type        string          `json:"type"`
+	SessionID   string          `json:"sessionId"`
+	Timestamp   time.Time       `json:"timestamp"`
+	Cwd         string          `json:"cwd"`
+	IsMeta      bool            `json:"isMeta"`
+	IsSidechain bool            `json:"isSidechain"`
+	Message     json.RawMessage `json:"message"`
+	// ToolUseResult is the structured result of the tool call the entry's
+	// tool_result reports, as hooks receive it
+	ToolUseResult json.RawMessage `json:"toolUseResult"`
+}
+
+// message is the model-facing content of a user or assistant entry.
+type message struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// block is a content block within a message.
+type block struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+// ProjectsDir returns the directory Claude Code writes transcripts to.
+// CLAUDE_CONFIG_DIR overrides the default of ~/.claude.
+func ProjectsDir() (string, error) {
+	if dir := os.Getenv("CLAUDE_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "projects"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".claude", "projects"), nil
+}
+
+var slugPattern = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+// Slug returns the directory name Claude Code uses for a project path.
+func Slug(projectDir string) string {
+	return slugPattern.ReplaceAllString(projectDir, "-")
+}
+
+// ProjectDir returns the transcript directory for a project.
+func ProjectDir(projectsDir, projectDir string) string {
+	if abs, err := filepath.Abs(projectDir); err == nil {
+		projectDir = abs
+	}
+	return filepath.Join(projectsDir, Slug(projectDir))
+}
+
+// Files returns the transcript files for a project, sorted by name.
+func Files(projectsDir, projectDir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(ProjectDir(projectsDir, projectDir), "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ParseFile reads a transcript file into a session. Text is redacted with
+// redactor, or kept as is if it is nil.
+func ParseFile(path string, redactor *redact.Redactor) (*types.Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open transcript: %w", err)
+	}
+	defer f.Close()
+
+	session, err := Parse(f, redactor)
+	if err != nil {
+		return nil, err
+	}
+	if session.ClaudeSessionID == "" {
+		session.ClaudeSessionID = strings.TrimSuffix(filepath.Base(path), ".jsonl")
+	}
+	if session.StartedAt.IsZero() {
+		// No entry carried a timestamp, so date the session by the file
+		info, err := f.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat transcript: %w", err)
+		}
+		session.StartedAt = info.ModTime()
+		session.ID = store.NewULIDAt(session.StartedAt)
+	}
+	return session, nil
+}
+
+// Parse reads a transcript into a completed session. The first user prompt
+// becomes the TaskPrompt and each tool call becomes a step, except calls to
+// trajectory-memory's own tools; tool calls with no result are recorded as
+// incomplete. Text is redacted with redactor, if not nil, before step
+// summaries are truncated. A transcript without timestamps gives a session
+// with no ID or start time; ParseFile dates it by the file.
+func Parse(r io.Reader, redactor *redact.Redactor) (*types.Session, error) {
+	session := &types.Session{
+		LoadedContext: []string{},
+		Steps:         []types.TrajectoryStep{},
+		Tags:          []string{ImportedTag},
+		Status:        types.StatusCompleted,
+	}
+
+	pending := make(map[string]int) // tool use ID -> step index
+	var first, last time.Time
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 32*1024*1024)
+	for scanner.Scan() {
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue // Skip lines we don't understand
+		}
+		if e.Type != "user" && e.Type != "assistant" {
+			continue
+		}
+		// Subagent activity is not part of the main trajectory
+		if e.IsSidechain {
+			continue
+		}
+
+		if session.ClaudeSessionID == "" {
+			session.ClaudeSessionID = e.SessionID
+		}
+		if session.WorkingDir == "" {
+			session.WorkingDir = e.Cwd
+		}
+		if !e.Timestamp.IsZero() {
+			if first.IsZero() {
+				first = e.Timestamp
+			}
+			last = e.Timestamp
+		}
+
+		var msg message
+		if err := json.Unmarshal(e.Message, &msg); err != nil {
+			continue
+		}
+		text, blocks := parseContent(msg.Content)
+
+		if e.Type == "user" && session.TaskPrompt == "" && !e.IsMeta && isPrompt(text) {
+			session.TaskPrompt, _ = redactor.Redact(strings.TrimSpace(text))
+		}
+
+		for _, b := range blocks {
+			switch b.Type {
+			case "tool_use":
+				// Recording calls are not part of the task, as when recorded live
+				if ingestion.IsOwnTool(b.Name) {
+					continue
+				}
+				input, redactions := redactor.Redact(summarizeInput(b.Input))
+				session.Steps = append(session.Steps, types.TrajectoryStep{
+					Timestamp:      e.Timestamp,
+					ToolName:       b.Name,
+					ToolUseID:      b.ID,
+					InputSummary:   types.TruncateString(input, types.MaxInputSummaryLen),
+					Status:         types.StepStatusStarted,
+					RedactionCount: redactions,
+				})
+				pending[b.ID] = len(session.Steps) - 1
+
+				if b.Name == "Read" && strings.HasSuffix(strings.ToLower(input), ".md") {
+					session.LoadedContext = appendUnique(session.LoadedContext, input)
+				}
+
+			case "tool_result":
+				idx, ok := pending[b.ToolUseID]
+				if !ok {
+					continue
+				}
+				delete(pending, b.ToolUseID)
+
+				step := &session.Steps[idx]
+				output, _ := parseContent(b.Content)
+				result := e.ToolUseResult
+				if len(result) == 0 {
+					result, _ = json.Marshal(output)
+				}
+				failed, exitCode := ingestion.DetectFailure(step.ToolName, b.IsError, result)
+
+				output, redactions := redactor.Redact(output)
+				step.OutputSummary = types.TruncateString(output, types.MaxOutputSummaryLen)
+				step.RedactionCount += redactions
+				if !step.Timestamp.IsZero() && e.Timestamp.After(step.Timestamp) {
+					step.DurationMs = e.Timestamp.Sub(step.Timestamp).Milliseconds()
+				}
+				step.Status = types.StepStatusCompleted
+				if failed {
+					step.Status = types.StepStatusFailed
+					step.ExitCode = exitCode
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+
+	if session.TaskPrompt == "" {
+		return nil, ErrNoTask
+	}
+
+	session.MarkIncompleteSteps()
+	if !first.IsZero() {
+		session.ID = store.NewULIDAt(first)
+		session.StartedAt = first
+		session.CompletedAt = &last
+	}
+
+	return session, nil
+}
+
+// parseContent returns the text of message content, which is either a plain
+// string or a list of blocks, along with the blocks themselves.
+func parseContent(raw json.RawMessage) (string, []block) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+
+	var blocks []block
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return "", nil
+	}
+
+	var texts []string
+	for _, b := range blocks {
+		if b.Type == "text" && b.Text != "" {
+			texts = append(texts, b.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), blocks
+}
+
+// isPrompt reports whether user text is a real prompt rather than
+// slash-command plumbing or an interruption notice.
+func isPrompt(text string) bool {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return false
+	}
+	if strings.HasPrefix(text, "<") {
+		// <command-name>, <local-command-stdout>, <system-reminder>, ...
+		return false
+	}
+	return !strings.HasPrefix(text, "[Request interrupted")
+}
+
+// summarizeInput extracts the most descriptive field of a tool input.
+func summarizeInput(input json.RawMessage) string {
+	if len(input) == 0 {
+		return ""
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(input, &obj); err == nil {
+		for _, key := range []string{"file_path", "command", "pattern", "url", "query", "notebook_path"} {
+			if v, ok := obj[key].(string); ok {
+				return v
+			}
+		}
+	}
+	return string(input)
+}
+
+func appendUnique(list []string, item string) []string {
+	for _, existing := range list {
+		if existing == item {
+			return list
+		}
+	}
+	return append(list, item)
+}
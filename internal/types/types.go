@@ -7,19 +7,24 @@ import (
 
 // Session represents a single trajectory recording session.
 type Session struct {
-	ID            string           `json:"id"`              // ULID
-	TaskPrompt    string           `json:"task_prompt"`     // what the user asked
-	WorkingDir    string           `json:"working_dir"`     // pwd at session start
-	ClaudeMDHash  string           `json:"claude_md_hash"`  // hash of active CLAUDE.md
-	LoadedContext []string         `json:"loaded_context"`  // .md files read during session
-	Steps         []TrajectoryStep `json:"steps"`           // ordered tool invocations
-	Summary       string           `json:"summary"`         // post-hoc model summarization
-	Outcome       *Outcome         `json:"outcome"`         // score + notes (nil if unscored)
-	Tags          []string         `json:"tags"`            // user or auto-assigned tags
-	Strategy      string           `json:"strategy"`        // strategy profile used (for bandit, future)
-	StartedAt     time.Time        `json:"started_at"`
-	CompletedAt   *time.Time       `json:"completed_at"`
-	Status        SessionStatus    `json:"status"` // "recording", "completed", "scored"
+	ID              string           `json:"id"`                          // ULID
+	TaskPrompt      string           `json:"task_prompt"`                 // what the user asked
+	WorkingDir      string           `json:"working_dir"`                 // pwd at session start
+	ClaudeMDHash    string           `json:"claude_md_hash"`              // hash of active CLAUDE.md
+	LoadedContext   []string         `json:"loaded_context"`              // .md files read during session
+	Steps           []TrajectoryStep `json:"steps"`                       // ordered tool invocations
+	Summary         string           `json:"summary"`                     // post-hoc model summarization
+	Outcome         *Outcome         `json:"outcome"`                     // score + notes (nil if unscored)
+	AutoOutcome     *AutoOutcome     `json:"auto_outcome,omitempty"`      // provisional heuristic score, kept apart from Outcome
+	GitOutcome      *AutoOutcome     `json:"git_outcome,omitempty"`       // provisional score from what happened to the changes in git
+	Tags            []string         `json:"tags"`                        // user or auto-assigned tags
+	Strategy        string           `json:"strategy"`                    // strategy selected for the session
+	StrategyTag     string           `json:"strategy_tag,omitempty"`      // tag whose strategies Strategy came from
+	StrategyVersion string           `json:"strategy_version,omitempty"`  // hash of Strategy's approach prompt when it was selected
+	ClaudeSessionID string           `json:"claude_session_id,omitempty"` // Claude Code session that produced it
+	StartedAt       time.Time        `json:"started_at"`
+	CompletedAt     *time.Time       `json:"completed_at"`
+	Status          SessionStatus    `json:"status"` // "recording", "completed", "scored"
 }
 
 // SessionStatus represents the state of a session.
@@ -33,18 +38,99 @@ const (
 
 // TrajectoryStep represents a single tool invocation within a session.
 type TrajectoryStep struct {
-	Timestamp     time.Time `json:"timestamp"`
-	ToolName      string    `json:"tool_name"`      // Read, Write, Bash, TodoWrite, etc.
-	InputSummary  string    `json:"input_summary"`  // truncated to 500 chars
-	OutputSummary string    `json:"output_summary"` // truncated to 500 chars
-	DurationMs    int64     `json:"duration_ms"`    // if measurable
+	Timestamp      time.Time  `json:"timestamp"`
+	ToolName       string     `json:"tool_name"`                 // Read, Write, Bash, TodoWrite, etc.
+	ToolUseID      string     `json:"tool_use_id,omitempty"`     // pairs PreToolUse and PostToolUse events
+	InputSummary   string     `json:"input_summary"`             // truncated to 500 chars
+	OutputSummary  string     `json:"output_summary"`            // truncated to 500 chars
+	DurationMs     int64      `json:"duration_ms"`               // if measurable
+	Status         StepStatus `json:"status,omitempty"`          // empty for steps recorded before pairing
+	ExitCode       int        `json:"exit_code,omitempty"`       // non-zero exit status of failed commands
+	RedactionCount int        `json:"redaction_count,omitempty"` // secrets/PII replaced before storage
+}
+
+// StepStatus represents the lifecycle state of a step.
+type StepStatus string
+
+const (
+	// StepStatusStarted means PreToolUse was seen but PostToolUse has not arrived yet.
+	StepStatusStarted StepStatus = "started"
+	// StepStatusCompleted means the tool ran to completion.
+	StepStatusCompleted StepStatus = "completed"
+	// StepStatusIncomplete means the tool started but never finished (denied or interrupted).
+	StepStatusIncomplete StepStatus = "incomplete"
+	// StepStatusFailed means the tool finished but reported an error or non-zero exit code.
+	StepStatusFailed StepStatus = "failed"
+)
+
+// IsIncomplete reports whether the step started but never finished.
+func (s TrajectoryStep) IsIncomplete() bool {
+	return s.Status == StepStatusStarted || s.Status == StepStatusIncomplete
+}
+
+// IsFailed reports whether the step finished with an error.
+func (s TrajectoryStep) IsFailed() bool {
+	return s.Status == StepStatusFailed
 }
 
 // Outcome represents the scoring result for a session.
 type Outcome struct {
-	Score    float64   `json:"score"`     // 0.0 to 1.0
-	Notes    string    `json:"notes"`     // free-text user notes
-	ScoredAt time.Time `json:"scored_at"`
+	Score      float64            `json:"score"`                // 0.0 to 1.0, weighted composite when dimensions are scored
+	Dimensions map[string]float64 `json:"dimensions,omitempty"` // per-dimension scores from the tag's rubric
+	Notes      string             `json:"notes"`                // free-text user notes
+	ScoredAt   time.Time          `json:"scored_at"`
+}
+
+// DimensionScore returns the score for a rubric dimension, or the composite
+// score for an empty name. ok is false if the dimension wasn't scored.
+func (o *Outcome) DimensionScore(name string) (score float64, ok bool) {
+	if name == "" {
+		return o.Score, true
+	}
+	score, ok = o.Dimensions[name]
+	return score, ok
+}
+
+// AutoOutcome is a provisional score derived from a session's trajectory
+// rather than given by a person.
+type AutoOutcome struct {
+	Score    float64      `json:"score"`  // 0.0 to 1.0, weighted average of the signals
+	Scorer   string       `json:"scorer"` // name and version of the scorer that produced it
+	Signals  []AutoSignal `json:"signals"`
+	ScoredAt time.Time    `json:"scored_at"`
+}
+
+// AutoSignal is the result of one auto-scoring rule.
+type AutoSignal struct {
+	Rule   string  `json:"rule"`
+	Score  float64 `json:"score"`
+	Weight float64 `json:"weight"`
+	Detail string  `json:"detail,omitempty"`
+}
+
+// Comparison records which of two sessions with the same tag a reviewer
+// judged better. Ties record both sessions without a preference.
+type Comparison struct {
+	ID        string    `json:"id"`
+	Tag       string    `json:"tag"`
+	Winner    string    `json:"winner"` // session ID judged better (either session on a tie)
+	Loser     string    `json:"loser"`
+	Tie       bool      `json:"tie,omitempty"`
+	Notes     string    `json:"notes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Rubric defines the named dimensions sessions with a tag are scored on.
+type Rubric struct {
+	Tag        string            `json:"tag"`
+	Dimensions []RubricDimension `json:"dimensions"`
+}
+
+// RubricDimension is one weighted aspect of an outcome, such as correctness.
+type RubricDimension struct {
+	Name        string  `json:"name"`
+	Weight      float64 `json:"weight"`
+	Description string  `json:"description,omitempty"`
 }
 
 // SessionMetadata is a lightweight representation for listing sessions.
@@ -52,11 +138,14 @@ type SessionMetadata struct {
 	ID         string    `json:"id"`
 	TaskPrompt string    `json:"task_prompt"` // first 200 chars
 	Score      *float64  `json:"score"`
+	AutoScore  *float64  `json:"auto_score,omitempty"`
 	StepCount  int       `json:"step_count"`
 	Tags       []string  `json:"tags"`
 	Status     string    `json:"status"`
 	StartedAt  time.Time `json:"started_at"`
 	Summary    string    `json:"summary,omitempty"`
+
+	ClaudeSessionID string `json:"claude_session_id,omitempty"`
 }
 
 // MaxInputSummaryLen is the maximum length for input summaries.
@@ -86,21 +175,85 @@ func (s *Session) ToMetadata() SessionMetadata {
 		Status:     string(s.Status),
 		StartedAt:  s.StartedAt,
 		Summary:    s.Summary,
+
+		ClaudeSessionID: s.ClaudeSessionID,
 	}
 	if s.Outcome != nil {
 		meta.Score = &s.Outcome.Score
 	}
+	if auto := s.ProvisionalOutcome(); auto != nil {
+		meta.AutoScore = &auto.Score
+	}
 	return meta
 }
 
+// ProvisionalOutcome returns the best score not given by a person: the git
+// outcome if the session has one, since it reflects what happened to the
+// work, otherwise the heuristic auto outcome. It returns nil if neither is set.
+func (s *Session) ProvisionalOutcome() *AutoOutcome {
+	if s.GitOutcome != nil {
+		return s.GitOutcome
+	}
+	return s.AutoOutcome
+}
+
+// MarkIncompleteSteps marks steps still awaiting PostToolUse as incomplete.
+// Returns the number of steps marked.
+func (s *Session) MarkIncompleteSteps() int {
+	marked := 0
+	for i := range s.Steps {
+		if s.Steps[i].Status == StepStatusStarted {
+			s.Steps[i].Status = StepStatusIncomplete
+			marked++
+		}
+	}
+	return marked
+}
+
+// HasTag reports whether the session has the given tag.
+func (s *Session) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ToolTimeMs returns the total measured duration of the session's steps.
+func (s *Session) ToolTimeMs() int64 {
+	var total int64
+	for _, step := range s.Steps {
+		total += step.DurationMs
+	}
+	return total
+}
+
 // OptimizationTarget represents a section in a markdown file that can be optimized.
 type OptimizationTarget struct {
-	FilePath    string `json:"file_path"`
-	Tag         string `json:"tag"`
-	MinSessions int    `json:"min_sessions"`
-	StartLine   int    `json:"start_line"` // line number of start marker (1-indexed)
-	EndLine     int    `json:"end_line"`   // line number of end marker (1-indexed)
-	Content     string `json:"content"`    // current content between markers
+	FilePath          string           `json:"file_path"`
+	Tag               string           `json:"tag"`
+	MinSessions       int              `json:"min_sessions"`
+	Dimension         string           `json:"dimension,omitempty"`           // rubric dimension to split cohorts on (empty = composite score)
+	IncludeAutoScored bool             `json:"include_auto_scored,omitempty"` // count sessions by their auto score when unscored
+	UsePreferences    bool             `json:"use_preferences,omitempty"`     // score compared sessions from pairwise preferences
+	Settings          AnalyzerSettings `json:"settings"`                      // analyzer settings from marker attributes
+	StartLine         int              `json:"start_line"`                    // line number of start marker (1-indexed)
+	EndLine           int              `json:"end_line"`                      // line number of end marker (1-indexed)
+	Content           string           `json:"content"`                       // current content between markers
+}
+
+// AnalyzerSettings tunes trajectory analysis for a tag. Zero values keep
+// the analyzer's defaults.
+type AnalyzerSettings struct {
+	Tag                 string   `json:"tag,omitempty"`
+	HighThreshold       float64  `json:"high_threshold,omitempty"`         // lowest score in the high cohort (default 0.75)
+	LowThreshold        float64  `json:"low_threshold,omitempty"`          // scores below this form the low cohort (default 0.5)
+	CohortQuantile      float64  `json:"cohort_quantile,omitempty"`        // split on the top and bottom share of sessions instead, e.g. 0.25
+	RecencyHalfLifeDays float64  `json:"recency_half_life_days,omitempty"` // halve a session's weight for every this many days of age
+	Significance        float64  `json:"significance,omitempty"`           // p-value patterns must fall below (default 0.05)
+	Detectors           []string `json:"detectors,omitempty"`              // pattern detectors to run (default all)
+	DisabledDetectors   []string `json:"disabled_detectors,omitempty"`     // pattern detectors to skip
 }
 
 // OptimizationRecord tracks an optimization proposal and its lifecycle.
@@ -114,7 +267,9 @@ type OptimizationRecord struct {
 	PreviousContent string     `json:"previous_content"`
 	NewContent      string     `json:"new_content"`
 	Diff            string     `json:"diff"`
-	Status          string     `json:"status"` // "proposed", "accepted", "rejected", "rolled_back"
+	Status          string     `json:"status"`            // "proposed", "accepted", "rejected", "rolled_back"
+	Kind            string     `json:"kind,omitempty"`    // section the record rewrites, see OptKindStrategies
+	Retired         []string   `json:"retired,omitempty"` // strategies a strategies proposal removes
 	CreatedAt       time.Time  `json:"created_at"`
 	AppliedAt       *time.Time `json:"applied_at"`
 	RolledBackAt    *time.Time `json:"rolled_back_at"`
@@ -128,18 +283,70 @@ const (
 	OptStatusRolledBack = "rolled_back"
 )
 
+// OptKindStrategies marks records that rewrite a trajectory-strategies
+// section. Records without a kind rewrite a trajectory-optimize section.
+const OptKindStrategies = "strategies"
+
 // TrajectoryAnalysis contains the analysis results for a set of trajectories.
 type TrajectoryAnalysis struct {
-	Tag                  string           `json:"tag"`
-	TotalSessions        int              `json:"total_sessions"`
-	HighScoreSessions    int              `json:"high_score_sessions"`
-	LowScoreSessions     int              `json:"low_score_sessions"`
-	AvgScoreHigh         float64          `json:"avg_score_high"`
-	AvgScoreLow          float64          `json:"avg_score_low"`
-	HighScorePatterns    []string         `json:"high_score_patterns"`
-	LowScoreAntiPatterns []string         `json:"low_score_anti_patterns"`
-	RecommendedPractices []string         `json:"recommended_practices"`
-	CuratedExamples      []CuratedExample `json:"curated_examples"`
+	Tag                  string            `json:"tag"`
+	Dimension            string            `json:"dimension,omitempty"` // rubric dimension cohorts were split on (empty = composite)
+	TotalSessions        int               `json:"total_sessions"`
+	AutoScoredSessions   int               `json:"auto_scored_sessions,omitempty"` // sessions counted by their auto score
+	PreferenceSessions   int               `json:"preference_sessions,omitempty"`  // sessions scored from pairwise preferences
+	HighScoreSessions    int               `json:"high_score_sessions"`
+	LowScoreSessions     int               `json:"low_score_sessions"`
+	AvgScoreHigh         float64           `json:"avg_score_high"`
+	AvgScoreLow          float64           `json:"avg_score_low"`
+	HighScorePatterns    []string          `json:"high_score_patterns"`
+	LowScoreAntiPatterns []string          `json:"low_score_anti_patterns"`
+	Evidence             []PatternEvidence `json:"evidence,omitempty"`  // how each trajectory feature tracks score
+	Sequences            *SequenceAnalysis `json:"sequences,omitempty"` // tool orderings mined from the trajectories
+	RecommendedPractices []string          `json:"recommended_practices"`
+	CuratedExamples      []CuratedExample  `json:"curated_examples"`
+	Settings             AnalyzerSettings  `json:"settings"` // settings the analysis ran with, defaults filled in
+}
+
+// PatternEvidence measures how strongly one trajectory feature, such as
+// reading before writing, tracks score across all analyzed sessions.
+type PatternEvidence struct {
+	Feature     string  `json:"feature"`
+	Description string  `json:"description"`
+	Sessions    int     `json:"sessions"` // sessions the feature could be measured in
+	Effect      float64 `json:"effect"`   // Spearman rank correlation with score, -1 to 1
+	CILow       float64 `json:"ci_low"`   // 95% bootstrap confidence interval for the effect
+	CIHigh      float64 `json:"ci_high"`
+	PValue      float64 `json:"p_value"`     // one-sided, in the direction the feature is expected to help
+	Significant bool    `json:"significant"` // adjusted p-value below the analyzer's significance level
+
+	// AdjustedPValue is PValue adjusted with Benjamini-Hochberg across every
+	// feature measured.
+	AdjustedPValue float64 `json:"adjusted_p_value"`
+}
+
+// SequenceAnalysis describes the order in which sessions used their tools.
+// Steps are reduced to tokens such as "Read" or "Bash(test)".
+type SequenceAnalysis struct {
+	FrequentSequences []SequenceStat `json:"frequent_sequences"` // most common runs of steps across all sessions
+	Discriminative    []SequenceStat `json:"discriminative"`     // runs that separate high from low cohorts
+
+	// Transition probabilities between consecutive steps in each cohort,
+	// indexed by the current token and then the next one.
+	HighTransitions map[string]map[string]float64 `json:"high_transitions,omitempty"`
+	LowTransitions  map[string]map[string]float64 `json:"low_transitions,omitempty"`
+}
+
+// SequenceStat is how often a run of consecutive step tokens occurs.
+type SequenceStat struct {
+	Tokens      []string `json:"tokens"`
+	Support     float64  `json:"support"`           // share of all sessions containing the run
+	HighSupport float64  `json:"high_support"`      // share of high-scoring sessions containing it
+	LowSupport  float64  `json:"low_support"`       // share of low-scoring sessions containing it
+	PValue      float64  `json:"p_value,omitempty"` // one-sided Fisher exact test between cohorts
+
+	// AdjustedPValue is PValue adjusted with Benjamini-Hochberg across every
+	// run tested; it is what the significance level applies to.
+	AdjustedPValue float64 `json:"adjusted_p_value,omitempty"`
 }
 
 // CuratedExample represents a selected trajectory for use as a few-shot example.
@@ -148,8 +355,9 @@ type CuratedExample struct {
 	TaskPrompt  string  `json:"task_prompt"`
 	Summary     string  `json:"summary"`
 	Score       float64 `json:"score"`
-	Notes       string  `json:"notes"`       // user notes from outcome
-	WhySelected string  `json:"why_selected"` // rationale for selection
+	Notes       string  `json:"notes"`              // user notes from outcome
+	WhySelected string  `json:"why_selected"`       // rationale for selection
+	Negative    bool    `json:"negative,omitempty"` // drawn from the low cohort as an example to avoid
 }
 
 // ExamplesTarget represents a section for curated examples in markdown.
@@ -165,11 +373,12 @@ type ExamplesTarget struct {
 
 // StrategiesTarget represents a section containing strategy definitions in markdown.
 type StrategiesTarget struct {
-	FilePath  string `json:"file_path"`
-	Tag       string `json:"tag"`
-	StartLine int    `json:"start_line"`
-	EndLine   int    `json:"end_line"`
-	Content   string `json:"content"`
+	FilePath    string                `json:"file_path"`
+	Tag         string                `json:"tag"`
+	DefaultMode StrategySelectionMode `json:"default_mode,omitempty"` // mode used when select doesn't name one
+	StartLine   int                   `json:"start_line"`
+	EndLine     int                   `json:"end_line"`
+	Content     string                `json:"content"`
 }
 
 // Strategy represents a named approach for a task type.
@@ -179,6 +388,8 @@ type Strategy struct {
 	ApproachPrompt string  `json:"approach_prompt"`
 	AvgScore       float64 `json:"avg_score,omitempty"`
 	SessionCount   int     `json:"session_count,omitempty"`
+	ScoredCount    int     `json:"scored_count,omitempty"` // sessions with a score, which AvgScore averages
+	Version        string  `json:"version,omitempty"`      // hash of ApproachPrompt
 }
 
 // StrategyUsage records which strategy was used for a session.
@@ -187,9 +398,47 @@ type StrategyUsage struct {
 	StrategyName string    `json:"strategy_name"`
 	SessionID    string    `json:"session_id"`
 	Score        float64   `json:"score,omitempty"`
+	Scored       bool      `json:"scored,omitempty"`  // Score is set, which tells a score of 0 from none
+	Version      string    `json:"version,omitempty"` // hash of the approach prompt used, empty if unknown
 	UsedAt       time.Time `json:"used_at"`
 }
 
+// IsScored reports whether the usage has a score. Records from before
+// Scored existed only had their score when it was above 0.
+func (u StrategyUsage) IsScored() bool {
+	return u.Scored || u.Score > 0
+}
+
+// StrategyModel is a contextual bandit model that predicts each strategy's
+// score for a tag from features of the task.
+type StrategyModel struct {
+	Tag       string        `json:"tag"`
+	Features  []string      `json:"features"` // names of the feature vector's entries
+	Alpha     float64       `json:"alpha"`    // width of the confidence bound
+	Arms      []StrategyArm `json:"arms"`
+	Samples   int           `json:"samples"` // scored sessions the model learned from
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// StrategyArm is one strategy's ridge regression over a StrategyModel's
+// features.
+type StrategyArm struct {
+	Name    string      `json:"name"`
+	A       [][]float64 `json:"a"`       // identity plus the sum of feature outer products
+	B       []float64   `json:"b"`       // score-weighted sum of feature vectors
+	Weights []float64   `json:"weights"` // learned weight per feature, A⁻¹b
+	Pulls   int         `json:"pulls"`   // scored sessions that used the strategy
+}
+
+// ArchivedStrategy is a strategy retired by an applied strategies proposal,
+// kept with the stats it had when it was retired.
+type ArchivedStrategy struct {
+	Tag        string    `json:"tag"`
+	Strategy   Strategy  `json:"strategy"`
+	RecordID   string    `json:"record_id"` // proposal that retired it
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
 // StrategiesAnalysis contains analysis of strategy performance.
 type StrategiesAnalysis struct {
 	Tag               string     `json:"tag"`
@@ -210,4 +459,32 @@ const (
 	StrategyModeRecommend StrategySelectionMode = "recommend"
 	// StrategyModeRotate means cycle through strategies for exploration.
 	StrategyModeRotate StrategySelectionMode = "rotate"
+	// StrategyModeThompson means sample each strategy's score posterior and
+	// pick the best draw.
+	StrategyModeThompson StrategySelectionMode = "thompson"
+	// StrategyModeUCB1 means pick the highest upper confidence bound.
+	StrategyModeUCB1 StrategySelectionMode = "ucb1"
+	// StrategyModeEpsilonGreedy means pick the best performer, exploring at
+	// random some of the time.
+	StrategyModeEpsilonGreedy StrategySelectionMode = "epsilon_greedy"
+	// StrategyModeContextual means pick the strategy a learned model
+	// expects to score best on the current task.
+	StrategyModeContextual StrategySelectionMode = "contextual"
 )
+
+// StrategySelectionModes lists every selection mode.
+var StrategySelectionModes = []StrategySelectionMode{
+	StrategyModeExplicit, StrategyModeRecommend, StrategyModeRotate,
+	StrategyModeThompson, StrategyModeUCB1, StrategyModeEpsilonGreedy,
+	StrategyModeContextual,
+}
+
+// Valid reports whether m is a known selection mode.
+func (m StrategySelectionMode) Valid() bool {
+	for _, mode := range StrategySelectionModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}